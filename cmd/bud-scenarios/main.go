@@ -0,0 +1,438 @@
+// bud-scenarios runs the conversation scenarios in tests/scenarios against an
+// in-process ExecutiveV2 and reports pass/fail per conversation step.
+//
+// Each scenario gets a fresh state directory, its own MCP server with the
+// standard bud tools, and a fresh executive. Messages are fed through the
+// same inbox → percept → focus queue path the daemon uses, and everything
+// sent via talk_to_user is captured and checked against the step's expect
+// list.
+//
+// Usage:
+//
+//	bud-scenarios [flags] [scenario.yaml | dir ...]
+//
+// With no arguments, tests/scenarios is used. By default a scripted replay
+// provider answers each message (see fake_replies in the scenario file);
+// pass --config to run against the executive model configured in bud.yaml,
+// optionally with --record to capture the session as a replay transcript,
+// or --fixture to replay a previously recorded transcript.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/activity"
+	"github.com/vthunder/bud2/internal/config"
+	"github.com/vthunder/bud2/internal/engram"
+	"github.com/vthunder/bud2/internal/executive"
+	"github.com/vthunder/bud2/internal/executive/provider"
+	"github.com/vthunder/bud2/internal/focus"
+	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/mcp/tools"
+	"github.com/vthunder/bud2/internal/memory"
+	"github.com/vthunder/bud2/internal/state"
+)
+
+type options struct {
+	configPath string
+	engramURL  string
+	engramKey  string
+	stateRoot  string
+	timeout    time.Duration
+	keepState  bool
+	fixture    string
+	record     string
+}
+
+func main() {
+	var opts options
+	format := flag.String("format", "json", "report format: json or junit")
+	outPath := flag.String("out", "", "write report to this file (default stdout)")
+	verbose := flag.Bool("v", false, "show daemon logs")
+	flag.StringVar(&opts.configPath, "config", "", "bud.yaml to use a real executive provider (default: scripted fake)")
+	flag.StringVar(&opts.engramURL, "engram-url", os.Getenv("ENGRAM_URL"), "Engram URL for memory (default $ENGRAM_URL; empty disables memory)")
+	flag.StringVar(&opts.stateRoot, "state", "", "directory for per-scenario state (default: temp dir)")
+	flag.DurationVar(&opts.timeout, "timeout", 5*time.Minute, "per-message processing timeout")
+	flag.BoolVar(&opts.keepState, "keep-state", false, "do not delete per-scenario state directories")
+	flag.StringVar(&opts.fixture, "fixture", "", "replay this recorded transcript instead of scripted fake replies")
+	flag.StringVar(&opts.record, "record", "", "record provider turns to this transcript file (requires --config)")
+	flag.Parse()
+	opts.engramKey = os.Getenv("ENGRAM_API_KEY")
+
+	if *format != "json" && *format != "junit" {
+		fmt.Fprintf(os.Stderr, "error: --format must be json or junit\n")
+		os.Exit(2)
+	}
+	if opts.record != "" && opts.configPath == "" {
+		fmt.Fprintf(os.Stderr, "error: --record requires --config\n")
+		os.Exit(2)
+	}
+	if opts.fixture != "" && opts.configPath != "" {
+		fmt.Fprintf(os.Stderr, "error: --fixture and --config are mutually exclusive\n")
+		os.Exit(2)
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{filepath.Join("tests", "scenarios")}
+	}
+	scenarios, err := LoadScenarios(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// One provider for the whole run, so a recording (or a replayed fixture)
+	// covers every scenario in order.
+	prov, err := resolveProvider(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	var results []ScenarioResult
+	allPassed := true
+	for _, sc := range scenarios {
+		r := runScenario(context.Background(), sc, prov, opts)
+		results = append(results, r)
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+			allPassed = false
+		}
+		fmt.Fprintf(os.Stderr, "%s  %s (%.1fs)\n", status, sc.Name, r.DurationSec)
+		for _, s := range r.Steps {
+			if s.Error != "" {
+				fmt.Fprintf(os.Stderr, "      %s: %s\n", s.Name, s.Error)
+			}
+			for _, f := range s.Failures {
+				fmt.Fprintf(os.Stderr, "      %s: %s\n", s.Name, f)
+			}
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		out = f
+	}
+	if *format == "junit" {
+		err = writeJUnit(out, results)
+	} else {
+		err = writeJSON(out, results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing report: %v\n", err)
+		os.Exit(2)
+	}
+	if !allPassed {
+		os.Exit(1)
+	}
+}
+
+// harness is the per-scenario wiring: state dir, MCP server, executive and
+// the capture buffer for talk_to_user output.
+type harness struct {
+	channelID string
+	exec      *executive.ExecutiveV2
+	engram    *engram.Client
+	fake      *provider.ReplayProvider
+	closeFn   func()
+
+	mu      sync.Mutex
+	replies []string
+}
+
+func newHarness(sc *Scenario, prov resolvedProvider, opts options) (*harness, error) {
+	stateDir, err := os.MkdirTemp(opts.stateRoot, "bud-scenario-"+sc.Name+"-")
+	if err != nil {
+		return nil, err
+	}
+	os.MkdirAll(filepath.Join(stateDir, "system", "queues"), 0755)
+
+	h := &harness{channelID: fmt.Sprintf("scenario-%s-%d", sc.Name, time.Now().Unix())}
+	if opts.engramURL != "" {
+		h.engram = engram.NewClient(opts.engramURL, opts.engramKey)
+	}
+
+	if fake, ok := prov.Provider.(*provider.ReplayProvider); ok && opts.fixture == "" {
+		h.fake = fake
+	}
+
+	server := mcp.NewServer()
+	deps := &tools.Dependencies{
+		EngramClient:   h.engram,
+		ActivityLog:    activity.New(stateDir),
+		StateInspector: state.NewInspector(stateDir),
+		StatePath:      stateDir,
+		SystemPath:     filepath.Join(stateDir, "system"),
+		QueuesPath:     filepath.Join(stateDir, "system", "queues"),
+		DefaultChannel: h.channelID,
+		SendMessage: func(channelID, message string) error {
+			h.mu.Lock()
+			h.replies = append(h.replies, message)
+			h.mu.Unlock()
+			h.ingest("Bud", "bud", message)
+			return nil
+		},
+		AddThought: func(content string) error {
+			h.ingest("Bud", "bud", content)
+			return nil
+		},
+	}
+	tools.RegisterAll(server, deps)
+
+	ln, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go server.Serve(ln)
+	token := mcp.NewToken()
+	server.RegisterSession(token, "executive", "/", []string{"*"})
+	mcpURL := fmt.Sprintf("http://%s/mcp/%s", ln.Addr().String(), token)
+
+	h.exec = executive.NewExecutiveV2(h.engram, stateDir, executive.ExecutiveV2Config{
+		Provider:         prov.Provider,
+		AgentProvider:    prov.Provider,
+		ProviderName:     prov.Type,
+		Model:            prov.Model,
+		MCPServerURL:     mcpURL,
+		BotAuthor:        "Bud",
+		DefaultChannelID: h.channelID,
+	})
+	deps.OnMCPToolCall = func(toolName string) {
+		h.exec.GetMCPToolCallback()(toolName)
+	}
+	deps.SendSignal = func(signalType, content string, extra map[string]any) error {
+		if signalType == "done" {
+			h.exec.SignalDone()
+		}
+		return nil
+	}
+
+	h.closeFn = func() {
+		ln.Close()
+		if !opts.keepState {
+			os.RemoveAll(stateDir)
+		}
+	}
+	return h, nil
+}
+
+// ingest stores an episode in Engram so later steps can recall it.
+func (h *harness) ingest(author, authorID, content string) {
+	if h.engram == nil || content == "" {
+		return
+	}
+	if _, err := h.engram.IngestEpisode(engram.IngestEpisodeRequest{
+		Content:        content,
+		Source:         "discord",
+		Author:         author,
+		AuthorID:       authorID,
+		Channel:        h.channelID,
+		TimestampEvent: time.Now(),
+	}); err != nil {
+		log.Printf("[scenarios] Failed to ingest episode: %v", err)
+	}
+}
+
+// send feeds one user message through the inbox path and processes it.
+func (h *harness) send(ctx context.Context, content string) error {
+	msg := &memory.InboxMessage{
+		ID:        fmt.Sprintf("scenario-%d", time.Now().UnixNano()),
+		Type:      "message",
+		Content:   content,
+		ChannelID: h.channelID,
+		AuthorID:  "scenario-user",
+		Author:    "user",
+		Timestamp: time.Now(),
+		Status:    "pending",
+	}
+	h.ingest(msg.Author, msg.AuthorID, msg.Content)
+
+	percept := msg.ToPercept()
+	item := &focus.PendingItem{
+		ID:        percept.ID,
+		Type:      percept.Type,
+		Priority:  focus.P1UserInput,
+		Source:    percept.Source,
+		Content:   content,
+		ChannelID: h.channelID,
+		AuthorID:  msg.Author,
+		Timestamp: percept.Timestamp,
+		Data:      percept.Data,
+	}
+	if err := h.exec.AddPending(item); err != nil {
+		return fmt.Errorf("queue add: %w", err)
+	}
+	processed, err := h.exec.ProcessNextP1(ctx)
+	if err != nil {
+		return err
+	}
+	if !processed {
+		return fmt.Errorf("message was not processed")
+	}
+	return nil
+}
+
+func (h *harness) takeReplies() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := h.replies
+	h.replies = nil
+	return r
+}
+
+func runScenario(ctx context.Context, sc *Scenario, prov resolvedProvider, opts options) ScenarioResult {
+	start := time.Now()
+	result := ScenarioResult{Scenario: sc.Name, File: sc.path, Passed: true}
+
+	h, err := newHarness(sc, prov, opts)
+	if err != nil {
+		result.Passed = false
+		result.Steps = []StepResult{{Name: "setup", Error: err.Error()}}
+		return result
+	}
+	defer h.closeFn()
+
+	for _, conv := range sc.Conversations {
+		stepStart := time.Now()
+		step := StepResult{Name: conv.Name, Passed: true}
+
+		if conv.NewSession {
+			h.exec.ResetSession()
+		}
+		for i, m := range conv.Messages {
+			if h.fake != nil {
+				reply := "(fake) " + m
+				if i < len(conv.FakeReplies) {
+					reply = conv.FakeReplies[i]
+				}
+				h.fake.Append(scriptedTurn(reply))
+			}
+			msgCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+			err := h.send(msgCtx, m)
+			cancel()
+			if err != nil {
+				step.Error = fmt.Sprintf("message %d: %v", i+1, err)
+				break
+			}
+		}
+
+		step.Replies = h.takeReplies()
+		output := ""
+		for _, r := range step.Replies {
+			output += r + "\n"
+		}
+		for _, e := range conv.Expect {
+			if msg := e.Check(output); msg != "" {
+				step.Failures = append(step.Failures, msg)
+			}
+		}
+		step.Passed = step.Error == "" && len(step.Failures) == 0
+		step.DurationSec = time.Since(stepStart).Seconds()
+		if !step.Passed {
+			result.Passed = false
+		}
+		result.Steps = append(result.Steps, step)
+	}
+
+	result.DurationSec = time.Since(start).Seconds()
+	return result
+}
+
+// scriptedTurn is the fake provider's answer to one message: the reply via
+// the real talk_to_user tool, then signal_done, so the runner exercises the
+// same tool routing a live model would.
+func scriptedTurn(reply string) provider.TranscriptTurn {
+	return provider.TranscriptTurn{
+		Events: []provider.TranscriptEvent{
+			{Tool: "talk_to_user", Input: map[string]any{"message": reply}},
+			{Tool: "signal_done", Input: map[string]any{"summary": "scripted reply"}},
+		},
+	}
+}
+
+// resolvedProvider is the executive provider plus the type and model names
+// passed through to ExecutiveV2Config.
+type resolvedProvider struct {
+	Provider provider.Provider
+	Type     string
+	Model    string
+}
+
+// resolveProvider returns a replay provider when no config is given (empty
+// for scripted replies, or loaded from --fixture), or the executive provider
+// configured in bud.yaml, wrapped in a recorder when --record is set.
+func resolveProvider(opts options) (resolvedProvider, error) {
+	if opts.configPath == "" {
+		var t *provider.Transcript
+		if opts.fixture != "" {
+			var err error
+			if t, err = provider.LoadTranscript(opts.fixture); err != nil {
+				return resolvedProvider{}, err
+			}
+		}
+		return resolvedProvider{Provider: provider.NewReplayProvider(t), Type: "replay"}, nil
+	}
+	prov, providerType, model, err := configuredProvider(opts.configPath)
+	if err != nil {
+		return resolvedProvider{}, err
+	}
+	if opts.record != "" {
+		// The executive only routes through Provider for non-claude-code
+		// names, so the recorder gets its own name to stay in the path.
+		prov = provider.NewRecordingProvider(prov, opts.record)
+		providerType = "recording"
+	}
+	return resolvedProvider{Provider: prov, Type: providerType, Model: model}, nil
+}
+
+func configuredProvider(configPath string) (provider.Provider, string, string, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, "", "", err
+	}
+	name, model, err := cfg.ResolveModel("executive")
+	if err != nil {
+		return nil, "", "", err
+	}
+	apiKey, _ := cfg.APIKey(name)
+	pc := cfg.Providers[name]
+	switch pc.Type {
+	case "claude-code":
+		return provider.NewClaudeCodeProvider(model), pc.Type, model, nil
+	case "opencode-serve":
+		p := provider.NewOpenCodeServeProvider("", apiKey, model, pc.BaseURL)
+		if cw := cfg.ContextWindow(name, model); cw > 0 {
+			p.WithContextWindow(cw)
+		}
+		return p, pc.Type, model, nil
+	case "openai-compatible":
+		p := provider.NewOpenAICompatibleProvider(apiKey, model, pc.BaseURL)
+		if cw := cfg.ContextWindow(name, model); cw > 0 {
+			p.WithContextWindow(cw)
+		}
+		if mo := cfg.MaxOutputTokens(name, model); mo > 0 {
+			p.WithMaxOutputTokens(mo)
+		}
+		return p, pc.Type, model, nil
+	}
+	return nil, "", "", fmt.Errorf("unsupported provider type %q", pc.Type)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ScenarioResult is the outcome of running one scenario file.
+type ScenarioResult struct {
+	Scenario    string       `json:"scenario"`
+	File        string       `json:"file"`
+	Passed      bool         `json:"passed"`
+	DurationSec float64      `json:"duration_sec"`
+	Steps       []StepResult `json:"steps"`
+}
+
+// StepResult is the outcome of one conversation within a scenario.
+type StepResult struct {
+	Name        string   `json:"name"`
+	Passed      bool     `json:"passed"`
+	DurationSec float64  `json:"duration_sec"`
+	Replies     []string `json:"replies"`
+	Failures    []string `json:"failures,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func writeJSON(w io.Writer, results []ScenarioResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func writeJUnit(w io.Writer, results []ScenarioResult) error {
+	doc := junitSuites{}
+	for _, r := range results {
+		suite := junitSuite{Name: r.Scenario, Time: fmt.Sprintf("%.3f", r.DurationSec)}
+		for _, s := range r.Steps {
+			tc := junitCase{
+				Name:      s.Name,
+				ClassName: "scenarios." + r.Scenario,
+				Time:      fmt.Sprintf("%.3f", s.DurationSec),
+				SystemOut: strings.Join(s.Replies, "\n---\n"),
+			}
+			if !s.Passed {
+				msgs := s.Failures
+				if s.Error != "" {
+					msgs = append([]string{s.Error}, msgs...)
+				}
+				tc.Failure = &junitFailure{Message: msgs[0], Body: strings.Join(msgs, "\n")}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+		}
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
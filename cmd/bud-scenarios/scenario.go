@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is one tests/scenarios/*.yaml file: a named sequence of
+// conversations, each optionally starting a fresh executive session.
+type Scenario struct {
+	Name          string         `yaml:"name"`
+	Description   string         `yaml:"description"`
+	Conversations []Conversation `yaml:"conversations"`
+
+	path string
+}
+
+// Conversation is one step of a scenario. Expectations are checked against
+// everything Bud sent via talk_to_user while the step's messages were processed.
+type Conversation struct {
+	Name       string        `yaml:"name"`
+	NewSession bool          `yaml:"new_session"`
+	Messages   []string      `yaml:"messages"`
+	Expect     []Expectation `yaml:"expect"`
+
+	// FakeReplies scripts the fake provider's talk_to_user reply for each
+	// message, in order. Ignored by real providers.
+	FakeReplies []string `yaml:"fake_replies,omitempty"`
+}
+
+// Expectation is a single assertion. Exactly one field should be set.
+// Matching is case-insensitive.
+type Expectation struct {
+	Contains    string   `yaml:"contains,omitempty"`
+	NotContains string   `yaml:"not_contains,omitempty"`
+	ContainsAny []string `yaml:"contains_any,omitempty"`
+}
+
+// LoadScenario parses a scenario file. The scenario name defaults to the
+// file's base name.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario %s: %w", path, err)
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if len(sc.Conversations) == 0 {
+		return nil, fmt.Errorf("scenario %s: no conversations", path)
+	}
+	for i, c := range sc.Conversations {
+		for j, e := range c.Expect {
+			if e.count() != 1 {
+				return nil, fmt.Errorf("scenario %s: conversation %d expect %d: exactly one of contains, not_contains, contains_any required", path, i, j)
+			}
+		}
+	}
+	sc.path = path
+	return &sc, nil
+}
+
+// LoadScenarios expands each argument (file or directory) into scenarios.
+// Directories contribute every *.yaml file they contain, sorted by name.
+func LoadScenarios(args []string) ([]*Scenario, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(arg, "*.yaml"))
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	var scenarios []*Scenario
+	for _, f := range files {
+		sc, err := LoadScenario(f)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios, nil
+}
+
+func (e Expectation) count() int {
+	n := 0
+	if e.Contains != "" {
+		n++
+	}
+	if e.NotContains != "" {
+		n++
+	}
+	if len(e.ContainsAny) > 0 {
+		n++
+	}
+	return n
+}
+
+// Check returns "" when the expectation holds for output, or a failure message.
+func (e Expectation) Check(output string) string {
+	lower := strings.ToLower(output)
+	switch {
+	case e.Contains != "":
+		if !strings.Contains(lower, strings.ToLower(e.Contains)) {
+			return fmt.Sprintf("expected output to contain %q", e.Contains)
+		}
+	case e.NotContains != "":
+		if strings.Contains(lower, strings.ToLower(e.NotContains)) {
+			return fmt.Sprintf("expected output not to contain %q", e.NotContains)
+		}
+	case len(e.ContainsAny) > 0:
+		for _, s := range e.ContainsAny {
+			if strings.Contains(lower, strings.ToLower(s)) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("expected output to contain one of %q", e.ContainsAny)
+	}
+	return ""
+}
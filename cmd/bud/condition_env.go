@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/activity"
+	"github.com/vthunder/bud2/internal/budget"
+	"github.com/vthunder/bud2/internal/focus"
+	"github.com/vthunder/bud2/internal/integrations/calendar"
+	"github.com/vthunder/bud2/internal/senses"
+)
+
+const (
+	// conditionEnvTTL bounds how stale the cached variables may be. Condition
+	// triggers tick as often as once a second, and reading the last user input
+	// scans the whole activity log.
+	conditionEnvTTL = 15 * time.Second
+	// calendarEnvTTL is how often busy periods are refetched from Google.
+	calendarEnvTTL = 5 * time.Minute
+	// calendarEnvWindow is how far ahead busy periods are fetched.
+	calendarEnvWindow = 2 * time.Hour
+)
+
+// conditionEnv implements plugins.SystemEnv, exposing daemon state to plugin
+// condition triggers. The focus queue and Discord sense are created after the
+// dispatcher, so they are attached later with SetQueue and SetDiscord.
+type conditionEnv struct {
+	activity *activity.Log
+	tracker  *budget.SessionTracker
+	calendar *calendar.Client
+
+	mu       sync.Mutex
+	queue    *focus.Queue
+	discord  *senses.DiscordSense
+	vars     map[string]any
+	computed time.Time
+	busy     []calendar.BusyPeriod
+	busyAt   time.Time
+}
+
+func newConditionEnv(activityLog *activity.Log, tracker *budget.SessionTracker, cal *calendar.Client) *conditionEnv {
+	return &conditionEnv{activity: activityLog, tracker: tracker, calendar: cal}
+}
+
+// SetQueue attaches the executive's focus queue.
+func (c *conditionEnv) SetQueue(q *focus.Queue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue = q
+}
+
+// SetDiscord attaches the Discord sense.
+func (c *conditionEnv) SetDiscord(d *senses.DiscordSense) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.discord = d
+}
+
+// Vars returns the cached variables, recomputing them once conditionEnvTTL
+// has passed. The returned map must not be modified.
+func (c *conditionEnv) Vars() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.vars != nil && now.Sub(c.computed) < conditionEnvTTL {
+		return c.vars
+	}
+
+	vars := map[string]any{
+		"idle_minutes":                 -1.0,
+		"queue_pending":                0,
+		"queue_in_flight":              0,
+		"tokens_today":                 0,
+		"output_tokens_today":          0,
+		"cost_today":                   0.0,
+		"calendar_busy":                false,
+		"minutes_until_busy":           -1.0,
+		"discord_connected":            false,
+		"discord_disconnected_minutes": 0.0,
+	}
+	if c.activity != nil {
+		if last := c.activity.LastUserInputTime(); !last.IsZero() {
+			vars["idle_minutes"] = now.Sub(last).Minutes()
+		}
+	}
+	if c.queue != nil {
+		vars["queue_pending"] = c.queue.Len()
+		vars["queue_in_flight"] = c.queue.InFlight()
+	}
+	if c.tracker != nil {
+		usage := c.tracker.TodayTokenUsage()
+		vars["tokens_today"] = usage.InputTokens + usage.OutputTokens
+		vars["output_tokens_today"] = usage.OutputTokens
+		vars["cost_today"] = c.tracker.TodayCost().TotalUSD
+	}
+	if c.calendar != nil {
+		c.refreshBusyLocked(now)
+		busy, until := busyStatus(c.busy, now)
+		vars["calendar_busy"] = busy
+		if until >= 0 {
+			vars["minutes_until_busy"] = until.Minutes()
+		}
+	}
+	if c.discord != nil {
+		vars["discord_connected"] = c.discord.IsConnected()
+		vars["discord_disconnected_minutes"] = c.discord.DisconnectedDuration().Minutes()
+	}
+
+	c.vars = vars
+	c.computed = now
+	return vars
+}
+
+// refreshBusyLocked refetches busy periods when the cached ones are older
+// than calendarEnvTTL. On failure the previous periods are kept.
+func (c *conditionEnv) refreshBusyLocked(now time.Time) {
+	if !c.busyAt.IsZero() && now.Sub(c.busyAt) < calendarEnvTTL {
+		return
+	}
+	c.busyAt = now
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	busy, err := c.calendar.FreeBusy(ctx, calendar.FreeBusyParams{TimeMin: now, TimeMax: now.Add(calendarEnvWindow)})
+	if err != nil {
+		log.Printf("[triggers] condition env: calendar free/busy: %v", err)
+		return
+	}
+	c.busy = busy
+}
+
+// busyStatus reports whether now falls in a busy period and, if not, how long
+// until the next one starts (-1 if none is known).
+func busyStatus(periods []calendar.BusyPeriod, now time.Time) (bool, time.Duration) {
+	until := time.Duration(-1)
+	for _, p := range periods {
+		if !now.Before(p.Start) && now.Before(p.End) {
+			return true, 0
+		}
+		if p.Start.After(now) && (until < 0 || p.Start.Sub(now) < until) {
+			until = p.Start.Sub(now)
+		}
+	}
+	return false, until
+}
@@ -41,6 +41,7 @@ import (
 	"github.com/vthunder/bud2/internal/paths"
 	"github.com/vthunder/bud2/internal/profiling"
 	"github.com/vthunder/bud2/internal/reflex"
+	"github.com/vthunder/bud2/internal/sandbox"
 	"github.com/vthunder/bud2/internal/senses"
 	"github.com/vthunder/bud2/internal/state"
 	"github.com/vthunder/bud2/internal/terminal"
@@ -130,18 +131,67 @@ func getProcessStartTime(proc *process.Process) string {
 	return time.UnixMilli(createTime).Format("2006-01-02 15:04:05")
 }
 
-func main() {
-	log.Printf("bud2 - focus-based agent v2 [%s]", Version)
-	log.Println("==================================")
+// newOpenAIProvider builds an openai-compatible provider from its bud.yaml
+// entry (base_url, api_key_env, per-model limits).
+func newOpenAIProvider(budCfg *config.BudConfig, providerName, modelID string) *provider.OpenAICompatibleProvider {
+	apiKey, err := budCfg.APIKey(providerName)
+	if err != nil {
+		log.Printf("[config] Warning: %v (continuing without API key)", err)
+	}
+	p := provider.NewOpenAICompatibleProvider(apiKey, modelID, budCfg.Providers[providerName].BaseURL)
+	if cw := budCfg.ContextWindow(providerName, modelID); cw > 0 {
+		p.WithContextWindow(cw)
+	}
+	if mo := budCfg.MaxOutputTokens(providerName, modelID); mo > 0 {
+		p.WithMaxOutputTokens(mo)
+	}
+	return p
+}
 
-	// Load .env file (optional - won't error if missing)
-	if err := godotenv.Load(); err != nil {
-		log.Println("[config] No .env file found, using environment variables")
-	} else {
-		log.Println("[config] Loaded .env file")
+// budgetSettings converts the bud.yaml budgets section into budget limits.
+func budgetSettings(bc config.BudgetsConfig) budget.Settings {
+	s := budget.Settings{
+		DailyOutputTokens: 1_000_000,
+		DailyCostUSD:      bc.DailyCostUSD,
+		MonthlyCostUSD:    bc.MonthlyCostUSD,
+		SoftLimitFraction: 0.8,
+	}
+	if bc.DailyOutputTokens != nil {
+		s.DailyOutputTokens = *bc.DailyOutputTokens
+	}
+	if bc.SoftLimit > 0 {
+		s.SoftLimitFraction = bc.SoftLimit
+	}
+	for _, l := range bc.Limits {
+		s.Limits = append(s.Limits, budget.Limit{
+			Name:         l.Name,
+			Role:         l.Role,
+			Provider:     l.Provider,
+			Window:       l.ParsedWindow(),
+			InputTokens:  l.InputTokens,
+			OutputTokens: l.OutputTokens,
+			CacheTokens:  l.CacheTokens,
+			TotalTokens:  l.TotalTokens,
+			CostUSD:      l.CostUSD,
+			SoftLimit:    l.SoftLimit,
+		})
+	}
+	return s
+}
+
+// priceTable converts the bud.yaml pricing section into a budget price table.
+func priceTable(pricing map[string]config.ModelPricing) budget.PriceTable {
+	prices := make(budget.PriceTable, len(pricing))
+	for key, p := range pricing {
+		prices[key] = budget.Price{Input: p.Input, Output: p.Output, CacheRead: p.CacheRead, CacheWrite: p.CacheWrite}
 	}
+	return prices
+}
 
-	// Load bud config from --config flag or BUD_CONFIG env var
+// loadBudConfig loads bud.yaml from the --config flag or BUD_CONFIG env var,
+// falling back to the defaults with environment overrides, and returns it with
+// the path it came from ("" for defaults). Exits on an invalid config.
+func loadBudConfig() (*config.BudConfig, string) {
 	var budCfg *config.BudConfig
 	configPath := os.Getenv("BUD_CONFIG")
 	for i, arg := range os.Args[1:] {
@@ -163,26 +213,21 @@ func main() {
 		log.Printf("[config] Loaded config from %s", configPath)
 	} else {
 		budCfg = config.DefaultConfig()
+		if err := budCfg.ApplyEnv(os.Getenv); err != nil {
+			log.Fatalf("[config] Invalid environment override: %v", err)
+		}
+		if err := budCfg.Validate(); err != nil {
+			log.Fatalf("[config] Invalid config: %v", err)
+		}
 		log.Println("[config] Using default config (no --config flag or BUD_CONFIG env)")
 	}
+	return budCfg, configPath
+}
 
-	// Initialize terminal window manager based on config
-	var termManager terminal.Manager
-	switch budCfg.GetTerminalManager() {
-	case "tmux":
-		termManager = tmux.NewManager()
-		log.Println("[config] Using tmux terminal manager")
-	default:
-		termManager = zellij.NewManager()
-		log.Println("[config] Using zellij terminal manager")
-	}
-
-	// Config from environment
-	discordToken := os.Getenv("DISCORD_TOKEN")
-	discordChannel := os.Getenv("DISCORD_CHANNEL_ID")
-	discordOwner := os.Getenv("DISCORD_OWNER_ID")
-	discordGuildID := os.Getenv("DISCORD_GUILD_ID") // For slash command registration
-	statePath := os.Getenv("STATE_PATH")
+// resolveStatePath returns the absolute state directory: bud.yaml's
+// state_path, or the platform default.
+func resolveStatePath(budCfg *config.BudConfig) string {
+	statePath := budCfg.StatePath
 	if statePath == "" {
 		home, _ := os.UserHomeDir()
 		if runtime.GOOS == "darwin" {
@@ -196,27 +241,72 @@ func main() {
 	if abs, err := filepath.Abs(statePath); err == nil {
 		statePath = abs
 	}
+	return statePath
+}
+
+// pluginDirs returns the bundled plugin dir and every dir plugins are loaded
+// from: bundled, user, then plugins.yaml sources, later overriding earlier.
+func pluginDirs(statePath string) (sysDir string, all []string) {
+	sysDir = filepath.Join(paths.DefaultsDir, "system", "plugins")
+	userDir := filepath.Join(statePath, "system", "plugins")
+	all = append([]string{sysDir, userDir}, executive.ManifestPluginDirs(statePath)...)
+	return sysDir, all
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "plugins" {
+		os.Exit(runPluginsCommand(os.Args[2:]))
+	}
+
+	log.Printf("bud2 - focus-based agent v2 [%s]", Version)
+	log.Println("==================================")
+
+	// Load .env file (optional - won't error if missing)
+	if err := godotenv.Load(); err != nil {
+		log.Println("[config] No .env file found, using environment variables")
+	} else {
+		log.Println("[config] Loaded .env file")
+	}
+
+	budCfg, configPath := loadBudConfig()
+
+	// Initialize terminal window manager based on config
+	var termManager terminal.Manager
+	switch budCfg.GetTerminalManager() {
+	case "tmux":
+		termManager = tmux.NewManager()
+		log.Println("[config] Using tmux terminal manager")
+	default:
+		termManager = zellij.NewManager()
+		log.Println("[config] Using zellij terminal manager")
+	}
+
+	// Secrets stay in the environment; everything else comes from bud.yaml
+	// (with environment overrides already applied by the config loader).
+	discordToken := os.Getenv("DISCORD_TOKEN")
+	discordChannel := budCfg.Discord.ChannelID
+	discordOwner := budCfg.Discord.OwnerID
+	discordGuildID := budCfg.Discord.GuildID // For slash command registration
+	statePath := resolveStatePath(budCfg)
 	providerName, modelID, err := budCfg.ResolveModel("executive")
 	if err != nil {
 		log.Fatalf("[config] Failed to resolve executive model: %v", err)
 	}
-	log.Printf("[config] Executive provider: %s, model: %s", providerName, modelID)
+	providerType := budCfg.Providers[providerName].Type
+	log.Printf("[config] Executive provider: %s (%s), model: %s", providerName, providerType, modelID)
 	claudeModel := os.Getenv("CLAUDE_MODEL")
-	if claudeModel == "" && providerName == "claude-code" {
+	if claudeModel == "" && providerType == "claude-code" {
 		claudeModel = modelID
 	}
 	if claudeModel == "" {
 		claudeModel = "claude-sonnet-4-20250514"
 	}
-	mcpHTTPPort := os.Getenv("MCP_HTTP_PORT")
-	if mcpHTTPPort == "" {
-		mcpHTTPPort = "8066" // Default MCP HTTP port
-	}
+	mcpHTTPPort := budCfg.MCP.Port()
 
 	// Create the appropriate provider based on config
 	var executiveProvider provider.Provider
 	var agentProvider provider.Provider
-	switch providerName {
+	switch providerType {
 	case "claude-code":
 		executiveProvider = provider.NewClaudeCodeProvider(claudeModel)
 		log.Printf("[config] Using claude-code provider with model %s", claudeModel)
@@ -232,8 +322,11 @@ func main() {
 			log.Printf("[config] Using opencode-serve provider with model %s (default context window)", ocModel)
 		}
 		executiveProvider = ocProvider
+	case "openai-compatible":
+		executiveProvider = newOpenAIProvider(budCfg, providerName, modelID)
+		log.Printf("[config] Using openai-compatible provider with model %s", modelID)
 	default:
-		log.Fatalf("[config] Unsupported provider type: %s", providerName)
+		log.Fatalf("[config] Unsupported provider type %q for provider %s", providerType, providerName)
 	}
 
 	// Resolve agent provider (defaults to executive provider if not specified)
@@ -242,7 +335,7 @@ func main() {
 		// No agent-specific config, use executive provider
 		agentProvider = executiveProvider
 	} else {
-		switch agentProviderName {
+		switch budCfg.Providers[agentProviderName].Type {
 		case "claude-code":
 			agentProvider = provider.NewClaudeCodeProvider(agentModelID)
 			log.Printf("[config] Using claude-code provider for agents with model %s", agentModelID)
@@ -256,6 +349,9 @@ func main() {
 			}
 			agentProvider = ocAgentProvider
 			log.Printf("[config] Using opencode-serve provider for agents with model %s", ocModel)
+		case "openai-compatible":
+			agentProvider = newOpenAIProvider(budCfg, agentProviderName, agentModelID)
+			log.Printf("[config] Using openai-compatible provider for agents with model %s", agentModelID)
 		default:
 			log.Printf("[config] Warning: unsupported agent provider type %s, falling back to executive provider", agentProviderName)
 			agentProvider = executiveProvider
@@ -266,59 +362,27 @@ func main() {
 	os.MkdirAll(filepath.Join(statePath, "system"), 0755) // Ensure system/ dir exists for pid file
 	cleanupPidFile := checkPidFile(statePath)
 	defer cleanupPidFile()
-	autonomousEnabled := os.Getenv("AUTONOMOUS_ENABLED") == "true"
-	autonomousIntervalStr := os.Getenv("AUTONOMOUS_INTERVAL")
-	autonomousIdleRequiredStr := os.Getenv("AUTONOMOUS_IDLE_REQUIRED")
-	autonomousSessionCapStr := os.Getenv("AUTONOMOUS_SESSION_CAP")
-	dailyTokenBudgetStr := os.Getenv("DAILY_OUTPUT_TOKEN_BUDGET")
-	userTimezoneStr := os.Getenv("USER_TIMEZONE") // e.g., "Europe/Berlin"
-
-	// Parse autonomous interval (default 2 hours)
-	autonomousInterval := 2 * time.Hour
-	if autonomousIntervalStr != "" {
-		if d, err := time.ParseDuration(autonomousIntervalStr); err == nil {
-			autonomousInterval = d
-		}
+	// Autonomous settings can change on config reload; the wake loop reads
+	// them through autonomousSettings.
+	var autonomousMu sync.Mutex
+	autonomousCfg := budCfg.Autonomous
+	autonomousSettings := func() config.AutonomousConfig {
+		autonomousMu.Lock()
+		defer autonomousMu.Unlock()
+		return autonomousCfg
 	}
 
-	// Parse idle required before autonomous wake (default 0 = no idle gate).
-	// Set AUTONOMOUS_IDLE_REQUIRED=15m to skip wakes while user is recently active.
-	autonomousIdleRequired := time.Duration(0)
-	if autonomousIdleRequiredStr != "" {
-		if d, err := time.ParseDuration(autonomousIdleRequiredStr); err == nil {
-			autonomousIdleRequired = d
-		}
+	// The timezone can change on config reload too; read it through
+	// userTimezone.
+	var timezoneMu sync.Mutex
+	timezone := budCfg.ParsedTimezone()
+	userTimezone := func() *time.Location {
+		timezoneMu.Lock()
+		defer timezoneMu.Unlock()
+		return timezone
 	}
-
-	// Parse autonomous session cap (default 8 minutes).
-	// Executive wake sessions are capped at this duration so they stay short
-	// and delegate real work to subagents rather than doing it inline.
-	autonomousSessionCap := 8 * time.Minute
-	if autonomousSessionCapStr != "" {
-		if d, err := time.ParseDuration(autonomousSessionCapStr); err == nil {
-			autonomousSessionCap = d
-		}
-	}
-
-	// Parse daily output token budget (default 1M tokens)
-	dailyOutputTokenBudget := 1_000_000
-	if dailyTokenBudgetStr != "" {
-		if v, err := strconv.Atoi(dailyTokenBudgetStr); err == nil {
-			dailyOutputTokenBudget = v
-		}
-	}
-
-	// Parse user timezone (default UTC)
-	var userTimezone *time.Location
-	if userTimezoneStr != "" {
-		var err error
-		userTimezone, err = time.LoadLocation(userTimezoneStr)
-		if err != nil {
-			log.Printf("Warning: invalid USER_TIMEZONE %q, using UTC: %v", userTimezoneStr, err)
-			userTimezone = time.UTC
-		} else {
-			log.Printf("[config] User timezone: %s", userTimezone)
-		}
+	if budCfg.Timezone != "" {
+		log.Printf("[config] User timezone: %s", timezone)
 	}
 
 	if discordToken == "" {
@@ -357,7 +421,7 @@ func main() {
 	os.MkdirAll(queuesPath, 0755)
 
 	// Engram HTTP client - used by executive for memory retrieval
-	engramURL := os.Getenv("ENGRAM_URL")
+	engramURL := budCfg.Integrations.EngramURL
 	engramAPIKey := os.Getenv("ENGRAM_API_KEY")
 	var engramClient *engram.Client
 	if engramURL != "" {
@@ -369,7 +433,7 @@ func main() {
 			log.Printf("[main] Engram health check OK")
 		}
 	} else {
-		log.Println("[main] Warning: ENGRAM_URL not set, executive memory retrieval disabled")
+		log.Println("[main] Warning: integrations.engram_url not set, executive memory retrieval disabled")
 	}
 
 	// Ollama embedding client - used by stateInspector and memoryJudge
@@ -389,11 +453,16 @@ func main() {
 	// Initialize session tracker and signal processor for thinking time budget
 	sessionTracker := budget.NewSessionTracker(statePath)
 	thinkingBudget := budget.NewThinkingBudget(sessionTracker)
-	thinkingBudget.DailyOutputTokens = dailyOutputTokenBudget
+	thinkingBudget.ExecutiveProvider = providerType
+	thinkingBudget.Apply(budgetSettings(budCfg.Budgets))
+	sessionTracker.SetPricing(priceTable(budCfg.Pricing))
+	if n := len(thinkingBudget.Limits); n > 0 {
+		log.Printf("[main] Loaded %d token budget limits", n)
+	}
 
 	todayUsage := sessionTracker.TodayTokenUsage()
-	log.Printf("[main] Session tracker initialized (output tokens today: %dk, budget: %dk, sessions: %d)",
-		todayUsage.OutputTokens/1000, dailyOutputTokenBudget/1000, todayUsage.SessionCount)
+	log.Printf("[main] Session tracker initialized (output tokens today: %dk, budget: %dk, sessions: %d, cost today: $%.2f)",
+		todayUsage.OutputTokens/1000, thinkingBudget.DailyOutputTokens/1000, todayUsage.SessionCount, sessionTracker.TodayCost().TotalUSD)
 	if sessionTracker.HasActiveSessions() {
 		log.Printf("[main] Warning: session tracker has active sessions at startup — possible unclean shutdown")
 	}
@@ -404,14 +473,30 @@ func main() {
 		log.Printf("Warning: failed to load reflexes: %v", err)
 	}
 	reflexEngine.SetDefaultChannel(discordChannel)
+	reflexEngine.SetLLMBudget(thinkingBudget.ForRole(budget.RoleReflex, "ollama"))
+
+	// Shared sandbox for reflex shell actions and plugin action scripts.
+	sandboxRunner := sandbox.NewRunner(sandbox.Policy{
+		Timeout:        budCfg.Sandbox.ParsedTimeout(),
+		MaxOutputBytes: budCfg.Sandbox.MaxOutputBytes,
+		EnvAllow:       budCfg.Sandbox.EnvAllow,
+		Isolate:        budCfg.Sandbox.Isolate,
+		Network:        budCfg.Sandbox.Network,
+		Wrapper:        budCfg.Sandbox.Wrapper,
+	}, filepath.Join(statePath, "system", "sandbox"))
+	reflexEngine.SetSandbox(sandboxRunner)
 
 	// Initialize calendar client (optional)
 	var calendarClient *calendar.Client
-	hasCalendarCreds := os.Getenv("GOOGLE_CALENDAR_CREDENTIALS") != "" || os.Getenv("GOOGLE_CALENDAR_CREDENTIALS_FILE") != ""
-	hasCalendarIDs := os.Getenv("GOOGLE_CALENDAR_IDS") != "" || os.Getenv("GOOGLE_CALENDAR_ID") != ""
-	if hasCalendarCreds && hasCalendarIDs {
+	calendarCredsJSON := os.Getenv("GOOGLE_CALENDAR_CREDENTIALS") // base64 service account key
+	hasCalendarCreds := calendarCredsJSON != "" || budCfg.Integrations.CalendarCredentialsFile != ""
+	if hasCalendarCreds && len(budCfg.Integrations.CalendarIDs) > 0 {
 		var err error
-		calendarClient, err = calendar.NewClient()
+		calendarClient, err = calendar.NewClientWithConfig(calendar.Config{
+			CredentialsFile: budCfg.Integrations.CalendarCredentialsFile,
+			CredentialsJSON: calendarCredsJSON,
+			CalendarIDs:     budCfg.Integrations.CalendarIDs,
+		})
 		if err != nil {
 			log.Printf("Warning: failed to create calendar client: %v", err)
 		} else {
@@ -424,16 +509,16 @@ func main() {
 
 	// Initialize GitHub client (optional)
 	var githubClient *github.Client
-	if os.Getenv("GITHUB_TOKEN") != "" && os.Getenv("GITHUB_ORG") != "" {
+	if githubToken := os.Getenv("GITHUB_TOKEN"); githubToken != "" && budCfg.Integrations.GitHubOrg != "" {
 		var err error
-		githubClient, err = github.NewClient()
+		githubClient, err = github.NewClientWithConfig(github.Config{Token: githubToken, Org: budCfg.Integrations.GitHubOrg})
 		if err != nil {
 			log.Printf("Warning: failed to create GitHub client: %v", err)
 		} else {
 			log.Printf("[main] GitHub integration enabled (org: %s)", githubClient.Org())
 		}
 	} else {
-		log.Println("[main] GitHub integration disabled (GITHUB_TOKEN or GITHUB_ORG not set)")
+		log.Println("[main] GitHub integration disabled (GITHUB_TOKEN or integrations.github_org not set)")
 	}
 
 	// Initialize state inspector for MCP tools
@@ -448,10 +533,10 @@ func main() {
 	var mcpAddReaction func(channelID, messageID, emoji string) error // Will be wired to Discord effector
 	var mcpSendFile func(channelID, filePath, message string) error   // Will be wired to Discord effector
 
-	// Initialize GK process pool if GK_PATH is configured.
-	// GK_PATH should point to the gk project directory (e.g. ~/src/gk).
+	// Initialize GK process pool if integrations.gk_path is configured.
+	// It should point to the gk project directory (e.g. ~/src/gk).
 	var gkPool *mcp.GKPool
-	if gkPath := os.Getenv("GK_PATH"); gkPath != "" {
+	if gkPath := budCfg.Integrations.GKPath; gkPath != "" {
 		gkPool = mcp.NewGKPool(gkPath, statePath)
 		defer gkPool.Close()
 		log.Printf("[main] GK pool initialized (path=%s)", gkPath)
@@ -471,22 +556,43 @@ func main() {
 	// Extensions missing from any dir are silently skipped. A failed load is non-fatal.
 	var pluginRegistry *plugins.Registry
 	{
-		sysExtDir := filepath.Join(paths.DefaultsDir, "system", "plugins")
-		userExtDir := filepath.Join(statePath, "system", "plugins")
-		manifestDirs := executive.ManifestPluginDirs(statePath)
-		allDirs := append([]string{sysExtDir, userExtDir}, manifestDirs...)
+		sysExtDir, allDirs := pluginDirs(statePath)
 		reg, regErr := plugins.LoadAll(allDirs...)
 		if regErr != nil {
 			log.Printf("[main] Warning: failed to load plugin registry: %v", regErr)
 		} else {
 			pluginRegistry = reg
 			log.Printf("[main] Plugin registry loaded: %d plugin(s)", reg.Len())
+			checkPluginLock(statePath, reg)
+
+			// Bundled plugins are trusted; everything else runs only once the
+			// user has approved its declared permissions.
+			reg.TrustDir(sysExtDir)
+			approvals, apprErr := plugins.LoadApprovals(filepath.Join(statePath, "system", "plugin-approvals.json"))
+			if apprErr != nil {
+				log.Printf("[main] Warning: %v; plugin approvals start empty", apprErr)
+			}
+			reg.SetApprovals(approvals)
+			reg.SetOnDenied(func(e *plugins.PermissionError) {
+				activityLog.LogPermissionDenied(e.Plugin, e.Permission, e.Target, e.Reason)
+			})
+			reflexEngine.SetAuthorizer(reg)
 		}
 	}
 
+	// Expose skills, workflows and guides as MCP prompts for external clients
+	// (editors etc.). Guides merge state-defaults with state overrides.
+	mcpPrompts := plugins.NewPrompts(pluginRegistry, func() []string {
+		return paths.MergeDir(statePath, "guides", []string{".md"})
+	})
+	mcpServer.RegisterPromptProvider(mcpPrompts.List, mcpPrompts.Get)
+
 	// Initialize the Dispatcher to fire extension behaviors (schedule, slash_command, pattern_match, etc).
 	// Must be declared here so processPercept (closure) and slash command registration can capture it.
 	var dispatcher *plugins.Dispatcher
+	var pluginLifecycle *plugins.Lifecycle
+	var actionProxy *plugins.ActionProxy
+	condEnv := newConditionEnv(activityLog, sessionTracker, calendarClient)
 	if pluginRegistry != nil {
 		runner := &extWorkflowRunner{engine: reflexEngine, registry: pluginRegistry}
 		eventBus := plugins.NewEventBus()
@@ -504,8 +610,18 @@ func main() {
 				activityLog.LogAction(msg, "dispatcher", "", "")
 			},
 		})
-		dispatcher.RegisterAll(context.Background())
-		log.Printf("[main] Dispatcher registered behaviors for %d plugin(s)", pluginRegistry.Len())
+		dispatcher.SetSystemEnv(condEnv)
+		deadLetters, dlErr := plugins.LoadDeadLetters(filepath.Join(statePath, "system", "plugin-dead-letters.json"))
+		if dlErr != nil {
+			log.Printf("[main] Warning: %v; dead letters start empty", dlErr)
+		}
+		dispatcher.SetDeadLetters(deadLetters)
+		// Route workflow type:direct steps to plugin action scripts, run in
+		// the same sandbox as reflex shell actions.
+		actionProxy = plugins.NewActionProxy(pluginRegistry)
+		actionProxy.SetSandbox(sandboxRunner)
+		actionProxy.RegisterMCPTools(mcpServer)
+		reflexEngine.SetActionCaller(actionProxy)
 
 		// Wire a workflow fallback so type:invoke steps in reflexes can resolve
 		// extension capability workflows (e.g. gtd-today, gtd-inbox, gtd-add).
@@ -529,9 +645,24 @@ func main() {
 			r, err := reflexEngine.LoadFile(yamlPath)
 			if err != nil {
 				log.Printf("[main] workflowFallback: LoadFile %s failed: %v", yamlPath, err)
+				return nil, err
+			}
+			if err := pluginRegistry.Authorize(ext.Manifest.Name, plugins.PermissionExecute, name); err != nil {
+				return nil, err
 			}
-			return r, err
+			r.Plugin = ext.Manifest.Name
+			return r, nil
 		})
+
+		// Run install/upgrade and daemon_start hooks before registering
+		// behaviors, so a vetoed upgrade leaves the plugin's behaviors off.
+		pluginLifecycle = plugins.NewLifecycle(pluginRegistry, runner, filepath.Join(statePath, "system", "plugin-installs.json"))
+		pluginLifecycle.SetSandbox(sandboxRunner)
+		pluginLifecycle.SetDispatcher(dispatcher)
+		pluginLifecycle.Start(context.Background())
+
+		dispatcher.RegisterAll(context.Background())
+		log.Printf("[main] Dispatcher registered behaviors for %d plugin(s)", pluginRegistry.Len())
 	}
 
 	// Declare variable for executive (will be initialized after MCP deps are set up)
@@ -549,8 +680,10 @@ func main() {
 		MemoryJudge:    memoryJudge,
 		CalendarClient: calendarClient,
 		GitHubClient:   githubClient,
-		VMControlURL:      os.Getenv("VM_CONTROL_URL"), // defaults to http://127.0.0.1:3099 in vm_browser.go
+		VMControlURL:      budCfg.Integrations.VMControlURL, // defaults to http://127.0.0.1:3099 in vm_browser.go
 		PluginRegistry: pluginRegistry,
+		PluginLifecycle: pluginLifecycle,
+		PluginDispatcher: dispatcher,
 		GKCallTool: func() func(domain, toolName string, args map[string]any) (string, error) {
 			if gkPool == nil {
 				return nil
@@ -586,8 +719,16 @@ func main() {
 			return fmt.Errorf("Discord effector not yet initialized")
 		},
 		MCPBaseURL: fmt.Sprintf("http://127.0.0.1:%s", mcpHTTPPort),
-		RegisterSession: func(token, agentID, domain string) {
-			mcpServer.RegisterSession(token, agentID, domain)
+		RegisterSession: func(token, agentID, domain string, tools []string) {
+			mcpServer.RegisterSession(token, agentID, domain, tools)
+		},
+		SetSessionAgent:   mcpServer.SetSessionAgent,
+		UnregisterSession: mcpServer.UnregisterSession,
+		SubagentMCPTools: func(profile string) []string {
+			if exec != nil {
+				return exec.SubagentMCPTools(profile)
+			}
+			return nil
 		},
 		AddThought: nil, // Will be set after processInboxMessage is defined
 		OnMCPToolCall: func(toolName string) {
@@ -595,10 +736,21 @@ func main() {
 				exec.GetMCPToolCallback()(toolName)
 			}
 		},
+		FocusQueuePicks: func() []focus.PickReason {
+			if exec == nil {
+				return nil
+			}
+			return exec.GetQueue().RecentPicks()
+		},
+		CostReport: thinkingBudget.CostReport,
+		QueryUsage: sessionTracker.QueryUsage,
 		SpawnSubagent: func(task, systemPromptAppend, profile, workflowInstanceID, workflowStep, mcpURL string) (string, string, error) {
 			if exec == nil {
 				return "", "", fmt.Errorf("executive not yet initialized")
 			}
+			if ok, reason := thinkingBudget.Check(budget.RoleAgent, agentProvider.Name()); !ok {
+				return "", "", fmt.Errorf("agent budget: %s", reason)
+			}
 			spawnFn, _, _, _, _, _, _, _, _ := exec.SubagentCallbacks()
 			id, logPath, err := spawnFn(task, systemPromptAppend, profile, workflowInstanceID, workflowStep, mcpURL)
 			if err == nil && logPath != "" {
@@ -668,11 +820,20 @@ func main() {
 	tools.RegisterAll(mcpServer, mcpDeps)
 	log.Printf("[main] MCP server initialized with %d tools", mcpServer.ToolCount())
 
-	// Start stdio MCP proxy servers from state/system/mcp.json and register their tools
-	// This makes things-mcp (and any other stdio servers) available to both
+	// Remote MCP servers' headers can reference secrets kept out of their
+	// config, as ${secret:NAME}.
+	mcpSecrets, secretsErr := mcp.LoadFileSecrets(filepath.Join(statePath, "system", "secrets.json"))
+	if secretsErr != nil {
+		log.Printf("[main] Warning: %v; MCP header secrets unavailable", secretsErr)
+	}
+
+	// Start stdio MCP proxy servers and connect to remote ones from state/system/mcp.json,
+	// and register their tools.
+	// This makes things-mcp (and any other external servers) available to both
 	// Claude sessions (via HTTP) and the reflex engine (via call_tool action).
 	mcpConfigPath := filepath.Join(statePath, "system", "mcp.json")
-	if proxyClients, proxyErr := mcp.StartProxiesFromConfig(mcpConfigPath, mcpServer); proxyErr != nil {
+	proxyClients, proxyErr := mcp.StartProxiesFromConfig(mcpConfigPath, mcpServer, mcpSecrets)
+	if proxyErr != nil {
 		log.Printf("[main] Warning: failed to start MCP proxies: %v", proxyErr)
 	} else if len(proxyClients) > 0 {
 		log.Printf("[main] Started %d MCP proxy server(s), total tools: %d", len(proxyClients), mcpServer.ToolCount())
@@ -684,48 +845,28 @@ func main() {
 	}
 
 	// Start MCP proxy servers declared by extensions (mcp_servers field in extension.yaml)
+	var pluginMCPServers *plugins.MCPServers
 	if pluginRegistry != nil {
-		for _, ext := range pluginRegistry.All() {
-			for srvName, srv := range ext.Manifest.MCPServers {
-				if srv.Command == "" {
-					continue
-				}
-				// Resolve relative args against the extension directory
-				args := make([]string, len(srv.Args))
-				for i, a := range srv.Args {
-					if !filepath.IsAbs(a) {
-						a = filepath.Join(ext.Dir, a)
-					}
-					args[i] = a
-				}
-				log.Printf("[main] Starting plugin MCP server %s from %s", srvName, ext.Manifest.Name)
-				proxy, proxyErr := mcp.StartProxy(mcp.ExternalServerConfig{
-					Name:    srvName,
-					Command: srv.Command,
-					Args:    args,
-					Env:     srv.Env,
-				})
-				if proxyErr != nil {
-					log.Printf("[main] Warning: failed to start plugin MCP server %s: %v", srvName, proxyErr)
-					continue
-				}
-				extTools, toolErr := proxy.DiscoverTools()
-				if toolErr != nil {
-					log.Printf("[main] Warning: plugin MCP server %s tool discovery failed: %v", srvName, toolErr)
-					proxy.Close()
-					continue
-				}
-				log.Printf("[main] Plugin MCP server %s: %d tools", srvName, len(extTools))
-				for _, def := range extTools {
-					toolName := def.Name
-					proxyRef := proxy
-					mcpServer.RegisterTool(toolName, def, func(ctx any, args map[string]any) (string, error) {
-						return proxyRef.CallTool(toolName, args)
-					})
-				}
-				defer proxy.Close()
-			}
+		pluginMCPServers = plugins.NewMCPServers(mcpServer)
+		pluginMCPServers.SetSecrets(mcpSecrets)
+		pluginMCPServers.SetAuthorizer(pluginRegistry)
+		pluginMCPServers.StartAll(pluginRegistry)
+		defer pluginMCPServers.Close()
+	}
+
+	// Report every proxied MCP server in state_health.
+	mcpDeps.ProxyHealth = func() []mcp.ProxyHealth {
+		var health []mcp.ProxyHealth
+		for _, p := range proxyClients {
+			health = append(health, p.Health())
 		}
+		if pluginMCPServers != nil {
+			health = append(health, pluginMCPServers.Health()...)
+		}
+		if gkPool != nil {
+			health = append(health, gkPool.Health()...)
+		}
+		return health
 	}
 
 	// Wire the MCP server as the tool caller for the reflex engine
@@ -745,6 +886,14 @@ func main() {
 	// Declare variable for fallback callback (will be set after discordEffector is created)
 	var fallbackSendMessage func(channelID, message string) error
 
+	// The executive's MCP token allows every tool; subagents get their own
+	// tokens limited to what their profile grants (see Agent_spawn_async).
+	execMCPToken := mcp.NewToken()
+	mcpServer.RegisterSession(execMCPToken, "executive", "/", []string{"*"})
+	mcpServer.SetDeniedToolHook(func(session mcp.SessionInfo, tool string) {
+		activityLog.LogMCPToolDenied(session.AgentID, tool)
+	})
+
 	// Initialize v2 executive with focus-based attention
 	// Note: exec is already declared above so OnMCPToolCall can reference it
 	exec = executive.NewExecutiveV2(
@@ -753,17 +902,22 @@ func main() {
 		executive.ExecutiveV2Config{
 			Provider:                     executiveProvider,
 			AgentProvider:                agentProvider,
-			ProviderName:                 providerName,
+			ProviderName:                 providerType,
 			ProviderConfig:               budCfg,
 			Model:                        claudeModel,
 			WorkDir:                      statePath, // Run Claude from state/ directory
-			MCPServerURL:                 fmt.Sprintf("http://127.0.0.1:%s/mcp", mcpHTTPPort),
+			MCPServerURL:                 fmt.Sprintf("http://127.0.0.1:%s/mcp/%s", mcpHTTPPort, execMCPToken),
+			OnSubagentDone: func(mcpURL string) {
+				if token, ok := strings.CutPrefix(mcpURL, fmt.Sprintf("http://127.0.0.1:%s/mcp/", mcpHTTPPort)); ok {
+					mcpServer.UnregisterSession(token)
+				}
+			},
 			BotAuthor:                    "Bud", // Kept for compatibility, but no longer used
 			SessionTracker:               sessionTracker,
 			WakeupInstructions:           wakeupInstructions,
 			StartupInstructions:          startupInstructions,
 			DefaultChannelID:             discordChannel,
-			MaxAutonomousSessionDuration: autonomousSessionCap,
+			MaxAutonomousSessionDuration: budCfg.Autonomous.ParsedSessionCap(),
 			PluginRegistry:            pluginRegistry,
 			SendMessageFallback: func(channelID, message string) error {
 				if fallbackSendMessage != nil {
@@ -782,6 +936,9 @@ func main() {
 				}
 				activityLog.LogExecDone(summary, focusID, durationSec, "executive", extra)
 			},
+			OnItemDropped: func(item *focus.PendingItem, reason string) {
+				activityLog.LogItemDropped(item.ID, item.Type, item.Source, item.Redeliveries, reason)
+			},
 			OnMemoryEval: func(eval string) {
 				activityLog.Log(activity.Entry{
 					Type:    "memory_eval",
@@ -793,6 +950,7 @@ func main() {
 			},
 		},
 	)
+	condEnv.SetQueue(exec.GetQueue())
 	// Set known MCP tool names so tool_grants wildcards (e.g. mcp__bud2__gk_*)
 	// can be expanded when loading agent definitions from plugins.
 	{
@@ -807,6 +965,11 @@ func main() {
 	// Wire PostToolUse lifecycle hooks into the MCP server so hook scripts
 	// receive the actual tool output (not fired from the observer path).
 	mcpServer.SetPostToolHook(exec.PostToolUseHook())
+	mcpServer.SetToolCallHook(func(session mcp.SessionInfo, tool string, args map[string]any) {
+		if session.AgentID == "executive" {
+			exec.RecordMCPToolCall(tool, args)
+		}
+	})
 
 	if err := exec.Start(); err != nil {
 		log.Fatalf("Failed to start executive: %v", err)
@@ -1125,6 +1288,11 @@ func main() {
 			Timestamp: percept.Timestamp,
 			Data:      percept.Data,
 		}
+		if deadline, ok := percept.Data["deadline"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, deadline); err == nil {
+				item.Deadline = &t
+			}
+		}
 
 		func() {
 			defer profiling.Get().Start(perceptID, "percept.queue_add")()
@@ -1198,6 +1366,28 @@ func main() {
 		return nil
 	}
 
+	// Warn the executive before a token budget's hard cap is reached
+	thinkingBudget.OnSoftLimit(func(w budget.SoftLimitWarning) {
+		log.Printf("[budget] Soft limit reached: %s", w)
+		impulse := &types.Impulse{
+			ID:          fmt.Sprintf("impulse-budget-%d", time.Now().UnixNano()),
+			Source:      types.ImpulseSystem,
+			Type:        "budget_warning",
+			Intensity:   0.6,
+			Timestamp:   time.Now(),
+			Description: fmt.Sprintf("Token budget warning: %s. Prefer cheaper work until usage drops.", w),
+			Data: map[string]any{
+				"limit":    w.Limit,
+				"role":     w.Role,
+				"provider": w.Provider,
+				"metric":   w.Metric,
+				"used":     w.Used,
+				"cap":      w.Cap,
+			},
+		}
+		processInboxMessage(memory.NewInboxMessageFromImpulse(impulse))
+	})
+
 	// Capture outgoing response as episode in Engram
 	captureResponse := func(channelID, content string) {
 		if engramClient == nil {
@@ -1306,6 +1496,7 @@ func main() {
 		)
 	})
 	discordSense.StartHealthMonitor()
+	condEnv.SetDiscord(discordSense)
 	log.Printf("[discord] initial connected=%v", discordSense.IsConnected())
 
 	// Wire up typing indicator to executive
@@ -1413,6 +1604,32 @@ func main() {
 	// Wire /stop to kill whatever session is currently running
 	discordSense.SetOnStop(exec.InterruptCurrentSession)
 
+	// Wire /plugin-approve and /plugin-deny. Approval is only recorded here,
+	// from the user's own command, never by a model-callable tool. Approving
+	// starts what was held back: install hooks and the plugin's MCP servers.
+	discordSense.SetOnPluginDecision(func(name string, approve bool) string {
+		if pluginRegistry == nil {
+			return "Plugins are not loaded."
+		}
+		if err := pluginRegistry.Decide(name, approve); err != nil {
+			return fmt.Sprintf("Could not record the decision: %v", err)
+		}
+		ext := pluginRegistry.Get(name)
+		if !approve {
+			if pluginMCPServers != nil {
+				pluginMCPServers.Stop(name)
+			}
+			return fmt.Sprintf("Denied permissions for plugin %s; it will not run until approved.", name)
+		}
+		if pluginLifecycle != nil {
+			pluginLifecycle.Sync(context.Background(), ext)
+		}
+		if pluginMCPServers != nil {
+			pluginMCPServers.Start(ext)
+		}
+		return fmt.Sprintf("Approved permissions for plugin %s.", name)
+	})
+
 	// Wire /debug-executive to toggle the live debug stream
 	dbg := newExecutiveDebugger(exec, discordSense.Session())
 	discordSense.SetOnDebugExecutive(dbg.Toggle)
@@ -1449,17 +1666,48 @@ func main() {
 
 	// Send startup message so future sessions can tell when a restart happened
 	if mcpSendMessage != nil && discordChannel != "" {
-		tz := userTimezone
-		if tz == nil {
-			tz = time.UTC
-		}
-		ts := time.Now().In(tz).Format("15:04 MST")
+		ts := time.Now().In(userTimezone()).Format("15:04 MST")
 		startupMsg := fmt.Sprintf("♻️ Back at %s", ts)
 		if err := mcpSendMessage(discordChannel, startupMsg); err != nil {
 			log.Printf("[main] Warning: failed to send startup message: %v", err)
 		}
 	}
 
+	// Ask once about plugins whose permissions have not been approved yet.
+	promptPluginApprovals := func() {
+		if mcpSendMessage == nil || discordChannel == "" || pluginRegistry == nil {
+			return
+		}
+		for _, ext := range pluginRegistry.PendingApproval() {
+			if !pluginRegistry.NeedsPrompt(ext) {
+				continue
+			}
+			msg := fmt.Sprintf("🔌 Plugin **%s** is installed but won't run until you approve its permissions:\n- %s\nUse `/plugin-approve %[1]s` or `/plugin-deny %[1]s`.",
+				ext.Manifest.Name, strings.Join(ext.Manifest.Permissions.Summary(), "\n- "))
+			if err := mcpSendMessage(discordChannel, msg); err != nil {
+				log.Printf("[main] Warning: failed to send plugin approval prompt for %s: %v", ext.Manifest.Name, err)
+				continue
+			}
+			if err := pluginRegistry.MarkPrompted(ext); err != nil {
+				log.Printf("[main] Warning: failed to record plugin approval prompt for %s: %v", ext.Manifest.Name, err)
+			}
+		}
+	}
+	promptPluginApprovals()
+
+	// Hot-reload plugins: pick up edits, new installs and removals from the
+	// plugin dirs without a restart. New plugins are prompted for approval.
+	if pluginRegistry != nil {
+		pluginReloader := plugins.NewReloader(pluginRegistry)
+		pluginReloader.SetDispatcher(dispatcher)
+		pluginReloader.SetActionProxy(actionProxy)
+		pluginReloader.SetMCPServers(pluginMCPServers)
+		pluginReloader.SetLifecycle(pluginLifecycle)
+		pluginReloader.SetOnReload(func(*plugins.ReloadResult) { promptPluginApprovals() })
+		pluginReloader.Start()
+		defer pluginReloader.Stop()
+	}
+
 	// Inject startup impulse so the executive runs startup housekeeping.
 	go func() {
 		time.Sleep(3 * time.Second) // Let executive initialize
@@ -1479,7 +1727,7 @@ func main() {
 	if calendarClient != nil {
 		calendarSense = senses.NewCalendarSense(senses.CalendarConfig{
 			Client:    calendarClient,
-			Timezone:  userTimezone,
+			Timezone:  userTimezone(),
 			StatePath: filepath.Join(statePath, "system", "calendar_state.json"),
 		}, processInboxMessage)
 
@@ -1551,7 +1799,7 @@ func main() {
 			case <-stopChan:
 				return
 			case <-notifyCh:
-				exec.RequestBackgroundInterrupt() // interrupt any running background session
+				exec.RequestBackgroundInterrupt() // preempt background work if a P0/P1 item is waiting
 				for {
 					ctx := context.Background()
 					processed, err := exec.ProcessNextP1(ctx)
@@ -1588,60 +1836,91 @@ func main() {
 	// Start autonomous wake-up goroutine (periodic self-initiated work).
 	// Uses adaptive intervals: active mode (base interval) when the user has been
 	// present recently, quiet mode (2× interval) when idle for 4+ hours.
-	if autonomousEnabled {
-		log.Printf("[main] Autonomous mode enabled (base interval: %v)", autonomousInterval)
-		go func() {
-			time.Sleep(10 * time.Second)
+	// The loop always runs so that autonomous.enabled can be toggled by a
+	// config reload; a disabled tick does nothing.
+	if budCfg.Autonomous.Enabled {
+		log.Printf("[main] Autonomous mode enabled (base interval: %v)", budCfg.Autonomous.ParsedInterval())
+	} else {
+		log.Println("[main] Autonomous mode disabled (set autonomous.enabled in bud.yaml to enable)")
+	}
+	go func() {
+		time.Sleep(10 * time.Second)
+
+		for {
+			// Adaptive interval: double base if no user input for 4+ hours.
+			auto := autonomousSettings()
+			interval := auto.ParsedInterval()
+			lastInput := activityLog.LastUserInputTime()
+			if auto.Enabled && !lastInput.IsZero() && time.Since(lastInput) > 4*time.Hour {
+				interval *= 2
+				log.Printf("[autonomous] Quiet mode (last input %v ago) — next wake in %v", time.Since(lastInput).Round(time.Minute), interval)
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-stopChan:
+				timer.Stop()
+				return
 
-			for {
-				// Adaptive interval: double base if no user input for 4+ hours.
-				interval := autonomousInterval
+			case <-timer.C:
+				auto := autonomousSettings()
+				if !auto.Enabled {
+					continue
+				}
+				// Idle gate: skip if user was active too recently or a P1 session is running.
 				lastInput := activityLog.LastUserInputTime()
-				if !lastInput.IsZero() && time.Since(lastInput) > 4*time.Hour {
-					interval = autonomousInterval * 2
-					log.Printf("[autonomous] Quiet mode (last input %v ago) — next wake in %v", time.Since(lastInput).Round(time.Minute), interval)
+				if idle := auto.ParsedIdleRequired(); !lastInput.IsZero() && time.Since(lastInput) < idle {
+					log.Printf("[autonomous] User active %v ago (< %v required) — skipping wake",
+						time.Since(lastInput).Round(time.Second), idle)
+					continue
+				}
+				if exec.IsP1Active() {
+					log.Printf("[autonomous] P1 session active — skipping wake")
+					continue
 				}
 
-				timer := time.NewTimer(interval)
-				select {
-				case <-stopChan:
-					timer.Stop()
-					return
-
-				case <-timer.C:
-					// Idle gate: skip if user was active too recently or a P1 session is running.
-					lastInput := activityLog.LastUserInputTime()
-					if !lastInput.IsZero() && time.Since(lastInput) < autonomousIdleRequired {
-						log.Printf("[autonomous] User active %v ago (< %v required) — skipping wake",
-							time.Since(lastInput).Round(time.Second), autonomousIdleRequired)
-						continue
-					}
-					if exec.IsP1Active() {
-						log.Printf("[autonomous] P1 session active — skipping wake")
-						continue
-					}
+				impulse := &types.Impulse{
+					ID:          fmt.Sprintf("impulse-wake-%d", time.Now().UnixNano()),
+					Source:      types.ImpulseSystem,
+					Type:        "wake",
+					Intensity:   0.5,
+					Timestamp:   time.Now(),
+					Description: "Periodic autonomous wake-up. Check for pending tasks, review commitments, or do background work.",
+					Data: map[string]any{
+						"trigger":              "periodic",
+						"last_user_session_ts": lastInput.Format(time.RFC3339),
+					},
+				}
 
-					impulse := &types.Impulse{
-						ID:          fmt.Sprintf("impulse-wake-%d", time.Now().UnixNano()),
-						Source:      types.ImpulseSystem,
-						Type:        "wake",
-						Intensity:   0.5,
-						Timestamp:   time.Now(),
-						Description: "Periodic autonomous wake-up. Check for pending tasks, review commitments, or do background work.",
-						Data: map[string]any{
-							"trigger":              "periodic",
-							"last_user_session_ts": lastInput.Format(time.RFC3339),
-						},
-					}
+				log.Printf("[autonomous] Queueing periodic wake-up impulse")
+				inboxMsg := memory.NewInboxMessageFromImpulse(impulse)
+				processInboxMessage(inboxMsg)
+			}
+		}
+	}()
 
-					log.Printf("[autonomous] Queueing periodic wake-up impulse")
-					inboxMsg := memory.NewInboxMessageFromImpulse(impulse)
-					processInboxMessage(inboxMsg)
-				}
+	// Watch bud.yaml and apply safe changes without a restart.
+	if configPath != "" {
+		watcher := config.NewWatcher(configPath, budCfg, func(old, next *config.BudConfig) {
+			if restart := old.ReloadableChanges(next); len(restart) > 0 {
+				log.Printf("[config] Changes to %s take effect after restart", strings.Join(restart, ", "))
 			}
-		}()
-	} else {
-		log.Println("[main] Autonomous mode disabled (set AUTONOMOUS_ENABLED=true to enable)")
+			autonomousMu.Lock()
+			autonomousCfg = next.Autonomous
+			autonomousMu.Unlock()
+			timezoneMu.Lock()
+			timezone = next.ParsedTimezone()
+			timezoneMu.Unlock()
+			thinkingBudget.Apply(budgetSettings(next.Budgets))
+			sessionTracker.SetPricing(priceTable(next.Pricing))
+			exec.ApplyConfig(next)
+			if calendarSense != nil {
+				calendarSense.SetTimezone(userTimezone())
+			}
+		})
+		watcher.Start()
+		defer watcher.Stop()
+		log.Printf("[config] Watching %s for changes", configPath)
 	}
 
 	// Activation decay is handled automatically by Engram.
@@ -1666,6 +1945,9 @@ func main() {
 	// Stop subsystems
 	close(stopChan)
 	exec.Stop()
+	if pluginLifecycle != nil {
+		pluginLifecycle.Stop(context.Background())
+	}
 	if discordEffector != nil {
 		discordEffector.StopAllTyping()
 		discordEffector.Stop()
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/vthunder/bud2/internal/executive"
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+const pluginsUsage = `usage: bud plugins <command> [args]
+
+Commands:
+  list                   show plugins with their version, source and lock status
+  pin <plugin> [commit]  hold the plugin's source at its locked commit (or check
+                         out and lock the given one); upgrade skips it
+  unpin <plugin>         let upgrade move the plugin's source again
+  upgrade [plugin]       move every unpinned source (or just the plugin's) to its
+                         newest commit, keeping the old one if a requires.plugins
+                         constraint breaks
+  rollback <plugin>      return the plugin's source to the commit before its last
+                         upgrade
+
+Plugins from one git source share a clone, so pin, upgrade and rollback act on
+every plugin in that source. A running bud picks up the change by hot reload.
+`
+
+// pluginsCLI implements the "bud plugins" subcommand.
+type pluginsCLI struct {
+	statePath string
+	lock      *plugins.Lock
+	sources   []executive.ManifestSource
+	out       io.Writer
+}
+
+// runPluginsCommand runs "bud plugins <args>" and returns the exit code.
+func runPluginsCommand(args []string) int {
+	godotenv.Load() //nolint:errcheck
+	log.SetFlags(0)
+	budCfg, _ := loadBudConfig()
+	statePath := resolveStatePath(budCfg)
+
+	lock, err := plugins.LoadLock(executive.PluginsLockPath(statePath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bud plugins: %v\n", err)
+		return 1
+	}
+	c := &pluginsCLI{
+		statePath: statePath,
+		lock:      lock,
+		sources:   executive.ManifestSources(statePath),
+		out:       os.Stdout,
+	}
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, pluginsUsage)
+		return 2
+	}
+	var cmdErr error
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "list" && len(rest) == 0:
+		cmdErr = c.list()
+	case cmd == "pin" && (len(rest) == 1 || len(rest) == 2):
+		commit := ""
+		if len(rest) == 2 {
+			commit = rest[1]
+		}
+		cmdErr = c.pin(rest[0], commit)
+	case cmd == "unpin" && len(rest) == 1:
+		cmdErr = c.unpin(rest[0])
+	case cmd == "upgrade" && len(rest) <= 1:
+		name := ""
+		if len(rest) == 1 {
+			name = rest[0]
+		}
+		cmdErr = c.upgrade(name)
+	case cmd == "rollback" && len(rest) == 1:
+		cmdErr = c.rollback(rest[0])
+	default:
+		fmt.Fprint(os.Stderr, pluginsUsage)
+		return 2
+	}
+	if cmdErr != nil {
+		fmt.Fprintf(os.Stderr, "bud plugins: %v\n", cmdErr)
+		return 1
+	}
+	return 0
+}
+
+// load loads the registry from the same dirs as the daemon.
+func (c *pluginsCLI) load() (*plugins.Registry, error) {
+	_, dirs := pluginDirs(c.statePath)
+	return plugins.LoadAll(dirs...)
+}
+
+// sourceOf returns the git source of the named plugin.
+func (c *pluginsCLI) sourceOf(reg *plugins.Registry, name string) (executive.ManifestSource, error) {
+	ext := reg.Get(name)
+	if ext == nil {
+		return executive.ManifestSource{}, fmt.Errorf("unknown plugin %q", name)
+	}
+	src, ok := executive.SourceFor(c.sources, ext.Dir)
+	if !ok {
+		return executive.ManifestSource{}, fmt.Errorf("%s is a local plugin (%s); only plugins from git sources in plugins.yaml are versioned", name, ext.Dir)
+	}
+	return src, nil
+}
+
+// lockedSource returns the lock entry for src, creating it from the clone's
+// current commit if the source was never locked.
+func (c *pluginsCLI) lockedSource(src executive.ManifestSource) (*plugins.LockedSource, error) {
+	if entry, ok := c.lock.Sources[src.Key]; ok {
+		return entry, nil
+	}
+	commit, err := executive.SourceCommit(src)
+	if err != nil {
+		return nil, err
+	}
+	entry := &plugins.LockedSource{Commit: commit}
+	c.lock.Sources[src.Key] = entry
+	return entry, nil
+}
+
+// relock records every loaded plugin in the lock, drops plugins that are
+// gone, and saves it.
+func (c *pluginsCLI) relock(reg *plugins.Registry) error {
+	present := make(map[string]bool)
+	for _, ext := range reg.All() {
+		present[ext.Manifest.Name] = true
+		source := ""
+		if src, ok := executive.SourceFor(c.sources, ext.Dir); ok {
+			source = src.Key
+		}
+		if _, err := c.lock.Record(ext, source); err != nil {
+			return fmt.Errorf("hashing %s: %w", ext.Manifest.Name, err)
+		}
+	}
+	excluded := reg.Excluded()
+	for name := range c.lock.Plugins {
+		if _, ok := excluded[name]; !present[name] && !ok {
+			delete(c.lock.Plugins, name)
+		}
+	}
+	return c.lock.Save()
+}
+
+func (c *pluginsCLI) list() error {
+	reg, err := c.load()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PLUGIN\tVERSION\tSOURCE\tCOMMIT\tLOCK")
+	for _, ext := range reg.All() {
+		source, commit := "local", "-"
+		if src, ok := executive.SourceFor(c.sources, ext.Dir); ok {
+			source = src.Key
+			if entry, ok := c.lock.Sources[src.Key]; ok {
+				commit = shortCommit(entry.Commit)
+				if entry.Pinned {
+					commit += " (pinned)"
+				}
+			}
+		}
+		status, err := c.lock.Check(ext)
+		if err != nil {
+			status = "error: " + err.Error()
+		}
+		version := ext.Manifest.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ext.Manifest.Name, version, source, commit, status)
+	}
+	w.Flush()
+
+	excluded := reg.Excluded()
+	names := make([]string, 0, len(excluded))
+	for name := range excluded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.out, "excluded: %v\n", excluded[name])
+	}
+	return nil
+}
+
+func (c *pluginsCLI) pin(name, commit string) error {
+	reg, err := c.load()
+	if err != nil {
+		return err
+	}
+	src, err := c.sourceOf(reg, name)
+	if err != nil {
+		return err
+	}
+	entry, err := c.lockedSource(src)
+	if err != nil {
+		return err
+	}
+	if commit != "" {
+		if err := executive.CheckoutSource(src, commit); err != nil {
+			return err
+		}
+		resolved, err := executive.SourceCommit(src)
+		if err != nil {
+			return err
+		}
+		if resolved != entry.Commit {
+			entry.Previous, entry.Commit = entry.Commit, resolved
+		}
+		if reg, err = c.load(); err != nil {
+			return err
+		}
+	}
+	entry.Pinned = true
+	if err := c.relock(reg); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Pinned %s at %s\n", src.Key, shortCommit(entry.Commit))
+	return nil
+}
+
+func (c *pluginsCLI) unpin(name string) error {
+	reg, err := c.load()
+	if err != nil {
+		return err
+	}
+	src, err := c.sourceOf(reg, name)
+	if err != nil {
+		return err
+	}
+	entry, err := c.lockedSource(src)
+	if err != nil {
+		return err
+	}
+	entry.Pinned = false
+	if err := c.lock.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "Unpinned %s\n", src.Key)
+	return nil
+}
+
+func (c *pluginsCLI) upgrade(name string) error {
+	reg, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	var targets []executive.ManifestSource
+	if name != "" {
+		src, err := c.sourceOf(reg, name)
+		if err != nil {
+			return err
+		}
+		if entry, ok := c.lock.Sources[src.Key]; ok && entry.Pinned {
+			return fmt.Errorf("%s is pinned; run `bud plugins unpin %s` first", src.Key, name)
+		}
+		targets = append(targets, src)
+	} else {
+		seen := make(map[string]bool)
+		for _, src := range c.sources {
+			if seen[src.Key] {
+				continue
+			}
+			seen[src.Key] = true
+			if entry, ok := c.lock.Sources[src.Key]; ok && entry.Pinned {
+				fmt.Fprintf(c.out, "%s: pinned, skipping\n", src.Key)
+				continue
+			}
+			if _, err := os.Stat(src.RepoDir); err != nil {
+				fmt.Fprintf(c.out, "%s: not cloned yet (start bud once), skipping\n", src.Key)
+				continue
+			}
+			targets = append(targets, src)
+		}
+		// Floating ClaWHub skills re-resolve to their latest version on the
+		// next start once their lock entry is gone.
+		if n := len(c.lock.Skills); n > 0 {
+			c.lock.Skills = make(map[string]*plugins.LockedSkill)
+			fmt.Fprintf(c.out, "%d ClaWHub skill(s) will update to their latest version on the next start\n", n)
+		}
+	}
+
+	var failed []string
+	for _, src := range targets {
+		entry, err := c.lockedSource(src)
+		if err != nil {
+			return err
+		}
+		old := entry.Commit
+		next, err := executive.UpgradeSource(src)
+		if err != nil {
+			fmt.Fprintf(c.out, "%s: %v\n", src.Key, err)
+			failed = append(failed, src.Key)
+			continue
+		}
+		if next == old {
+			fmt.Fprintf(c.out, "%s: up to date at %s\n", src.Key, shortCommit(old))
+			continue
+		}
+		// Keep the upgrade only if it does not break a version constraint
+		// that held before.
+		upgraded, err := c.load()
+		if err == nil {
+			err = newRequirementError(reg.Excluded(), upgraded.Excluded())
+		}
+		if err != nil {
+			if rbErr := executive.CheckoutSource(src, old); rbErr != nil {
+				return fmt.Errorf("%s: upgrade rejected (%v) and restoring %s failed: %w", src.Key, err, shortCommit(old), rbErr)
+			}
+			fmt.Fprintf(c.out, "%s: upgrade to %s rejected, kept %s: %v\n", src.Key, shortCommit(next), shortCommit(old), err)
+			failed = append(failed, src.Key)
+			continue
+		}
+		entry.Previous, entry.Commit = old, next
+		reg = upgraded
+		fmt.Fprintf(c.out, "%s: upgraded %s → %s\n", src.Key, shortCommit(old), shortCommit(next))
+	}
+
+	if err := c.relock(reg); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("not upgraded: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// newRequirementError returns the first unmet version constraint in after
+// that was not already unmet in before.
+func newRequirementError(before, after map[string]error) error {
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var reqErr *plugins.RequirementError
+		if _, ok := before[name]; !ok && errors.As(after[name], &reqErr) {
+			return reqErr
+		}
+	}
+	return nil
+}
+
+func (c *pluginsCLI) rollback(name string) error {
+	reg, err := c.load()
+	if err != nil {
+		return err
+	}
+	src, err := c.sourceOf(reg, name)
+	if err != nil {
+		return err
+	}
+	entry, ok := c.lock.Sources[src.Key]
+	if !ok || entry.Previous == "" {
+		return fmt.Errorf("no previous commit recorded for %s", src.Key)
+	}
+	if err := executive.CheckoutSource(src, entry.Previous); err != nil {
+		return err
+	}
+	entry.Commit, entry.Previous = entry.Previous, entry.Commit
+	if reg, err = c.load(); err != nil {
+		return err
+	}
+	if err := c.relock(reg); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "%s: rolled back %s → %s\n", src.Key, shortCommit(entry.Previous), shortCommit(entry.Commit))
+	return nil
+}
+
+// checkPluginLock records plugins missing from plugins.lock and warns about
+// plugins whose files no longer match their locked hash.
+func checkPluginLock(statePath string, reg *plugins.Registry) {
+	lock, err := plugins.LoadLock(executive.PluginsLockPath(statePath))
+	if err != nil {
+		log.Printf("[main] Warning: %v; not checking plugins against plugins.lock", err)
+		return
+	}
+	sources := executive.ManifestSources(statePath)
+	changed := false
+	for _, ext := range reg.All() {
+		status, err := lock.Check(ext)
+		switch {
+		case err != nil:
+			log.Printf("[main] Warning: hashing plugin %s: %v", ext.Manifest.Name, err)
+		case status == plugins.LockModified:
+			log.Printf("[main] Warning: plugin %s differs from plugins.lock; run `bud plugins list` to review", ext.Manifest.Name)
+		case status == plugins.LockMissing:
+			source := ""
+			if src, ok := executive.SourceFor(sources, ext.Dir); ok {
+				source = src.Key
+			}
+			if _, err := lock.Record(ext, source); err == nil {
+				changed = true
+			}
+		}
+	}
+	if changed {
+		if err := lock.Save(); err != nil {
+			log.Printf("[main] Warning: failed to write plugins.lock: %v", err)
+		}
+	}
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/executive"
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// gitRun runs git in dir and returns its trimmed output.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writePlugin writes a plugin manifest to root/name.
+func writePlugin(t *testing.T, root, name, version, requires string) {
+	t.Helper()
+	dir := filepath.Join(root, name, ".bud-plugin")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "name: " + name + "\ndescription: test\nversion: " + version + "\n"
+	if requires != "" {
+		manifest += "requires:\n  plugins: [\"" + requires + "\"]\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitNotes commits the notes plugin at version to origin and returns the
+// commit.
+func commitNotes(t *testing.T, origin, version string) string {
+	t.Helper()
+	writePlugin(t, origin, "notes", version, "")
+	gitRun(t, origin, "add", "-A")
+	gitRun(t, origin, "commit", "-qm", "notes "+version)
+	return gitRun(t, origin, "rev-parse", "HEAD")
+}
+
+// pluginsFixture is a state dir whose plugins.yaml lists git:owner/repo, cloned
+// from a local origin holding the notes plugin.
+type pluginsFixture struct {
+	state  string
+	origin string
+	clone  string
+}
+
+func newPluginsFixture(t *testing.T) *pluginsFixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	f := &pluginsFixture{
+		state:  t.TempDir(),
+		origin: t.TempDir(),
+		clone:  filepath.Join(cache, "bud", "plugins", "owner", "repo"),
+	}
+	if err := os.MkdirAll(filepath.Join(f.state, "system"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(f.state, "system", "plugins.yaml"), []byte("sources:\n  - git:owner/repo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, f.origin, "init", "-q")
+	return f
+}
+
+// cli returns a CLI reading plugins.lock afresh, as each bud plugins run does.
+func (f *pluginsFixture) cli(t *testing.T) (*pluginsCLI, *bytes.Buffer) {
+	t.Helper()
+	lock, err := plugins.LoadLock(executive.PluginsLockPath(f.state))
+	if err != nil {
+		t.Fatalf("LoadLock: %v", err)
+	}
+	out := &bytes.Buffer{}
+	return &pluginsCLI{
+		statePath: f.state,
+		lock:      lock,
+		sources:   executive.ManifestSources(f.state),
+		out:       out,
+	}, out
+}
+
+// head returns the commit checked out in the clone.
+func (f *pluginsFixture) head(t *testing.T) string {
+	t.Helper()
+	return gitRun(t, f.clone, "rev-parse", "HEAD")
+}
+
+func TestPluginsCommand_UpgradeRollbackPin(t *testing.T) {
+	f := newPluginsFixture(t)
+	v1 := commitNotes(t, f.origin, "1.0.0")
+	if err := os.MkdirAll(filepath.Dir(f.clone), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, f.origin, "clone", "-q", "file://"+f.origin, f.clone)
+	v2 := commitNotes(t, f.origin, "1.1.0")
+
+	c, out := f.cli(t)
+	if err := c.upgrade(""); err != nil {
+		t.Fatalf("upgrade: %v\n%s", err, out)
+	}
+	if got := f.head(t); got != v2 {
+		t.Errorf("clone at %s after upgrade, want %s", got, v2)
+	}
+	c, _ = f.cli(t)
+	if src := c.lock.Sources["owner/repo"]; src == nil || src.Commit != v2 || src.Previous != v1 {
+		t.Fatalf("locked source after upgrade = %+v", src)
+	}
+	if p := c.lock.Plugins["notes"]; p == nil || p.Version != "1.1.0" || p.Source != "owner/repo" {
+		t.Errorf("locked notes after upgrade = %+v", p)
+	}
+
+	if err := c.rollback("notes"); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if got := f.head(t); got != v1 {
+		t.Errorf("clone at %s after rollback, want %s", got, v1)
+	}
+	c, _ = f.cli(t)
+	if src := c.lock.Sources["owner/repo"]; src.Commit != v1 || src.Previous != v2 {
+		t.Errorf("locked source after rollback = %+v", src)
+	}
+	if p := c.lock.Plugins["notes"]; p.Version != "1.0.0" {
+		t.Errorf("locked notes version after rollback = %q", p.Version)
+	}
+
+	// A pinned source is skipped by a full upgrade and refused by a named one.
+	if err := c.pin("notes", ""); err != nil {
+		t.Fatalf("pin: %v", err)
+	}
+	c, out = f.cli(t)
+	if err := c.upgrade(""); err != nil {
+		t.Fatalf("upgrade with pin: %v", err)
+	}
+	if !strings.Contains(out.String(), "owner/repo: pinned, skipping") {
+		t.Errorf("upgrade output = %q", out)
+	}
+	if err := c.upgrade("notes"); err == nil || !strings.Contains(err.Error(), "pinned") {
+		t.Errorf("expected upgrading a pinned plugin to fail, got %v", err)
+	}
+	if got := f.head(t); got != v1 {
+		t.Errorf("pinned clone moved to %s", got)
+	}
+
+	// Pinning at a commit checks it out.
+	if err := c.pin("notes", v2); err != nil {
+		t.Fatalf("pin at commit: %v", err)
+	}
+	if got := f.head(t); got != v2 {
+		t.Errorf("clone at %s after pin, want %s", got, v2)
+	}
+	c, _ = f.cli(t)
+	if src := c.lock.Sources["owner/repo"]; !src.Pinned || src.Commit != v2 || src.Previous != v1 {
+		t.Errorf("locked source after pin = %+v", src)
+	}
+
+	if err := c.unpin("notes"); err != nil {
+		t.Fatalf("unpin: %v", err)
+	}
+	c, _ = f.cli(t)
+	if c.lock.Sources["owner/repo"].Pinned {
+		t.Error("source still pinned after unpin")
+	}
+}
+
+func TestPluginsCommand_UpgradeKeepsRequirements(t *testing.T) {
+	f := newPluginsFixture(t)
+	v1 := commitNotes(t, f.origin, "1.0.0")
+	if err := os.MkdirAll(filepath.Dir(f.clone), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, f.origin, "clone", "-q", "file://"+f.origin, f.clone)
+	commitNotes(t, f.origin, "2.0.0")
+	writePlugin(t, filepath.Join(f.state, "system", "plugins"), "journal", "1.0.0", "notes@^1")
+
+	c, out := f.cli(t)
+	if err := c.upgrade("notes"); err == nil || !strings.Contains(err.Error(), "not upgraded: owner/repo") {
+		t.Fatalf("expected the upgrade to be rejected, got %v", err)
+	}
+	if !strings.Contains(out.String(), "rejected, kept") {
+		t.Errorf("upgrade output = %q", out)
+	}
+	if got := f.head(t); got != v1 {
+		t.Errorf("clone at %s after a rejected upgrade, want %s", got, v1)
+	}
+	c, _ = f.cli(t)
+	if src := c.lock.Sources["owner/repo"]; src == nil || src.Commit != v1 || src.Previous != "" {
+		t.Errorf("locked source after a rejected upgrade = %+v", src)
+	}
+
+	if err := c.rollback("notes"); err == nil || !strings.Contains(err.Error(), "no previous commit") {
+		t.Errorf("expected rollback without a previous commit to fail, got %v", err)
+	}
+	if err := c.pin("journal", ""); err == nil || !strings.Contains(err.Error(), "local plugin") {
+		t.Errorf("expected pinning a local plugin to fail, got %v", err)
+	}
+}
@@ -40,6 +40,7 @@ func (r *extWorkflowRunner) RunWorkflow(ctx context.Context, name string, params
 		if err != nil {
 			return nil, fmt.Errorf("workflow %q: failed to load from %s: %w", name, yamlPath, err)
 		}
+		loaded.Plugin = ext.Manifest.Name
 		rx = loaded
 	}
 
@@ -54,6 +55,9 @@ func (r *extWorkflowRunner) RunWorkflow(ctx context.Context, name string, params
 	if result == nil {
 		return nil, nil
 	}
+	if !result.Success && result.Error != nil {
+		return nil, result.Error
+	}
 	return result.Output, nil
 }
 
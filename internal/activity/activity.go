@@ -2,6 +2,7 @@ package activity
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,6 +22,7 @@ const (
 	TypeAction       Type = "action"        // Action taken (message sent, etc.)
 	TypeDecision     Type = "decision"      // Explicit decision logged
 	TypeError        Type = "error"         // Something went wrong
+	TypePermissionDenied Type = "permission_denied" // Plugin attempted something it may not do
 )
 
 // Entry represents a single activity log entry
@@ -180,6 +182,52 @@ func (l *Log) LogError(summary string, err error, data map[string]any) error {
 	})
 }
 
+// LogPermissionDenied logs a plugin being denied a permission
+func (l *Log) LogPermissionDenied(plugin, permission, target, reason string) error {
+	return l.Log(Entry{
+		Type:      TypePermissionDenied,
+		Summary:   fmt.Sprintf("Plugin %s denied %s access to %s", plugin, permission, target),
+		Source:    "plugin:" + plugin,
+		Reasoning: reason,
+		Data: map[string]any{
+			"plugin":     plugin,
+			"permission": permission,
+			"target":     target,
+		},
+	})
+}
+
+// LogItemDropped logs a queued item the executive gave up on
+func (l *Log) LogItemDropped(itemID, itemType, source string, deliveries int, reason string) error {
+	return l.Log(Entry{
+		Type:      TypeError,
+		Summary:   fmt.Sprintf("Dropped %s item %s: %s", itemType, itemID, reason),
+		Source:    source,
+		Reasoning: reason,
+		Data: map[string]any{
+			"item_id":      itemID,
+			"item_type":    itemType,
+			"redeliveries": deliveries,
+		},
+	})
+}
+
+// LogMCPToolDenied logs an MCP session calling a tool its token does not allow
+func (l *Log) LogMCPToolDenied(agentID, tool string) error {
+	if agentID == "" {
+		agentID = "unknown"
+	}
+	return l.Log(Entry{
+		Type:    TypePermissionDenied,
+		Summary: fmt.Sprintf("Agent %s denied MCP tool %s", agentID, tool),
+		Source:  "mcp:" + agentID,
+		Data: map[string]any{
+			"agent_id": agentID,
+			"tool":     tool,
+		},
+	})
+}
+
 // Query methods
 
 // Recent returns the last n entries
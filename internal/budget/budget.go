@@ -2,6 +2,8 @@ package budget
 
 import (
 	"fmt"
+	"sync"
+	"time"
 )
 
 // ThinkingBudget manages limits on autonomous Claude usage
@@ -10,6 +12,60 @@ type ThinkingBudget struct {
 
 	// Limits
 	DailyOutputTokens int // Max output tokens per 24h (default 1_000_000)
+
+	// DailyCostUSD and MonthlyCostUSD cap priced spend per calendar day and
+	// month across all roles. Zero means no cap.
+	DailyCostUSD   float64
+	MonthlyCostUSD float64
+
+	// Limits are additional per-role, per-provider, rolling-window budgets.
+	Limits []Limit
+
+	// SoftLimitFraction is the default fraction of a limit at which a
+	// soft-limit warning fires (default 0.8). Limits may override it.
+	SoftLimitFraction float64
+
+	// ExecutiveProvider is the provider checked by CanDoAutonomousWork.
+	ExecutiveProvider string
+
+	// mu guards the limits above once the budget is in use; see Apply.
+	mu     sync.Mutex
+	warned map[string]bool // limit key -> warning already sent
+}
+
+// Limit caps token usage or cost within a rolling window. Role and Provider
+// scope the limit; empty values match every role or provider. Zero caps are
+// unlimited.
+type Limit struct {
+	Name     string
+	Role     string
+	Provider string
+	Window   time.Duration // rolling window; zero means 24h
+
+	InputTokens  int
+	OutputTokens int
+	CacheTokens  int // cache creation + cache read
+	TotalTokens  int // input + output + cache
+	CostUSD      float64
+
+	// SoftLimit overrides ThinkingBudget.SoftLimitFraction when non-zero.
+	SoftLimit float64
+}
+
+// SoftLimitWarning describes a limit that crossed its soft threshold.
+type SoftLimitWarning struct {
+	Limit    string  `json:"limit"`
+	Role     string  `json:"role,omitempty"`
+	Provider string  `json:"provider,omitempty"`
+	Metric   string  `json:"metric"`
+	Used     float64 `json:"used"`
+	Cap      float64 `json:"cap"`
+	Fraction float64 `json:"fraction"`
+}
+
+func (w SoftLimitWarning) String() string {
+	m := limitMetric{name: w.Metric, used: w.Used, cap: w.Cap}
+	return fmt.Sprintf("%s at %.0f%% of %s budget (%s)", w.Limit, w.Fraction*100, w.Metric, m.ratio())
 }
 
 // NewThinkingBudget creates a new budget manager
@@ -17,12 +73,48 @@ func NewThinkingBudget(tracker *SessionTracker) *ThinkingBudget {
 	return &ThinkingBudget{
 		tracker:           tracker,
 		DailyOutputTokens: 1_000_000, // 1M output tokens/day default
+		SoftLimitFraction: 0.8,
+		warned:            make(map[string]bool),
 	}
 }
 
+// Settings are the limits of a ThinkingBudget that can change at runtime.
+type Settings struct {
+	DailyOutputTokens int
+	DailyCostUSD      float64
+	MonthlyCostUSD    float64
+	SoftLimitFraction float64
+	Limits            []Limit
+}
+
+// Apply replaces the budget's limits, e.g. after a config reload. Pending
+// soft-limit warnings are reset so changed limits warn afresh.
+func (b *ThinkingBudget) Apply(s Settings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.DailyOutputTokens = s.DailyOutputTokens
+	b.DailyCostUSD = s.DailyCostUSD
+	b.MonthlyCostUSD = s.MonthlyCostUSD
+	b.SoftLimitFraction = s.SoftLimitFraction
+	b.Limits = s.Limits
+	b.warned = make(map[string]bool)
+}
+
 // CanDoAutonomousWork checks if autonomous work is allowed
 func (b *ThinkingBudget) CanDoAutonomousWork() (bool, string) {
-	if b.tracker != nil {
+	return b.Check(RoleExecutive, b.ExecutiveProvider)
+}
+
+// Check reports whether the given role may spend tokens on provider. The
+// legacy daily output limit applies to every role.
+func (b *ThinkingBudget) Check(role, provider string) (bool, string) {
+	if b.tracker == nil {
+		return true, ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.DailyOutputTokens > 0 {
 		usage := b.tracker.TodayTokenUsage()
 		if usage.OutputTokens >= b.DailyOutputTokens {
 			return false, fmt.Sprintf("daily output token budget exceeded (%d/%d)",
@@ -30,5 +122,215 @@ func (b *ThinkingBudget) CanDoAutonomousWork() (bool, string) {
 		}
 	}
 
+	for _, c := range b.calendarCostCaps() {
+		if c.used >= c.cap {
+			return false, fmt.Sprintf("%s cost budget exceeded (%s)", c.period, c.ratio())
+		}
+	}
+
+	now := time.Now()
+	for _, l := range b.Limits {
+		if !l.applies(role, provider) {
+			continue
+		}
+		usage := b.tracker.UsageSince(now.Add(-l.window()), UsageFilter{Role: l.Role, Provider: l.Provider})
+		for _, m := range l.metrics(usage) {
+			if m.cap > 0 && m.used >= m.cap {
+				return false, fmt.Sprintf("%s %s budget exceeded (%s in %s)",
+					l.Name, m.name, m.ratio(), l.window())
+			}
+		}
+	}
+
 	return true, ""
 }
+
+// OnSoftLimit registers fn to be called when recorded usage pushes a limit
+// past its soft threshold. Each limit warns once until its usage falls back
+// below the threshold.
+func (b *ThinkingBudget) OnSoftLimit(fn func(SoftLimitWarning)) {
+	if b.tracker == nil {
+		return
+	}
+	b.tracker.SetOnRecord(func(rec UsageRecord) {
+		for _, w := range b.softLimitWarnings(rec) {
+			fn(w)
+		}
+	})
+}
+
+// softLimitWarnings returns warnings for limits affected by rec that newly
+// crossed their soft threshold.
+func (b *ThinkingBudget) softLimitWarnings(rec UsageRecord) []SoftLimitWarning {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.warned == nil {
+		b.warned = make(map[string]bool)
+	}
+
+	var out []SoftLimitWarning
+	check := func(key string, l Limit, m limitMetric) {
+		frac := l.SoftLimit
+		if frac == 0 {
+			frac = b.SoftLimitFraction
+		}
+		if m.cap <= 0 || frac <= 0 {
+			return
+		}
+		over := m.used >= frac*m.cap
+		if !over {
+			delete(b.warned, key)
+			return
+		}
+		if b.warned[key] {
+			return
+		}
+		b.warned[key] = true
+		out = append(out, SoftLimitWarning{
+			Limit:    l.Name,
+			Role:     l.Role,
+			Provider: l.Provider,
+			Metric:   m.name,
+			Used:     m.used,
+			Cap:      m.cap,
+			Fraction: m.used / m.cap,
+		})
+	}
+
+	if b.DailyOutputTokens > 0 {
+		daily := Limit{Name: "daily"}
+		used := b.tracker.TodayTokenUsage().OutputTokens
+		check("daily/output", daily, limitMetric{"output", float64(used), float64(b.DailyOutputTokens)})
+	}
+	for _, c := range b.calendarCostCaps() {
+		check(c.period+"/cost", Limit{Name: c.period}, c.limitMetric)
+	}
+
+	now := time.Now()
+	for i, l := range b.Limits {
+		if !l.applies(rec.Role, rec.Provider) {
+			continue
+		}
+		usage := b.tracker.UsageSince(now.Add(-l.window()), UsageFilter{Role: l.Role, Provider: l.Provider})
+		for _, m := range l.metrics(usage) {
+			check(fmt.Sprintf("%d/%s", i, m.name), l, m)
+		}
+	}
+	return out
+}
+
+func (l Limit) window() time.Duration {
+	if l.Window <= 0 {
+		return 24 * time.Hour
+	}
+	return l.Window
+}
+
+func (l Limit) applies(role, provider string) bool {
+	return (l.Role == "" || l.Role == role) && (l.Provider == "" || l.Provider == provider)
+}
+
+type limitMetric struct {
+	name string
+	used float64
+	cap  float64
+}
+
+// ratio formats used/cap as dollars for the cost metric and tokens otherwise.
+func (m limitMetric) ratio() string {
+	if m.name == "cost" {
+		return fmt.Sprintf("$%.2f/$%.2f", m.used, m.cap)
+	}
+	return fmt.Sprintf("%d/%d tokens", int(m.used), int(m.cap))
+}
+
+func (l Limit) metrics(u TokenUsage) []limitMetric {
+	cache := u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return []limitMetric{
+		{"input", float64(u.InputTokens), float64(l.InputTokens)},
+		{"output", float64(u.OutputTokens), float64(l.OutputTokens)},
+		{"cache", float64(cache), float64(l.CacheTokens)},
+		{"total", float64(u.InputTokens + u.OutputTokens + cache), float64(l.TotalTokens)},
+		{"cost", u.CostUSD, l.CostUSD},
+	}
+}
+
+// calendarCost is spend against a calendar-period dollar cap.
+type calendarCost struct {
+	period string // "daily" or "monthly"
+	limitMetric
+}
+
+// calendarCostCaps returns the configured daily and monthly dollar caps with
+// current spend.
+func (b *ThinkingBudget) calendarCostCaps() []calendarCost {
+	var out []calendarCost
+	if b.DailyCostUSD > 0 {
+		out = append(out, calendarCost{"daily", limitMetric{"cost", b.tracker.TodayCost().TotalUSD, b.DailyCostUSD}})
+	}
+	if b.MonthlyCostUSD > 0 {
+		out = append(out, calendarCost{"monthly", limitMetric{"cost", b.tracker.MonthCost().TotalUSD, b.MonthlyCostUSD}})
+	}
+	return out
+}
+
+// CostReport summarises spend against the dollar caps.
+type CostReport struct {
+	Today         CostSummary `json:"today"`
+	Month         CostSummary `json:"month"`
+	DailyCapUSD   float64     `json:"daily_cap_usd,omitempty"`
+	MonthlyCapUSD float64     `json:"monthly_cap_usd,omitempty"`
+	Days          []DayCost   `json:"days,omitempty"`
+}
+
+// CostReport returns today's and this month's spend, with a per-day
+// breakdown of the last days days.
+func (b *ThinkingBudget) CostReport(days int) CostReport {
+	b.mu.Lock()
+	r := CostReport{DailyCapUSD: b.DailyCostUSD, MonthlyCapUSD: b.MonthlyCostUSD}
+	b.mu.Unlock()
+	if b.tracker == nil {
+		return r
+	}
+	r.Today = b.tracker.TodayCost()
+	r.Month = b.tracker.MonthCost()
+	if days > 0 {
+		r.Days = b.tracker.DailyCosts(days)
+	}
+	return r
+}
+
+// RoleBudget binds a ThinkingBudget to one role and provider, for callers
+// that gate and report their own model calls.
+type RoleBudget struct {
+	budget   *ThinkingBudget
+	role     string
+	provider string
+}
+
+// ForRole returns a RoleBudget for role on provider.
+func (b *ThinkingBudget) ForRole(role, provider string) *RoleBudget {
+	return &RoleBudget{budget: b, role: role, provider: provider}
+}
+
+// Allow returns an error when the role is over budget.
+func (r *RoleBudget) Allow() error {
+	if ok, reason := r.budget.Check(r.role, r.provider); !ok {
+		return fmt.Errorf("%s budget: %s", r.role, reason)
+	}
+	return nil
+}
+
+// Record adds the tokens used by one call to the tracker.
+func (r *RoleBudget) Record(model string, inputTokens, outputTokens int) {
+	if r.budget.tracker == nil {
+		return
+	}
+	r.budget.tracker.RecordUsage(UsageRecord{
+		Role:         r.role,
+		Provider:     r.provider,
+		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+	})
+}
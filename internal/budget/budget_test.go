@@ -1,6 +1,7 @@
 package budget
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -101,3 +102,229 @@ func TestThinkingBudget(t *testing.T) {
 		t.Errorf("Expected 2 sessions, got %d", usage.SessionCount)
 	}
 }
+
+func TestThinkingBudget_RoleAndProviderLimits(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	b := NewThinkingBudget(tracker)
+	b.Limits = []Limit{
+		{Name: "agents-hourly", Role: RoleAgent, Window: time.Hour, TotalTokens: 1000},
+		{Name: "openai-weekly", Provider: "openai-compatible", Window: 7 * 24 * time.Hour, InputTokens: 500},
+	}
+
+	tracker.RecordUsage(UsageRecord{Role: RoleAgent, Provider: "claude-code", InputTokens: 400, OutputTokens: 200, CacheReadInputTokens: 400})
+	if ok, reason := b.Check(RoleAgent, "claude-code"); ok {
+		t.Error("expected agent role to be blocked by hourly total limit")
+	} else {
+		t.Logf("blocked: %s", reason)
+	}
+	if ok, reason := b.Check(RoleExecutive, "claude-code"); !ok {
+		t.Errorf("expected executive unaffected by agent limit, got: %s", reason)
+	}
+
+	tracker.RecordUsage(UsageRecord{Role: RoleReflex, Provider: "openai-compatible", InputTokens: 600})
+	if ok, _ := b.Check(RoleExecutive, "openai-compatible"); ok {
+		t.Error("expected openai provider limit to apply to every role")
+	}
+	if ok, _ := b.Check(RoleExecutive, "claude-code"); !ok {
+		t.Error("expected other providers unaffected by openai limit")
+	}
+}
+
+func TestThinkingBudget_RollingWindow(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	b := NewThinkingBudget(tracker)
+	b.Limits = []Limit{{Name: "hourly", Window: time.Hour, OutputTokens: 100}}
+
+	tracker.RecordUsage(UsageRecord{Time: time.Now().Add(-2 * time.Hour), Role: RoleExecutive, OutputTokens: 500})
+	if ok, reason := b.Check(RoleExecutive, ""); !ok {
+		t.Errorf("expected usage outside window to be ignored, got: %s", reason)
+	}
+
+	// Records survive a restart.
+	tracker.RecordUsage(UsageRecord{Role: RoleExecutive, OutputTokens: 100})
+	b = NewThinkingBudget(NewSessionTracker(tracker.statePath))
+	b.Limits = []Limit{{Name: "hourly", Window: time.Hour, OutputTokens: 100}}
+	if ok, _ := b.Check(RoleExecutive, ""); ok {
+		t.Error("expected persisted usage to count after reload")
+	}
+}
+
+func TestThinkingBudget_SoftLimitWarnsOnce(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	b := NewThinkingBudget(tracker)
+	b.Limits = []Limit{{Name: "reflex-daily", Role: RoleReflex, OutputTokens: 100, SoftLimit: 0.5}}
+
+	var warnings []SoftLimitWarning
+	b.OnSoftLimit(func(w SoftLimitWarning) { warnings = append(warnings, w) })
+
+	tracker.RecordUsage(UsageRecord{Role: RoleReflex, OutputTokens: 40})
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warning below soft limit, got %v", warnings)
+	}
+	tracker.RecordUsage(UsageRecord{Role: RoleReflex, OutputTokens: 20})
+	tracker.RecordUsage(UsageRecord{Role: RoleReflex, OutputTokens: 10})
+	if len(warnings) != 1 || warnings[0].Limit != "reflex-daily" || warnings[0].Used != 60 {
+		t.Fatalf("expected a single warning at 60/100, got %v", warnings)
+	}
+	if ok, _ := b.Check(RoleReflex, ""); !ok {
+		t.Error("expected soft limit not to block")
+	}
+}
+
+func TestThinkingBudget_Apply(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	b := NewThinkingBudget(tracker)
+	tracker.RecordUsage(UsageRecord{Role: RoleAgent, OutputTokens: 300})
+	if ok, reason := b.Check(RoleAgent, ""); !ok {
+		t.Fatalf("expected agent allowed before reload, got: %s", reason)
+	}
+
+	b.Apply(Settings{
+		DailyOutputTokens: 1_000_000,
+		SoftLimitFraction: 0.8,
+		Limits:            []Limit{{Name: "agents", Role: RoleAgent, OutputTokens: 200}},
+	})
+	if ok, _ := b.Check(RoleAgent, ""); ok {
+		t.Error("expected applied limit to block agent role")
+	}
+
+	b.Apply(Settings{DailyOutputTokens: 1_000_000})
+	if ok, reason := b.Check(RoleAgent, ""); !ok {
+		t.Errorf("expected removed limit to stop blocking, got: %s", reason)
+	}
+}
+
+func TestSessionTracker_CostAccounting(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	tracker.SetPricing(PriceTable{
+		"claude-code/claude-sonnet-4-5": {Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75},
+		"ollama/*":                      {},
+	})
+
+	tracker.StartSession("s1", "thread-1")
+	tracker.CompleteSession("s1")
+	tracker.RecordUsage(UsageRecord{
+		Role: RoleExecutive, Provider: "claude-code", Model: "claude-sonnet-4-5", SessionID: "s1",
+		InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadInputTokens: 2_000_000, CacheCreationInputTokens: 400_000,
+	})
+	tracker.RecordUsage(UsageRecord{Role: RoleReflex, Provider: "ollama", Model: "qwen2.5:7b", InputTokens: 5000})
+	tracker.RecordUsage(UsageRecord{Role: RoleAgent, Provider: "openai-compatible", Model: "unknown", OutputTokens: 10})
+
+	// 3 + 1.5 + 0.6 + 1.5
+	const want = 6.6
+	today := tracker.TodayCost()
+	if diff := today.TotalUSD - want; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("expected $%.2f today, got $%.4f", want, today.TotalUSD)
+	}
+	if today.ByRole[RoleExecutive] != today.TotalUSD || today.Unpriced != 1 {
+		t.Errorf("unexpected breakdown %+v", today)
+	}
+	if month := tracker.MonthCost(); month.TotalUSD != today.TotalUSD {
+		t.Errorf("expected month to include today, got %+v", month)
+	}
+	if s := tracker.completed[0]; s.CostUSD != today.TotalUSD {
+		t.Errorf("expected session cost %.2f, got %.2f", today.TotalUSD, s.CostUSD)
+	}
+
+	// The ledger survives a restart.
+	reloaded := NewSessionTracker(tracker.statePath)
+	if days := reloaded.DailyCosts(3); len(days) != 3 || days[2].TotalUSD != today.TotalUSD {
+		t.Errorf("expected persisted cost for today, got %+v", days)
+	}
+}
+
+func TestThinkingBudget_CostCaps(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	tracker.SetPricing(PriceTable{"openai-compatible/*": {Input: 1, Output: 2}})
+	b := NewThinkingBudget(tracker)
+	b.DailyCostUSD = 5
+	b.Limits = []Limit{{Name: "agents", Role: RoleAgent, CostUSD: 1}}
+
+	var warnings []SoftLimitWarning
+	b.OnSoftLimit(func(w SoftLimitWarning) { warnings = append(warnings, w) })
+
+	tracker.RecordUsage(UsageRecord{Role: RoleAgent, Provider: "openai-compatible", OutputTokens: 500_000})
+	if ok, reason := b.Check(RoleAgent, "openai-compatible"); ok {
+		t.Error("expected agent cost limit to block")
+	} else if !strings.Contains(reason, "$1.00/$1.00") {
+		t.Errorf("expected dollar amounts in reason, got %q", reason)
+	}
+	if len(warnings) != 1 || warnings[0].Metric != "cost" {
+		t.Fatalf("expected a cost soft-limit warning, got %v", warnings)
+	}
+
+	tracker.RecordUsage(UsageRecord{Role: RoleExecutive, Provider: "openai-compatible", InputTokens: 4_000_000})
+	if ok, reason := b.CanDoAutonomousWork(); ok {
+		t.Error("expected daily dollar cap to block executive work")
+	} else {
+		t.Logf("blocked: %s", reason)
+	}
+	if r := b.CostReport(2); r.Today.TotalUSD != 5 || r.DailyCapUSD != 5 || len(r.Days) != 2 {
+		t.Errorf("unexpected cost report %+v", r)
+	}
+}
+
+func TestSessionTracker_ArchivesRolledOverDays(t *testing.T) {
+	tracker := NewSessionTracker(t.TempDir())
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	tracker.StartSession("s1", "thread-a")
+	tracker.CompleteSession("s1")
+	tracker.SetSessionUsage("s1", 100, 50, 0, 300, 4)
+	// Pretend the session ran yesterday.
+	tracker.today = yesterday.Format("2006-01-02")
+	tracker.RecordUsage(UsageRecord{Time: yesterday, Role: RoleExecutive, SessionID: "s1", InputTokens: 100, OutputTokens: 50, CacheReadInputTokens: 300})
+	tracker.RecordUsage(UsageRecord{Time: yesterday, Role: RoleReflex, Provider: "ollama", InputTokens: 10, OutputTokens: 5})
+
+	// Rollover archives yesterday and starts today empty.
+	if usage := tracker.TodayTokenUsage(); usage.SessionCount != 0 {
+		t.Fatalf("expected today to start empty, got %+v", usage)
+	}
+	tracker.StartSession("s2", "thread-b")
+	tracker.CompleteSession("s2")
+	tracker.SetSessionUsage("s2", 200, 20, 0, 0, 1)
+	tracker.RecordUsage(UsageRecord{Role: RoleExecutive, SessionID: "s2", InputTokens: 200, OutputTokens: 20})
+
+	// A fresh tracker sees both days.
+	tracker = NewSessionTracker(tracker.statePath)
+	days, err := tracker.UsageHistory(yesterday, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days of history, got %+v", days)
+	}
+	y := days[0]
+	if y.Total.OutputTokens != 55 || y.ByRole[RoleExecutive].TotalTurns != 4 || y.ByThread["thread-a"].SessionCount != 1 {
+		t.Errorf("unexpected archived day %+v", y)
+	}
+
+	byDay, err := tracker.QueryUsage(UsageQuery{From: yesterday, Role: RoleExecutive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byDay) != 2 || byDay[0].CacheHitRate != 0.75 || byDay[1].OutputTokens != 20 {
+		t.Errorf("unexpected per-day executive usage %+v", byDay)
+	}
+
+	byRole, err := tracker.QueryUsage(UsageQuery{From: yesterday, GroupBy: GroupByRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byRole) != 2 || byRole[0].Key != RoleExecutive || byRole[0].OutputTokens != 70 || byRole[1].Key != RoleReflex {
+		t.Errorf("unexpected per-role usage %+v", byRole)
+	}
+
+	byThread, err := tracker.QueryUsage(UsageQuery{From: yesterday, GroupBy: GroupByThread})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byThread) != 2 || byThread[0].Key != "thread-a" || byThread[1].Key != "thread-b" {
+		t.Errorf("unexpected per-thread usage %+v", byThread)
+	}
+	t.Logf("\n%s", RenderUsageChart(byDay))
+
+	if _, err := tracker.QueryUsage(UsageQuery{GroupBy: "week"}); err == nil {
+		t.Error("expected unknown group_by to fail")
+	}
+}
@@ -0,0 +1,210 @@
+package budget
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// costRetentionDays bounds how many days of cost history are kept.
+const costRetentionDays = 400
+
+// Price is a model's price in USD per million tokens.
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// Cost returns the USD cost of a usage record at this price.
+func (p Price) Cost(rec UsageRecord) float64 {
+	return (float64(rec.InputTokens)*p.Input +
+		float64(rec.OutputTokens)*p.Output +
+		float64(rec.CacheReadInputTokens)*p.CacheRead +
+		float64(rec.CacheCreationInputTokens)*p.CacheWrite) / 1_000_000
+}
+
+// PriceTable maps "<provider>/<model>" to a price. A "<provider>/*" entry
+// prices every model of that provider without its own entry.
+type PriceTable map[string]Price
+
+// Lookup returns the price for provider and model.
+func (t PriceTable) Lookup(provider, model string) (Price, bool) {
+	if p, ok := t[provider+"/"+model]; ok {
+		return p, true
+	}
+	p, ok := t[provider+"/*"]
+	return p, ok
+}
+
+// CostSummary aggregates spend over a period.
+type CostSummary struct {
+	TotalUSD   float64            `json:"total_usd"`
+	ByRole     map[string]float64 `json:"by_role,omitempty"`
+	ByProvider map[string]float64 `json:"by_provider,omitempty"`
+	// Unpriced counts usage records with no matching price.
+	Unpriced int `json:"unpriced,omitempty"`
+}
+
+func (s *CostSummary) add(o CostSummary) {
+	s.TotalUSD += o.TotalUSD
+	s.Unpriced += o.Unpriced
+	for k, v := range o.ByRole {
+		if s.ByRole == nil {
+			s.ByRole = make(map[string]float64)
+		}
+		s.ByRole[k] += v
+	}
+	for k, v := range o.ByProvider {
+		if s.ByProvider == nil {
+			s.ByProvider = make(map[string]float64)
+		}
+		s.ByProvider[k] += v
+	}
+}
+
+// DayCost is one day's spend.
+type DayCost struct {
+	Date string `json:"date"`
+	CostSummary
+}
+
+// SetPricing sets the price table used to cost new usage records.
+func (t *SessionTracker) SetPricing(prices PriceTable) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices = prices
+}
+
+// priceLocked fills in rec.CostUSD from the price table and reports whether
+// a price was found.
+func (t *SessionTracker) priceLocked(rec *UsageRecord) bool {
+	if rec.CostUSD != 0 {
+		return true
+	}
+	p, ok := t.prices.Lookup(rec.Provider, rec.Model)
+	if !ok {
+		return false
+	}
+	rec.CostUSD = p.Cost(*rec)
+	return true
+}
+
+// addCostLocked charges rec to its day in the cost ledger and, if it belongs
+// to a tracked session, to that session.
+func (t *SessionTracker) addCostLocked(rec UsageRecord, priced bool) {
+	day := rec.Time.Format("2006-01-02")
+	if t.costs == nil {
+		t.costs = make(map[string]*CostSummary)
+	}
+	sum, ok := t.costs[day]
+	if !ok {
+		sum = &CostSummary{}
+		t.costs[day] = sum
+	}
+	if !priced {
+		sum.Unpriced++
+	} else {
+		sum.add(CostSummary{
+			TotalUSD:   rec.CostUSD,
+			ByRole:     map[string]float64{rec.Role: rec.CostUSD},
+			ByProvider: map[string]float64{rec.Provider: rec.CostUSD},
+		})
+	}
+	t.pruneCostsLocked()
+	t.saveCosts()
+
+	if rec.SessionID == "" || rec.CostUSD == 0 {
+		return
+	}
+	for i := len(t.completed) - 1; i >= 0; i-- {
+		if t.completed[i].ID == rec.SessionID {
+			t.completed[i].CostUSD += rec.CostUSD
+			t.save()
+			return
+		}
+	}
+}
+
+// TodayCost returns today's spend.
+func (t *SessionTracker) TodayCost() CostSummary {
+	return t.costSince(time.Now().Format("2006-01-02"))
+}
+
+// MonthCost returns spend since the start of the current calendar month.
+func (t *SessionTracker) MonthCost() CostSummary {
+	return t.costSince(time.Now().Format("2006-01") + "-01")
+}
+
+func (t *SessionTracker) costSince(firstDay string) CostSummary {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var total CostSummary
+	for day, sum := range t.costs {
+		if day >= firstDay {
+			total.add(*sum)
+		}
+	}
+	return total
+}
+
+// DailyCosts returns spend for each of the last n days, oldest first. Days
+// without usage are included with zero cost.
+func (t *SessionTracker) DailyCosts(n int) []DayCost {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := time.Now()
+	out := make([]DayCost, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i).Format("2006-01-02")
+		dc := DayCost{Date: day}
+		if sum, ok := t.costs[day]; ok {
+			dc.CostSummary = *sum
+		}
+		out = append(out, dc)
+	}
+	return out
+}
+
+func (t *SessionTracker) pruneCostsLocked() {
+	if len(t.costs) <= costRetentionDays {
+		return
+	}
+	days := make([]string, 0, len(t.costs))
+	for day := range t.costs {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days[:len(days)-costRetentionDays] {
+		delete(t.costs, day)
+	}
+}
+
+func (t *SessionTracker) costsPath() string {
+	return filepath.Join(t.statePath, "system", "costs.json")
+}
+
+func (t *SessionTracker) loadCosts() {
+	data, err := os.ReadFile(t.costsPath())
+	if err != nil {
+		return // File doesn't exist yet
+	}
+	var costs map[string]*CostSummary
+	if err := json.Unmarshal(data, &costs); err != nil {
+		return
+	}
+	t.costs = costs
+}
+
+func (t *SessionTracker) saveCosts() {
+	data, err := json.MarshalIndent(t.costs, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(t.costsPath(), data, 0644)
+}
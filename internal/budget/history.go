@@ -0,0 +1,316 @@
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// DayUsage is one day's usage, archived to system/usage-history/<date>.json
+// when the tracker rolls over to a new day.
+type DayUsage struct {
+	Date  string     `json:"date"`
+	Total TokenUsage `json:"total"`
+	// ByRole aggregates usage records per model role. Days recorded before
+	// role tracking only have an executive entry built from sessions.
+	ByRole map[string]TokenUsage `json:"by_role,omitempty"`
+	// ByThread aggregates executive sessions per focus thread.
+	ByThread        map[string]TokenUsage `json:"by_thread,omitempty"`
+	ThinkingMinutes float64               `json:"thinking_minutes"`
+}
+
+// CacheHitRate returns the fraction of input tokens served from cache.
+func (u TokenUsage) CacheHitRate() float64 {
+	total := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheReadInputTokens) / float64(total)
+}
+
+func (u *TokenUsage) add(o TokenUsage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationInputTokens += o.CacheCreationInputTokens
+	u.CacheReadInputTokens += o.CacheReadInputTokens
+	u.SessionCount += o.SessionCount
+	u.TotalTurns += o.TotalTurns
+	u.CostUSD += o.CostUSD
+}
+
+// summarizeDayLocked builds a DayUsage from a day's completed sessions and
+// the usage records that fall on that day.
+func (t *SessionTracker) summarizeDayLocked(date string, sessions []*Session) DayUsage {
+	day := DayUsage{
+		Date:     date,
+		ByRole:   make(map[string]TokenUsage),
+		ByThread: make(map[string]TokenUsage),
+	}
+
+	var sessionTotal TokenUsage
+	for _, s := range sessions {
+		u := TokenUsage{
+			InputTokens:              s.InputTokens,
+			OutputTokens:             s.OutputTokens,
+			CacheCreationInputTokens: s.CacheCreationInputTokens,
+			CacheReadInputTokens:     s.CacheReadInputTokens,
+			SessionCount:             1,
+			TotalTurns:               s.NumTurns,
+			CostUSD:                  s.CostUSD,
+		}
+		sessionTotal.add(u)
+		thread := day.ByThread[s.ThreadID]
+		thread.add(u)
+		day.ByThread[s.ThreadID] = thread
+		day.ThinkingMinutes += s.DurationSec / 60
+	}
+
+	for _, r := range t.records {
+		if r.Time.Format(dayFormat) != date {
+			continue
+		}
+		role := day.ByRole[r.Role]
+		role.add(TokenUsage{
+			InputTokens:              r.InputTokens,
+			OutputTokens:             r.OutputTokens,
+			CacheCreationInputTokens: r.CacheCreationInputTokens,
+			CacheReadInputTokens:     r.CacheReadInputTokens,
+			SessionCount:             1,
+			CostUSD:                  r.CostUSD,
+		})
+		day.ByRole[r.Role] = role
+	}
+	if exec, ok := day.ByRole[RoleExecutive]; ok {
+		// Turns are only reported per session
+		exec.TotalTurns = sessionTotal.TotalTurns
+		day.ByRole[RoleExecutive] = exec
+	} else if len(sessions) > 0 {
+		day.ByRole[RoleExecutive] = sessionTotal
+	}
+
+	for _, u := range day.ByRole {
+		day.Total.add(u)
+	}
+	return day
+}
+
+func (t *SessionTracker) historyDir() string {
+	return filepath.Join(t.statePath, "system", "usage-history")
+}
+
+// archiveDayLocked writes a finished day's summary to the history directory.
+func (t *SessionTracker) archiveDayLocked(date string, sessions []*Session) {
+	if date == "" || (len(sessions) == 0 && !t.hasRecordsOn(date)) {
+		return
+	}
+	day := t.summarizeDayLocked(date, sessions)
+	data, err := json.Marshal(day)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(t.historyDir(), 0755); err != nil {
+		log.Printf("[budget] Warning: failed to archive usage for %s: %v", date, err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(t.historyDir(), date+".json"), data, 0644); err != nil {
+		log.Printf("[budget] Warning: failed to archive usage for %s: %v", date, err)
+	}
+}
+
+func (t *SessionTracker) hasRecordsOn(date string) bool {
+	for _, r := range t.records {
+		if r.Time.Format(dayFormat) == date {
+			return true
+		}
+	}
+	return false
+}
+
+// UsageHistory returns per-day usage for the calendar days from..to
+// (inclusive), oldest first. Archived days are read from disk; today is
+// computed live. Days without usage are omitted.
+func (t *SessionTracker) UsageHistory(from, to time.Time) ([]DayUsage, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.checkDayRollover()
+
+	first, last := from.Format(dayFormat), to.Format(dayFormat)
+	var days []DayUsage
+
+	entries, err := os.ReadDir(t.historyDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading usage history: %w", err)
+	}
+	for _, e := range entries {
+		date := strings.TrimSuffix(e.Name(), ".json")
+		if date == e.Name() || date < first || date > last || date == t.today {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(t.historyDir(), e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading usage history %s: %w", date, err)
+		}
+		var day DayUsage
+		if err := json.Unmarshal(data, &day); err != nil {
+			log.Printf("[budget] Warning: skipping corrupt usage history %s: %v", e.Name(), err)
+			continue
+		}
+		days = append(days, day)
+	}
+
+	if t.today >= first && t.today <= last {
+		if today := t.summarizeDayLocked(t.today, t.completed); len(today.ByRole) > 0 {
+			days = append(days, today)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// Usage query groupings.
+const (
+	GroupByDay    = "day"
+	GroupByRole   = "role"
+	GroupByThread = "thread"
+)
+
+// UsageQuery selects a range of days and how to group them.
+type UsageQuery struct {
+	// From and To bound the query by calendar day, inclusive. Zero From
+	// means 30 days before To; zero To means today.
+	From, To time.Time
+	// GroupBy is GroupByDay (default), GroupByRole or GroupByThread.
+	GroupBy string
+	// Role restricts day and thread grouping to one role's usage. Thread
+	// grouping only covers executive sessions.
+	Role string
+}
+
+// UsageBucket is one group of a usage query.
+type UsageBucket struct {
+	Key string `json:"key"`
+	TokenUsage
+	CacheHitRate    float64 `json:"cache_hit_rate"`
+	ThinkingMinutes float64 `json:"thinking_minutes,omitempty"`
+}
+
+// QueryUsage aggregates usage history over a range of days.
+func (t *SessionTracker) QueryUsage(q UsageQuery) ([]UsageBucket, error) {
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.AddDate(0, 0, -30)
+	}
+	if q.From.After(q.To) {
+		return nil, fmt.Errorf("from %s is after to %s", q.From.Format(dayFormat), q.To.Format(dayFormat))
+	}
+	switch q.GroupBy {
+	case "", GroupByDay, GroupByRole, GroupByThread:
+	default:
+		return nil, fmt.Errorf("unknown group_by %q (must be day, role, or thread)", q.GroupBy)
+	}
+	if q.GroupBy == GroupByThread && q.Role != "" && q.Role != RoleExecutive {
+		return nil, fmt.Errorf("thread grouping only covers the %s role", RoleExecutive)
+	}
+
+	days, err := t.UsageHistory(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*UsageBucket)
+	var order []string
+	add := func(key string, u TokenUsage, minutes float64) {
+		b, ok := groups[key]
+		if !ok {
+			b = &UsageBucket{Key: key}
+			groups[key] = b
+			order = append(order, key)
+		}
+		b.TokenUsage.add(u)
+		b.ThinkingMinutes += minutes
+	}
+
+	for _, day := range days {
+		switch q.GroupBy {
+		case "", GroupByDay:
+			u := day.Total
+			if q.Role != "" {
+				u = day.ByRole[q.Role]
+			}
+			add(day.Date, u, day.ThinkingMinutes)
+		case GroupByRole:
+			for role, u := range day.ByRole {
+				if q.Role == "" || q.Role == role {
+					add(role, u, 0)
+				}
+			}
+		case GroupByThread:
+			for thread, u := range day.ByThread {
+				add(thread, u, 0)
+			}
+		}
+	}
+
+	if q.GroupBy == GroupByRole || q.GroupBy == GroupByThread {
+		sort.Strings(order)
+	}
+	out := make([]UsageBucket, 0, len(order))
+	for _, key := range order {
+		b := groups[key]
+		b.CacheHitRate = b.TokenUsage.CacheHitRate()
+		out = append(out, *b)
+	}
+	return out, nil
+}
+
+// RenderUsageChart draws buckets as a text bar chart of output tokens with
+// cost and cache hit rate, for MCP tool output.
+func RenderUsageChart(buckets []UsageBucket) string {
+	if len(buckets) == 0 {
+		return "No usage recorded in this range."
+	}
+	const width = 30
+	maxOut, keyWidth := 0, 0
+	for _, b := range buckets {
+		if b.OutputTokens > maxOut {
+			maxOut = b.OutputTokens
+		}
+		if len(b.Key) > keyWidth {
+			keyWidth = len(b.Key)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-*s  %-*s  %9s  %8s  %6s\n", keyWidth, "", width, "output tokens", "output", "cost", "cache")
+	for _, b := range buckets {
+		bar := 0
+		if maxOut > 0 {
+			bar = b.OutputTokens * width / maxOut
+		}
+		fmt.Fprintf(&sb, "%-*s  %s%s  %9s  %8s  %5.1f%%\n",
+			keyWidth, b.Key, strings.Repeat("█", bar), strings.Repeat(" ", width-bar), formatTokens(b.OutputTokens),
+			fmt.Sprintf("$%.2f", b.CostUSD), b.CacheHitRate*100)
+	}
+	return sb.String()
+}
+
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 10_000:
+		return fmt.Sprintf("%dk", n/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
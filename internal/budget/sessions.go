@@ -22,6 +22,9 @@ type Session struct {
 	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
 	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 	NumTurns                 int `json:"num_turns,omitempty"`
+
+	// CostUSD is the priced cost of the session's usage records
+	CostUSD float64 `json:"cost_usd,omitempty"`
 }
 
 // TokenUsage holds daily aggregated token counts
@@ -32,6 +35,8 @@ type TokenUsage struct {
 	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 	SessionCount             int `json:"session_count"`
 	TotalTurns               int `json:"total_turns"`
+
+	CostUSD float64 `json:"cost_usd"`
 }
 
 // SessionTracker tracks active and completed sessions
@@ -41,8 +46,16 @@ type SessionTracker struct {
 
 	// In-memory state
 	active    map[string]*Session // sessionID -> session
-	completed []*Session          // today's completed sessions
+	completed []*Session          // today's completed sessions (earlier days are archived, see history.go)
 	today     string              // date string for daily reset
+
+	// Usage records for rolling-window limits (see usage.go)
+	records  []UsageRecord
+	onRecord func(UsageRecord)
+
+	// Pricing and per-day cost ledger (see cost.go)
+	prices PriceTable
+	costs  map[string]*CostSummary // date -> spend
 }
 
 // NewSessionTracker creates a new tracker
@@ -52,6 +65,8 @@ func NewSessionTracker(statePath string) *SessionTracker {
 		active:    make(map[string]*Session),
 		today:     time.Now().Format("2006-01-02"),
 	}
+	t.loadRecords()
+	t.loadCosts()
 	t.load()
 	return t
 }
@@ -102,8 +117,8 @@ func (t *SessionTracker) HasActiveSessions() bool {
 
 // TodayThinkingMinutes returns total thinking time today in minutes
 func (t *SessionTracker) TodayThinkingMinutes() float64 {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	t.checkDayRollover()
 
@@ -141,8 +156,8 @@ func (t *SessionTracker) SetSessionUsage(sessionID string, input, output, cacheC
 
 // TodayTokenUsage returns aggregated token usage for today
 func (t *SessionTracker) TodayTokenUsage() TokenUsage {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	t.checkDayRollover()
 
@@ -158,10 +173,12 @@ func (t *SessionTracker) TodayTokenUsage() TokenUsage {
 	return usage
 }
 
-// checkDayRollover resets completed sessions on new day
+// checkDayRollover archives the finished day and resets completed sessions
+// on new day. Callers must hold the write lock.
 func (t *SessionTracker) checkDayRollover() {
 	today := time.Now().Format("2006-01-02")
 	if today != t.today {
+		t.archiveDayLocked(t.today, t.completed)
 		t.completed = nil
 		t.today = today
 	}
@@ -188,12 +205,15 @@ func (t *SessionTracker) load() {
 		return
 	}
 
-	// Only load if same day
+	// Only load if same day; an earlier day was never archived (bud was not
+	// running at rollover), so archive it now.
 	if file.Date == t.today {
 		t.completed = file.Completed
 		for _, s := range file.Active {
 			t.active[s.ID] = s
 		}
+	} else {
+		t.archiveDayLocked(file.Date, file.Completed)
 	}
 }
 
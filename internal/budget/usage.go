@@ -0,0 +1,169 @@
+package budget
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Model roles used to attribute usage and scope limits.
+const (
+	RoleExecutive = "executive"
+	RoleAgent     = "agent"
+	RoleReflex    = "reflex"
+)
+
+// usageRetention is how far back usage records are kept for rolling-window
+// limits. Limits with a longer window are rejected by config validation.
+const usageRetention = 7 * 24 * time.Hour
+
+// UsageRecord is the token usage of one model call or session, attributed
+// to a role and provider.
+type UsageRecord struct {
+	Time                     time.Time `json:"time"`
+	Role                     string    `json:"role"`
+	Provider                 string    `json:"provider,omitempty"`
+	Model                    string    `json:"model,omitempty"`
+	SessionID                string    `json:"session_id,omitempty"`
+	InputTokens              int       `json:"input_tokens,omitempty"`
+	OutputTokens             int       `json:"output_tokens,omitempty"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens,omitempty"`
+	CostUSD                  float64   `json:"cost_usd,omitempty"`
+}
+
+// UsageFilter selects usage records. Empty fields match everything.
+type UsageFilter struct {
+	Role     string
+	Provider string
+}
+
+func (f UsageFilter) matches(r UsageRecord) bool {
+	return (f.Role == "" || f.Role == r.Role) && (f.Provider == "" || f.Provider == r.Provider)
+}
+
+// RecordUsage prices and appends a usage record and notifies the OnRecord
+// hook. Records are persisted to system/usage.jsonl.
+func (t *SessionTracker) RecordUsage(rec UsageRecord) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	priced := t.priceLocked(&rec)
+	t.records = append(t.records, rec)
+	t.pruneRecords(rec.Time)
+	t.appendRecord(rec)
+	t.addCostLocked(rec, priced)
+	hook := t.onRecord
+	t.mu.Unlock()
+
+	if hook != nil {
+		hook(rec)
+	}
+}
+
+// SetOnRecord registers a callback invoked after each RecordUsage, outside
+// the tracker lock.
+func (t *SessionTracker) SetOnRecord(fn func(UsageRecord)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onRecord = fn
+}
+
+// UsageSince aggregates recorded usage at or after since that matches filter.
+func (t *SessionTracker) UsageSince(since time.Time, filter UsageFilter) TokenUsage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var usage TokenUsage
+	for _, r := range t.records {
+		if r.Time.Before(since) || !filter.matches(r) {
+			continue
+		}
+		usage.InputTokens += r.InputTokens
+		usage.OutputTokens += r.OutputTokens
+		usage.CacheCreationInputTokens += r.CacheCreationInputTokens
+		usage.CacheReadInputTokens += r.CacheReadInputTokens
+		usage.CostUSD += r.CostUSD
+		usage.SessionCount++
+	}
+	return usage
+}
+
+// pruneRecords drops in-memory records older than the retention window.
+func (t *SessionTracker) pruneRecords(now time.Time) {
+	cutoff := now.Add(-usageRetention)
+	i := 0
+	for i < len(t.records) && t.records[i].Time.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		t.records = append([]UsageRecord(nil), t.records[i:]...)
+	}
+}
+
+func (t *SessionTracker) usagePath() string {
+	return filepath.Join(t.statePath, "system", "usage.jsonl")
+}
+
+func (t *SessionTracker) appendRecord(rec UsageRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(t.usagePath()), 0755)
+	f, err := os.OpenFile(t.usagePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("[budget] Warning: failed to record usage: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// loadRecords reads usage.jsonl, keeping records within the retention
+// window, and rewrites the file when old records were dropped.
+func (t *SessionTracker) loadRecords() {
+	f, err := os.Open(t.usagePath())
+	if err != nil {
+		return // File doesn't exist yet
+	}
+	cutoff := time.Now().Add(-usageRetention)
+	dropped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			dropped++
+			continue
+		}
+		if rec.Time.Before(cutoff) {
+			dropped++
+			continue
+		}
+		t.records = append(t.records, rec)
+	}
+	f.Close()
+
+	if dropped == 0 {
+		return
+	}
+	tmp := t.usagePath() + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	for _, rec := range t.records {
+		enc.Encode(rec)
+	}
+	w.Flush()
+	out.Close()
+	os.Rename(tmp, t.usagePath())
+}
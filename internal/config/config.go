@@ -31,11 +31,123 @@ func (e ExtensionsConfig) ParsedUpdateInterval() time.Duration {
 	return d
 }
 
+// QueueConfig tunes how the focus queue orders pending items beyond their raw
+// priority. Durations are Go duration strings; "0" (or 0 for counts) disables
+// that mechanism. Empty fields keep the built-in defaults.
+type QueueConfig struct {
+	// AgingInterval is how long an item waits before being promoted one
+	// priority level (default 30m).
+	AgingInterval string `yaml:"aging_interval,omitempty"`
+	// MaxAgingBoost caps promotion from aging, in levels (default 2).
+	MaxAgingBoost *int `yaml:"max_aging_boost,omitempty"`
+	// DeadlineLead promotes an item with a deadline to P0 this long before
+	// it is due (default 5m).
+	DeadlineLead string `yaml:"deadline_lead,omitempty"`
+	// FairnessWindow is the number of recent background picks used to keep
+	// one source from monopolising background slots (default 10).
+	FairnessWindow *int `yaml:"fairness_window,omitempty"`
+}
+
+// SandboxConfig bounds reflex shell actions and plugin action scripts.
+// Empty fields keep the sandbox defaults.
+type SandboxConfig struct {
+	// Timeout is a Go duration string (default 30s).
+	Timeout string `yaml:"timeout,omitempty"`
+	// MaxOutputBytes caps stdout and stderr each (default 1 MiB).
+	MaxOutputBytes int `yaml:"max_output_bytes,omitempty"`
+	// EnvAllow lists environment variables passed through to commands
+	// (default PATH, LANG, LC_ALL, TZ, TERM).
+	EnvAllow []string `yaml:"env_allow,omitempty"`
+	// Isolate runs commands in fresh Linux namespaces; Network keeps network
+	// access when isolated. The filesystem is not remounted, so use Wrapper
+	// to confine it.
+	Isolate bool `yaml:"isolate,omitempty"`
+	Network bool `yaml:"network,omitempty"`
+	// Wrapper is a command prefix such as bwrap or nsjail, e.g. for seccomp.
+	Wrapper []string `yaml:"wrapper,omitempty"`
+}
+
+// ParsedTimeout returns Timeout, or 0 to use the sandbox default.
+func (s SandboxConfig) ParsedTimeout() time.Duration {
+	return parseDurationOr(s.Timeout, 0)
+}
+
+// BudgetsConfig declares token budgets on top of the daily output limit.
+type BudgetsConfig struct {
+	// DailyOutputTokens caps output tokens per day across all roles
+	// (default 1,000,000; 0 disables the cap).
+	DailyOutputTokens *int `yaml:"daily_output_tokens,omitempty"`
+	// SoftLimit is the fraction of a limit at which a budget_warning impulse
+	// fires (default 0.8). Individual limits may override it.
+	SoftLimit float64 `yaml:"soft_limit,omitempty"`
+	// DailyCostUSD and MonthlyCostUSD cap total spend per calendar day and
+	// month, priced with the pricing table. 0 means no cap.
+	DailyCostUSD   float64 `yaml:"daily_cost_usd,omitempty"`
+	MonthlyCostUSD float64 `yaml:"monthly_cost_usd,omitempty"`
+	// Limits are rolling-window caps scoped by role and/or provider.
+	Limits []BudgetLimit `yaml:"limits,omitempty"`
+}
+
+// BudgetLimit caps token usage within a rolling window. Role is a model role
+// (executive, agent or reflex) and Provider a provider type (claude-code,
+// opencode-serve, openai-compatible, or ollama for reflex calls); empty
+// values match all. Token caps of 0 are unlimited.
+type BudgetLimit struct {
+	Name     string `yaml:"name"`
+	Role     string `yaml:"role,omitempty"`
+	Provider string `yaml:"provider,omitempty"`
+	// Window is a Go duration string, at most 168h (default 24h).
+	Window       string  `yaml:"window,omitempty"`
+	InputTokens  int     `yaml:"input_tokens,omitempty"`
+	OutputTokens int     `yaml:"output_tokens,omitempty"`
+	CacheTokens  int     `yaml:"cache_tokens,omitempty"`
+	TotalTokens  int     `yaml:"total_tokens,omitempty"`
+	CostUSD      float64 `yaml:"cost_usd,omitempty"`
+	SoftLimit    float64 `yaml:"soft_limit,omitempty"`
+}
+
+// ModelPricing gives a model's price in USD per million tokens.
+type ModelPricing struct {
+	Input      float64 `yaml:"input"`
+	Output     float64 `yaml:"output"`
+	CacheRead  float64 `yaml:"cache_read,omitempty"`
+	CacheWrite float64 `yaml:"cache_write,omitempty"`
+}
+
+// MaxBudgetWindow is the longest rolling window usage history supports.
+const MaxBudgetWindow = 7 * 24 * time.Hour
+
+// ParsedWindow returns the limit's window, defaulting to 24 hours.
+func (l BudgetLimit) ParsedWindow() time.Duration {
+	if l.Window == "" {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(l.Window)
+	if err != nil {
+		return 24 * time.Hour
+	}
+	return d
+}
+
 type BudConfig struct {
 	Providers       map[string]ProviderConfig `yaml:"providers"`
 	Models          map[string]string         `yaml:"models"`
 	TerminalManager string                    `yaml:"terminal_manager,omitempty"`
-	Extensions      ExtensionsConfig          `yaml:"extensions,omitempty"`
+	// StatePath is the state directory (default "state").
+	StatePath string `yaml:"state_path,omitempty"`
+	// Timezone is the user's IANA timezone for calendar and daily rhythms.
+	Timezone     string             `yaml:"timezone,omitempty"`
+	Autonomous   AutonomousConfig   `yaml:"autonomous,omitempty"`
+	Discord      DiscordConfig      `yaml:"discord,omitempty"`
+	MCP          MCPConfig          `yaml:"mcp,omitempty"`
+	Integrations IntegrationsConfig `yaml:"integrations,omitempty"`
+	Extensions   ExtensionsConfig   `yaml:"extensions,omitempty"`
+	Queue        QueueConfig        `yaml:"queue,omitempty"`
+	Budgets      BudgetsConfig      `yaml:"budgets,omitempty"`
+	Sandbox      SandboxConfig      `yaml:"sandbox,omitempty"`
+	// Pricing is keyed by "<provider type>/<model>"; "<provider type>/*"
+	// prices every model of a provider type without its own entry.
+	Pricing map[string]ModelPricing `yaml:"pricing,omitempty"`
 }
 
 type ProviderConfig struct {
@@ -51,6 +163,8 @@ type ModelConfig struct {
 	MaxOutputTokens int `yaml:"max_output_tokens,omitempty"`
 }
 
+// Load reads a config file, applies environment overrides and validates
+// the result.
 func Load(path string) (*BudConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -60,6 +174,9 @@ func Load(path string) (*BudConfig, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
 	}
+	if err := cfg.ApplyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -99,6 +216,75 @@ func (c *BudConfig) Validate() error {
 	if c.TerminalManager != "" && c.TerminalManager != "zellij" && c.TerminalManager != "tmux" {
 		return fmt.Errorf("terminal_manager: must be \"zellij\" or \"tmux\", got %q", c.TerminalManager)
 	}
+	for field, v := range map[string]string{"aging_interval": c.Queue.AgingInterval, "deadline_lead": c.Queue.DeadlineLead} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("queue.%s: invalid duration %q", field, v)
+		}
+	}
+	for field, v := range map[string]*int{"max_aging_boost": c.Queue.MaxAgingBoost, "fairness_window": c.Queue.FairnessWindow} {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("queue.%s: must not be negative, got %d", field, *v)
+		}
+	}
+	for key, p := range c.Pricing {
+		if _, _, err := SplitModelRef(key); err != nil {
+			return fmt.Errorf("pricing.%s: %w", key, err)
+		}
+		if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+			return fmt.Errorf("pricing.%s: rates must not be negative", key)
+		}
+	}
+	if err := c.validateDaemon(); err != nil {
+		return err
+	}
+	return c.Budgets.validate()
+}
+
+func (b BudgetsConfig) validate() error {
+	if b.DailyCostUSD < 0 || b.MonthlyCostUSD < 0 {
+		return fmt.Errorf("budgets: cost caps must not be negative")
+	}
+	if b.SoftLimit < 0 || b.SoftLimit > 1 {
+		return fmt.Errorf("budgets.soft_limit: must be between 0 and 1, got %v", b.SoftLimit)
+	}
+	names := make(map[string]bool)
+	for i, l := range b.Limits {
+		if l.Name == "" {
+			return fmt.Errorf("budgets.limits[%d]: name is required", i)
+		}
+		if names[l.Name] {
+			return fmt.Errorf("budgets.limits[%d]: duplicate name %q", i, l.Name)
+		}
+		names[l.Name] = true
+		switch l.Role {
+		case "", "executive", "agent", "reflex":
+		default:
+			return fmt.Errorf("budgets.limits.%s: unknown role %q (must be executive, agent, or reflex)", l.Name, l.Role)
+		}
+		switch l.Provider {
+		case "", "claude-code", "opencode-serve", "openai-compatible", "ollama":
+		default:
+			return fmt.Errorf("budgets.limits.%s: unknown provider type %q", l.Name, l.Provider)
+		}
+		if l.Window != "" {
+			d, err := time.ParseDuration(l.Window)
+			if err != nil || d <= 0 || d > MaxBudgetWindow {
+				return fmt.Errorf("budgets.limits.%s: window must be a duration between 0 and 168h, got %q", l.Name, l.Window)
+			}
+		}
+		if l.InputTokens < 0 || l.OutputTokens < 0 || l.CacheTokens < 0 || l.TotalTokens < 0 || l.CostUSD < 0 {
+			return fmt.Errorf("budgets.limits.%s: caps must not be negative", l.Name)
+		}
+		if l.InputTokens == 0 && l.OutputTokens == 0 && l.CacheTokens == 0 && l.TotalTokens == 0 && l.CostUSD == 0 {
+			return fmt.Errorf("budgets.limits.%s: at least one token or cost cap is required", l.Name)
+		}
+		if l.SoftLimit < 0 || l.SoftLimit > 1 {
+			return fmt.Errorf("budgets.limits.%s: soft_limit must be between 0 and 1, got %v", l.Name, l.SoftLimit)
+		}
+	}
 	return nil
 }
 
@@ -154,6 +340,17 @@ func (c *BudConfig) ContextWindow(providerName, modelID string) int {
 	return 0
 }
 
+func (c *BudConfig) MaxOutputTokens(providerName, modelID string) int {
+	pc, ok := c.Providers[providerName]
+	if !ok {
+		return 0
+	}
+	if mc, ok := pc.Models[modelID]; ok && mc.MaxOutputTokens > 0 {
+		return mc.MaxOutputTokens
+	}
+	return 0
+}
+
 func SplitModelRef(ref string) (providerName, modelID string, err error) {
 	idx := strings.Index(ref, "/")
 	if idx < 0 {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -31,6 +32,7 @@ providers:
     models:
       opencode-go/glm-5.1:
         context_window: 128000
+        max_output_tokens: 8192
 models:
   executive: opencode/opencode-go/glm-5.1
 `
@@ -87,6 +89,14 @@ models:
 	if cw != 0 {
 		t.Errorf("expected 0 context window for claude-code (not configured), got %d", cw)
 	}
+
+	// Test MaxOutputTokens
+	if mo := cfg.MaxOutputTokens("opencode", "opencode-go/glm-5.1"); mo != 8192 {
+		t.Errorf("expected max output tokens 8192, got %d", mo)
+	}
+	if mo := cfg.MaxOutputTokens("claude-code", "claude-sonnet-4-20250514"); mo != 0 {
+		t.Errorf("expected 0 max output tokens for claude-code (not configured), got %d", mo)
+	}
 }
 
 func TestValidation(t *testing.T) {
@@ -122,6 +132,108 @@ models:
   executive: noslash`,
 			"must be provider/model",
 		},
+		{
+			"bad queue duration",
+			`providers:
+  claude-code:
+    type: claude-code
+queue:
+  aging_interval: soon`,
+			"queue.aging_interval",
+		},
+		{
+			"negative queue fairness window",
+			`providers:
+  claude-code:
+    type: claude-code
+queue:
+  fairness_window: -1`,
+			"queue.fairness_window",
+		},
+		{
+			"budget window too long",
+			`providers:
+  claude-code:
+    type: claude-code
+budgets:
+  limits:
+    - name: monthly
+      window: 720h
+      output_tokens: 1000`,
+			"budgets.limits.monthly: window",
+		},
+		{
+			"budget limit unknown role",
+			`providers:
+  claude-code:
+    type: claude-code
+budgets:
+  limits:
+    - name: subagents
+      role: subagent
+      total_tokens: 1000`,
+			"unknown role",
+		},
+		{
+			"budget limit without caps",
+			`providers:
+  claude-code:
+    type: claude-code
+budgets:
+  limits:
+    - name: empty
+      role: agent`,
+			"at least one token or cost cap",
+		},
+		{
+			"pricing key without model",
+			`providers:
+  claude-code:
+    type: claude-code
+pricing:
+  claude-code:
+    input: 3
+    output: 15`,
+			"pricing.claude-code",
+		},
+		{
+			"negative pricing rate",
+			`providers:
+  claude-code:
+    type: claude-code
+pricing:
+  claude-code/claude-sonnet-4-5:
+    input: 3
+    output: -15`,
+			"rates must not be negative",
+		},
+		{
+			"unknown timezone",
+			`providers:
+  claude-code:
+    type: claude-code
+timezone: Mars/Olympus_Mons`,
+			"unknown location",
+		},
+		{
+			"bad autonomous interval",
+			`providers:
+  claude-code:
+    type: claude-code
+autonomous:
+  enabled: true
+  interval: often`,
+			"autonomous.interval",
+		},
+		{
+			"bad mcp port",
+			`providers:
+  claude-code:
+    type: claude-code
+mcp:
+  http_port: "99999"`,
+			"invalid port",
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,3 +291,96 @@ func TestSplitModelRef(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyEnv(t *testing.T) {
+	cfg := &BudConfig{
+		Timezone:   "UTC",
+		Autonomous: AutonomousConfig{Interval: "1h"},
+		Discord:    DiscordConfig{ChannelID: "from-yaml"},
+	}
+	env := map[string]string{
+		"USER_TIMEZONE":             "Europe/Berlin",
+		"AUTONOMOUS_ENABLED":        "true",
+		"DAILY_OUTPUT_TOKEN_BUDGET": "5000",
+		"GOOGLE_CALENDAR_IDS":       "a@example.com, b@example.com",
+	}
+	if err := cfg.ApplyEnv(func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	if cfg.Timezone != "Europe/Berlin" {
+		t.Errorf("timezone = %q, want env override", cfg.Timezone)
+	}
+	if !cfg.Autonomous.Enabled || cfg.Autonomous.ParsedInterval() != time.Hour {
+		t.Errorf("autonomous = %+v, want enabled with yaml interval", cfg.Autonomous)
+	}
+	if cfg.Discord.ChannelID != "from-yaml" {
+		t.Errorf("unset env var overrode channel_id: %q", cfg.Discord.ChannelID)
+	}
+	if n := cfg.Budgets.DailyOutputTokens; n == nil || *n != 5000 {
+		t.Errorf("daily_output_tokens = %v, want 5000", n)
+	}
+	if got := cfg.Integrations.CalendarIDs; len(got) != 2 || got[1] != "b@example.com" {
+		t.Errorf("calendar_ids = %v", got)
+	}
+
+	bad := map[string]string{"DAILY_OUTPUT_TOKEN_BUDGET": "lots"}
+	if err := cfg.ApplyEnv(func(k string) string { return bad[k] }); err == nil {
+		t.Error("expected error for non-integer DAILY_OUTPUT_TOKEN_BUDGET")
+	}
+}
+
+func TestReloadableChanges(t *testing.T) {
+	old := DefaultConfig()
+	next := DefaultConfig()
+	next.Timezone = "Europe/Berlin"
+	next.Autonomous.Enabled = true
+	next.Budgets.DailyCostUSD = 5
+	if restart := old.ReloadableChanges(next); len(restart) != 0 {
+		t.Errorf("expected only reloadable changes, got restart for %v", restart)
+	}
+
+	next.MCP.HTTPPort = "9000"
+	next.Models["executive"] = "claude-code/claude-opus-4"
+	restart := old.ReloadableChanges(next)
+	if strings.Join(restart, ",") != "models,mcp" {
+		t.Errorf("restart = %v, want [models mcp]", restart)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/bud.yaml"
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+	base := time.Now().Add(-time.Hour)
+	write("providers:\n  claude-code:\n    type: claude-code\ntimezone: UTC\n", base)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var got *BudConfig
+	w := NewWatcher(path, cfg, func(old, next *BudConfig) { got = next })
+
+	if w.Check() {
+		t.Fatal("Check reloaded an unchanged file")
+	}
+
+	write("providers:\n  claude-code:\n    type: claude-code\ntimezone: Europe/Berlin\n", base.Add(time.Minute))
+	if !w.Check() || got == nil || got.Timezone != "Europe/Berlin" {
+		t.Fatalf("expected reload with new timezone, got %+v", got)
+	}
+
+	// An invalid edit keeps the last good config
+	write("providers:\n  claude-code:\n    type: claude-code\ntimezone: Nowhere/Land\n", base.Add(2*time.Minute))
+	if w.Check() {
+		t.Fatal("Check applied an invalid config")
+	}
+	if w.Current().Timezone != "Europe/Berlin" {
+		t.Errorf("current timezone = %q, want last good value", w.Current().Timezone)
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutonomousConfig controls periodic self-initiated wakes. Durations are Go
+// duration strings.
+type AutonomousConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the base time between wakes; it doubles after 4h without
+	// user input (default 2h).
+	Interval string `yaml:"interval,omitempty"`
+	// IdleRequired skips a wake if the user was active more recently than
+	// this (default 0, no idle gate).
+	IdleRequired string `yaml:"idle_required,omitempty"`
+	// SessionCap bounds how long a wake session may run (default 8m).
+	SessionCap string `yaml:"session_cap,omitempty"`
+}
+
+// ParsedInterval returns Interval, defaulting to 2 hours.
+func (a AutonomousConfig) ParsedInterval() time.Duration {
+	return parseDurationOr(a.Interval, 2*time.Hour)
+}
+
+// ParsedIdleRequired returns IdleRequired, defaulting to 0.
+func (a AutonomousConfig) ParsedIdleRequired() time.Duration {
+	return parseDurationOr(a.IdleRequired, 0)
+}
+
+// ParsedSessionCap returns SessionCap, defaulting to 8 minutes.
+func (a AutonomousConfig) ParsedSessionCap() time.Duration {
+	return parseDurationOr(a.SessionCap, 8*time.Minute)
+}
+
+// DiscordConfig identifies the Discord channel and owner. The bot token is
+// a secret and only read from DISCORD_TOKEN.
+type DiscordConfig struct {
+	ChannelID string `yaml:"channel_id,omitempty"`
+	OwnerID   string `yaml:"owner_id,omitempty"`
+	// GuildID scopes slash command registration.
+	GuildID string `yaml:"guild_id,omitempty"`
+}
+
+// MCPConfig configures the local MCP HTTP server.
+type MCPConfig struct {
+	HTTPPort string `yaml:"http_port,omitempty"` // default 8066
+}
+
+// Port returns HTTPPort, defaulting to 8066.
+func (m MCPConfig) Port() string {
+	if m.HTTPPort == "" {
+		return "8066"
+	}
+	return m.HTTPPort
+}
+
+// IntegrationsConfig configures optional external services. Secrets (API
+// keys, tokens, inline credentials) stay in environment variables.
+type IntegrationsConfig struct {
+	// EngramURL is the memory service; ENGRAM_API_KEY holds its key.
+	EngramURL string `yaml:"engram_url,omitempty"`
+	// Calendar credentials may also be given inline, base64-encoded, via
+	// GOOGLE_CALENDAR_CREDENTIALS.
+	CalendarCredentialsFile string   `yaml:"calendar_credentials_file,omitempty"`
+	CalendarIDs             []string `yaml:"calendar_ids,omitempty"`
+	// GitHubOrg enables the GitHub integration together with GITHUB_TOKEN.
+	GitHubOrg string `yaml:"github_org,omitempty"`
+	// GKPath points at the gk project directory.
+	GKPath       string `yaml:"gk_path,omitempty"`
+	VMControlURL string `yaml:"vm_control_url,omitempty"`
+}
+
+// ParsedTimezone returns the user's timezone, defaulting to UTC.
+func (c *BudConfig) ParsedTimezone() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// envOverride maps an environment variable onto a config field.
+type envOverride struct {
+	name  string
+	apply func(c *BudConfig, v string) error
+}
+
+func setString(field func(c *BudConfig) *string) func(*BudConfig, string) error {
+	return func(c *BudConfig, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+// envOverrides lists the environment variables that override bud.yaml, in
+// the order they are applied.
+var envOverrides = []envOverride{
+	{"STATE_PATH", setString(func(c *BudConfig) *string { return &c.StatePath })},
+	{"USER_TIMEZONE", setString(func(c *BudConfig) *string { return &c.Timezone })},
+	{"AUTONOMOUS_ENABLED", func(c *BudConfig, v string) error {
+		c.Autonomous.Enabled = v == "true"
+		return nil
+	}},
+	{"AUTONOMOUS_INTERVAL", setString(func(c *BudConfig) *string { return &c.Autonomous.Interval })},
+	{"AUTONOMOUS_IDLE_REQUIRED", setString(func(c *BudConfig) *string { return &c.Autonomous.IdleRequired })},
+	{"AUTONOMOUS_SESSION_CAP", setString(func(c *BudConfig) *string { return &c.Autonomous.SessionCap })},
+	{"DAILY_OUTPUT_TOKEN_BUDGET", func(c *BudConfig, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", v)
+		}
+		c.Budgets.DailyOutputTokens = &n
+		return nil
+	}},
+	{"DISCORD_CHANNEL_ID", setString(func(c *BudConfig) *string { return &c.Discord.ChannelID })},
+	{"DISCORD_OWNER_ID", setString(func(c *BudConfig) *string { return &c.Discord.OwnerID })},
+	{"DISCORD_GUILD_ID", setString(func(c *BudConfig) *string { return &c.Discord.GuildID })},
+	{"MCP_HTTP_PORT", setString(func(c *BudConfig) *string { return &c.MCP.HTTPPort })},
+	{"ENGRAM_URL", setString(func(c *BudConfig) *string { return &c.Integrations.EngramURL })},
+	{"GOOGLE_CALENDAR_CREDENTIALS_FILE", setString(func(c *BudConfig) *string { return &c.Integrations.CalendarCredentialsFile })},
+	// GOOGLE_CALENDAR_ID is the legacy singular form.
+	{"GOOGLE_CALENDAR_ID", setCalendarIDs},
+	{"GOOGLE_CALENDAR_IDS", setCalendarIDs},
+	{"GITHUB_ORG", setString(func(c *BudConfig) *string { return &c.Integrations.GitHubOrg })},
+	{"GK_PATH", setString(func(c *BudConfig) *string { return &c.Integrations.GKPath })},
+	{"VM_CONTROL_URL", setString(func(c *BudConfig) *string { return &c.Integrations.VMControlURL })},
+}
+
+func setCalendarIDs(c *BudConfig, v string) error {
+	c.Integrations.CalendarIDs = nil
+	for _, id := range strings.Split(v, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			c.Integrations.CalendarIDs = append(c.Integrations.CalendarIDs, id)
+		}
+	}
+	return nil
+}
+
+// ApplyEnv overrides config fields with any set environment variables.
+// getenv is usually os.Getenv.
+func (c *BudConfig) ApplyEnv(getenv func(string) string) error {
+	for _, o := range envOverrides {
+		v := getenv(o.name)
+		if v == "" {
+			continue
+		}
+		if err := o.apply(c, v); err != nil {
+			return fmt.Errorf("%s: %w", o.name, err)
+		}
+	}
+	return nil
+}
+
+func (c *BudConfig) validateDaemon() error {
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone: unknown location %q", c.Timezone)
+		}
+	}
+	for field, v := range map[string]string{
+		"interval":      c.Autonomous.Interval,
+		"idle_required": c.Autonomous.IdleRequired,
+		"session_cap":   c.Autonomous.SessionCap,
+	} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("autonomous.%s: invalid duration %q", field, v)
+		}
+	}
+	if c.Autonomous.Enabled && c.Autonomous.ParsedInterval() <= 0 {
+		return fmt.Errorf("autonomous.interval: must be positive when autonomous mode is enabled")
+	}
+	if p := c.MCP.HTTPPort; p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("mcp.http_port: invalid port %q", p)
+		}
+	}
+	if v := c.Sandbox.Timeout; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("sandbox.timeout: invalid duration %q", v)
+		}
+	}
+	if c.Sandbox.MaxOutputBytes < 0 {
+		return fmt.Errorf("sandbox.max_output_bytes: must not be negative, got %d", c.Sandbox.MaxOutputBytes)
+	}
+	if c.Sandbox.Isolate && runtime.GOOS != "linux" {
+		return fmt.Errorf("sandbox.isolate: namespace isolation requires Linux; use sandbox.wrapper instead")
+	}
+	if n := c.Budgets.DailyOutputTokens; n != nil && *n < 0 {
+		return fmt.Errorf("budgets.daily_output_tokens: must not be negative, got %d", *n)
+	}
+	return nil
+}
+
+// ReloadableChanges reports whether next differs from c only in sections
+// that can be applied to a running daemon: timezone, autonomous, queue,
+// budgets and pricing. It returns the top-level keys of any other sections
+// that changed and need a restart.
+func (c *BudConfig) ReloadableChanges(next *BudConfig) (restart []string) {
+	cur, nxt := reflect.ValueOf(*c), reflect.ValueOf(*next)
+	typ := cur.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		key := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if reloadableSections[key] {
+			continue
+		}
+		if !reflect.DeepEqual(cur.Field(i).Interface(), nxt.Field(i).Interface()) {
+			restart = append(restart, key)
+		}
+	}
+	return restart
+}
+
+// reloadableSections are the top-level keys applied without a restart.
+var reloadableSections = map[string]bool{
+	"timezone":   true,
+	"autonomous": true,
+	"queue":      true,
+	"budgets":    true,
+	"pricing":    true,
+}
+
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks the config file.
+const DefaultWatchInterval = 5 * time.Second
+
+// Watcher polls a config file for changes and reloads it. A file that fails
+// to load or validate is logged and ignored, keeping the last good config.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func(old, next *BudConfig)
+
+	mu      sync.Mutex
+	current *BudConfig
+	modTime time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewWatcher creates a watcher for path. current is the config already in
+// use; onChange is called with it and the reloaded config after each
+// successful reload.
+func NewWatcher(path string, current *BudConfig, onChange func(old, next *BudConfig)) *Watcher {
+	w := &Watcher{
+		path:     path,
+		interval: DefaultWatchInterval,
+		onChange: onChange,
+		current:  current,
+		stop:     make(chan struct{}),
+	}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+// Current returns the most recently loaded config.
+func (w *Watcher) Current() *BudConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Start begins polling in a goroutine.
+func (w *Watcher) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.Check()
+			}
+		}
+	}()
+}
+
+// Stop ends polling.
+func (w *Watcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// Check reloads the config if the file's modification time changed. It
+// reports whether a new config was applied.
+func (w *Watcher) Check() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	if info.ModTime().Equal(w.modTime) {
+		w.mu.Unlock()
+		return false
+	}
+	w.modTime = info.ModTime()
+	w.mu.Unlock()
+
+	next, err := Load(w.path)
+	if err != nil {
+		log.Printf("[config] Ignoring invalid config change: %v", err)
+		return false
+	}
+
+	w.mu.Lock()
+	old := w.current
+	w.current = next
+	w.mu.Unlock()
+
+	log.Printf("[config] Reloaded %s", w.path)
+	if w.onChange != nil {
+		w.onChange(old, next)
+	}
+	return true
+}
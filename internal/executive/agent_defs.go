@@ -115,6 +115,95 @@ func ResolveSubagentConfigFromRegistry(reg *plugins.Registry, agentName, baseToo
 	return mergedTools, systemPromptAppend, nil
 }
 
+// subagentMCPTools returns the bud2 MCP tools a subagent spawned with profile
+// may call. It resolves the profile the same way the spawn does (so an
+// unknown profile gets only the base set), including the tools its plugin
+// requires. The agents it names in Agent(...) grants share its MCP token, so
+// their tools are added too, following their own grants in turn. A bare
+// Agent grant names no delegate and adds nothing.
+func subagentMCPTools(reg *plugins.Registry, profile string, knownTools []string) []string {
+	tools := subagentBaseTools
+	if reg == nil || profile == "" {
+		return mcpToolPatterns(tools, "bud2")
+	}
+	if merged, _, err := ResolveSubagentConfigFromRegistry(reg, profile, subagentBaseTools); err == nil {
+		tools = merged
+	}
+
+	defs := LoadAgentDefsFromRegistry(reg, knownTools)
+	seen := map[string]bool{profile: true}
+	queue := agentDelegates(reg, profile)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		def, ok := defs[name]
+		if !ok {
+			continue
+		}
+		tools += "," + strings.Join(def.Tools, ",")
+		queue = append(queue, agentDelegates(reg, name)...)
+	}
+	return mcpToolPatterns(tools, "bud2")
+}
+
+// agentDelegates returns the agents named in the Agent(...) grants of the
+// agent capability name, from its own tools and its plugin's requires.tools,
+// in declaration order.
+func agentDelegates(reg *plugins.Registry, name string) []string {
+	cap, ext, ok := reg.GetCapabilityByFullName(name)
+	if !ok {
+		return nil
+	}
+	grants := make([]string, 0, len(cap.Tools)+len(ext.Manifest.Requires.Tools))
+	grants = append(grants, cap.Tools...)
+	grants = append(grants, ext.Manifest.Requires.Tools...)
+
+	var names []string
+	for _, t := range grants {
+		t = strings.TrimSpace(t)
+		if !strings.HasPrefix(t, "Agent(") || !strings.HasSuffix(t, ")") {
+			continue
+		}
+		for _, n := range strings.Split(t[len("Agent("):len(t)-1], ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// mcpToolPatterns picks the tools granted on one MCP server out of a
+// comma-separated allowed-tools list, without their mcp__<server>__ prefix
+// (wildcards such as "gk_*" are kept). A grant of the whole server,
+// "mcp__<server>" or "mcp__<server>__*", yields "*".
+func mcpToolPatterns(allowedTools, server string) []string {
+	prefix := "mcp__" + server + "__"
+	seen := make(map[string]bool)
+	var patterns []string
+	for _, t := range strings.Split(allowedTools, ",") {
+		t = strings.TrimSpace(t)
+		var name string
+		switch {
+		case t == "mcp__"+server:
+			name = "*"
+		case strings.HasPrefix(t, prefix):
+			name = strings.TrimPrefix(t, prefix)
+		default:
+			continue
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			patterns = append(patterns, name)
+		}
+	}
+	return patterns
+}
+
 // parseAgentModel converts a model string from agent YAML to an AgentModel enum value.
 func parseAgentModel(model string) claudecode.AgentModel {
 	switch strings.ToLower(strings.TrimSpace(model)) {
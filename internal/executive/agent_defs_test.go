@@ -0,0 +1,93 @@
+package executive
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestMCPToolPatterns(t *testing.T) {
+	tests := []struct {
+		allowed string
+		want    []string
+	}{
+		{subagentBaseTools, []string{"search_memory"}},
+		{"Read, mcp__bud2__gk_*,mcp__bud2__search_memory,mcp__other__x,mcp__bud2__gk_*", []string{"gk_*", "search_memory"}},
+		{"Bash,mcp__bud2", []string{"*"}},
+		{"mcp__bud2__*", []string{"*"}},
+		{"Read,Write", nil},
+	}
+	for _, tt := range tests {
+		if got := mcpToolPatterns(tt.allowed, "bud2"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mcpToolPatterns(%q) = %v, want %v", tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestSubagentMCPTools(t *testing.T) {
+	root := t.TempDir()
+	writeAgentPlugin(t, root, "team", "requires:\n  tools: [mcp__bud2__gk_*]\n", map[string]string{
+		"lead":   "tools: [Read, \"Agent(team:helper)\", mcp__bud2__talk_to_user]",
+		"helper": "tools: [mcp__bud2__save_thought]",
+		"boss":   "tools: [\"Agent(team:lead)\"]",
+		"solo":   "tools: [Agent]",
+	})
+	writeAgentPlugin(t, root, "notes", "requires:\n  tools: [mcp__bud2__note_*]\n", map[string]string{
+		"writer": "tools: [Write]",
+	})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	known := []string{"mcp__bud2__note_add", "mcp__bud2__note_list"}
+
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		{"", []string{"search_memory"}},
+		{"team:missing", []string{"search_memory"}},
+		// The profile's plugin requires.tools are granted.
+		{"team:helper", []string{"search_memory", "save_thought", "gk_*"}},
+		// The delegates an Agent(...) grant names share the token, so their
+		// tools are granted too; unrelated plugins' agents are not.
+		{"team:lead", []string{"search_memory", "talk_to_user", "gk_*", "save_thought"}},
+		// Delegation is followed transitively.
+		{"team:boss", []string{"search_memory", "gk_*", "talk_to_user", "save_thought"}},
+		// A bare Agent grant names no delegate.
+		{"team:solo", []string{"search_memory", "gk_*"}},
+	}
+	for _, tt := range tests {
+		if got := subagentMCPTools(reg, tt.profile, known); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("subagentMCPTools(%q) = %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+}
+
+// writeAgentPlugin writes a plugin whose manifest has the extra YAML and one
+// agent per entry of agents (name -> AGENT.md front matter lines).
+func writeAgentPlugin(t *testing.T, root, name, extra string, agents map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, ".bud-plugin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "name: " + name + "\nversion: \"1.0.0\"\n" + extra + "capabilities:\n"
+	for agent, front := range agents {
+		manifest += "  " + agent + ":\n    callable_from: both\n"
+		agentDir := filepath.Join(dir, "agents", agent)
+		if err := os.MkdirAll(agentDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		body := "---\nname: " + agent + "\ntype: agent\ncallable_from: both\n" + front + "\n---\nDo the work.\n"
+		if err := os.WriteFile(filepath.Join(agentDir, "AGENT.md"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".bud-plugin", "plugin.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -40,6 +40,9 @@ type ExecutiveV2 struct {
 	// Provider session for non-claude-code providers. When set, processItem
 	// delegates to this session instead of SimpleSession.SendPromptWithCfg.
 	providerSession provider.Session
+	// providerModel is the model the provider session runs, used to price
+	// its usage. Config Model names the Claude model and does not apply.
+	providerModel string
 
 	// Plugin registry for agent/skill/workflow discovery.
 	pluginRegistry *plugins.Registry
@@ -57,6 +60,12 @@ type ExecutiveV2 struct {
 	// MCP tool call tracking (for detecting user responses via MCP tools)
 	mcpToolCalled map[string]bool
 
+	// pendingTools logs the tool calls of the running session, for its
+	// checkpoint if it is preempted. Claude-code sessions call bud2 tools
+	// over MCP, so those arrive via RecordMCPToolCall.
+	pendingMu    sync.Mutex
+	pendingTools *toolLog
+
 	// Known MCP tool names (with mcp__<server>__ prefix) used to expand
 	// wildcard patterns in plugins.yaml tool_grants. Set after tool registration.
 	knownMCPTools []string
@@ -73,6 +82,15 @@ type ExecutiveV2 struct {
 	backgroundActive atomic.Bool
 	p1Active         atomic.Bool
 
+	// wakeSessionCap mirrors config.MaxAutonomousSessionDuration so it can
+	// change on config reload.
+	wakeSessionCap atomic.Int64
+
+	// preemptedBy is the ID of the P0/P1 item that triggered the pending
+	// background cancel; empty for a plain interrupt (/stop). Guarded by
+	// backgroundMu.
+	preemptedBy string
+
 	// signal_done termination support
 	signalDoneCancel func() // cancels current session when signal_done fires
 	signalDoneActive bool   // true when signal_done triggered the cancel
@@ -122,12 +140,20 @@ type ExecutiveV2Config struct {
 	OnExecWake   func(focusID, context, existingClaudeSessionID string)
 	OnExecDone   func(focusID, summary string, durationSec float64, usage *SessionUsage)
 	OnMemoryEval func(eval string) // Called when Claude outputs memory self-evaluation
+	// OnItemDropped is called when a queued item is given up on after
+	// repeated failed or unacknowledged deliveries.
+	OnItemDropped func(item *focus.PendingItem, reason string)
 
 	// MCPServerURL is the HTTP URL for the bud2 MCP server (e.g. "http://127.0.0.1:8066/mcp").
 	// Passed directly to Claude SDK sessions so MCP tools are available without
 	// relying on auto-discovery.
 	MCPServerURL string
 
+	// OnSubagentDone is called with a subagent's MCP server URL once the
+	// subagent completes, fails or is stopped, so the session token the URL
+	// carries can be revoked.
+	OnSubagentDone func(mcpURL string)
+
 	// MaxAutonomousSessionDuration caps how long a wake session can run.
 	// Zero means no cap (limited only by signal_done or the Claude subprocess timeout).
 	// Recommended: 8-10 minutes to enforce coordinator-style wake sessions.
@@ -171,12 +197,35 @@ func NewExecutiveV2(
 		config:            cfg,
 		pluginRegistry: cfg.PluginRegistry,
 	}
+	exec.wakeSessionCap.Store(int64(cfg.MaxAutonomousSessionDuration))
+	if cfg.ProviderConfig != nil {
+		exec.queue.SetPolicy(queuePolicy(cfg.ProviderConfig.Queue))
+	}
+	if cfg.OnItemDropped != nil {
+		exec.queue.SetDropHook(cfg.OnItemDropped)
+	}
+	if tracker := cfg.SessionTracker; tracker != nil {
+		exec.subagents.OnUsage = func(sessionID, providerName, model string, usage *provider.SessionUsage) {
+			tracker.RecordUsage(budget.UsageRecord{
+				Role:                     budget.RoleAgent,
+				Provider:                 providerName,
+				Model:                    model,
+				SessionID:                sessionID,
+				InputTokens:              usage.InputTokens,
+				OutputTokens:             usage.OutputTokens,
+				CacheCreationInputTokens: usage.CacheCreationInputTokens,
+				CacheReadInputTokens:     usage.CacheReadInputTokens,
+			})
+		}
+	}
 
 	// If a non-claude-code provider is configured, create a provider session
 	// and set the agent provider on the subagent manager for agent sessions.
 	if cfg.Provider != nil && cfg.ProviderName != "claude-code" {
+		// cfg.Model is the Claude model; the provider runs its own.
+		model := modelOf(cfg.Provider, "")
 		providerSess, err := cfg.Provider.NewSession(provider.SessionOpts{
-			Model:        cfg.Model,
+			Model:        model,
 			WorkDir:      cfg.WorkDir,
 			MCPServerURL: cfg.MCPServerURL,
 		})
@@ -184,6 +233,7 @@ func NewExecutiveV2(
 			log.Printf("[executive-v2] Warning: failed to create provider session, falling back to claude-code: %v", err)
 		} else {
 			exec.providerSession = providerSess
+			exec.providerModel = model
 			exec.subagents.AgentProvider = cfg.AgentProvider
 			log.Printf("[executive-v2] Using provider %s for executive and agent sessions", cfg.ProviderName)
 		}
@@ -210,6 +260,32 @@ func NewExecutiveV2(
 	return exec
 }
 
+// ApplyConfig applies the reloadable parts of a changed bud.yaml: the queue
+// scheduling policy and the wake session cap.
+func (e *ExecutiveV2) ApplyConfig(cfg *config.BudConfig) {
+	e.queue.SetPolicy(queuePolicy(cfg.Queue))
+	e.wakeSessionCap.Store(int64(cfg.Autonomous.ParsedSessionCap()))
+}
+
+// queuePolicy converts the bud.yaml queue section into a focus scheduling
+// policy, keeping focus defaults for unset fields.
+func queuePolicy(qc config.QueueConfig) focus.SchedulingPolicy {
+	p := focus.DefaultSchedulingPolicy()
+	if d, err := time.ParseDuration(qc.AgingInterval); err == nil {
+		p.AgingInterval = d
+	}
+	if qc.MaxAgingBoost != nil {
+		p.MaxAgingBoost = *qc.MaxAgingBoost
+	}
+	if d, err := time.ParseDuration(qc.DeadlineLead); err == nil {
+		p.DeadlineLead = d
+	}
+	if qc.FairnessWindow != nil {
+		p.FairnessWindow = *qc.FairnessWindow
+	}
+	return p
+}
+
 // PostToolUseHook returns a callback suitable for mcp.Server.SetPostToolHook.
 // It fires the PostToolUse lifecycle hooks with the actual tool result.
 // Returns nil if no hook runner is configured.
@@ -234,6 +310,16 @@ func (e *ExecutiveV2) SetKnownMCPTools(tools []string) {
 	e.knownMCPTools = tools
 }
 
+// subagentBaseTools is the default restricted tool set for subagents:
+// standard file tools + search_memory only. No talk_to_user, signal_done, etc.
+const subagentBaseTools = "Read,Write,Edit,Glob,Grep,Bash,mcp__bud2__search_memory"
+
+// SubagentMCPTools returns the bud2 MCP tools a subagent spawned with profile
+// may call, for its MCP session token (see subagentMCPTools).
+func (e *ExecutiveV2) SubagentMCPTools(profile string) []string {
+	return subagentMCPTools(e.pluginRegistry, profile, e.knownMCPTools)
+}
+
 // loadAgentDefs returns programmatic agent definitions for the current session.
 // Definitions are sourced from plugin capabilities (type:agent, callable_from: both|model).
 // Returns nil if no plugin registry is configured.
@@ -264,10 +350,6 @@ func (e *ExecutiveV2) SubagentCallbacks() (
 	peekMemoriesFn func(sessionID string) int,
 	listMemoriesFn func(sessionID string) []string,
 ) {
-	// subagentBaseTools is the default restricted tool set for subagents:
-	// standard file tools + search_memory only. No talk_to_user, signal_done, etc.
-	const subagentBaseTools = "Read,Write,Edit,Glob,Grep,Bash,mcp__bud2__search_memory"
-
 	spawnFn = func(task, systemPromptAppend, profile, workflowInstanceID, workflowStep, mcpURL string) (id, logPath string, err error) {
 		allowedTools := subagentBaseTools
 		promptAppend := systemPromptAppend
@@ -291,16 +373,12 @@ func (e *ExecutiveV2) SubagentCallbacks() (
 			log.Printf("[executive-v2] Warning: no extension registry; cannot resolve agent profile %q", profile)
 		}
 
-		// Use provided mcpURL (tokenized for domain routing) or fall back to base URL.
-		resolvedMCPURL := mcpURL
-		if resolvedMCPURL == "" {
-			resolvedMCPURL = e.config.MCPServerURL
-		}
-
+		// Only the tokenized mcpURL is used: the executive's own URL carries a
+		// token that allows every tool, which the subagent must not inherit.
 		s, err := e.subagents.Spawn(context.Background(), SubagentConfig{
 			Task:               task,
 			SystemPromptAppend: promptAppend,
-			MCPServerURL:       resolvedMCPURL,
+			MCPServerURL:       mcpURL,
 			AllowedTools:       allowedTools,
 			WorkDir:            e.config.WorkDir,
 			AgentDefs:          e.loadAgentDefs(),
@@ -421,6 +499,46 @@ func (e *ExecutiveV2) GetMCPToolCallback() func(toolName string) {
 	}
 }
 
+// RecordMCPToolCall notes a bud2 MCP tool call made by the executive session,
+// for the checkpoint if the session is preempted. Provider sessions report
+// their calls through OnTool, so only claude-code calls are recorded here.
+func (e *ExecutiveV2) RecordMCPToolCall(name string, args map[string]any) {
+	if e.providerSession != nil {
+		return
+	}
+	e.pendingMu.Lock()
+	pending := e.pendingTools
+	e.pendingMu.Unlock()
+	if pending != nil {
+		pending.add(summarizeToolCall(name, args))
+	}
+}
+
+// toolLog collects the tool calls a session issued since its last text
+// output, i.e. work the model had started but not yet reported on.
+type toolLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *toolLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+func (l *toolLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
+
+func (l *toolLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
 // Start initializes the executive
 func (e *ExecutiveV2) Start() error {
 	// Load queue state
@@ -554,6 +672,9 @@ func (e *ExecutiveV2) watchSubagentDone() {
 		}
 
 		log.Printf("[executive-v2] Subagent %s %s", sessionID, label)
+		if e.config.OnSubagentDone != nil && session.MCPServerURL != "" {
+			e.config.OnSubagentDone(session.MCPServerURL)
+		}
 
 		// Auto-post structured observations and principles to Engram if agent output schema is detected.
 		if status == SubagentCompleted {
@@ -646,26 +767,48 @@ func (e *ExecutiveV2) ProcessNextP1(ctx context.Context) (bool, error) {
 	e.p1Active.Store(true)
 	defer e.p1Active.Store(false)
 	if err := e.processItem(ctx, items); err != nil {
+		e.nackItems(items...)
 		return true, err
 	}
+	e.ackItems(items...)
 	return true, nil
 }
 
 // ProcessNextBackground processes the next P2+ item (autonomous wakes, scheduled tasks).
-// Bypasses the attention system so it can run concurrently with ProcessNextP1.
+// Runs concurrently with ProcessNextP1. The item is tracked as the attention
+// focus: if a P0/P1 item preempts it, it is checkpointed onto the suspended
+// stack, and suspended items are resumed (most recent first) before any new
+// background item is popped. The stack itself is memory-only; the checkpoint
+// is journaled with the unacked item, so after a restart the item is
+// redelivered through the queue and resumes from it.
 // Returns true if an item was processed, false if no background items are queued.
 func (e *ExecutiveV2) ProcessNextBackground(ctx context.Context) (bool, error) {
 	if e.p1Active.Load() {
 		return false, nil
 	}
-	item := e.queue.PopHighestMinPriority(focus.P2DueTask)
-	if item == nil {
-		return false, nil
+	item := e.attention.Resume()
+	if item != nil {
+		if item.Checkpoint != nil {
+			item.Checkpoint.Resumes++
+		}
+		log.Printf("[executive-v2] Resuming suspended item %s", item.ID)
+	} else {
+		item = e.queue.PopHighestMinPriority(focus.P2DueTask)
+		if item == nil {
+			return false, nil
+		}
+		if item.Checkpoint != nil {
+			// Preempted before a restart and redelivered.
+			item.Checkpoint.Resumes++
+			log.Printf("[executive-v2] Resuming redelivered item %s", item.ID)
+		}
+		e.attention.Focus(item)
 	}
 
 	bgCtx, bgCancel := context.WithCancel(ctx)
 	e.backgroundMu.Lock()
 	e.backgroundCancel = bgCancel
+	e.preemptedBy = ""
 	e.backgroundActive.Store(true)
 	e.backgroundMu.Unlock()
 	defer func() {
@@ -676,22 +819,89 @@ func (e *ExecutiveV2) ProcessNextBackground(ctx context.Context) (bool, error) {
 		e.backgroundMu.Unlock()
 	}()
 
-	if err := e.processItem(bgCtx, []*focus.PendingItem{item}); err != nil {
+	err := e.processItem(bgCtx, []*focus.PendingItem{item})
+	if errors.Is(err, errPreempted) {
+		// Still unacked in the queue, so a restart redelivers it too.
+		e.attention.Suspend()
+		return true, nil
+	}
+	e.attention.Complete()
+	if err != nil {
+		e.nackItems(item)
 		return true, err
 	}
+	e.ackItems(item)
 	return true, nil
 }
 
+// errPreempted is returned by processItem when a background session was
+// cancelled for a P0/P1 item; the item carries a Checkpoint for resumption.
+var errPreempted = errors.New("session preempted")
+
+// checkpoint captures the interrupted session for item.
+func (e *ExecutiveV2) checkpoint(item *focus.PendingItem, output string, pendingTools []string, preemptedBy string) *focus.Checkpoint {
+	cp := &focus.Checkpoint{
+		SessionID:        e.session.ClaudeSessionID(),
+		PartialOutput:    output,
+		PendingToolCalls: pendingTools,
+		PreemptedBy:      preemptedBy,
+		SuspendedAt:      time.Now(),
+	}
+	if e.providerSession != nil {
+		cp.SessionID = e.providerSession.SessionID()
+	}
+	// Keep context from earlier runs if the item was already resumed once.
+	if prev := item.Checkpoint; prev != nil {
+		cp.Resumes = prev.Resumes
+		if prev.PartialOutput != "" {
+			cp.PartialOutput = prev.PartialOutput + "\n" + output
+		}
+	}
+	return cp
+}
+
+// summarizeToolCall renders a tool call as "name {args}" with args truncated.
+func summarizeToolCall(name string, args map[string]any) string {
+	if len(args) == 0 {
+		return name
+	}
+	data, _ := json.Marshal(args)
+	return name + " " + truncate(string(data), 200)
+}
+
+// ackItems tells the queue that items were fully processed so they are not
+// redelivered after a restart.
+func (e *ExecutiveV2) ackItems(items ...*focus.PendingItem) {
+	if err := e.queue.Ack(items...); err != nil {
+		log.Printf("[executive-v2] Warning: failed to ack queue items: %v", err)
+	}
+}
+
+// nackItems tells the queue that processing items failed, so they are
+// retried with backoff, or dropped after too many failures.
+func (e *ExecutiveV2) nackItems(items ...*focus.PendingItem) {
+	if err := e.queue.Nack(items...); err != nil {
+		log.Printf("[executive-v2] Warning: failed to nack queue items: %v", err)
+	}
+}
+
 // IsP1Active returns true if a P1 user session is currently running.
 func (e *ExecutiveV2) IsP1Active() bool { return e.p1Active.Load() }
 
-// RequestBackgroundInterrupt cancels any running background session so a P1
-// item can be processed promptly.
+// RequestBackgroundInterrupt preempts any running background session when a
+// P0/P1 item is waiting, so it can be processed promptly. The background item
+// is checkpointed and resumed once the urgent work is done. Does nothing if
+// no urgent item is queued.
 func (e *ExecutiveV2) RequestBackgroundInterrupt() {
+	urgent := e.queue.PeekMaxPriority(focus.P1UserInput)
+	if urgent == nil {
+		return
+	}
 	e.backgroundMu.Lock()
 	defer e.backgroundMu.Unlock()
-	if e.backgroundCancel != nil {
-		log.Printf("[executive] Interrupting background session for P1")
+	if e.backgroundCancel != nil && e.preemptedBy == "" {
+		log.Printf("[executive] Preempting background session for %s item %s", urgent.Priority, urgent.ID)
+		e.preemptedBy = urgent.ID
 		e.backgroundCancel()
 	}
 }
@@ -813,9 +1023,15 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 	// ResolveMemoryEval can resolve them.
 	claudeSessionID := e.session.ClaudeSessionID()
 	shouldReset := e.session.ShouldReset()
-	// For non-claude-code providers, delegate reset check to provider session
+	// For non-claude-code providers, delegate the resume and reset checks to
+	// the provider session. It has no Claude session ID; it can resume once a
+	// turn has run in it.
 	if e.providerSession != nil {
 		shouldReset = e.providerSession.ShouldReset()
+		claudeSessionID = ""
+		if e.providerSession.LastUsage() != nil {
+			claudeSessionID = e.providerSession.SessionID()
+		}
 	}
 	resuming := claudeSessionID != "" && !shouldReset
 
@@ -832,11 +1048,17 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 		// that's already in the Claude session history.
 		log.Printf("[executive-v2] Resuming session: %s", claudeSessionID)
 		e.session.PrepareForResume()
+		if e.providerSession != nil {
+			e.providerSession.PrepareForResume()
+		}
 	} else {
 		// Fresh session: full context injection, new Claude session.
 		if shouldReset {
 			log.Printf("[executive-v2] Context limit reached, starting fresh session")
 			e.session.WriteSessionLogEntry("=== CONTEXT CLEARED (token limit) ===")
+			if e.providerSession != nil {
+				e.providerSession.Reset()
+			}
 		} else {
 			log.Printf("[executive-v2] Starting new session (no prior session ID)")
 		}
@@ -913,12 +1135,20 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 	// Set up callbacks
 	var output strings.Builder
 	var thinkingBlocks []string
+	// A fresh log per session, so a preempted session keeps its own calls
+	// while the next one runs.
+	pendingTools := &toolLog{}
+	e.pendingMu.Lock()
+	e.pendingTools = pendingTools
+	e.pendingMu.Unlock()
 	e.session.OnOutput(func(text string) {
 		output.WriteString(text)
+		pendingTools.clear()
 		e.notifyDebug(DebugEvent{Type: DebugEventText, Text: text})
 	})
 
 	e.session.OnToolCall(func(name string, args map[string]any) (string, error) {
+		pendingTools.add(summarizeToolCall(name, args))
 		e.notifyDebug(DebugEvent{Type: DebugEventToolCall, Tool: name, Args: args})
 		// Track responses to user (talk_to_user or emoji reaction)
 		// Note: This won't fire for MCP tools, but we keep it for any non-MCP tools
@@ -957,9 +1187,9 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 	// stays short and delegates real work to subagents.
 	var sessionCtx context.Context
 	var sessionCancel context.CancelFunc
-	if item.Type == "wake" && e.config.MaxAutonomousSessionDuration > 0 {
-		sessionCtx, sessionCancel = context.WithTimeout(ctx, e.config.MaxAutonomousSessionDuration)
-		log.Printf("[executive-v2] Wake session capped at %v", e.config.MaxAutonomousSessionDuration)
+	if wakeCap := time.Duration(e.wakeSessionCap.Load()); item.Type == "wake" && wakeCap > 0 {
+		sessionCtx, sessionCancel = context.WithTimeout(ctx, wakeCap)
+		log.Printf("[executive-v2] Wake session capped at %v", wakeCap)
 	} else {
 		sessionCtx, sessionCancel = context.WithCancel(ctx)
 	}
@@ -983,6 +1213,7 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 			_, sendErr = e.providerSession.SendPrompt(sessionCtx, prompt, provider.StreamCallbacks{
 				OnText: func(text string) {
 					output.WriteString(text)
+					pendingTools.clear()
 					e.notifyDebug(DebugEvent{Type: DebugEventText, Text: text})
 					e.session.WriteSessionLogEntry("TEXT: %s", text)
 				},
@@ -991,6 +1222,7 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 					e.session.WriteSessionLogEntry("THINKING: %s", truncate(text, 200))
 				},
 				OnTool: func(name string, input map[string]any) {
+					pendingTools.add(summarizeToolCall(name, input))
 					e.notifyDebug(DebugEvent{Type: DebugEventToolCall, Tool: name, Args: input})
 					e.session.WriteSessionLogEntry("TOOL: %s %v", name, input)
 					if strings.HasSuffix(name, "talk_to_user") || strings.HasSuffix(name, "send_message") || strings.HasSuffix(name, "respond_to_user") {
@@ -1023,7 +1255,20 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 				log.Printf("[executive] Session terminated cleanly after signal_done")
 				sendErr = nil
 			} else {
-				log.Printf("[executive] Background session interrupted by P1 item")
+				e.backgroundMu.Lock()
+				preemptedBy := e.preemptedBy
+				e.backgroundMu.Unlock()
+				if preemptedBy != "" && item.Priority >= focus.P2DueTask {
+					calls := pendingTools.snapshot()
+					item.Checkpoint = e.checkpoint(item, output.String(), calls, preemptedBy)
+					if err := e.queue.Checkpoint(item); err != nil {
+						log.Printf("[executive] Warning: %v", err)
+					}
+					log.Printf("[executive] Background session for %s preempted by %s (%d chars output, %d pending tool calls)",
+						item.ID, preemptedBy, output.Len(), len(calls))
+					return errPreempted
+				}
+				log.Printf("[executive] Session interrupted")
 				return nil
 			}
 		} else if errors.Is(sendErr, ErrSessionFallback) {
@@ -1069,6 +1314,30 @@ func (e *ExecutiveV2) processItem(ctx context.Context, items []*focus.PendingIte
 				usage.CacheCreationInputTokens, usage.CacheReadInputTokens,
 				usage.NumTurns)
 		}
+
+		// Attribute usage to the executive role for per-role/provider budgets
+		usage := e.session.LastUsage()
+		model := modelOf(e.config.Provider, e.config.Model)
+		if usage == nil && e.providerSession != nil {
+			usage = e.providerSession.LastUsage()
+			model = e.providerModel
+		}
+		if usage != nil {
+			providerName := e.config.ProviderName
+			if providerName == "" {
+				providerName = "claude-code"
+			}
+			e.config.SessionTracker.RecordUsage(budget.UsageRecord{
+				Role:                     budget.RoleExecutive,
+				Provider:                 providerName,
+				Model:                    model,
+				SessionID:                e.session.SessionID(),
+				InputTokens:              usage.InputTokens,
+				OutputTokens:             usage.OutputTokens,
+				CacheCreationInputTokens: usage.CacheCreationInputTokens,
+				CacheReadInputTokens:     usage.CacheReadInputTokens,
+			})
+		}
 	}
 
 	// Log session completion summary with token stats
@@ -1615,6 +1884,32 @@ func focusSectionHeader(item *focus.PendingItem) string {
 	}
 }
 
+// writeCheckpoint renders the state of a preempted session so the model can
+// pick the work back up instead of starting over.
+func writeCheckpoint(prompt *strings.Builder, cp *focus.Checkpoint) {
+	if cp == nil {
+		return
+	}
+	prompt.WriteString("### Resuming Interrupted Work\n")
+	prompt.WriteString(fmt.Sprintf("You were working on this when a higher-priority item preempted you (%s ago). Continue from where you left off; do not redo completed steps.\n",
+		time.Since(cp.SuspendedAt).Round(time.Second)))
+	if out := strings.TrimSpace(cp.PartialOutput); out != "" {
+		if len(out) > 2000 {
+			out = "..." + out[len(out)-2000:]
+		}
+		prompt.WriteString("Output so far:\n")
+		prompt.WriteString(out)
+		prompt.WriteString("\n")
+	}
+	if len(cp.PendingToolCalls) > 0 {
+		prompt.WriteString("Tool calls in progress when interrupted (results not yet seen; check before repeating):\n")
+		for _, call := range cp.PendingToolCalls {
+			prompt.WriteString("- " + call + "\n")
+		}
+	}
+	prompt.WriteString("\n")
+}
+
 // writeFocusAttachments renders any attachments from a focus item into the prompt.
 // Data["attachments"] may be []map[string]any (in-memory) or []interface{} (after JSON round-trip).
 func writeFocusAttachments(prompt *strings.Builder, item *focus.PendingItem) {
@@ -1822,6 +2117,7 @@ func (e *ExecutiveV2) buildPrompt(bundle *focus.ContextBundle) string {
 			}
 			writeFocusAttachments(&prompt, bundle.CurrentFocus)
 			prompt.WriteString("\n")
+			writeCheckpoint(&prompt, bundle.CurrentFocus.Checkpoint)
 
 			// For subagent-done: include the full result as handoff notes
 			if bundle.CurrentFocus.Type == "subagent-done" {
@@ -2029,6 +2325,9 @@ func (e *ExecutiveV2) Stop() error {
 	if e.providerSession != nil {
 		e.providerSession.Close()
 	}
+	if err := e.queue.Close(); err != nil {
+		log.Printf("[executive-v2] Warning: failed to close queue journal: %v", err)
+	}
 	return e.session.Close()
 }
 
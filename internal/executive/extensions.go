@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/vthunder/bud2/internal/logging"
+	"github.com/vthunder/bud2/internal/plugins"
 	"gopkg.in/yaml.v3"
 )
 
@@ -169,8 +170,10 @@ func clawhubSkillsDir(cacheBase string) string {
 // process from cachedPlugins().
 //
 // ClaWHub pinned skills: downloaded once, never re-fetched while cached version matches.
-// ClaWHub floating skills: re-fetched if the cached _meta.json is older than updateInterval.
-// Git skills: cloned once (with sparse checkout if dir is set); updated on existing clones.
+// ClaWHub floating skills: held at the version in plugins.lock once recorded there;
+// until then, re-fetched if the cached _meta.json is older than updateInterval.
+// Git skills: cloned once (with sparse checkout if dir is set); updated on existing
+// clones unless plugins.lock holds them at a commit.
 func loadManifestSkills(statePath string, updateInterval time.Duration) {
 	manifestPath := pluginsManifestPath(statePath)
 	data, err := os.ReadFile(manifestPath)
@@ -197,15 +200,41 @@ func loadManifestSkills(statePath string, updateInterval time.Duration) {
 		return
 	}
 
+	lock := loadPluginsLock(statePath)
+	lockChanged := false
 	for _, e := range manifest.Skills {
 		switch {
 		case e.clawSlug != "":
-			downloadClawhubSkill(e.clawSlug, e.clawVersion, cacheBase, updateInterval)
+			version := e.clawVersion
+			if locked, ok := lockedSkill(lock, e.clawSlug); ok && version == "" {
+				version = locked
+			}
+			downloadClawhubSkill(e.clawSlug, version, cacheBase, updateInterval)
+			if version == "" && lock != nil {
+				metaPath := filepath.Join(clawhubSkillsDir(cacheBase), "skills", e.clawSlug, "_meta.json")
+				if v := readClawhubMetaVersion(metaPath); v != "" {
+					lock.Skills[e.clawSlug] = &plugins.LockedSkill{Version: v}
+					lockChanged = true
+				}
+			}
 		case e.owner != "":
-			cloneOrUpdateGitSkillEntry(e, cacheBase)
+			lockChanged = cloneOrUpdateGitSkillEntry(e, cacheBase, lock) || lockChanged
 		// path: entries are used directly via resolvedManifestSkillDirs — nothing to download
 		}
 	}
+	saveLock(lock, lockChanged)
+}
+
+// lockedSkill returns the version plugins.lock holds a floating ClaWHub skill at.
+func lockedSkill(lock *plugins.Lock, slug string) (string, bool) {
+	if lock == nil {
+		return "", false
+	}
+	locked, ok := lock.Skills[slug]
+	if !ok || locked.Version == "" {
+		return "", false
+	}
+	return locked.Version, true
 }
 
 // resolvedManifestSkillDirs returns the already-on-disk directories for git and
@@ -419,8 +448,11 @@ func extractZipEntry(f *zip.File, destDir string) error {
 // cloneOrUpdateGitSkillEntry clones or updates a git-sourced skill repo.
 // Uses sparse checkout when dir is specified to fetch only the relevant subtree.
 // Clones share the same cache dir as manifest plugins (~/Library/Caches/bud/plugins/).
-func cloneOrUpdateGitSkillEntry(e skillManifestEntry, cacheBase string) {
+// A repo locked in plugins.lock is held at its commit instead of updated; an
+// unlocked one is recorded. Reports whether the lock changed.
+func cloneOrUpdateGitSkillEntry(e skillManifestEntry, cacheBase string, lock *plugins.Lock) bool {
 	repoDir := filepath.Join(cacheBase, "bud", "plugins", e.owner, e.repo)
+	src := ManifestSource{Key: e.owner + "/" + e.repo, RepoDir: repoDir, Ref: e.ref}
 
 	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
 		cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", e.owner, e.repo)
@@ -438,7 +470,7 @@ func cloneOrUpdateGitSkillEntry(e skillManifestEntry, cacheBase string) {
 
 		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
 			log.Printf("[skills] failed to clone git:%s/%s: %v\n%s", e.owner, e.repo, err, out)
-			return
+			return false
 		}
 
 		if e.dir != "" {
@@ -455,15 +487,21 @@ func cloneOrUpdateGitSkillEntry(e skillManifestEntry, cacheBase string) {
 			}
 		}
 		log.Printf("[skills] cloned git:%s/%s", e.owner, e.repo)
-		return
+		if syncLockedSource(lock, src) {
+			return false
+		}
+		return recordSource(lock, src)
 	}
 
-	// Repo exists — update it.
+	if syncLockedSource(lock, src) {
+		return false
+	}
+	// Repo exists and is not locked — update it.
 	if e.ref != "" {
 		fetch := exec.Command("git", "-C", repoDir, "fetch", "--depth=1", "origin", e.ref)
 		if out, err := fetch.CombinedOutput(); err != nil {
 			log.Printf("[skills] failed to fetch git:%s/%s@%s: %v\n%s", e.owner, e.repo, e.ref, err, out)
-			return
+			return false
 		}
 		if e.dir != "" {
 			exec.Command("git", "-C", repoDir, "sparse-checkout", "set", e.dir).CombinedOutput() //nolint:errcheck
@@ -476,4 +514,5 @@ func cloneOrUpdateGitSkillEntry(e skillManifestEntry, cacheBase string) {
 			log.Printf("[skills] failed to pull git:%s/%s: %v\n%s", e.owner, e.repo, err, out)
 		}
 	}
+	return recordSource(lock, src)
 }
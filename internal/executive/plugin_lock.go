@@ -0,0 +1,174 @@
+package executive
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vthunder/bud2/internal/plugins"
+	"github.com/vthunder/bud2/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// PluginsLockPath returns the path of plugins.lock, kept next to plugins.yaml
+// so it travels with the state repo.
+func PluginsLockPath(statePath string) string {
+	return filepath.Join(statePath, "system", "plugins.lock")
+}
+
+// loadPluginsLock loads plugins.lock. If it cannot be read it is logged and
+// nil is returned: sources then update as if unlocked, and the unreadable
+// file is left alone rather than overwritten.
+func loadPluginsLock(statePath string) *plugins.Lock {
+	lock, err := plugins.LoadLock(PluginsLockPath(statePath))
+	if err != nil {
+		log.Printf("[plugins] failed to read plugins.lock, ignoring it: %v", err)
+		return nil
+	}
+	return lock
+}
+
+// ManifestSource is a git source from plugins.yaml (a plugins: source or a
+// git: skill), resolved to its clone in the user cache dir.
+type ManifestSource struct {
+	Key     string // "owner/repo", the plugins.lock key
+	RepoDir string // the clone
+	Dir     string // the plugin dir within the clone
+	Ref     string // branch, tag or commit from plugins.yaml; "" = default branch
+}
+
+// ManifestSources returns the git sources listed in plugins.yaml. No git
+// operations; the clones may not exist yet.
+func ManifestSources(statePath string) []ManifestSource {
+	data, err := os.ReadFile(pluginsManifestPath(statePath))
+	if err != nil {
+		return nil
+	}
+	var manifest pluginManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+	cacheBase, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+
+	var sources []ManifestSource
+	add := func(owner, repo, dir, ref string) {
+		src := ManifestSource{
+			Key:     owner + "/" + repo,
+			RepoDir: filepath.Join(cacheBase, "bud", "plugins", owner, repo),
+			Ref:     ref,
+		}
+		src.Dir = filepath.Join(src.RepoDir, dir)
+		sources = append(sources, src)
+	}
+	for _, e := range manifest.Sources {
+		if e.owner != "" {
+			add(e.owner, e.repo, e.dir, e.ref)
+		}
+	}
+	for _, e := range manifest.Skills {
+		if e.owner != "" {
+			add(e.owner, e.repo, e.dir, e.ref)
+		}
+	}
+	return sources
+}
+
+// SourceFor returns the source whose clone contains dir.
+func SourceFor(sources []ManifestSource, dir string) (ManifestSource, bool) {
+	for _, src := range sources {
+		if sandbox.Within(src.RepoDir, dir) {
+			return src, true
+		}
+	}
+	return ManifestSource{}, false
+}
+
+// SourceCommit returns the commit checked out in a source's clone.
+func SourceCommit(src ManifestSource) (string, error) {
+	out, err := exec.Command("git", "-C", src.RepoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("rev-parse %s: %w", src.Key, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CheckoutSource checks out commit in a source's clone, fetching it first if
+// the shallow clone does not have it.
+func CheckoutSource(src ManifestSource, commit string) error {
+	if exec.Command("git", "-C", src.RepoDir, "cat-file", "-e", commit+"^{commit}").Run() != nil {
+		if out, err := exec.Command("git", "-C", src.RepoDir, "fetch", "--depth=1", "origin", commit).CombinedOutput(); err != nil {
+			return fmt.Errorf("fetch %s@%s: %w\n%s", src.Key, commit, err, out)
+		}
+	}
+	if out, err := exec.Command("git", "-C", src.RepoDir, "checkout", "--detach", commit).CombinedOutput(); err != nil {
+		return fmt.Errorf("checkout %s@%s: %w\n%s", src.Key, commit, err, out)
+	}
+	return nil
+}
+
+// UpgradeSource fetches the newest commit of a source's ref (its default
+// branch if none) and checks it out. Returns the new commit.
+func UpgradeSource(src ManifestSource) (string, error) {
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if out, err := exec.Command("git", "-C", src.RepoDir, "fetch", "--depth=1", "origin", ref).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("fetch %s@%s: %w\n%s", src.Key, ref, err, out)
+	}
+	if out, err := exec.Command("git", "-C", src.RepoDir, "checkout", "--detach", "FETCH_HEAD").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("checkout %s: %w\n%s", src.Key, err, out)
+	}
+	return SourceCommit(src)
+}
+
+// syncLockedSource brings a freshly cloned or existing source to the commit
+// recorded in plugins.lock. It reports false if the source is not locked, in
+// which case the caller updates it as usual and then calls recordSource.
+func syncLockedSource(lock *plugins.Lock, src ManifestSource) bool {
+	if lock == nil {
+		return false
+	}
+	locked, ok := lock.Sources[src.Key]
+	if !ok || locked.Commit == "" {
+		return false
+	}
+	if commit, err := SourceCommit(src); err == nil && commit == locked.Commit {
+		return true
+	}
+	if err := CheckoutSource(src, locked.Commit); err != nil {
+		log.Printf("[plugins] failed to check out locked commit of %s: %v", src.Key, err)
+	}
+	return true
+}
+
+// recordSource adds a source's current commit to the lock. Returns whether
+// the lock changed.
+func recordSource(lock *plugins.Lock, src ManifestSource) bool {
+	if lock == nil {
+		return false
+	}
+	commit, err := SourceCommit(src)
+	if err != nil {
+		log.Printf("[plugins] %v", err)
+		return false
+	}
+	lock.Sources[src.Key] = &plugins.LockedSource{Commit: commit}
+	return true
+}
+
+// saveLock writes the lock if changed, logging failures.
+func saveLock(lock *plugins.Lock, changed bool) {
+	if lock == nil || !changed {
+		return
+	}
+	if err := lock.Save(); err != nil {
+		log.Printf("[plugins] failed to write plugins.lock: %v", err)
+	}
+}
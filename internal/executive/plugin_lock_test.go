@@ -0,0 +1,84 @@
+package executive
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// gitRun runs git in dir and returns its trimmed output.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commitFile(t *testing.T, repo, content string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(repo, "plugin.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-qm", content)
+	return gitRun(t, repo, "rev-parse", "HEAD")
+}
+
+func TestManifestSource_LockAndUpgrade(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	origin := t.TempDir()
+	gitRun(t, origin, "init", "-q")
+	first := commitFile(t, origin, "v1")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gitRun(t, origin, "clone", "-q", "file://"+origin, clone)
+	src := ManifestSource{Key: "owner/repo", RepoDir: clone}
+	second := commitFile(t, origin, "v2")
+
+	// A locked source is held at its commit instead of being updated.
+	lock, err := plugins.LoadLock(filepath.Join(t.TempDir(), "plugins.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syncLockedSource(lock, src) {
+		t.Fatal("expected unlocked source to report false")
+	}
+	if !recordSource(lock, src) || lock.Sources["owner/repo"].Commit != first {
+		t.Fatalf("expected first commit recorded, got %+v", lock.Sources["owner/repo"])
+	}
+
+	next, err := UpgradeSource(src)
+	if err != nil {
+		t.Fatalf("UpgradeSource: %v", err)
+	}
+	if next != second {
+		t.Errorf("expected upgrade to %s, got %s", second, next)
+	}
+
+	// Starting with the lock still at the first commit moves the clone back.
+	if !syncLockedSource(lock, src) {
+		t.Fatal("expected locked source to report true")
+	}
+	if got, _ := SourceCommit(src); got != first {
+		t.Errorf("expected clone held at locked commit %s, got %s", first, got)
+	}
+
+	if err := CheckoutSource(src, second); err != nil {
+		t.Fatalf("CheckoutSource: %v", err)
+	}
+	if got, _ := SourceCommit(src); got != second {
+		t.Errorf("expected %s after checkout, got %s", second, got)
+	}
+}
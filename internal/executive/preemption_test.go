@@ -0,0 +1,183 @@
+package executive
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/executive/provider"
+	"github.com/vthunder/bud2/internal/focus"
+)
+
+// blockingProvider answers its first prompt with some output and a tool call,
+// then blocks until the session context is cancelled. Later prompts reply via
+// talk_to_user and return immediately. Every prompt is recorded.
+type blockingProvider struct {
+	started chan struct{}
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *blockingProvider) Name() string { return "blocking" }
+
+func (p *blockingProvider) NewSession(provider.SessionOpts) (provider.Session, error) {
+	return &blockingSession{p: p}, nil
+}
+
+func (p *blockingProvider) prompt(i int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i >= len(p.prompts) {
+		return ""
+	}
+	return p.prompts[i]
+}
+
+type blockingSession struct {
+	p *blockingProvider
+}
+
+func (s *blockingSession) SendPrompt(ctx context.Context, prompt string, cb provider.StreamCallbacks) (*provider.SessionResult, error) {
+	s.p.mu.Lock()
+	s.p.prompts = append(s.p.prompts, prompt)
+	first := len(s.p.prompts) == 1
+	s.p.mu.Unlock()
+
+	if first {
+		cb.OnText("Fetched the first source.")
+		cb.OnTool("mcp__bud2__web_fetch", map[string]any{"url": "https://example.com/2"})
+		close(s.p.started)
+		<-ctx.Done()
+		return nil, provider.ErrInterrupted
+	}
+	cb.OnTool("mcp__bud2__talk_to_user", map[string]any{"message": "done"})
+	return &provider.SessionResult{SessionID: "blocking"}, nil
+}
+
+func (s *blockingSession) SessionID() string                 { return "blocking" }
+func (s *blockingSession) ShouldReset() bool                 { return false }
+func (s *blockingSession) PrepareForResume()                 {}
+func (s *blockingSession) Reset()                            {}
+func (s *blockingSession) LastUsage() *provider.SessionUsage { return nil }
+func (s *blockingSession) Close() error                      { return nil }
+
+// TestBackgroundPreemption verifies that a P1 item suspends a running
+// background session with a checkpoint, and that the background item is
+// resumed with that checkpoint once the P1 work is done.
+func TestBackgroundPreemption(t *testing.T) {
+	prov := &blockingProvider{started: make(chan struct{})}
+	exec := NewExecutiveV2(nil, t.TempDir(), ExecutiveV2Config{
+		Provider:            prov,
+		ProviderName:        "blocking",
+		SendMessageFallback: func(string, string) error { return nil },
+	})
+
+	exec.AddPending(&focus.PendingItem{
+		ID: "research", Type: "task", Priority: focus.P3ActiveWork,
+		Source: "system", Content: "Research the second source", Timestamp: time.Now(),
+	})
+
+	type result struct {
+		processed bool
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		processed, err := exec.ProcessNextBackground(context.Background())
+		done <- result{processed, err}
+	}()
+	<-prov.started
+
+	// A non-urgent arrival must not preempt.
+	exec.AddPending(&focus.PendingItem{ID: "idea", Priority: focus.P4Exploration, Content: "later"})
+	exec.RequestBackgroundInterrupt()
+	select {
+	case <-done:
+		t.Fatal("background session was interrupted by a P4 item")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	exec.AddPending(&focus.PendingItem{
+		ID: "msg-1", Type: "message", Priority: focus.P1UserInput,
+		Source: "discord", Content: "quick question", ChannelID: "ch", Timestamp: time.Now(),
+	})
+	exec.RequestBackgroundInterrupt()
+
+	select {
+	case r := <-done:
+		if !r.processed || r.err != nil {
+			t.Fatalf("expected preempted background run to report processed without error, got %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("background session was not preempted")
+	}
+
+	state := exec.attention.GetState()
+	if state.CurrentItem != nil || len(state.Suspended) != 1 {
+		t.Fatalf("expected one suspended item and no focus, got %+v", state)
+	}
+	cp := state.Suspended[0].Checkpoint
+	if cp == nil {
+		t.Fatal("expected suspended item to carry a checkpoint")
+	}
+	if cp.PreemptedBy != "msg-1" || cp.PartialOutput != "Fetched the first source." {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+	if len(cp.PendingToolCalls) != 1 || !strings.HasPrefix(cp.PendingToolCalls[0], "mcp__bud2__web_fetch") {
+		t.Errorf("expected web_fetch as pending tool call, got %v", cp.PendingToolCalls)
+	}
+	if n := exec.GetQueue().InFlight(); n != 1 {
+		t.Errorf("expected suspended item to stay unacked, %d in flight", n)
+	}
+
+	// The P1 prompt lists the suspended task.
+	if _, err := exec.ProcessNextP1(context.Background()); err != nil {
+		t.Fatalf("ProcessNextP1: %v", err)
+	}
+	if p := prov.prompt(1); !strings.Contains(p, "## Suspended Tasks") || !strings.Contains(p, "Research the second source") {
+		t.Errorf("expected P1 prompt to list the suspended task, got:\n%s", p)
+	}
+
+	// The next background run resumes the suspended item before the P4 idea.
+	if _, err := exec.ProcessNextBackground(context.Background()); err != nil {
+		t.Fatalf("ProcessNextBackground: %v", err)
+	}
+	p := prov.prompt(2)
+	if !strings.Contains(p, "Research the second source") || !strings.Contains(p, "Resuming Interrupted Work") ||
+		!strings.Contains(p, "Fetched the first source.") || !strings.Contains(p, "web_fetch") {
+		t.Errorf("expected resume prompt with checkpoint, got:\n%s", p)
+	}
+	if state := exec.attention.GetState(); state.CurrentItem != nil || len(state.Suspended) != 0 {
+		t.Errorf("expected attention to be clear after resume, got %+v", state)
+	}
+	if n := exec.GetQueue().InFlight(); n != 0 {
+		t.Errorf("expected resumed item to be acked, %d in flight", n)
+	}
+}
+
+// TestRecordMCPToolCall verifies that bud2 MCP calls from a claude-code
+// session land in the running session's pending tool calls, and that a
+// provider session, which reports its calls via OnTool, is not doubled up.
+func TestRecordMCPToolCall(t *testing.T) {
+	exec := NewExecutiveV2(nil, t.TempDir(), ExecutiveV2Config{})
+	exec.RecordMCPToolCall("talk_to_user", nil) // no session running
+	pending := &toolLog{}
+	exec.pendingTools = pending
+	exec.RecordMCPToolCall("web_fetch", map[string]any{"url": "https://example.com"})
+	if calls := pending.snapshot(); len(calls) != 1 || calls[0] != `web_fetch {"url":"https://example.com"}` {
+		t.Errorf("pending calls = %q", calls)
+	}
+
+	prov := NewExecutiveV2(nil, t.TempDir(), ExecutiveV2Config{
+		Provider:     &blockingProvider{started: make(chan struct{})},
+		ProviderName: "blocking",
+	})
+	prov.pendingTools = &toolLog{}
+	prov.RecordMCPToolCall("web_fetch", nil)
+	if calls := prov.pendingTools.snapshot(); len(calls) != 0 {
+		t.Errorf("provider session recorded MCP calls twice: %q", calls)
+	}
+}
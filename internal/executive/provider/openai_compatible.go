@@ -0,0 +1,573 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOpenAIBaseURL is used when an openai-compatible provider has no base_url.
+const DefaultOpenAIBaseURL = "http://127.0.0.1:8080/v1"
+
+// maxToolRounds bounds the agentic loop in a single SendPrompt so a model
+// that keeps calling tools cannot spin forever.
+const maxToolRounds = 50
+
+// OpenAICompatibleProvider talks directly to any server implementing the
+// OpenAI chat-completions API (llama.cpp, vLLM, LM Studio, OpenAI itself).
+// Tool calls are executed against bud's own MCP server over HTTP.
+type OpenAICompatibleProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+
+	contextWindow   int
+	maxOutputTokens int
+}
+
+func NewOpenAICompatibleProvider(apiKey, model, baseURL string) *OpenAICompatibleProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	return &OpenAICompatibleProvider{
+		apiKey:        apiKey,
+		model:         model,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		client:        &http.Client{Timeout: 30 * time.Minute},
+		contextWindow: MaxContextTokensDefault,
+	}
+}
+
+func (p *OpenAICompatibleProvider) Name() string { return "openai-compatible" }
+
+func (p *OpenAICompatibleProvider) Model() string { return p.model }
+
+func (p *OpenAICompatibleProvider) WithContextWindow(tokens int) *OpenAICompatibleProvider {
+	p.contextWindow = tokens
+	return p
+}
+
+// WithMaxOutputTokens sets max_tokens on every completion request.
+// Zero leaves the server default in place.
+func (p *OpenAICompatibleProvider) WithMaxOutputTokens(tokens int) *OpenAICompatibleProvider {
+	p.maxOutputTokens = tokens
+	return p
+}
+
+func (p *OpenAICompatibleProvider) NewSession(opts SessionOpts) (Session, error) {
+	model := p.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	if model == "" {
+		return nil, fmt.Errorf("openai-compatible: no model configured")
+	}
+	s := &OpenAISession{
+		provider:      p,
+		sessionID:     generateOpenAISessionID(),
+		model:         model,
+		contextWindow: p.contextWindow,
+	}
+	if opts.MCPServerURL != "" {
+		s.mcp = newMCPHTTPClient(opts.MCPServerURL, p.client)
+	}
+	return s, nil
+}
+
+// OpenAISession keeps the chat history for one logical conversation.
+// PrepareForResume keeps the history for the next SendPrompt; otherwise
+// each SendPrompt starts from an empty conversation.
+type OpenAISession struct {
+	provider      *OpenAICompatibleProvider
+	sessionID     string
+	model         string
+	contextWindow int
+	mcp           *mcpHTTPClient
+
+	mu         sync.Mutex
+	messages   []oaiMessage
+	tools      []oaiTool
+	lastUsage  *SessionUsage
+	isResuming bool
+	// contextTokens is the prompt size of the last completion request, the
+	// current context size. lastUsage sums every round and overstates it.
+	contextTokens int
+}
+
+func (s *OpenAISession) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+func (s *OpenAISession) ShouldReset() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastUsage == nil {
+		return false
+	}
+	threshold := s.contextWindow
+	if threshold <= 0 {
+		threshold = MaxContextTokensDefault
+	}
+	if s.contextTokens > threshold {
+		log.Printf("[openai-session] Context tokens %d exceeds threshold %d, should reset", s.contextTokens, threshold)
+		return true
+	}
+	return false
+}
+
+func (s *OpenAISession) PrepareForResume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isResuming = true
+}
+
+func (s *OpenAISession) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionID = generateOpenAISessionID()
+	s.messages = nil
+	s.lastUsage = nil
+	s.contextTokens = 0
+	s.isResuming = false
+	log.Printf("[openai-session] Session reset, new ID: %s", s.sessionID)
+}
+
+func (s *OpenAISession) LastUsage() *SessionUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsage
+}
+
+func (s *OpenAISession) Close() error { return nil }
+
+func (s *OpenAISession) SendPrompt(ctx context.Context, prompt string, cb StreamCallbacks) (*SessionResult, error) {
+	start := time.Now()
+
+	s.mu.Lock()
+	if !s.isResuming {
+		s.messages = nil
+	}
+	s.isResuming = false
+	s.messages = append(s.messages, oaiMessage{Role: "user", Content: prompt})
+	s.mu.Unlock()
+
+	// Tools are listed every turn so plugin reloads and proxy restarts show
+	// up; a failed listing keeps the previous turn's tools.
+	if s.mcp != nil {
+		tools, err := s.mcp.listTools(ctx)
+		if err != nil {
+			log.Printf("[openai-session] Warning: failed to list MCP tools, keeping the last turn's: %v", err)
+		} else {
+			s.mu.Lock()
+			changed := len(tools) != len(s.tools)
+			s.tools = tools
+			s.mu.Unlock()
+			if changed {
+				log.Printf("[openai-session] Loaded %d MCP tools", len(tools))
+			}
+		}
+	}
+
+	usage := &SessionUsage{ContextWindow: s.contextWindow, MaxOutputTokens: s.provider.maxOutputTokens}
+	var apiTime time.Duration
+	finish := func() *SessionResult {
+		usage.DurationMs = int(time.Since(start).Milliseconds())
+		usage.DurationApiMs = int(apiTime.Milliseconds())
+		s.mu.Lock()
+		s.lastUsage = usage
+		id := s.sessionID
+		s.mu.Unlock()
+		if cb.OnResult != nil {
+			cb.OnResult(usage)
+		}
+		return &SessionResult{SessionID: id, Usage: usage}
+	}
+
+	for round := 0; round < maxToolRounds; round++ {
+		callStart := time.Now()
+		turn, err := s.streamCompletion(ctx, cb)
+		apiTime += time.Since(callStart)
+		if turn != nil {
+			usage.NumTurns++
+			// Each round re-sends and is billed for the full history, so
+			// usage sums the rounds while the last round's prompt size is
+			// the current context size.
+			usage.InputTokens += turn.promptTokens - turn.cachedTokens
+			usage.CacheReadInputTokens += turn.cachedTokens
+			usage.OutputTokens += turn.completionTokens
+			s.mu.Lock()
+			s.contextTokens = turn.promptTokens
+			s.mu.Unlock()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				finish()
+				return nil, ErrInterrupted
+			}
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.messages = append(s.messages, turn.message)
+		s.mu.Unlock()
+
+		if len(turn.message.ToolCalls) == 0 {
+			return finish(), nil
+		}
+
+		for _, call := range turn.message.ToolCalls {
+			result := s.runToolCall(ctx, call, cb)
+			s.mu.Lock()
+			s.messages = append(s.messages, oaiMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    result,
+			})
+			s.mu.Unlock()
+			if ctx.Err() != nil {
+				// signal_done (or a P1 interrupt) cancelled the session mid-loop.
+				finish()
+				return nil, ErrInterrupted
+			}
+		}
+	}
+
+	log.Printf("[openai-session] Stopping after %d tool rounds", maxToolRounds)
+	return finish(), nil
+}
+
+// runToolCall reports the call via OnTool and executes it on the MCP server.
+// Failures are returned to the model as tool output rather than aborting.
+func (s *OpenAISession) runToolCall(ctx context.Context, call oaiToolCall, cb StreamCallbacks) string {
+	var args map[string]any
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			return fmt.Sprintf("Error: invalid JSON arguments for %s: %v", call.Function.Name, err)
+		}
+	}
+	if cb.OnTool != nil {
+		cb.OnTool(call.Function.Name, args)
+	}
+	if s.mcp == nil {
+		return fmt.Sprintf("Error: tool %s unavailable (no MCP server configured)", call.Function.Name)
+	}
+	result, err := s.mcp.callTool(ctx, call.Function.Name, args)
+	if err != nil {
+		log.Printf("[openai-session] Tool %s failed: %v", call.Function.Name, err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return result
+}
+
+// oaiTurn is one assistant response assembled from the SSE stream.
+type oaiTurn struct {
+	message          oaiMessage
+	promptTokens     int
+	completionTokens int
+	cachedTokens     int
+}
+
+func (s *OpenAISession) streamCompletion(ctx context.Context, cb StreamCallbacks) (*oaiTurn, error) {
+	s.mu.Lock()
+	body := map[string]any{
+		"model":          s.model,
+		"messages":       s.messages,
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+	}
+	if len(s.tools) > 0 {
+		body["tools"] = s.tools
+	}
+	s.mu.Unlock()
+	if s.provider.maxOutputTokens > 0 {
+		body["max_tokens"] = s.provider.maxOutputTokens
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.provider.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if s.provider.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.provider.apiKey)
+	}
+
+	resp, err := s.provider.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai API error (status %d): %s", resp.StatusCode, truncateResp(respBody))
+	}
+
+	return parseChatStream(resp.Body, cb)
+}
+
+// parseChatStream reads an OpenAI SSE stream, firing OnText/OnThinking as
+// deltas arrive and assembling tool calls by their stream index.
+func parseChatStream(r io.Reader, cb StreamCallbacks) (*oaiTurn, error) {
+	turn := &oaiTurn{message: oaiMessage{Role: "assistant"}}
+	var text strings.Builder
+	calls := map[int]*oaiToolCall{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk oaiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("[openai-session] Warning: skipping malformed chunk: %v", err)
+			continue
+		}
+		if chunk.Usage != nil {
+			turn.promptTokens = chunk.Usage.PromptTokens
+			turn.completionTokens = chunk.Usage.CompletionTokens
+			if chunk.Usage.PromptTokensDetails != nil {
+				turn.cachedTokens = chunk.Usage.PromptTokensDetails.CachedTokens
+			}
+		}
+		for _, choice := range chunk.Choices {
+			d := choice.Delta
+			if d.ReasoningContent != "" && cb.OnThinking != nil {
+				cb.OnThinking(d.ReasoningContent)
+			}
+			if d.Content != "" {
+				text.WriteString(d.Content)
+				if cb.OnText != nil {
+					cb.OnText(d.Content)
+				}
+			}
+			for _, tc := range d.ToolCalls {
+				call, ok := calls[tc.Index]
+				if !ok {
+					call = &oaiToolCall{Type: "function"}
+					calls[tc.Index] = call
+				}
+				if tc.ID != "" {
+					call.ID = tc.ID
+				}
+				if tc.Function.Name != "" {
+					call.Function.Name = tc.Function.Name
+				}
+				call.Function.Arguments += tc.Function.Arguments
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return turn, fmt.Errorf("reading stream: %w", err)
+	}
+
+	turn.message.Content = text.String()
+	indexes := make([]int, 0, len(calls))
+	for i := range calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		call := calls[i]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", i)
+		}
+		turn.message.ToolCalls = append(turn.message.ToolCalls, *call)
+	}
+
+	if turn.completionTokens == 0 {
+		turn.completionTokens = estimateTokens(text.Len())
+	}
+	return turn, nil
+}
+
+// OpenAI wire types
+
+type oaiMessage struct {
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	ToolCalls  []oaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+type oaiToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type oaiTool struct {
+	Type     string          `json:"type"`
+	Function oaiFunctionSpec `json:"function"`
+}
+
+type oaiFunctionSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type oaiStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+// mcpHTTPClient is a minimal JSON-RPC client for bud's MCP HTTP endpoint,
+// covering just tools/list and tools/call.
+type mcpHTTPClient struct {
+	url    string
+	client *http.Client
+	nextID atomic.Int64
+}
+
+func newMCPHTTPClient(url string, client *http.Client) *mcpHTTPClient {
+	return &mcpHTTPClient{url: url, client: client}
+}
+
+func (c *mcpHTTPClient) call(ctx context.Context, method string, params any, result any) error {
+	payload, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      c.nextID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read MCP response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("MCP %s failed (status %d): %s", method, resp.StatusCode, truncateResp(body))
+	}
+
+	var rpc struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &rpc); err != nil {
+		return fmt.Errorf("failed to parse MCP response: %w", err)
+	}
+	if rpc.Error != nil {
+		return fmt.Errorf("MCP %s error %d: %s", method, rpc.Error.Code, rpc.Error.Message)
+	}
+	return json.Unmarshal(rpc.Result, result)
+}
+
+func (c *mcpHTTPClient) listTools(ctx context.Context) ([]oaiTool, error) {
+	var result struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			InputSchema json.RawMessage `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := c.call(ctx, "tools/list", map[string]any{}, &result); err != nil {
+		return nil, err
+	}
+	tools := make([]oaiTool, 0, len(result.Tools))
+	for _, t := range result.Tools {
+		tools = append(tools, oaiTool{
+			Type: "function",
+			Function: oaiFunctionSpec{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		})
+	}
+	return tools, nil
+}
+
+func (c *mcpHTTPClient) callTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, c := range result.Content {
+		if c.Type == "text" {
+			parts = append(parts, c.Text)
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+func generateOpenAISessionID() string {
+	return fmt.Sprintf("bud-oai-%d", time.Now().UnixNano())
+}
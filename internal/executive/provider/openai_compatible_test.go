@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseChatStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"choices":[{"delta":{"reasoning_content":"hmm"}}]}`,
+		`data: {"choices":[{"delta":{"content":"Hel"}}]}`,
+		`data: {"choices":[{"delta":{"content":"lo"}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c1","function":{"name":"talk_to_user","arguments":"{\"mess"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"age\":\"hi\"}"}}]}}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":9,"prompt_tokens_details":{"cached_tokens":100}}}`,
+		`data: [DONE]`,
+	}, "\n\n")
+
+	var text, thinking []string
+	turn, err := parseChatStream(strings.NewReader(stream), StreamCallbacks{
+		OnText:     func(s string) { text = append(text, s) },
+		OnThinking: func(s string) { thinking = append(thinking, s) },
+	})
+	if err != nil {
+		t.Fatalf("parseChatStream: %v", err)
+	}
+	if got := strings.Join(text, ""); got != "Hello" {
+		t.Errorf("text = %q, want %q", got, "Hello")
+	}
+	if len(thinking) != 1 || thinking[0] != "hmm" {
+		t.Errorf("thinking = %v, want [hmm]", thinking)
+	}
+	if len(turn.message.ToolCalls) != 1 {
+		t.Fatalf("tool calls = %d, want 1", len(turn.message.ToolCalls))
+	}
+	call := turn.message.ToolCalls[0]
+	if call.ID != "c1" || call.Function.Name != "talk_to_user" || call.Function.Arguments != `{"message":"hi"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if turn.promptTokens != 120 || turn.cachedTokens != 100 || turn.completionTokens != 9 {
+		t.Errorf("usage = prompt %d cached %d completion %d", turn.promptTokens, turn.cachedTokens, turn.completionTokens)
+	}
+}
+
+// TestOpenAISessionToolLoop runs one prompt through a fake chat server that
+// asks for a tool call, then answers with text once the tool result arrives.
+func TestOpenAISessionToolLoop(t *testing.T) {
+	var toolCalls []string
+	mcpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string          `json:"method"`
+			ID     any             `json:"id"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result any
+		switch req.Method {
+		case "tools/list":
+			result = map[string]any{"tools": []map[string]any{{
+				"name":        "talk_to_user",
+				"description": "Send a message",
+				"inputSchema": map[string]any{"type": "object"},
+			}}}
+		case "tools/call":
+			var p struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(req.Params, &p)
+			toolCalls = append(toolCalls, p.Name)
+			result = map[string]any{"content": []map[string]any{{"type": "text", "text": "sent"}}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer mcpSrv.Close()
+
+	rounds := 0
+	chatSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Tools    []oaiTool    `json:"tools"`
+			Messages []oaiMessage `json:"messages"`
+		}
+		json.Unmarshal(body, &req)
+		if len(req.Tools) != 1 {
+			t.Errorf("round %d: tools = %d, want 1", rounds, len(req.Tools))
+		}
+		rounds++
+		w.Header().Set("Content-Type", "text/event-stream")
+		if rounds == 1 {
+			fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c1","function":{"name":"talk_to_user","arguments":"{}"}}]}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":50,"completion_tokens":5}}`+"\n\n")
+		} else {
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role != "tool" || last.Content != "sent" {
+				t.Errorf("last message = %+v, want tool result", last)
+			}
+			fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"done"}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":70,"completion_tokens":2,"prompt_tokens_details":{"cached_tokens":40}}}`+"\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer chatSrv.Close()
+
+	p := NewOpenAICompatibleProvider("", "local-model", chatSrv.URL).WithContextWindow(100)
+	sess, err := p.NewSession(SessionOpts{MCPServerURL: mcpSrv.URL})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+
+	var text strings.Builder
+	var seen []string
+	result, err := sess.SendPrompt(context.Background(), "hello", StreamCallbacks{
+		OnText: func(s string) { text.WriteString(s) },
+		OnTool: func(name string, _ map[string]any) { seen = append(seen, name) },
+	})
+	if err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if text.String() != "done" {
+		t.Errorf("text = %q, want done", text.String())
+	}
+	if len(seen) != 1 || len(toolCalls) != 1 {
+		t.Errorf("OnTool saw %v, MCP saw %v; want one talk_to_user each", seen, toolCalls)
+	}
+	// Both rounds are billed: 50 uncached, then 30 uncached and 40 cached.
+	if u := result.Usage; u.NumTurns != 2 || u.OutputTokens != 7 || u.InputTokens != 80 || u.CacheReadInputTokens != 40 {
+		t.Errorf("usage = %+v", result.Usage)
+	}
+	// The context is the last round's 70-token prompt, not the 120 billed.
+	if sess.ShouldReset() {
+		t.Error("ShouldReset with a 70-token context in a 100-token window")
+	}
+}
+
+// TestOpenAISessionRefreshesTools verifies that each turn lists the MCP tools
+// again, so tools added after the first turn reach the model.
+func TestOpenAISessionRefreshesTools(t *testing.T) {
+	tools := []string{"talk_to_user"}
+	mcpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID any `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var list []map[string]any
+		for _, name := range tools {
+			list = append(list, map[string]any{"name": name, "inputSchema": map[string]any{"type": "object"}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]any{"tools": list}})
+	}))
+	defer mcpSrv.Close()
+
+	var offered [][]string
+	chatSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Tools []oaiTool `json:"tools"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var names []string
+		for _, tool := range req.Tools {
+			names = append(names, tool.Function.Name)
+		}
+		offered = append(offered, names)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"ok"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer chatSrv.Close()
+
+	sess, err := NewOpenAICompatibleProvider("", "local-model", chatSrv.URL).NewSession(SessionOpts{MCPServerURL: mcpSrv.URL})
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	if _, err := sess.SendPrompt(context.Background(), "one", StreamCallbacks{}); err != nil {
+		t.Fatalf("first SendPrompt: %v", err)
+	}
+	tools = append(tools, "notes_search")
+	sess.PrepareForResume()
+	if _, err := sess.SendPrompt(context.Background(), "two", StreamCallbacks{}); err != nil {
+		t.Fatalf("second SendPrompt: %v", err)
+	}
+	if len(offered) != 2 || len(offered[0]) != 1 || len(offered[1]) != 2 || offered[1][1] != "notes_search" {
+		t.Errorf("offered tools = %v, want [[talk_to_user] [talk_to_user notes_search]]", offered)
+	}
+}
+
+func TestOpenAISessionResetClearsHistory(t *testing.T) {
+	s := &OpenAISession{
+		provider:  NewOpenAICompatibleProvider("", "m", ""),
+		sessionID: "old",
+		messages:  []oaiMessage{{Role: "user", Content: "x"}},
+		lastUsage: &SessionUsage{InputTokens: 10},
+	}
+	s.Reset()
+	if s.SessionID() == "old" {
+		t.Error("Reset should assign a new session ID")
+	}
+	if len(s.messages) != 0 || s.LastUsage() != nil {
+		t.Error("Reset should clear history and usage")
+	}
+}
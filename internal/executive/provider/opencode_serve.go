@@ -51,6 +51,8 @@ func NewOpenCodeServeProvider(binPath, apiKey, model, baseURL string) *OpenCodeS
 
 func (p *OpenCodeServeProvider) Name() string { return "opencode-serve" }
 
+func (p *OpenCodeServeProvider) Model() string { return p.model }
+
 func (p *OpenCodeServeProvider) WithContextWindow(tokens int) *OpenCodeServeProvider {
 	p.contextWindow = tokens
 	return p
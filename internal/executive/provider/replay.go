@@ -0,0 +1,369 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript is the fixture format for ReplayProvider and RecordingProvider:
+// an ordered list of turns, one per SendPrompt call, across all sessions.
+type Transcript struct {
+	Turns []TranscriptTurn `json:"turns"`
+}
+
+// TranscriptTurn is the model's side of one SendPrompt call.
+type TranscriptTurn struct {
+	// Match, when set, must be a substring of the prompt or replay fails.
+	// Used to catch fixtures drifting out of sync with the executive.
+	Match string `json:"match,omitempty"`
+	// Prompt is the prompt that was sent when the turn was recorded.
+	// Informational only; replay ignores it.
+	Prompt string            `json:"prompt,omitempty"`
+	Events []TranscriptEvent `json:"events"`
+	Usage  *SessionUsage     `json:"usage,omitempty"`
+	// Error, when set, is returned from SendPrompt after the events are
+	// replayed. "interrupted" maps to ErrInterrupted.
+	Error string `json:"error,omitempty"`
+}
+
+// TranscriptEvent is a single streamed item. Exactly one of Text, Thinking
+// or Tool is set.
+type TranscriptEvent struct {
+	Text     string         `json:"text,omitempty"`
+	Thinking string         `json:"thinking,omitempty"`
+	Tool     string         `json:"tool,omitempty"`
+	Input    map[string]any `json:"input,omitempty"`
+}
+
+// LoadTranscript reads a transcript fixture from a JSON file.
+func LoadTranscript(path string) (*Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading transcript %s: %w", path, err)
+	}
+	var t Transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing transcript %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Save writes the transcript atomically (temp file + rename).
+func (t *Transcript) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ErrTranscriptExhausted is returned when a replay session is asked for more
+// turns than the transcript contains.
+var ErrTranscriptExhausted = errors.New("replay transcript exhausted")
+
+// ReplayProvider is a deterministic provider that plays back a Transcript.
+// Turns are consumed in order by every session it creates, so an executive
+// that resets its session keeps advancing through the same fixture.
+//
+// When a session has an MCPServerURL, tool events are also executed against
+// that server, exercising bud's real tool handlers; otherwise they are only
+// reported through StreamCallbacks.OnTool.
+type ReplayProvider struct {
+	mu            sync.Mutex
+	turns         []TranscriptTurn
+	next          int
+	sessions      int
+	contextWindow int
+	client        *http.Client
+}
+
+func NewReplayProvider(t *Transcript) *ReplayProvider {
+	p := &ReplayProvider{
+		contextWindow: MaxContextTokensDefault,
+		client:        &http.Client{Timeout: 5 * time.Minute},
+	}
+	if t != nil {
+		p.turns = append(p.turns, t.Turns...)
+	}
+	return p
+}
+
+func (p *ReplayProvider) Name() string { return "replay" }
+
+func (p *ReplayProvider) WithContextWindow(tokens int) *ReplayProvider {
+	p.contextWindow = tokens
+	return p
+}
+
+// Append adds turns to the end of the script. Safe to call while sessions
+// are running, which lets a test queue each reply just before it is needed.
+func (p *ReplayProvider) Append(turns ...TranscriptTurn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.turns = append(p.turns, turns...)
+}
+
+// Remaining returns the number of turns not yet replayed.
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.turns) - p.next
+}
+
+func (p *ReplayProvider) nextTurn() (TranscriptTurn, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next >= len(p.turns) {
+		return TranscriptTurn{}, p.next, ErrTranscriptExhausted
+	}
+	t := p.turns[p.next]
+	p.next++
+	return t, p.next - 1, nil
+}
+
+func (p *ReplayProvider) newSessionID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions++
+	return fmt.Sprintf("bud-replay-%d", p.sessions)
+}
+
+func (p *ReplayProvider) NewSession(opts SessionOpts) (Session, error) {
+	s := &ReplaySession{
+		provider:      p,
+		sessionID:     p.newSessionID(),
+		contextWindow: p.contextWindow,
+	}
+	if opts.MCPServerURL != "" {
+		s.mcp = newMCPHTTPClient(opts.MCPServerURL, p.client)
+	}
+	return s, nil
+}
+
+// ReplaySession replays turns from its ReplayProvider.
+type ReplaySession struct {
+	provider      *ReplayProvider
+	mcp           *mcpHTTPClient
+	contextWindow int
+
+	mu         sync.Mutex
+	sessionID  string
+	lastUsage  *SessionUsage
+	isResuming bool
+}
+
+func (s *ReplaySession) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+func (s *ReplaySession) ShouldReset() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastUsage == nil {
+		return false
+	}
+	threshold := s.contextWindow
+	if threshold <= 0 {
+		threshold = MaxContextTokensDefault
+	}
+	return s.lastUsage.CacheReadInputTokens+s.lastUsage.InputTokens > threshold
+}
+
+func (s *ReplaySession) PrepareForResume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isResuming = true
+}
+
+func (s *ReplaySession) Reset() {
+	id := s.provider.newSessionID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionID = id
+	s.lastUsage = nil
+	s.isResuming = false
+}
+
+func (s *ReplaySession) LastUsage() *SessionUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsage
+}
+
+func (s *ReplaySession) Close() error { return nil }
+
+func (s *ReplaySession) SendPrompt(ctx context.Context, prompt string, cb StreamCallbacks) (*SessionResult, error) {
+	turn, idx, err := s.provider.nextTurn()
+	if err != nil {
+		return nil, err
+	}
+	if turn.Match != "" && !strings.Contains(prompt, turn.Match) {
+		return nil, fmt.Errorf("replay turn %d: prompt does not contain %q", idx, turn.Match)
+	}
+
+	for _, ev := range turn.Events {
+		if ctx.Err() != nil {
+			return nil, ErrInterrupted
+		}
+		switch {
+		case ev.Thinking != "":
+			if cb.OnThinking != nil {
+				cb.OnThinking(ev.Thinking)
+			}
+		case ev.Text != "":
+			if cb.OnText != nil {
+				cb.OnText(ev.Text)
+			}
+		case ev.Tool != "":
+			if cb.OnTool != nil {
+				cb.OnTool(ev.Tool, ev.Input)
+			}
+			if name, ok := budToolName(ev.Tool); ok && s.mcp != nil {
+				// Detached context: signal_done cancels ctx from inside its
+				// own handler, and that call must still complete.
+				// Failures are logged rather than fatal, matching how a live
+				// model sees a tool error and carries on.
+				if _, err := s.mcp.callTool(context.Background(), name, ev.Input); err != nil {
+					log.Printf("[replay] Turn %d: tool %s failed: %v", idx, ev.Tool, err)
+				}
+			}
+		}
+	}
+
+	usage := turn.Usage
+	if usage == nil {
+		usage = &SessionUsage{NumTurns: 1}
+	}
+	s.mu.Lock()
+	s.lastUsage = usage
+	s.isResuming = false
+	id := s.sessionID
+	s.mu.Unlock()
+	if cb.OnResult != nil {
+		cb.OnResult(usage)
+	}
+
+	switch turn.Error {
+	case "":
+	case "interrupted":
+		return nil, ErrInterrupted
+	default:
+		return nil, errors.New(turn.Error)
+	}
+	return &SessionResult{SessionID: id, Usage: usage}, nil
+}
+
+// budToolName maps a recorded tool name to the name bud's MCP server
+// registers. Claude Code reports MCP tools as mcp__<server>__<tool>; tools
+// from other servers are not replayed against bud's.
+func budToolName(recorded string) (string, bool) {
+	if !strings.HasPrefix(recorded, "mcp__") {
+		return recorded, true
+	}
+	if name, ok := strings.CutPrefix(recorded, "mcp__bud2__"); ok {
+		return name, true
+	}
+	return "", false
+}
+
+// RecordingProvider wraps another provider and appends every SendPrompt it
+// sees to a Transcript file, producing fixtures for ReplayProvider. The file
+// is rewritten after each turn so a crash loses at most the turn in flight.
+type RecordingProvider struct {
+	inner Provider
+	path  string
+
+	mu         sync.Mutex
+	transcript Transcript
+}
+
+func NewRecordingProvider(inner Provider, path string) *RecordingProvider {
+	return &RecordingProvider{inner: inner, path: path}
+}
+
+func (p *RecordingProvider) Name() string { return p.inner.Name() }
+
+func (p *RecordingProvider) NewSession(opts SessionOpts) (Session, error) {
+	sess, err := p.inner.NewSession(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSession{Session: sess, recorder: p}, nil
+}
+
+func (p *RecordingProvider) record(turn TranscriptTurn) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transcript.Turns = append(p.transcript.Turns, turn)
+	return p.transcript.Save(p.path)
+}
+
+type recordingSession struct {
+	Session
+	recorder *RecordingProvider
+}
+
+func (s *recordingSession) SendPrompt(ctx context.Context, prompt string, cb StreamCallbacks) (*SessionResult, error) {
+	turn := TranscriptTurn{Prompt: prompt}
+	var mu sync.Mutex
+	add := func(ev TranscriptEvent) {
+		mu.Lock()
+		turn.Events = append(turn.Events, ev)
+		mu.Unlock()
+	}
+	wrapped := cb
+	wrapped.OnText = func(text string) {
+		add(TranscriptEvent{Text: text})
+		if cb.OnText != nil {
+			cb.OnText(text)
+		}
+	}
+	wrapped.OnThinking = func(text string) {
+		add(TranscriptEvent{Thinking: text})
+		if cb.OnThinking != nil {
+			cb.OnThinking(text)
+		}
+	}
+	wrapped.OnTool = func(name string, input map[string]any) {
+		add(TranscriptEvent{Tool: name, Input: input})
+		if cb.OnTool != nil {
+			cb.OnTool(name, input)
+		}
+	}
+
+	result, err := s.Session.SendPrompt(ctx, prompt, wrapped)
+	if result != nil {
+		turn.Usage = result.Usage
+	} else {
+		turn.Usage = s.Session.LastUsage()
+	}
+	if err != nil {
+		if errors.Is(err, ErrInterrupted) {
+			turn.Error = "interrupted"
+		} else {
+			turn.Error = err.Error()
+		}
+	}
+	if recErr := s.recorder.record(turn); recErr != nil {
+		log.Printf("[recording] Warning: failed to write transcript %s: %v", s.recorder.path, recErr)
+	}
+	return result, err
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayProviderPlaysTurnsInOrder(t *testing.T) {
+	p := NewReplayProvider(&Transcript{Turns: []TranscriptTurn{
+		{
+			Match: "hello",
+			Events: []TranscriptEvent{
+				{Thinking: "greeting"},
+				{Text: "Hi"},
+				{Tool: "talk_to_user", Input: map[string]any{"message": "Hi there"}},
+			},
+			Usage: &SessionUsage{InputTokens: 100, OutputTokens: 10},
+		},
+		{Events: []TranscriptEvent{{Text: "second"}}},
+	}})
+	sess, _ := p.NewSession(SessionOpts{})
+
+	var got []string
+	cb := StreamCallbacks{
+		OnThinking: func(s string) { got = append(got, "thinking:"+s) },
+		OnText:     func(s string) { got = append(got, "text:"+s) },
+		OnTool:     func(name string, in map[string]any) { got = append(got, "tool:"+name+":"+in["message"].(string)) },
+	}
+	result, err := sess.SendPrompt(context.Background(), "say hello", cb)
+	if err != nil {
+		t.Fatalf("turn 1: %v", err)
+	}
+	want := "thinking:greeting,text:Hi,tool:talk_to_user:Hi there"
+	if strings.Join(got, ",") != want {
+		t.Errorf("events = %v, want %s", got, want)
+	}
+	if result.Usage.InputTokens != 100 || sess.LastUsage().OutputTokens != 10 {
+		t.Errorf("usage = %+v", result.Usage)
+	}
+
+	// A fresh session continues from the same cursor.
+	sess2, _ := p.NewSession(SessionOpts{})
+	got = nil
+	if _, err := sess2.SendPrompt(context.Background(), "anything", cb); err != nil {
+		t.Fatalf("turn 2: %v", err)
+	}
+	if len(got) != 1 || got[0] != "text:second" {
+		t.Errorf("turn 2 events = %v", got)
+	}
+	if sess2.SessionID() == sess.SessionID() {
+		t.Error("sessions should get distinct IDs")
+	}
+
+	if _, err := sess.SendPrompt(context.Background(), "more", cb); !errors.Is(err, ErrTranscriptExhausted) {
+		t.Errorf("err = %v, want ErrTranscriptExhausted", err)
+	}
+}
+
+func TestReplayProviderMatchAndErrors(t *testing.T) {
+	p := NewReplayProvider(&Transcript{Turns: []TranscriptTurn{
+		{Match: "expected"},
+		{Error: "interrupted"},
+	}})
+	sess, _ := p.NewSession(SessionOpts{})
+	if _, err := sess.SendPrompt(context.Background(), "something else", StreamCallbacks{}); err == nil {
+		t.Error("mismatched prompt should fail")
+	}
+	if _, err := sess.SendPrompt(context.Background(), "x", StreamCallbacks{}); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("err = %v, want ErrInterrupted", err)
+	}
+}
+
+func TestReplaySessionShouldReset(t *testing.T) {
+	p := NewReplayProvider(&Transcript{Turns: []TranscriptTurn{
+		{Usage: &SessionUsage{InputTokens: 600, CacheReadInputTokens: 500}},
+	}}).WithContextWindow(1000)
+	sess, _ := p.NewSession(SessionOpts{})
+	if sess.ShouldReset() {
+		t.Error("new session should not need reset")
+	}
+	sess.SendPrompt(context.Background(), "x", StreamCallbacks{})
+	if !sess.ShouldReset() {
+		t.Error("usage over context window should trigger reset")
+	}
+	sess.Reset()
+	if sess.ShouldReset() || sess.LastUsage() != nil {
+		t.Error("Reset should clear usage")
+	}
+}
+
+func TestRecordingProviderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	inner := NewReplayProvider(&Transcript{Turns: []TranscriptTurn{{
+		Events: []TranscriptEvent{
+			{Text: "hello"},
+			{Tool: "signal_done", Input: map[string]any{"summary": "ok"}},
+		},
+		Usage: &SessionUsage{OutputTokens: 3},
+	}}})
+	rec := NewRecordingProvider(inner, path)
+	sess, _ := rec.NewSession(SessionOpts{})
+	var text string
+	if _, err := sess.SendPrompt(context.Background(), "the prompt", StreamCallbacks{
+		OnText: func(s string) { text += s },
+	}); err != nil {
+		t.Fatalf("SendPrompt: %v", err)
+	}
+	if text != "hello" {
+		t.Errorf("callbacks should still reach the caller, got %q", text)
+	}
+
+	loaded, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatalf("LoadTranscript: %v", err)
+	}
+	if len(loaded.Turns) != 1 {
+		t.Fatalf("turns = %d, want 1", len(loaded.Turns))
+	}
+	turn := loaded.Turns[0]
+	if turn.Prompt != "the prompt" || len(turn.Events) != 2 || turn.Events[1].Tool != "signal_done" || turn.Usage.OutputTokens != 3 {
+		t.Errorf("recorded turn = %+v", turn)
+	}
+}
@@ -0,0 +1,229 @@
+package executive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/budget"
+	"github.com/vthunder/bud2/internal/executive/provider"
+	"github.com/vthunder/bud2/internal/focus"
+)
+
+// newReplayExecutive creates an ExecutiveV2 driven by a ReplayProvider, with
+// the SendMessageFallback calls captured for inspection.
+func newReplayExecutive(t *testing.T, turns ...provider.TranscriptTurn) (*ExecutiveV2, *provider.ReplayProvider, *[]string) {
+	t.Helper()
+	replay := provider.NewReplayProvider(&provider.Transcript{Turns: turns}).WithContextWindow(1000)
+	var mu sync.Mutex
+	var fallbacks []string
+	exec := NewExecutiveV2(nil, t.TempDir(), ExecutiveV2Config{
+		Provider:     replay,
+		ProviderName: "replay",
+		SendMessageFallback: func(channelID, message string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			fallbacks = append(fallbacks, message)
+			return nil
+		},
+	})
+	if exec.providerSession == nil {
+		t.Fatal("expected provider session to be created")
+	}
+	return exec, replay, &fallbacks
+}
+
+func processUserMessage(t *testing.T, exec *ExecutiveV2, content string) {
+	t.Helper()
+	item := &focus.PendingItem{
+		ID:        "msg-" + content,
+		Type:      "message",
+		Priority:  focus.P1UserInput,
+		Source:    "discord",
+		Content:   content,
+		ChannelID: "test-channel",
+		Timestamp: time.Now(),
+	}
+	if err := exec.AddPending(item); err != nil {
+		t.Fatalf("AddPending: %v", err)
+	}
+	processed, err := exec.ProcessNextP1(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessNextP1: %v", err)
+	}
+	if !processed {
+		t.Fatal("expected item to be processed")
+	}
+	if n := exec.GetQueue().InFlight(); n != 0 {
+		t.Errorf("expected processed items to be acked, %d still in flight", n)
+	}
+}
+
+// TestProcessItem_ReplayToolRouting verifies that a talk_to_user call counts
+// as a response (no fallback) and is surfaced as a debug tool_call event.
+func TestProcessItem_ReplayToolRouting(t *testing.T) {
+	exec, replay, fallbacks := newReplayExecutive(t, provider.TranscriptTurn{
+		Events: []provider.TranscriptEvent{
+			{Thinking: "user said hi"},
+			{Tool: "mcp__bud2__talk_to_user", Input: map[string]any{"message": "hello"}},
+		},
+	})
+
+	var mu sync.Mutex
+	var tools []string
+	exec.AddDebugListener("test", func(ev DebugEvent) {
+		if ev.Type == DebugEventToolCall {
+			mu.Lock()
+			tools = append(tools, ev.Tool)
+			mu.Unlock()
+		}
+	})
+
+	processUserMessage(t, exec, "hi")
+
+	if replay.Remaining() != 0 {
+		t.Errorf("expected transcript to be consumed, %d turns left", replay.Remaining())
+	}
+	if len(*fallbacks) != 0 {
+		t.Errorf("expected no fallback message, got %q", *fallbacks)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tools) != 1 || tools[0] != "mcp__bud2__talk_to_user" {
+		t.Errorf("expected one talk_to_user debug event, got %v", tools)
+	}
+}
+
+// TestProcessItem_ReplayFallback verifies that a user message answered with
+// neither text nor a response tool triggers SendMessageFallback.
+func TestProcessItem_ReplayFallback(t *testing.T) {
+	exec, _, fallbacks := newReplayExecutive(t, provider.TranscriptTurn{
+		Events: []provider.TranscriptEvent{{Thinking: "nothing to say"}},
+	})
+
+	processUserMessage(t, exec, "hi")
+
+	if len(*fallbacks) != 1 {
+		t.Fatalf("expected one fallback message, got %d", len(*fallbacks))
+	}
+}
+
+// TestProcessItem_ReplayContextReset verifies that when the provider session
+// reports the context limit, the next item starts a fresh provider session.
+func TestProcessItem_ReplayContextReset(t *testing.T) {
+	reply := provider.TranscriptEvent{Tool: "talk_to_user", Input: map[string]any{"message": "ok"}}
+	exec, _, _ := newReplayExecutive(t,
+		provider.TranscriptTurn{
+			Events: []provider.TranscriptEvent{reply},
+			Usage:  &provider.SessionUsage{InputTokens: 5000, NumTurns: 1},
+		},
+		provider.TranscriptTurn{Events: []provider.TranscriptEvent{reply}},
+	)
+
+	firstID := exec.providerSession.SessionID()
+	processUserMessage(t, exec, "first")
+	if !exec.providerSession.ShouldReset() {
+		t.Fatal("expected ShouldReset after exceeding context window")
+	}
+
+	processUserMessage(t, exec, "second")
+	if exec.providerSession.SessionID() == firstID {
+		t.Error("expected provider session to be reset after context limit")
+	}
+	if exec.providerSession.ShouldReset() {
+		t.Error("expected ShouldReset to clear after fresh session")
+	}
+}
+
+// resumeCountingSession counts PrepareForResume calls on a provider session.
+type resumeCountingSession struct {
+	provider.Session
+	resumes int
+}
+
+func (s *resumeCountingSession) PrepareForResume() {
+	s.resumes++
+	s.Session.PrepareForResume()
+}
+
+// TestProcessItem_ReplayResume verifies that the provider session is told to
+// resume once a turn has run in it, and not for the first turn.
+func TestProcessItem_ReplayResume(t *testing.T) {
+	reply := provider.TranscriptEvent{Tool: "talk_to_user", Input: map[string]any{"message": "ok"}}
+	exec, _, _ := newReplayExecutive(t,
+		provider.TranscriptTurn{Events: []provider.TranscriptEvent{reply}},
+		provider.TranscriptTurn{Events: []provider.TranscriptEvent{reply}},
+	)
+	sess := &resumeCountingSession{Session: exec.providerSession}
+	exec.providerSession = sess
+
+	processUserMessage(t, exec, "first")
+	if sess.resumes != 0 {
+		t.Errorf("expected a fresh first turn, got %d resumes", sess.resumes)
+	}
+	processUserMessage(t, exec, "second")
+	if sess.resumes != 1 {
+		t.Errorf("expected the second turn to resume, got %d resumes", sess.resumes)
+	}
+	if !exec.session.IsResuming() {
+		t.Error("expected the executive session to resume with the provider")
+	}
+}
+
+// TestResetSession_ResetsProviderSession verifies that ResetSession (used
+// after memory_reset) also gives the provider a new session.
+func TestResetSession_ResetsProviderSession(t *testing.T) {
+	exec, _, _ := newReplayExecutive(t)
+	before := exec.providerSession.SessionID()
+	exec.ResetSession()
+	if exec.providerSession.SessionID() == before {
+		t.Error("expected new provider session ID after ResetSession")
+	}
+}
+
+// TestProcessItem_ProviderUsageModel verifies that a non-Claude provider
+// runs and records usage under its own model, not the Claude Model in the
+// executive config.
+func TestProcessItem_ProviderUsageModel(t *testing.T) {
+	var requested []string
+	chatSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		requested = append(requested, req.Model)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"hello"}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":40,"completion_tokens":3}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer chatSrv.Close()
+
+	statePath := t.TempDir()
+	tracker := budget.NewSessionTracker(statePath)
+	var records []budget.UsageRecord
+	tracker.SetOnRecord(func(rec budget.UsageRecord) { records = append(records, rec) })
+	exec := NewExecutiveV2(nil, statePath, ExecutiveV2Config{
+		Provider:       provider.NewOpenAICompatibleProvider("", "qwen3-32b", chatSrv.URL),
+		ProviderName:   "openai-compatible",
+		Model:          provider.DefaultModelClaude,
+		SessionTracker: tracker,
+	})
+
+	processUserMessage(t, exec, "hi")
+
+	if len(requested) != 1 || requested[0] != "qwen3-32b" {
+		t.Errorf("requested models = %v, want [qwen3-32b]", requested)
+	}
+	if len(records) != 1 {
+		t.Fatalf("usage records = %d, want 1", len(records))
+	}
+	if rec := records[0]; rec.Model != "qwen3-32b" || rec.Provider != "openai-compatible" || rec.InputTokens != 40 {
+		t.Errorf("usage record = %+v", rec)
+	}
+}
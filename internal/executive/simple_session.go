@@ -281,7 +281,9 @@ func pluginsManifestPath(statePath string) string {
 // loadManifestPlugins reads the plugins: section of plugins.yaml (or the
 // legacy extensions.yaml) and ensures each listed repo is cloned under
 // ~/.cache/bud/plugins/. Returns the resolved local paths for --plugin-dir.
-// Errors are logged and the failing entry is skipped — startup continues.
+// Repos recorded in plugins.lock are held at their locked commit; others are
+// updated and then recorded. Errors are logged and the failing entry is
+// skipped — startup continues.
 func loadManifestPlugins(statePath string) []string {
 	manifestPath := pluginsManifestPath(statePath)
 	data, err := os.ReadFile(manifestPath)
@@ -304,6 +306,9 @@ func loadManifestPlugins(statePath string) []string {
 		return nil
 	}
 	pluginCacheDir := filepath.Join(cacheBase, "bud", "plugins")
+	lock := loadPluginsLock(statePath)
+	lockChanged := false
+	defer func() { saveLock(lock, lockChanged) }()
 
 	var paths []string
 	for _, e := range manifest.Sources {
@@ -316,6 +321,7 @@ func loadManifestPlugins(statePath string) []string {
 		}
 
 		repoDir := filepath.Join(pluginCacheDir, e.owner, e.repo)
+		src := ManifestSource{Key: e.owner + "/" + e.repo, RepoDir: repoDir, Ref: e.ref}
 
 		if _, err := os.Stat(repoDir); os.IsNotExist(err) {
 			// Clone the repo.
@@ -331,8 +337,11 @@ func loadManifestPlugins(statePath string) []string {
 				continue
 			}
 			log.Printf("[plugins] cloned %s/%s", e.owner, e.repo)
-		} else {
-			// Repo already exists — update it.
+			if !syncLockedSource(lock, src) {
+				lockChanged = recordSource(lock, src) || lockChanged
+			}
+		} else if !syncLockedSource(lock, src) {
+			// Repo already exists and is not locked — update it.
 			if e.ref != "" {
 				// Pinned ref: fetch then checkout.
 				fetch := exec.Command("git", "-C", repoDir, "fetch", "--depth=1", "origin", e.ref)
@@ -353,6 +362,7 @@ func loadManifestPlugins(statePath string) []string {
 					// Non-fatal: keep using the existing checkout.
 				}
 			}
+			lockChanged = recordSource(lock, src) || lockChanged
 		}
 
 		localPath := repoDir
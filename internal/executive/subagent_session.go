@@ -123,6 +123,9 @@ type SubagentSession struct {
 	// Set once the log file is opened in runSession; empty if no log file.
 	LogPath string
 
+	// MCPServerURL is the (tokenized) bud2 MCP URL the session was given.
+	MCPServerURL string
+
 	// events is a capped log of recent subagent activity (tool calls, text).
 	events      []SubagentEvent
 	lastEventAt time.Time
@@ -222,6 +225,11 @@ type SubagentManager struct {
 
 	// Notify executive when a subagent completes or fails
 	DoneNotify chan *SubagentSession
+
+	// OnUsage is called with a subagent session's token usage when it
+	// finishes. providerName is "claude-code" for SDK sessions; model is
+	// empty when the provider default was used and is unknown.
+	OnUsage func(sessionID, providerName, model string, usage *provider.SessionUsage)
 }
 
 // NewSubagentManager creates a new manager and starts a background goroutine
@@ -302,6 +310,7 @@ func (m *SubagentManager) Spawn(ctx context.Context, cfg SubagentConfig) (*Subag
 		cancel:             taskCancel,
 		WorkflowInstanceID: cfg.WorkflowInstanceID,
 		WorkflowStep:       cfg.WorkflowStep,
+		MCPServerURL:       cfg.MCPServerURL,
 	}
 
 	m.mu.Lock()
@@ -570,6 +579,9 @@ func (m *SubagentManager) runProviderSession(ctx context.Context, session *Subag
 			if logFile != nil {
 				writeLog(logFile, "RESULT: input=%d output=%d", usage.InputTokens, usage.OutputTokens)
 			}
+			if m.OnUsage != nil {
+				m.OnUsage(newID, m.AgentProvider.Name(), modelOf(m.AgentProvider, cfg.Model), usage)
+			}
 		},
 		OnPermission: func(perm provider.PermissionRequest) provider.PermissionDecision {
 			log.Printf("[subagent-%s] Permission request: type=%s title=%s id=%s", newID[:8], perm.Type, truncate(perm.Title, 80), perm.ID)
@@ -793,9 +805,12 @@ func (m *SubagentManager) runClaudeSession(ctx context.Context, session *Subagen
 					Summary:  summary,
 				})
 			},
-			OnResult: func(m *claudecode.ResultMessage) {
+			OnResult: func(res *claudecode.ResultMessage) {
 				log.Printf("[subagent-%s] Claude session complete (turns=%d duration=%dms)",
-					session.ID[:8], m.NumTurns, m.DurationMs)
+					session.ID[:8], res.NumTurns, res.DurationMs)
+				if m.OnUsage != nil {
+					m.OnUsage(session.ID, "claude-code", cfg.Model, parseUsageFromResult(res))
+				}
 			},
 		}
 		receiveLoop(ctx, client, logFile, cb) //nolint:errcheck
@@ -80,6 +80,18 @@ func truncate(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
+// modelOf returns model, or the provider's default model when model is empty
+// and the provider reports one. Used to price usage records.
+func modelOf(p provider.Provider, model string) string {
+	if model != "" || p == nil {
+		return model
+	}
+	if m, ok := p.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
+
 // formatMemoryTimestamp formats a memory timestamp for display in prompts
 // Shows relative time if recent, otherwise shows date
 func formatMemoryTimestamp(t time.Time) string {
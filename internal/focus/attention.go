@@ -22,5 +22,54 @@ func New() *Attention {
 func (a *Attention) GetState() FocusState {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return a.state
+	state := a.state
+	state.Suspended = append([]*PendingItem(nil), a.state.Suspended...)
+	return state
+}
+
+// Focus makes item the current focus. An item already in focus is pushed
+// onto the suspended stack first.
+func (a *Attention) Focus(item *PendingItem) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state.CurrentItem != nil {
+		a.state.Suspended = append(a.state.Suspended, a.state.CurrentItem)
+	}
+	a.state.CurrentItem = item
+}
+
+// Suspend pushes the current item onto the suspended stack and clears focus.
+// Returns the suspended item, or nil if nothing was in focus.
+func (a *Attention) Suspend() *PendingItem {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	item := a.state.CurrentItem
+	if item == nil {
+		return nil
+	}
+	a.state.Suspended = append(a.state.Suspended, item)
+	a.state.CurrentItem = nil
+	return item
+}
+
+// Complete clears the current focus.
+func (a *Attention) Complete() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.state.CurrentItem = nil
+}
+
+// Resume pops the most recently suspended item and makes it the current
+// focus. Returns nil if an item is already in focus or nothing is suspended.
+func (a *Attention) Resume() *PendingItem {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := len(a.state.Suspended)
+	if a.state.CurrentItem != nil || n == 0 {
+		return nil
+	}
+	item := a.state.Suspended[n-1]
+	a.state.Suspended = a.state.Suspended[:n-1]
+	a.state.CurrentItem = item
+	return item
 }
@@ -0,0 +1,52 @@
+package focus
+
+import "testing"
+
+func TestAttention_SuspendAndResume(t *testing.T) {
+	a := New()
+	bg := &PendingItem{ID: "bg"}
+	other := &PendingItem{ID: "other"}
+
+	a.Focus(bg)
+	if got := a.Suspend(); got != bg {
+		t.Fatalf("expected Suspend to return bg, got %v", got)
+	}
+	if st := a.GetState(); st.CurrentItem != nil || len(st.Suspended) != 1 {
+		t.Fatalf("expected bg suspended with no focus, got %+v", st)
+	}
+
+	// Focusing a new item while something is in focus pushes the old one.
+	a.Focus(other)
+	a.Focus(&PendingItem{ID: "third"})
+	if st := a.GetState(); len(st.Suspended) != 2 || st.Suspended[1] != other {
+		t.Fatalf("expected other pushed on top of bg, got %+v", st.Suspended)
+	}
+
+	if got := a.Resume(); got != nil {
+		t.Fatalf("expected Resume to refuse while an item is in focus, got %v", got)
+	}
+	a.Complete()
+	if got := a.Resume(); got != other {
+		t.Fatalf("expected most recently suspended item first, got %v", got)
+	}
+	a.Complete()
+	if got := a.Resume(); got != bg {
+		t.Fatalf("expected bg next, got %v", got)
+	}
+	a.Complete()
+	if got := a.Resume(); got != nil {
+		t.Fatalf("expected empty stack, got %v", got)
+	}
+}
+
+func TestAttention_GetStateCopiesStack(t *testing.T) {
+	a := New()
+	a.Focus(&PendingItem{ID: "bg"})
+	a.Suspend()
+
+	st := a.GetState()
+	st.Suspended[0] = nil
+	if a.GetState().Suspended[0] == nil {
+		t.Error("expected GetState to return a copy of the suspended stack")
+	}
+}
@@ -0,0 +1,251 @@
+package focus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Journal record operations.
+const (
+	opAdd  = "add"  // item queued
+	opPop  = "pop"  // item handed to the executive (in flight)
+	opAck  = "ack"  // in-flight item finished processing
+	opDrop = "drop" // queued item discarded by trim
+	// opCheckpoint records the checkpoint of a preempted in-flight item,
+	// so a restart redelivers it with its resume point.
+	opCheckpoint = "checkpoint"
+)
+
+const (
+	// maxRedeliveries caps how often an unacked or nacked item is redelivered.
+	maxRedeliveries = 3
+	// nackBackoff is the delay before a nacked item's first retry; it
+	// doubles with each redelivery.
+	nackBackoff = 5 * time.Second
+	// compactMinRecords is the journal length below which compaction is
+	// never worth the rewrite.
+	compactMinRecords = 256
+)
+
+// journalRecord is one line of pending_queue.wal.
+type journalRecord struct {
+	Op   string       `json:"op"`
+	ID   string       `json:"id,omitempty"`
+	Item *PendingItem `json:"item,omitempty"`
+}
+
+func (q *Queue) journalPath() string {
+	return filepath.Join(q.path, "pending_queue.wal")
+}
+
+// appendLocked writes records to the journal, opening it on first use.
+// With sync set the write is fsynced before returning.
+func (q *Queue) appendLocked(sync bool, recs ...journalRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	if q.journal == nil {
+		if err := os.MkdirAll(q.path, 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(q.journalPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		q.journal = f
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if _, err := q.journal.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	q.records += len(recs)
+	if sync {
+		return q.journal.Sync()
+	}
+	return nil
+}
+
+// maybeCompactLocked rewrites the journal once it is mostly dead records.
+func (q *Queue) maybeCompactLocked() {
+	live := len(q.items) + len(q.inFlight)
+	if q.records < compactMinRecords || q.records < 4*live {
+		return
+	}
+	if err := q.compactLocked(); err != nil {
+		log.Printf("[queue] Warning: journal compaction failed: %v", err)
+	}
+}
+
+// compactLocked replaces the journal with the minimal set of records that
+// reproduces the current state: an add per queued item, and an add plus pop
+// per in-flight item. The new journal is written to a temp file, fsynced and
+// renamed into place.
+func (q *Queue) compactLocked() error {
+	if q.journal != nil {
+		q.journal.Close()
+		q.journal = nil
+	}
+	if err := os.MkdirAll(q.path, 0755); err != nil {
+		return err
+	}
+
+	var recs []journalRecord
+	for _, item := range q.items {
+		recs = append(recs, journalRecord{Op: opAdd, Item: item})
+	}
+	for _, item := range q.inFlight {
+		recs = append(recs, journalRecord{Op: opAdd, Item: item}, journalRecord{Op: opPop, ID: item.ID})
+	}
+
+	tmpPath := q.journalPath() + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, q.journalPath()); err != nil {
+		return err
+	}
+
+	journal, err := os.OpenFile(q.journalPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	q.journal = journal
+	q.records = len(recs)
+	return nil
+}
+
+// replayJournal reads the journal and returns the queued items and the items
+// that were popped but never acked, each in journal order. A torn final line
+// (crash mid-write) is skipped.
+func replayJournal(path string) (queued, inFlight []*PendingItem, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to open queue journal: %w", err)
+	}
+	defer f.Close()
+
+	type entry struct {
+		item     *PendingItem
+		inFlight bool
+		done     bool
+	}
+	var entries []*entry
+	// find returns the oldest live entry for id in the given state.
+	find := func(id string, inFlight bool) *entry {
+		for _, e := range entries {
+			if !e.done && e.inFlight == inFlight && e.item.ID == id {
+				return e
+			}
+		}
+		return nil
+	}
+
+	r := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec journalRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				log.Printf("[queue] Warning: skipping bad journal record at line %d: %v", lineNo, err)
+			} else {
+				switch rec.Op {
+				case opAdd:
+					if rec.Item != nil {
+						entries = append(entries, &entry{item: rec.Item})
+					}
+				case opPop:
+					if e := find(rec.ID, false); e != nil {
+						e.inFlight = true
+					}
+				case opAck:
+					if e := find(rec.ID, true); e != nil {
+						e.done = true
+					}
+				case opDrop:
+					if e := find(rec.ID, false); e != nil {
+						e.done = true
+					}
+				case opCheckpoint:
+					if e := find(rec.ID, true); e != nil && rec.Item != nil {
+						e.item.Checkpoint = rec.Item.Checkpoint
+					}
+				}
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return nil, nil, fmt.Errorf("failed to read queue journal: %w", readErr)
+		}
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.done:
+		case e.inFlight:
+			inFlight = append(inFlight, e.item)
+		default:
+			queued = append(queued, e.item)
+		}
+	}
+	return queued, inFlight, nil
+}
+
+// loadLegacyQueue reads the pending_queue.json snapshot written by versions
+// before the journal existed.
+func loadLegacyQueue(path string) ([]*PendingItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	var items []*PendingItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queue: %w", err)
+	}
+	return items, nil
+}
@@ -1,8 +1,8 @@
 package focus
 
 import (
-	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,22 +10,40 @@ import (
 	"time"
 )
 
-// Queue manages the pending items queue with persistence
+// Queue manages the pending items queue with persistence.
+//
+// Every mutation is appended to a write-ahead journal (pending_queue.wal)
+// before it takes effect in memory. Popped items stay in flight until Ack or
+// Nack is called; on Load, in-flight items from a previous run are
+// redelivered, so a crash between Add and the end of processing never drops
+// an item.
 type Queue struct {
 	mu       sync.RWMutex
 	items    []*PendingItem
+	inFlight map[string]*PendingItem // popped, not yet acked
 	path     string
 	maxSize  int
 	notifyCh chan struct{} // Signal when new items are added
+
+	journal *os.File
+	records int // records in the journal since the last compaction
+
+	policy        SchedulingPolicy
+	recentSources []string     // sources of recent background picks (fairness window)
+	picks         []PickReason // recent pick explanations, oldest first
+
+	dropHook func(item *PendingItem, reason string)
 }
 
 // NewQueue creates a new pending items queue
 func NewQueue(statePath string, maxSize int) *Queue {
 	return &Queue{
 		items:    make([]*PendingItem, 0),
+		inFlight: make(map[string]*PendingItem),
 		path:     statePath,
 		maxSize:  maxSize,
 		notifyCh: make(chan struct{}, 1), // Buffered to prevent blocking
+		policy:   DefaultSchedulingPolicy(),
 	}
 }
 
@@ -34,6 +52,14 @@ func (q *Queue) NotifyChannel() <-chan struct{} {
 	return q.notifyCh
 }
 
+// SetDropHook registers a callback that fires when an item is given up on
+// after maxRedeliveries, with the reason. Pass nil to clear.
+func (q *Queue) SetDropHook(fn func(item *PendingItem, reason string)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dropHook = fn
+}
+
 // Add adds an item to the queue
 func (q *Queue) Add(item *PendingItem) error {
 	q.mu.Lock()
@@ -43,6 +69,13 @@ func (q *Queue) Add(item *PendingItem) error {
 		item.Timestamp = time.Now()
 	}
 
+	// Journal first, with fsync: once Add returns, the item survives a crash.
+	// A journal failure is logged rather than returned so the item is still
+	// processed by this run.
+	if err := q.appendLocked(true, journalRecord{Op: opAdd, Item: item}); err != nil {
+		log.Printf("[queue] Warning: failed to journal item %s: %v", item.ID, err)
+	}
+
 	q.items = append(q.items, item)
 
 	// Trim if over capacity (remove oldest, lowest priority)
@@ -50,20 +83,25 @@ func (q *Queue) Add(item *PendingItem) error {
 		q.trim()
 	}
 
-	// Signal that a new item is available (non-blocking)
+	q.notify()
+	return nil
+}
+
+// notify signals that an item is available (non-blocking).
+func (q *Queue) notify() {
 	select {
 	case q.notifyCh <- struct{}{}:
 		// Notification sent
 	default:
 		// Channel already has a pending signal, no need to add another
 	}
-
-	return nil
 }
 
 // PopAllMaxPriority removes and returns all items with priority <= maxPriority,
-// sorted by priority ascending (most urgent first), then salience descending.
-// Returns nil if no qualifying items exist.
+// sorted by effective priority ascending (most urgent first), then salience
+// descending. Items still backing off after a Nack are left queued. Returns
+// nil if no qualifying items exist. Returned items are in flight until passed
+// to Ack or Nack.
 func (q *Queue) PopAllMaxPriority(maxPriority Priority) []*PendingItem {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -71,41 +109,56 @@ func (q *Queue) PopAllMaxPriority(maxPriority Priority) []*PendingItem {
 	var result []*PendingItem
 	var remaining []*PendingItem
 
+	now := time.Now()
 	for _, item := range q.items {
-		if item.Priority <= maxPriority {
+		if item.Priority <= maxPriority && item.due(now) {
 			result = append(result, item)
 		} else {
 			remaining = append(remaining, item)
 		}
 	}
 	q.items = remaining
+	if len(result) == 0 {
+		return nil
+	}
+	q.markInFlightLocked(result...)
 
-	sort.Slice(result, func(i, j int) bool {
-		if result[i].Priority != result[j].Priority {
-			return result[i].Priority < result[j].Priority
-		}
-		return result[i].Salience > result[j].Salience
+	ranks := make(map[*PendingItem]rank, len(result))
+	for _, item := range result {
+		ranks[item] = q.rankLocked(item, now, false)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return ranks[result[i]].before(ranks[result[j]], result[i], result[j])
 	})
 
+	reason := q.recordPickLocked(result[0], ranks[result[0]], now, len(result), false)
+	log.Printf("[queue] Picked %s", reason)
 	return result
 }
 
-// PopHighestMinPriority removes and returns the highest priority item
-// with priority >= minPriority (i.e., items no more urgent than minPriority).
-// Returns nil if no qualifying item exists.
+// PopHighestMinPriority removes and returns the best item with raw priority
+// >= minPriority (i.e., items no more urgent than minPriority). Items are
+// compared by effective priority (aging and deadlines), then by how often
+// their source was picked recently, then salience, then age. Items still
+// backing off after a Nack are skipped. Returns nil if no qualifying item
+// exists. The returned item is in flight until passed to Ack or Nack.
 func (q *Queue) PopHighestMinPriority(minPriority Priority) *PendingItem {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	now := time.Now()
 	bestIdx := -1
+	var best rank
+	candidates := 0
 	for i, item := range q.items {
-		if item.Priority < minPriority {
+		if item.Priority < minPriority || !item.due(now) {
 			continue
 		}
-		if bestIdx == -1 || item.Priority < q.items[bestIdx].Priority {
-			bestIdx = i
-		} else if item.Priority == q.items[bestIdx].Priority && item.Salience > q.items[bestIdx].Salience {
+		candidates++
+		r := q.rankLocked(item, now, true)
+		if bestIdx == -1 || r.before(best, item, q.items[bestIdx]) {
 			bestIdx = i
+			best = r
 		}
 	}
 
@@ -115,9 +168,159 @@ func (q *Queue) PopHighestMinPriority(minPriority Priority) *PendingItem {
 
 	item := q.items[bestIdx]
 	q.items = append(q.items[:bestIdx], q.items[bestIdx+1:]...)
+	q.markInFlightLocked(item)
+	reason := q.recordPickLocked(item, best, now, candidates, true)
+	log.Printf("[queue] Picked %s", reason)
 	return item
 }
 
+// Ack marks popped items as fully processed. Items that are never acked are
+// redelivered the next time the queue is loaded.
+func (q *Queue) Ack(items ...*PendingItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var recs []journalRecord
+	for _, item := range items {
+		if _, ok := q.inFlight[item.ID]; !ok {
+			continue
+		}
+		delete(q.inFlight, item.ID)
+		recs = append(recs, journalRecord{Op: opAck, ID: item.ID})
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	if err := q.appendLocked(true, recs...); err != nil {
+		return fmt.Errorf("failed to journal ack: %w", err)
+	}
+	q.maybeCompactLocked()
+	return nil
+}
+
+// Checkpoint journals the Checkpoint of a popped item that was preempted, so
+// that if the item is redelivered after a restart it resumes from there.
+func (q *Queue) Checkpoint(item *PendingItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.inFlight[item.ID]; !ok {
+		return nil
+	}
+	if err := q.appendLocked(true, journalRecord{Op: opCheckpoint, ID: item.ID, Item: item}); err != nil {
+		return fmt.Errorf("failed to journal checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Nack puts popped items whose processing failed back on the queue. Each is
+// retried after a backoff that doubles with its redeliveries; an item that
+// has already been redelivered maxRedeliveries times is dropped instead, and
+// reported to the drop hook.
+func (q *Queue) Nack(items ...*PendingItem) error {
+	q.mu.Lock()
+
+	now := time.Now()
+	var recs []journalRecord
+	var dropped []*PendingItem
+	var retryIn time.Duration
+	for _, item := range items {
+		if _, ok := q.inFlight[item.ID]; !ok {
+			continue
+		}
+		delete(q.inFlight, item.ID)
+		recs = append(recs, journalRecord{Op: opAck, ID: item.ID})
+		if item.Redeliveries >= maxRedeliveries {
+			log.Printf("[queue] Dropping item %s after %d failed deliveries", item.ID, item.Redeliveries+1)
+			dropped = append(dropped, item)
+			continue
+		}
+		delay := nackBackoff << item.Redeliveries
+		notBefore := now.Add(delay)
+		item.Redeliveries++
+		item.NotBefore = &notBefore
+		log.Printf("[queue] Requeueing failed item %s (retry %d in %s)", item.ID, item.Redeliveries, delay)
+		recs = append(recs, journalRecord{Op: opAdd, Item: item})
+		q.items = append(q.items, item)
+		if retryIn == 0 || delay < retryIn {
+			retryIn = delay
+		}
+	}
+	var err error
+	if len(recs) > 0 {
+		if err = q.appendLocked(true, recs...); err != nil {
+			err = fmt.Errorf("failed to journal nack: %w", err)
+		}
+	}
+	hook := q.dropHook
+	q.mu.Unlock()
+
+	if retryIn > 0 {
+		// Wake the consumers once the earliest retry is due.
+		time.AfterFunc(retryIn, q.notify)
+	}
+	if hook != nil {
+		for _, item := range dropped {
+			hook(item, fmt.Sprintf("processing failed %d times", item.Redeliveries+1))
+		}
+	}
+	return err
+}
+
+// PeekMaxPriority returns the oldest queued item with priority <= maxPriority
+// that is not backing off, without removing it, or nil if there is none.
+func (q *Queue) PeekMaxPriority(maxPriority Priority) *PendingItem {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	now := time.Now()
+	for _, item := range q.items {
+		if item.Priority <= maxPriority && item.due(now) {
+			return item
+		}
+	}
+	return nil
+}
+
+// Len returns the number of items waiting to be popped.
+func (q *Queue) Len() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.items)
+}
+
+// InFlight returns the number of popped items awaiting Ack.
+func (q *Queue) InFlight() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.inFlight)
+}
+
+// Close closes the journal. Unacked items remain in it for redelivery.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.journal == nil {
+		return nil
+	}
+	err := q.journal.Close()
+	q.journal = nil
+	return err
+}
+
+func (q *Queue) markInFlightLocked(items ...*PendingItem) {
+	if len(items) == 0 {
+		return
+	}
+	recs := make([]journalRecord, 0, len(items))
+	for _, item := range items {
+		q.inFlight[item.ID] = item
+		recs = append(recs, journalRecord{Op: opPop, ID: item.ID})
+	}
+	// No fsync: losing a pop record only means the item is redelivered.
+	if err := q.appendLocked(false, recs...); err != nil {
+		log.Printf("[queue] Warning: failed to journal pop: %v", err)
+	}
+}
+
 // trim removes excess items (oldest, lowest priority first)
 func (q *Queue) trim() {
 	// Sort by priority (desc) then age (desc)
@@ -131,36 +334,80 @@ func (q *Queue) trim() {
 	var keep []*PendingItem
 	removed := 0
 
+	var drops []journalRecord
+
 	for _, item := range q.items {
 		if removed < excess && item.Priority > P1UserInput {
 			removed++
+			drops = append(drops, journalRecord{Op: opDrop, ID: item.ID})
 			continue
 		}
 		keep = append(keep, item)
 	}
 
 	q.items = keep
+	if err := q.appendLocked(false, drops...); err != nil {
+		log.Printf("[queue] Warning: failed to journal trim: %v", err)
+	}
 }
 
-// Load loads queue state from disk
+// Load rebuilds queue state from the journal and compacts it. Items that were
+// popped but never acked by the previous run are put back on the queue; an
+// item that has already been redelivered maxRedeliveries times is dropped
+// (and reported to the drop hook) so a message that crashes the daemon cannot
+// wedge it in a restart loop.
+//
+// A pending_queue.json left by older versions is imported and removed.
 func (q *Queue) Load() error {
+	var dropped []*PendingItem
+	var hook func(*PendingItem, string)
+	defer func() {
+		// Runs after the unlock below.
+		for _, item := range dropped {
+			hook(item, fmt.Sprintf("not acknowledged after %d redeliveries", item.Redeliveries))
+		}
+	}()
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	hook = q.dropHook
+
+	if q.journal != nil {
+		q.journal.Close()
+		q.journal = nil
+	}
 
-	filePath := filepath.Join(q.path, "pending_queue.json")
-	data, err := os.ReadFile(filePath)
+	items, inFlight, err := replayJournal(q.journalPath())
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return fmt.Errorf("failed to read queue: %w", err)
+		return err
 	}
 
-	var items []*PendingItem
-	if err := json.Unmarshal(data, &items); err != nil {
-		return fmt.Errorf("failed to unmarshal queue: %w", err)
+	legacyPath := filepath.Join(q.path, "pending_queue.json")
+	legacy, err := loadLegacyQueue(legacyPath)
+	if err != nil {
+		return err
+	}
+	items = append(items, legacy...)
+
+	for _, item := range inFlight {
+		if item.Redeliveries >= maxRedeliveries {
+			log.Printf("[queue] Dropping item %s after %d redeliveries", item.ID, item.Redeliveries)
+			if hook != nil {
+				dropped = append(dropped, item)
+			}
+			continue
+		}
+		item.Redeliveries++
+		log.Printf("[queue] Redelivering unacknowledged item %s (%s)", item.ID, item.Priority)
+		items = append(items, item)
 	}
 
 	q.items = items
+	q.inFlight = make(map[string]*PendingItem)
+	if err := q.compactLocked(); err != nil {
+		return err
+	}
+	if len(legacy) > 0 {
+		os.Remove(legacyPath)
+	}
 	return nil
 }
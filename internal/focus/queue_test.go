@@ -0,0 +1,253 @@
+package focus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func reopen(t *testing.T, q *Queue) *Queue {
+	t.Helper()
+	q.Close()
+	q2 := NewQueue(q.path, q.maxSize)
+	if err := q2.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return q2
+}
+
+func TestQueue_AddSurvivesRestart(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "a", Priority: P1UserInput, Content: "hello"})
+	q.Add(&PendingItem{ID: "b", Priority: P3ActiveWork})
+
+	q = reopen(t, q)
+
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 2 || items[0].ID != "a" || items[1].ID != "b" {
+		t.Fatalf("expected [a b] after restart, got %v", ids(items))
+	}
+	if items[0].Content != "hello" {
+		t.Errorf("expected content to round-trip, got %q", items[0].Content)
+	}
+}
+
+func TestQueue_UnackedItemsRedelivered(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "acked", Priority: P1UserInput})
+	q.Add(&PendingItem{ID: "crashed", Priority: P2DueTask})
+
+	p1 := q.PopAllMaxPriority(P1UserInput)
+	if err := q.Ack(p1...); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if bg := q.PopHighestMinPriority(P2DueTask); bg == nil || bg.ID != "crashed" {
+		t.Fatalf("expected to pop crashed, got %v", bg)
+	}
+	if q.InFlight() != 1 {
+		t.Fatalf("expected 1 in-flight item, got %d", q.InFlight())
+	}
+
+	// Simulate a crash: the background item was never acked.
+	q = reopen(t, q)
+
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 1 || items[0].ID != "crashed" {
+		t.Fatalf("expected only crashed to be redelivered, got %v", ids(items))
+	}
+	if items[0].Redeliveries != 1 {
+		t.Errorf("expected Redeliveries=1, got %d", items[0].Redeliveries)
+	}
+}
+
+func TestQueue_CheckpointSurvivesRestart(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "research", Priority: P3ActiveWork})
+	item := q.PopHighestMinPriority(P2DueTask)
+	item.Checkpoint = &Checkpoint{PartialOutput: "half done", PendingToolCalls: []string{"web_fetch"}, PreemptedBy: "msg-1"}
+	if err := q.Checkpoint(item); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	q = reopen(t, q)
+
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 1 || items[0].ID != "research" {
+		t.Fatalf("expected research to be redelivered, got %v", ids(items))
+	}
+	cp := items[0].Checkpoint
+	if cp == nil || cp.PartialOutput != "half done" || len(cp.PendingToolCalls) != 1 || cp.PreemptedBy != "msg-1" {
+		t.Errorf("checkpoint after restart = %+v", cp)
+	}
+}
+
+func TestQueue_RedeliveryLimit(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "poison", Priority: P1UserInput})
+
+	for i := 0; i <= maxRedeliveries; i++ {
+		if items := q.PopAllMaxPriority(P1UserInput); len(items) != 1 {
+			t.Fatalf("round %d: expected poison to be delivered, got %v", i, ids(items))
+		}
+		q = reopen(t, q)
+	}
+
+	if items := q.PopAllMaxPriority(P4Exploration); len(items) != 0 {
+		t.Fatalf("expected poison to be dropped after %d redeliveries, got %v", maxRedeliveries, ids(items))
+	}
+}
+
+func TestQueue_RedeliveryLimitReportsDrop(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "poison", Priority: P1UserInput})
+
+	var dropped []string
+	for i := 0; i <= maxRedeliveries; i++ {
+		q.PopAllMaxPriority(P1UserInput)
+		q.Close()
+		q2 := NewQueue(q.path, q.maxSize)
+		q2.SetDropHook(func(item *PendingItem, reason string) { dropped = append(dropped, item.ID) })
+		if err := q2.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		q = q2
+	}
+	if len(dropped) != 1 || dropped[0] != "poison" {
+		t.Errorf("expected the drop to be reported once, got %v", dropped)
+	}
+}
+
+func TestQueue_NackRequeuesWithBackoff(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.Add(&PendingItem{ID: "flaky", Priority: P1UserInput})
+
+	items := q.PopAllMaxPriority(P1UserInput)
+	if err := q.Nack(items...); err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	if q.InFlight() != 0 || q.Len() != 1 {
+		t.Fatalf("expected the item back on the queue, in flight=%d queued=%d", q.InFlight(), q.Len())
+	}
+	item := items[0]
+	if item.Redeliveries != 1 || item.NotBefore == nil || time.Until(*item.NotBefore) <= 0 {
+		t.Fatalf("expected a backoff after the first failure, got %+v", item)
+	}
+
+	// Not popped while backing off, by either pop or peek.
+	if got := q.PopAllMaxPriority(P4Exploration); len(got) != 0 {
+		t.Fatalf("popped %v during backoff", ids(got))
+	}
+	if q.PeekMaxPriority(P4Exploration) != nil {
+		t.Fatal("peeked an item during backoff")
+	}
+
+	// The backoff survives a restart.
+	q = reopen(t, q)
+	if got := q.PopAllMaxPriority(P4Exploration); len(got) != 0 {
+		t.Fatalf("popped %v during backoff after restart", ids(got))
+	}
+	past := time.Now().Add(-time.Second)
+	q.items[0].NotBefore = &past
+	if got := q.PopAllMaxPriority(P4Exploration); len(got) != 1 || got[0].Redeliveries != 1 {
+		t.Fatalf("expected the item once due, got %v", ids(got))
+	}
+}
+
+func TestQueue_NackDropsAfterLimit(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	var dropped []string
+	q.SetDropHook(func(item *PendingItem, reason string) { dropped = append(dropped, item.ID+": "+reason) })
+	q.Add(&PendingItem{ID: "poison", Priority: P2DueTask})
+
+	for i := 0; i <= maxRedeliveries; i++ {
+		item := q.PopHighestMinPriority(P2DueTask)
+		if item == nil {
+			t.Fatalf("round %d: expected poison to be delivered", i)
+		}
+		q.Nack(item)
+		if item.NotBefore != nil {
+			past := time.Now().Add(-time.Second)
+			item.NotBefore = &past
+		}
+	}
+
+	if q.Len() != 0 || q.InFlight() != 0 {
+		t.Fatalf("expected poison to be dropped, queued=%d in flight=%d", q.Len(), q.InFlight())
+	}
+	if len(dropped) != 1 || dropped[0] != "poison: processing failed 4 times" {
+		t.Errorf("drop hook got %v", dropped)
+	}
+	// The drop is journaled too.
+	if q = reopen(t, q); q.Len() != 0 {
+		t.Errorf("dropped item came back after restart")
+	}
+}
+
+func TestQueue_TornJournalTail(t *testing.T) {
+	dir := t.TempDir()
+	q := NewQueue(dir, 100)
+	q.Add(&PendingItem{ID: "a", Priority: P1UserInput})
+	q.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, "pending_queue.wal"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"add","item":{"id":"b"`)
+	f.Close()
+
+	q = reopen(t, q)
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 1 || items[0].ID != "a" {
+		t.Fatalf("expected [a], got %v", ids(items))
+	}
+}
+
+func TestQueue_Compaction(t *testing.T) {
+	dir := t.TempDir()
+	q := NewQueue(dir, 100)
+	for i := 0; i < compactMinRecords; i++ {
+		q.Add(&PendingItem{ID: "x", Priority: P1UserInput})
+		q.Ack(q.PopAllMaxPriority(P1UserInput)...)
+	}
+	q.Add(&PendingItem{ID: "keep", Priority: P2DueTask})
+
+	if q.records >= compactMinRecords {
+		t.Errorf("expected journal to be compacted, has %d records", q.records)
+	}
+
+	q = reopen(t, q)
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 1 || items[0].ID != "keep" {
+		t.Fatalf("expected [keep] after compaction, got %v", ids(items))
+	}
+}
+
+func TestQueue_ImportsLegacySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "pending_queue.json")
+	os.WriteFile(legacy, []byte(`[{"id":"old","priority":1}]`), 0644)
+
+	q := NewQueue(dir, 100)
+	if err := q.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("expected legacy snapshot to be removed after import")
+	}
+
+	q = reopen(t, q)
+	items := q.PopAllMaxPriority(P4Exploration)
+	if len(items) != 1 || items[0].ID != "old" {
+		t.Fatalf("expected [old], got %v", ids(items))
+	}
+}
+
+func ids(items []*PendingItem) []string {
+	var out []string
+	for _, it := range items {
+		out = append(out, it.ID)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package focus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchedulingPolicy controls how queued items are ordered beyond their raw
+// Priority. It only reorders items within the class a pop call selects from;
+// aging never moves a background item into the P1 user-input batch.
+type SchedulingPolicy struct {
+	// AgingInterval is how long an item must wait to be promoted one
+	// priority level. Zero disables aging.
+	AgingInterval time.Duration
+	// MaxAgingBoost caps how many levels aging can promote an item. Aging
+	// never promotes past P1; only deadlines reach P0.
+	MaxAgingBoost int
+	// DeadlineLead promotes an item to P0 this long before its Deadline.
+	DeadlineLead time.Duration
+	// FairnessWindow is how many recent background picks are remembered.
+	// Among items of equal effective priority, the one whose source appears
+	// least in that window wins. Zero disables fairness.
+	FairnessWindow int
+}
+
+// DefaultSchedulingPolicy returns the policy used when none is configured.
+func DefaultSchedulingPolicy() SchedulingPolicy {
+	return SchedulingPolicy{
+		AgingInterval:  30 * time.Minute,
+		MaxAgingBoost:  2,
+		DeadlineLead:   5 * time.Minute,
+		FairnessWindow: 10,
+	}
+}
+
+// PickReason records why the queue chose an item, for debugging scheduling.
+type PickReason struct {
+	ItemID    string    `json:"item_id"`
+	Source    string    `json:"source,omitempty"`
+	PickedAt  time.Time `json:"picked_at"`
+	Priority  Priority  `json:"priority"`  // raw priority
+	Effective Priority  `json:"effective"` // after aging and deadline promotion
+	Waited    string    `json:"waited"`
+	// AgingBoost is the number of levels gained from waiting.
+	AgingBoost int `json:"aging_boost,omitempty"`
+	// DeadlinePromoted is set when the item's deadline forced it to P0.
+	DeadlinePromoted bool `json:"deadline_promoted,omitempty"`
+	// SourceRecentPicks is how often the item's source appeared in the
+	// fairness window at pick time.
+	SourceRecentPicks int `json:"source_recent_picks,omitempty"`
+	// Candidates is the number of items that were eligible.
+	Candidates int `json:"candidates"`
+}
+
+// String returns a one-line explanation suitable for logs.
+func (r PickReason) String() string {
+	var why []string
+	if r.DeadlinePromoted {
+		why = append(why, "deadline")
+	}
+	if r.AgingBoost > 0 {
+		why = append(why, fmt.Sprintf("aged +%d", r.AgingBoost))
+	}
+	if r.SourceRecentPicks > 0 {
+		why = append(why, fmt.Sprintf("source %s picked %dx recently", r.Source, r.SourceRecentPicks))
+	}
+	s := fmt.Sprintf("%s %s→%s waited %s, %d candidates", r.ItemID, r.Priority, r.Effective, r.Waited, r.Candidates)
+	if len(why) > 0 {
+		s += " (" + strings.Join(why, ", ") + ")"
+	}
+	return s
+}
+
+// maxPickHistory bounds the number of PickReasons kept for RecentPicks.
+const maxPickHistory = 50
+
+// rank is an item's scheduling key at a point in time.
+type rank struct {
+	effective   Priority
+	boost       int
+	deadline    bool
+	sourcePicks int
+}
+
+// rankLocked computes an item's effective priority under the queue policy.
+func (q *Queue) rankLocked(item *PendingItem, now time.Time, fair bool) rank {
+	r := rank{effective: item.Priority}
+	if item.Deadline != nil && !now.Before(item.Deadline.Add(-q.policy.DeadlineLead)) {
+		r.effective = P0Critical
+		r.deadline = true
+	} else if q.policy.AgingInterval > 0 && r.effective > P1UserInput {
+		boost := int(now.Sub(item.Timestamp) / q.policy.AgingInterval)
+		if boost > q.policy.MaxAgingBoost {
+			boost = q.policy.MaxAgingBoost
+		}
+		if floor := int(r.effective - P1UserInput); boost > floor {
+			boost = floor
+		}
+		if boost > 0 {
+			r.boost = boost
+			r.effective -= Priority(boost)
+		}
+	}
+	if fair && q.policy.FairnessWindow > 0 {
+		for _, src := range q.recentSources {
+			if src == item.Source {
+				r.sourcePicks++
+			}
+		}
+	}
+	return r
+}
+
+// before reports whether a should be picked ahead of b.
+func (a rank) before(b rank, ai, bi *PendingItem) bool {
+	if a.effective != b.effective {
+		return a.effective < b.effective
+	}
+	if a.sourcePicks != b.sourcePicks {
+		return a.sourcePicks < b.sourcePicks
+	}
+	if ai.Salience != bi.Salience {
+		return ai.Salience > bi.Salience
+	}
+	return ai.Timestamp.Before(bi.Timestamp)
+}
+
+// recordPickLocked appends a PickReason to the history and, for background
+// picks, the item's source to the fairness window.
+func (q *Queue) recordPickLocked(item *PendingItem, r rank, now time.Time, candidates int, fair bool) PickReason {
+	reason := PickReason{
+		ItemID:            item.ID,
+		Source:            item.Source,
+		PickedAt:          now,
+		Priority:          item.Priority,
+		Effective:         r.effective,
+		Waited:            now.Sub(item.Timestamp).Round(time.Second).String(),
+		AgingBoost:        r.boost,
+		DeadlinePromoted:  r.deadline,
+		SourceRecentPicks: r.sourcePicks,
+		Candidates:        candidates,
+	}
+	q.picks = append(q.picks, reason)
+	if len(q.picks) > maxPickHistory {
+		q.picks = q.picks[len(q.picks)-maxPickHistory:]
+	}
+	if fair && q.policy.FairnessWindow > 0 {
+		q.recentSources = append(q.recentSources, item.Source)
+		if len(q.recentSources) > q.policy.FairnessWindow {
+			q.recentSources = q.recentSources[len(q.recentSources)-q.policy.FairnessWindow:]
+		}
+	}
+	return reason
+}
+
+// SetPolicy replaces the scheduling policy.
+func (q *Queue) SetPolicy(p SchedulingPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policy = p
+}
+
+// RecentPicks returns the explanations for the most recent picks, oldest first.
+func (q *Queue) RecentPicks() []PickReason {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	out := make([]PickReason, len(q.picks))
+	copy(out, q.picks)
+	return out
+}
@@ -0,0 +1,94 @@
+package focus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPopHighestMinPriority_AgingPromotesWaitingItems(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.SetPolicy(SchedulingPolicy{AgingInterval: 10 * time.Minute, MaxAgingBoost: 2})
+
+	now := time.Now()
+	q.Add(&PendingItem{ID: "fresh-p2", Priority: P2DueTask, Timestamp: now})
+	q.Add(&PendingItem{ID: "old-p4", Priority: P4Exploration, Timestamp: now.Add(-25 * time.Minute)})
+
+	item := q.PopHighestMinPriority(P2DueTask)
+	if item == nil || item.ID != "old-p4" {
+		t.Fatalf("expected aged P4 (now effective P2, older) first, got %v", item)
+	}
+	picks := q.RecentPicks()
+	if len(picks) != 1 || picks[0].AgingBoost != 2 || picks[0].Effective != P2DueTask {
+		t.Errorf("expected pick reason with boost 2 to P2, got %+v", picks)
+	}
+}
+
+func TestPopHighestMinPriority_AgingDisabled(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.SetPolicy(SchedulingPolicy{})
+
+	q.Add(&PendingItem{ID: "fresh-p2", Priority: P2DueTask})
+	q.Add(&PendingItem{ID: "old-p4", Priority: P4Exploration, Timestamp: time.Now().Add(-24 * time.Hour)})
+
+	if item := q.PopHighestMinPriority(P2DueTask); item == nil || item.ID != "fresh-p2" {
+		t.Fatalf("expected strict priority order without aging, got %v", item)
+	}
+}
+
+func TestPopHighestMinPriority_DeadlinePromotes(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.SetPolicy(SchedulingPolicy{DeadlineLead: 5 * time.Minute})
+
+	soon := time.Now().Add(2 * time.Minute)
+	later := time.Now().Add(time.Hour)
+	q.Add(&PendingItem{ID: "p2", Priority: P2DueTask, Salience: 1})
+	q.Add(&PendingItem{ID: "far", Priority: P3ActiveWork, Deadline: &later})
+	q.Add(&PendingItem{ID: "due", Priority: P4Exploration, Deadline: &soon})
+
+	item := q.PopHighestMinPriority(P2DueTask)
+	if item == nil || item.ID != "due" {
+		t.Fatalf("expected item within deadline lead first, got %v", item)
+	}
+	if r := q.RecentPicks()[0]; !r.DeadlinePromoted || r.Effective != P0Critical {
+		t.Errorf("expected deadline promotion to P0, got %+v", r)
+	}
+	if item := q.PopHighestMinPriority(P2DueTask); item == nil || item.ID != "p2" {
+		t.Fatalf("expected distant deadline not to promote, got %v", item)
+	}
+}
+
+func TestPopHighestMinPriority_SourceFairness(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.SetPolicy(SchedulingPolicy{FairnessWindow: 4})
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		q.Add(&PendingItem{ID: "noisy", Priority: P3ActiveWork, Source: "noisy", Salience: 0.9, Timestamp: now})
+	}
+	q.Add(&PendingItem{ID: "quiet", Priority: P3ActiveWork, Source: "quiet", Salience: 0.1, Timestamp: now})
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		order = append(order, q.PopHighestMinPriority(P2DueTask).ID)
+	}
+	// After one noisy pick, the quiet source wins the tie despite lower salience.
+	if order[0] != "noisy" || order[1] != "quiet" {
+		t.Fatalf("expected quiet source to be picked second, got %v", order)
+	}
+	if r := q.RecentPicks()[1]; r.Source != "quiet" || r.Candidates != 3 {
+		t.Errorf("unexpected pick reason %+v", r)
+	}
+}
+
+func TestPopAllMaxPriority_NotAffectedByBackgroundAging(t *testing.T) {
+	q := NewQueue(t.TempDir(), 100)
+	q.SetPolicy(SchedulingPolicy{AgingInterval: time.Minute, MaxAgingBoost: 3})
+
+	q.Add(&PendingItem{ID: "old-p3", Priority: P3ActiveWork, Timestamp: time.Now().Add(-time.Hour)})
+	q.Add(&PendingItem{ID: "user", Priority: P1UserInput})
+
+	items := q.PopAllMaxPriority(P1UserInput)
+	if len(items) != 1 || items[0].ID != "user" {
+		t.Fatalf("expected aging not to pull background items into the P1 batch, got %v", ids(items))
+	}
+}
@@ -27,6 +27,41 @@ type PendingItem struct {
 	AuthorID  string         `json:"author_id,omitempty"`
 	Timestamp time.Time      `json:"timestamp"`
 	Data      map[string]any `json:"data,omitempty"` // additional context
+
+	// Deadline, when set, promotes the item to P0 shortly before it passes
+	// (see SchedulingPolicy.DeadlineLead).
+	Deadline *time.Time `json:"deadline,omitempty"`
+
+	// Redeliveries counts how often the item was requeued, either after a
+	// restart because it was popped but never acked, or by Nack after its
+	// processing failed.
+	Redeliveries int `json:"redeliveries,omitempty"`
+
+	// NotBefore, when set, keeps a nacked item from being popped until the
+	// backoff has passed.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+
+	// Checkpoint is set when the item's session was preempted; it carries
+	// what is needed to resume the work.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// due reports whether the item may be popped at now.
+func (item *PendingItem) due(now time.Time) bool {
+	return item.NotBefore == nil || !now.Before(*item.NotBefore)
+}
+
+// Checkpoint captures a preempted executive session so the item can be
+// resumed once the preempting work is done.
+type Checkpoint struct {
+	SessionID     string `json:"session_id,omitempty"` // session the work was running in
+	PartialOutput string `json:"partial_output,omitempty"`
+	// PendingToolCalls are the tool calls issued after the last text output,
+	// i.e. work the model had started but not yet reported on.
+	PendingToolCalls []string  `json:"pending_tool_calls,omitempty"`
+	PreemptedBy      string    `json:"preempted_by,omitempty"` // ID of the item that preempted it
+	SuspendedAt      time.Time `json:"suspended_at"`
+	Resumes          int       `json:"resumes,omitempty"` // times resumed so far
 }
 
 // FocusState represents the current attention state
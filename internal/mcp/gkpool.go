@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -156,6 +157,18 @@ func (p *GKPool) cleanup() {
 	}
 }
 
+// Health reports the state of every running GK process.
+func (p *GKPool) Health() []ProxyHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProxyHealth, 0, len(p.entries))
+	for _, e := range p.entries {
+		out = append(out, e.client.Health())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
 // Close shuts down all running GK processes.
 func (p *GKPool) Close() {
 	p.mu.Lock()
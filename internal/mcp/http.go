@@ -0,0 +1,415 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/logging"
+)
+
+// Streamable HTTP transport (MCP 2025-03-26).
+//
+// POST carries one JSON-RPC message or a batch. Requests get a JSON body, or
+// an SSE stream when the client accepts text/event-stream and a tools/call
+// asks for progress: notifications/progress events, then the response. GET
+// opens an SSE stream for server-initiated messages such as
+// notifications/tools/list_changed. DELETE ends a transport session.
+//
+// Every request must carry a registered session token in its path
+// (/mcp/{token}); the token's SessionInfo decides which tools the client can
+// list and call, and its default GK domain. Requests without one get 401.
+//
+// Transport sessions (Mcp-Session-Id) are issued on initialize. They are
+// distinct from the path tokens and only track the protocol connection.
+// Clients that never send the header are still served, so plain
+// JSON-over-POST clients keep working.
+
+const (
+	sessionHeader = "Mcp-Session-Id"
+	// sessionIdleTTL is how long an unused transport session is kept.
+	sessionIdleTTL = 24 * time.Hour
+	// streamPingInterval keeps idle GET streams from being closed by proxies.
+	streamPingInterval = 25 * time.Second
+	// toolsChangedDelay coalesces bursts of tool (un)registrations, such as a
+	// plugin reload, into one notifications/tools/list_changed.
+	toolsChangedDelay = 250 * time.Millisecond
+)
+
+// httpSession is a transport session issued on initialize.
+type httpSession struct {
+	lastSeen time.Time
+}
+
+// sseStream is an open GET stream receiving server-initiated messages.
+type sseStream struct {
+	session string
+	msgs    chan []byte
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (st *sseStream) close() { st.once.Do(func() { close(st.done) }) }
+
+// handleHTTP handles HTTP requests for the MCP protocol.
+// Supports session tokens in the path (/mcp/{token}) for per-subagent domain routing.
+func (s *Server) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	info, ok := s.Session(extractToken(r.URL.Path))
+	if !ok {
+		log.Printf("[mcp-http] Rejected %s %s from %s: missing or unknown session token", r.Method, r.URL.Path, r.RemoteAddr)
+		http.Error(w, "Missing or unknown session token", http.StatusUnauthorized)
+		return
+	}
+
+	sid := r.Header.Get(sessionHeader)
+	if sid != "" && !s.touchSession(sid) {
+		http.Error(w, "Unknown or expired session", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r, info)
+	case http.MethodGet:
+		s.handleStream(w, r, sid)
+	case http.MethodDelete:
+		if sid == "" {
+			http.Error(w, "Missing "+sessionHeader, http.StatusBadRequest)
+			return
+		}
+		s.endSession(sid)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost handles a POSTed JSON-RPC message or batch.
+func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, info SessionInfo) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		s.handleBatch(w, trimmed, info)
+		return
+	}
+
+	var req jsonRPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		log.Printf("[mcp-http] Failed to parse request: %v", err)
+		http.Error(w, "Invalid JSON-RPC request", http.StatusBadRequest)
+		return
+	}
+
+	if token := progressToken(req); token != nil && acceptsSSE(r) {
+		s.streamCall(w, req, info, token)
+		return
+	}
+
+	resp := s.dispatchHTTP(req, info, noProgress)
+	if resp == nil {
+		// Notifications and replies are acknowledged without a body.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if req.Method == "initialize" && resp.Error == nil {
+		w.Header().Set(sessionHeader, s.newSession())
+	}
+	writeJSON(w, resp)
+}
+
+// handleBatch handles a JSON-RPC batch. Responses are returned together as a
+// JSON array; progress is not streamed for batched calls.
+func (s *Server) handleBatch(w http.ResponseWriter, body []byte, info SessionInfo) {
+	var reqs []jsonRPCRequest
+	if err := json.Unmarshal(body, &reqs); err != nil || len(reqs) == 0 {
+		http.Error(w, "Invalid JSON-RPC batch", http.StatusBadRequest)
+		return
+	}
+	var resps []*jsonRPCResponse
+	for _, req := range reqs {
+		if req.Method == "initialize" {
+			http.Error(w, "initialize must not be batched", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, req := range reqs {
+		if resp := s.dispatchHTTP(req, info, noProgress); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+	if len(resps) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, resps)
+}
+
+// streamCall runs a tools/call on an SSE response, sending a
+// notifications/progress event for each progress report and then the result.
+func (s *Server) streamCall(w http.ResponseWriter, req jsonRPCRequest, info SessionInfo, token any) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, s.dispatchHTTP(req, info, noProgress))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var mu sync.Mutex
+	send := func(msg any) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("[mcp-http] Failed to marshal event: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		writeEvent(w, data)
+		flusher.Flush()
+	}
+
+	progress := func(progress, total float64, message string) {
+		params := map[string]any{"progressToken": token, "progress": progress}
+		if total > 0 {
+			params["total"] = total
+		}
+		if message != "" {
+			params["message"] = message
+		}
+		send(jsonRPCNotification{JSONRPC: "2.0", Method: "notifications/progress", Params: params})
+	}
+	send(s.dispatchHTTP(req, info, progress))
+}
+
+// handleStream serves a GET stream of server-initiated messages until the
+// client disconnects or its session ends.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request, sid string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || !acceptsSSE(r) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	st := &sseStream{session: sid, msgs: make(chan []byte, 16), done: make(chan struct{})}
+	s.streamsMu.Lock()
+	s.streams[st] = struct{}{}
+	s.streamsMu.Unlock()
+	defer func() {
+		s.streamsMu.Lock()
+		delete(s.streams, st)
+		s.streamsMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case data := <-st.msgs:
+			writeEvent(w, data)
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-st.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// dispatchHTTP routes a request received over HTTP under a session token:
+// tools outside the token's allow-list are hidden and refused, and the
+// token's default domain is injected into GK tool calls and resource
+// requests.
+func (s *Server) dispatchHTTP(req jsonRPCRequest, info SessionInfo, progress ProgressFunc) *jsonRPCResponse {
+	domain := info.DefaultDomain
+	if domain == "" {
+		domain = "/"
+	}
+	switch req.Method {
+	case "tools/list":
+		return s.handleToolsList(req, info.Allows)
+	case "tools/call":
+		var params toolsCallParams
+		if req.Params == nil || json.Unmarshal(req.Params, &params) != nil {
+			return s.handleToolsCall(req, progress)
+		}
+		if !info.Allows(params.Name) {
+			return s.denyToolCall(req, info, params.Name)
+		}
+		// For tool calls on GK tools: inject domain from session token if not provided
+		if s.isGKTool(params.Name) {
+			if params.Arguments == nil {
+				params.Arguments = make(map[string]any)
+			}
+			if d, _ := params.Arguments["domain"].(string); d == "" {
+				params.Arguments["domain"] = domain
+				// Re-marshal params with injected domain
+				if newParams, err := json.Marshal(params); err == nil {
+					req.Params = newParams
+				}
+			}
+		}
+		if s.toolCallHook != nil {
+			s.toolCallHook(info, params.Name, params.Arguments)
+		}
+		return s.handleToolsCall(req, progress)
+	case "resources/list":
+		return s.handleResourcesList(req, domain)
+	case "resources/read":
+		return s.handleResourcesRead(req, domain)
+	default:
+		return s.handleRequest(req)
+	}
+}
+
+// denyToolCall refuses a call to a tool the session's token does not allow,
+// and audits it.
+func (s *Server) denyToolCall(req jsonRPCRequest, info SessionInfo, tool string) *jsonRPCResponse {
+	log.Printf("[mcp-http] Denied tool %s for agent %q: not in its allow-list", tool, info.AgentID)
+	if s.deniedToolHook != nil {
+		go s.deniedToolHook(info, tool)
+	}
+	return &jsonRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: toolsCallResult{
+			Content: []contentBlock{{Type: "text", Text: fmt.Sprintf("Tool %s is not permitted for this session", tool)}},
+			IsError: true,
+		},
+	}
+}
+
+// broadcast sends a notification to every open GET stream. Streams that are
+// not keeping up miss it rather than blocking the sender.
+func (s *Server) broadcast(method string, params any) {
+	data, err := json.Marshal(jsonRPCNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		log.Printf("[mcp-http] Failed to marshal %s: %v", method, err)
+		return
+	}
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	for st := range s.streams {
+		select {
+		case st.msgs <- data:
+		default:
+			logging.Debug("mcp", "Dropping %s for a slow stream", method)
+		}
+	}
+}
+
+// notifyToolsChanged schedules a notifications/tools/list_changed broadcast,
+// coalescing calls within toolsChangedDelay.
+func (s *Server) notifyToolsChanged() {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if s.toolsChanged != nil {
+		return
+	}
+	s.toolsChanged = time.AfterFunc(toolsChangedDelay, func() {
+		s.streamsMu.Lock()
+		s.toolsChanged = nil
+		s.streamsMu.Unlock()
+		s.broadcast("notifications/tools/list_changed", nil)
+	})
+}
+
+// newSession issues a transport session ID, pruning idle sessions.
+func (s *Server) newSession() string {
+	id := NewToken()
+
+	now := time.Now()
+	s.httpSessionsMu.Lock()
+	defer s.httpSessionsMu.Unlock()
+	for sid, sess := range s.httpSessions {
+		if now.Sub(sess.lastSeen) > sessionIdleTTL {
+			delete(s.httpSessions, sid)
+		}
+	}
+	s.httpSessions[id] = &httpSession{lastSeen: now}
+	return id
+}
+
+// touchSession marks a transport session as used, reporting whether it
+// exists.
+func (s *Server) touchSession(sid string) bool {
+	s.httpSessionsMu.Lock()
+	defer s.httpSessionsMu.Unlock()
+	sess, ok := s.httpSessions[sid]
+	if !ok || time.Since(sess.lastSeen) > sessionIdleTTL {
+		delete(s.httpSessions, sid)
+		return false
+	}
+	sess.lastSeen = time.Now()
+	return true
+}
+
+// endSession forgets a transport session and closes its GET streams.
+func (s *Server) endSession(sid string) {
+	s.httpSessionsMu.Lock()
+	delete(s.httpSessions, sid)
+	s.httpSessionsMu.Unlock()
+
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	for st := range s.streams {
+		if st.session == sid {
+			st.close()
+		}
+	}
+}
+
+// progressToken returns the progress token of a tools/call request, if any.
+func progressToken(req jsonRPCRequest) any {
+	if req.Method != "tools/call" || req.Params == nil {
+		return nil
+	}
+	var params toolsCallParams
+	if json.Unmarshal(req.Params, &params) != nil || params.Meta == nil {
+		return nil
+	}
+	return params.Meta.ProgressToken
+}
+
+// acceptsSSE reports whether the client accepts an event stream.
+func acceptsSSE(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeEvent(w io.Writer, data []byte) {
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[mcp-http] Failed to marshal response: %v", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,355 @@
+package mcp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+// newHTTPServer serves a bud MCP server with echo and admin tools and returns
+// it with its base URL (without the /mcp/{token} path).
+func newHTTPServer(t *testing.T) (*mcp.Server, string) {
+	t.Helper()
+	s := mcp.NewServer()
+	for _, name := range []string{"echo", "admin"} {
+		s.RegisterTool(name, mcp.ToolDef{Description: name}, func(_ any, args map[string]any) (string, error) {
+			msg, _ := args["msg"].(string)
+			return name + ": " + msg, nil
+		})
+	}
+	ln, err := s.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	return s, "http://" + ln.Addr().String()
+}
+
+// rpc POSTs a JSON-RPC message to url and returns the response.
+func rpc(t *testing.T, url string, msg any, header http.Header) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func call(id int, tool, msg string) map[string]any {
+	return map[string]any{"jsonrpc": "2.0", "id": id, "method": "tools/call",
+		"params": map[string]any{"name": tool, "arguments": map[string]any{"msg": msg}}}
+}
+
+type rpcResponse struct {
+	ID     int `json:"id"`
+	Result struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	} `json:"result"`
+}
+
+func decode(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+}
+
+func TestHTTPRequiresSessionToken(t *testing.T) {
+	s, base := newHTTPServer(t)
+	token := mcp.NewToken()
+	s.RegisterSession(token, "agent-1", "/", []string{"*"})
+	list := map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}
+
+	for _, path := range []string{"/mcp", "/mcp/" + mcp.NewToken()} {
+		if resp := rpc(t, base+path, list, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("POST %s: status %d, want 401", path, resp.StatusCode)
+		}
+	}
+	if resp := rpc(t, base+"/mcp/"+token, list, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("registered token: status %d", resp.StatusCode)
+	}
+
+	// A revoked token is refused.
+	s.UnregisterSession(token)
+	if resp := rpc(t, base+"/mcp/"+token, list, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("revoked token: status %d, want 401", resp.StatusCode)
+	}
+	// Filling in the agent ID does not revive it.
+	s.SetSessionAgent(token, "agent-1")
+	if _, ok := s.Session(token); ok {
+		t.Error("SetSessionAgent revived a revoked token")
+	}
+}
+
+func TestHTTPToolAllowList(t *testing.T) {
+	s, base := newHTTPServer(t)
+	token := mcp.NewToken()
+	s.RegisterSession(token, "", "/", []string{"ec*"})
+	s.SetSessionAgent(token, "agent-1")
+	denied := make(chan string, 1)
+	s.SetDeniedToolHook(func(session mcp.SessionInfo, tool string) {
+		denied <- session.AgentID + " " + tool
+	})
+	var called []string
+	s.SetToolCallHook(func(session mcp.SessionInfo, tool string, args map[string]any) {
+		called = append(called, session.AgentID+" "+tool+" "+args["msg"].(string))
+	})
+	url := base + "/mcp/" + token
+
+	var list rpcResponse
+	decode(t, rpc(t, url, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}, nil), &list)
+	if len(list.Result.Tools) != 1 || list.Result.Tools[0].Name != "echo" {
+		t.Errorf("tools/list = %+v, want only echo", list.Result.Tools)
+	}
+
+	var ok rpcResponse
+	decode(t, rpc(t, url, call(2, "echo", "hi"), nil), &ok)
+	if ok.Result.IsError || len(ok.Result.Content) != 1 || ok.Result.Content[0].Text != "echo: hi" {
+		t.Errorf("allowed call = %+v", ok.Result)
+	}
+
+	var refused rpcResponse
+	decode(t, rpc(t, url, call(3, "admin", "hi"), nil), &refused)
+	if !refused.Result.IsError || !strings.Contains(refused.Result.Content[0].Text, "not permitted") {
+		t.Errorf("disallowed call = %+v", refused.Result)
+	}
+	select {
+	case got := <-denied:
+		if got != "agent-1 admin" {
+			t.Errorf("denied hook got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("denied tool hook was not called")
+	}
+	// The call hook runs before the response, and only for allowed calls.
+	if len(called) != 1 || called[0] != "agent-1 echo hi" {
+		t.Errorf("tool call hook got %q, want [agent-1 echo hi]", called)
+	}
+}
+
+// sseEvents returns a channel of the data of each event in an SSE body.
+func sseEvents(body io.Reader) <-chan string {
+	events := make(chan string, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+	return events
+}
+
+func TestHTTPProgressStream(t *testing.T) {
+	s, base := newHTTPServer(t)
+	s.RegisterProgressTool("slow", mcp.ToolDef{Description: "Slow"}, func(_ any, _ map[string]any, progress mcp.ProgressFunc) (string, error) {
+		progress(1, 2, "halfway")
+		progress(2, 2, "")
+		return "done", nil
+	})
+	token := mcp.NewToken()
+	s.RegisterSession(token, "agent-1", "/", []string{"*"})
+
+	req := map[string]any{"jsonrpc": "2.0", "id": 7, "method": "tools/call",
+		"params": map[string]any{"name": "slow", "_meta": map[string]any{"progressToken": "p1"}}}
+	resp := rpc(t, base+"/mcp/"+token, req, http.Header{"Accept": {"application/json, text/event-stream"}})
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want an event stream", ct)
+	}
+
+	var events []string
+	for data := range sseEvents(resp.Body) {
+		events = append(events, data)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 2 progress and 1 result: %v", len(events), events)
+	}
+	var first struct {
+		Method string         `json:"method"`
+		Params map[string]any `json:"params"`
+	}
+	json.Unmarshal([]byte(events[0]), &first)
+	if first.Method != "notifications/progress" || first.Params["progressToken"] != "p1" ||
+		first.Params["progress"] != 1.0 || first.Params["total"] != 2.0 || first.Params["message"] != "halfway" {
+		t.Errorf("first event = %s", events[0])
+	}
+	if !strings.Contains(events[1], `"progress":2`) {
+		t.Errorf("second event = %s", events[1])
+	}
+	var result rpcResponse
+	if err := json.Unmarshal([]byte(events[2]), &result); err != nil || result.ID != 7 ||
+		len(result.Result.Content) != 1 || result.Result.Content[0].Text != "done" {
+		t.Errorf("last event = %s", events[2])
+	}
+
+	// Without an event-stream Accept header the result comes back as JSON.
+	var plain rpcResponse
+	decode(t, rpc(t, base+"/mcp/"+token, req, nil), &plain)
+	if plain.Result.Content[0].Text != "done" {
+		t.Errorf("plain result = %+v", plain.Result)
+	}
+}
+
+func TestHTTPTransportSession(t *testing.T) {
+	s, base := newHTTPServer(t)
+	token := mcp.NewToken()
+	s.RegisterSession(token, "agent-1", "/", []string{"*"})
+	url := base + "/mcp/" + token
+
+	resp := rpc(t, url, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": map[string]any{"protocolVersion": "2025-03-26"}}, nil)
+	sid := resp.Header.Get("Mcp-Session-Id")
+	if resp.StatusCode != http.StatusOK || sid == "" {
+		t.Fatalf("initialize: status %d, Mcp-Session-Id %q", resp.StatusCode, sid)
+	}
+	withSession := http.Header{"Mcp-Session-Id": {sid}}
+	list := map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}
+	if resp := rpc(t, url, list, withSession); resp.StatusCode != http.StatusOK {
+		t.Errorf("request in session: status %d", resp.StatusCode)
+	}
+	if resp := rpc(t, url, list, http.Header{"Mcp-Session-Id": {"bogus"}}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: status %d, want 404", resp.StatusCode)
+	}
+
+	// A GET stream opened in the session is closed when the session ends.
+	stream := openStream(t, url, sid)
+	send := func(method string, header http.Header) int {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header = header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := send(http.MethodDelete, http.Header{}); code != http.StatusBadRequest {
+		t.Errorf("DELETE without session: status %d, want 400", code)
+	}
+	if code := send(http.MethodDelete, withSession); code != http.StatusNoContent {
+		t.Errorf("DELETE: status %d, want 204", code)
+	}
+	select {
+	case _, open := <-stream:
+		if open {
+			t.Error("unexpected event on a stream whose session ended")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("GET stream still open after DELETE")
+	}
+	if resp := rpc(t, url, list, withSession); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("request after DELETE: status %d, want 404", resp.StatusCode)
+	}
+}
+
+// openStream opens a GET stream, in session sid if set, and returns its events.
+func openStream(t *testing.T, url, sid string) <-chan string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET: status %d", resp.StatusCode)
+	}
+	return sseEvents(resp.Body)
+}
+
+func TestHTTPBatch(t *testing.T) {
+	s, base := newHTTPServer(t)
+	token := mcp.NewToken()
+	s.RegisterSession(token, "agent-1", "/", []string{"echo"})
+	url := base + "/mcp/" + token
+
+	batch := []any{
+		map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/list"},
+		map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"},
+		call(2, "echo", "hi"),
+		call(3, "admin", "hi"),
+	}
+	var resps []rpcResponse
+	decode(t, rpc(t, url, batch, nil), &resps)
+	if len(resps) != 3 {
+		t.Fatalf("got %d responses, want 3 (none for the notification): %+v", len(resps), resps)
+	}
+	if resps[0].ID != 1 || len(resps[0].Result.Tools) != 1 {
+		t.Errorf("tools/list response = %+v", resps[0])
+	}
+	if resps[1].ID != 2 || resps[1].Result.Content[0].Text != "echo: hi" {
+		t.Errorf("echo response = %+v", resps[1])
+	}
+	if resps[2].ID != 3 || !resps[2].Result.IsError {
+		t.Errorf("batched calls must honor the allow-list: %+v", resps[2])
+	}
+
+	if resp := rpc(t, url, []any{map[string]any{"jsonrpc": "2.0", "method": "notifications/initialized"}}, nil); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification-only batch: status %d, want 202", resp.StatusCode)
+	}
+	initialize := []any{map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize"}}
+	if resp := rpc(t, url, initialize, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("batched initialize: status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHTTPToolsListChanged(t *testing.T) {
+	s, base := newHTTPServer(t)
+	token := mcp.NewToken()
+	s.RegisterSession(token, "agent-1", "/", []string{"*"})
+	stream := openStream(t, base+"/mcp/"+token, "")
+
+	// A burst of registrations is announced once.
+	s.RegisterTool("late", mcp.ToolDef{Description: "Late"}, func(any, map[string]any) (string, error) { return "", nil })
+	s.UnregisterTool("admin")
+	select {
+	case data := <-stream:
+		if !strings.Contains(data, `"method":"notifications/tools/list_changed"`) {
+			t.Errorf("event = %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notifications/tools/list_changed after RegisterTool")
+	}
+	select {
+	case data := <-stream:
+		t.Errorf("expected one coalesced notification, also got %s", data)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -1,89 +1,422 @@
 package mcp
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"os/exec"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
-// ExternalServerConfig describes an external stdio MCP server to proxy
+// ExternalServerConfig describes an external MCP server to proxy: a local
+// subprocess (Command) or a remote server (URL).
 type ExternalServerConfig struct {
 	Name    string
 	Command string
 	Args    []string
 	Env     map[string]string
+	// EnvAllow, if non-nil, names the only daemon environment variables a
+	// local server inherits, as for sandboxed commands; Env is added on top.
+	// Nil inherits the whole environment. Dir is the working directory.
+	EnvAllow []string
+	Dir      string
+	// URL is a remote server's endpoint. Transport selects "http"
+	// (Streamable HTTP) or "sse" (the older HTTP+SSE transport); empty tries
+	// Streamable HTTP and falls back to SSE. Headers are sent with every
+	// request, e.g. Authorization; see ExpandHeaders.
+	URL       string
+	Transport string
+	Headers   map[string]string
+	// Timeout bounds each request; zero means defaultProxyTimeout.
+	Timeout time.Duration
 }
 
-// ProxyClient manages a stdio MCP server subprocess and proxies tool calls to it
+const (
+	// defaultProxyTimeout bounds a request to an external server when its
+	// config does not set one.
+	defaultProxyTimeout = 2 * time.Minute
+	// Restart backoff after the server process exits or the remote session is
+	// lost: it doubles from proxyMinBackoff up to proxyMaxBackoff, and resets
+	// once a connection has stayed up for proxyStableAfter.
+	proxyMinBackoff  = time.Second
+	proxyMaxBackoff  = time.Minute
+	proxyStableAfter = time.Minute
+)
+
+// Proxy states reported by ProxyHealth.
+const (
+	ProxyReady      = "ready"
+	ProxyRestarting = "restarting"
+	ProxyClosed     = "closed"
+)
+
+// ProxyHealth is a snapshot of one proxied server, reported by state_health.
+type ProxyHealth struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	URL       string    `json:"url,omitempty"` // remote servers
+	PID       int       `json:"pid,omitempty"` // local servers
+	Since     time.Time `json:"since"`         // when State was entered
+	Restarts  int       `json:"restarts"`
+	InFlight  int       `json:"in_flight"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// ProxyClient proxies tool calls to an external MCP server, over stdio to a
+// subprocess or over HTTP to a remote server. Requests are multiplexed by
+// JSON-RPC id, so a slow call does not hold up the others. If the process
+// exits or the remote session is lost, in-flight calls fail and the
+// connection is re-established with backoff.
 type ProxyClient struct {
-	name   string
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout *bufio.Reader
-	mu     sync.Mutex // Serializes all send/receive operations
-	nextID int64
+	cfg     ExternalServerConfig
+	timeout time.Duration
+	nextID  int64
+
+	mu        sync.Mutex // guards everything below
+	conn      proxyConn  // nil while restarting or after Close
+	pending   map[int64]chan proxyResult
+	state     string
+	since     time.Time
+	restarts  int
+	lastError string
+	backoff   time.Duration
+	closed    chan struct{}
+	onRestart func(tools []ToolDef)
+}
+
+// proxyConn is one connection to the server: a run of the subprocess or a
+// remote session. Messages it receives are passed to ProxyClient.receive.
+type proxyConn interface {
+	// send delivers one JSON-RPC message to the server.
+	send(ctx context.Context, msg []byte) error
+	// done is closed when the connection is lost; err then says why.
+	done() <-chan struct{}
+	err() error
+	// protocolVersion is the MCP version to request on initialize.
+	protocolVersion() string
+	pid() int
+	close()
 }
 
-// StartProxy starts an external MCP server subprocess and initializes the MCP session
+// proxyResult is what a pending request receives: a response or the error
+// that ended it (such as the process exiting).
+type proxyResult struct {
+	resp *proxyResponse
+	err  error
+}
+
+// proxyMessage is any message from the server: a response to one of our
+// requests, a notification, or a request of its own.
+type proxyMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	proxyResponse
+}
+
+type proxyResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonRPCError   `json:"error,omitempty"`
+}
+
+// StartProxy starts or connects to an external MCP server and initializes
+// the MCP session.
 func StartProxy(cfg ExternalServerConfig) (*ProxyClient, error) {
-	cmd := exec.Command(cfg.Command, cfg.Args...)
+	if (cfg.Command == "") == (cfg.URL == "") {
+		return nil, fmt.Errorf("%s: exactly one of command or url is required", cfg.Name)
+	}
+	client := &ProxyClient{
+		cfg:     cfg,
+		timeout: cfg.Timeout,
+		pending: make(map[int64]chan proxyResult),
+		backoff: proxyMinBackoff,
+		closed:  make(chan struct{}),
+	}
+	if client.timeout <= 0 {
+		client.timeout = defaultProxyTimeout
+	}
+	if err := client.start(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
 
-	// Inherit parent environment, then override/add specified vars
-	cmd.Env = os.Environ()
-	for k, v := range cfg.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+// start opens a connection, runs the initialize handshake and makes it the
+// current connection.
+func (c *ProxyClient) start() error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
 	}
 
-	// Pipe stderr to our stderr so we can see server logs
-	cmd.Stderr = os.Stderr
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		conn.close()
+		return fmt.Errorf("%s: client closed", c.cfg.Name)
+	default:
+	}
+	c.conn = conn
+	c.setState(ProxyReady)
+	c.mu.Unlock()
+
+	go func() {
+		<-conn.done()
+		c.exited(conn, conn.err())
+	}()
+	if pid := conn.pid(); pid != 0 {
+		log.Printf("[proxy:%s] Ready (pid=%d)", c.cfg.Name, pid)
+	} else {
+		log.Printf("[proxy:%s] Ready (%s)", c.cfg.Name, c.cfg.URL)
+	}
+	return nil
+}
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("stdin pipe: %w", err)
+// connect opens and initializes a connection of the configured kind. With no
+// transport set, a remote server that rejects Streamable HTTP with a 4xx
+// status is retried over SSE.
+func (c *ProxyClient) connect() (proxyConn, error) {
+	var dials []func() (proxyConn, error)
+	switch {
+	case c.cfg.Command != "":
+		dials = append(dials, func() (proxyConn, error) { return startStdioConn(c) })
+	case c.cfg.Transport == "http":
+		dials = append(dials, func() (proxyConn, error) { return newStreamableConn(c), nil })
+	case c.cfg.Transport == "sse":
+		dials = append(dials, func() (proxyConn, error) { return dialSSEConn(c) })
+	case c.cfg.Transport == "":
+		dials = append(dials,
+			func() (proxyConn, error) { return newStreamableConn(c), nil },
+			func() (proxyConn, error) { return dialSSEConn(c) })
+	default:
+		return nil, fmt.Errorf("%s: unknown transport %q", c.cfg.Name, c.cfg.Transport)
+	}
+
+	var err error
+	for i, dial := range dials {
+		var conn proxyConn
+		conn, err = dial()
+		if err == nil {
+			if err = c.initialize(conn); err == nil {
+				return conn, nil
+			}
+			conn.close()
+		}
+		var status *httpStatusError
+		if i+1 < len(dials) && errors.As(err, &status) && status.code >= 400 && status.code < 500 {
+			log.Printf("[proxy:%s] Streamable HTTP rejected (%v); trying SSE", c.cfg.Name, err)
+			continue
+		}
+		break
 	}
+	return nil, fmt.Errorf("initialize %s: %w", c.cfg.Name, err)
+}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("stdout pipe: %w", err)
+// receive handles one message from conn.
+func (c *ProxyClient) receive(conn proxyConn, data []byte) {
+	var msg proxyMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		// Not valid JSON-RPC — skip (could be startup logs)
+		log.Printf("[proxy:%s] Skipping non-JSON line: %.80s", c.cfg.Name, data)
+		return
+	}
+
+	switch {
+	case msg.Method != "" && len(msg.ID) > 0:
+		c.answerServerRequest(conn, msg)
+	case msg.Method != "" || len(msg.ID) == 0:
+		// Notifications are not forwarded.
+	default:
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			log.Printf("[proxy:%s] Skipping response with unexpected id %s", c.cfg.Name, msg.ID)
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ok {
+			ch <- proxyResult{resp: &msg.proxyResponse}
+		}
 	}
+}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+// answerServerRequest replies to a request the server sent us. Only ping is
+// supported.
+func (c *ProxyClient) answerServerRequest(conn proxyConn, msg proxyMessage) {
+	reply := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = map[string]any{}
+	} else {
+		reply["error"] = jsonRPCError{Code: -32601, Message: "Method not found: " + msg.Method}
+	}
+	if err := c.write(context.Background(), conn, reply); err != nil {
+		log.Printf("[proxy:%s] Failed to answer %s: %v", c.cfg.Name, msg.Method, err)
 	}
+}
 
-	client := &ProxyClient{
-		name:   cfg.Name,
-		cmd:    cmd,
-		stdin:  stdin,
-		stdout: bufio.NewReader(stdout),
+// exited fails the calls still waiting on conn and, unless the client was
+// closed, schedules a restart.
+func (c *ProxyClient) exited(conn proxyConn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != conn {
+		// Already closed.
+		return
 	}
 
-	if err := client.initialize(); err != nil {
-		cmd.Process.Kill()
-		cmd.Wait()
-		return nil, fmt.Errorf("initialize %s: %w", cfg.Name, err)
+	reason := c.lostReason(err)
+	for id, ch := range c.pending {
+		ch <- proxyResult{err: fmt.Errorf("%s: %s", c.cfg.Name, reason)}
+		delete(c.pending, id)
 	}
 
-	log.Printf("[proxy:%s] Ready (pid=%d)", cfg.Name, cmd.Process.Pid)
-	return client, nil
+	c.conn = nil
+	if time.Since(c.since) >= proxyStableAfter {
+		c.backoff = proxyMinBackoff
+	}
+	c.lastError = reason
+	c.setState(ProxyRestarting)
+	log.Printf("[proxy:%s] %s; restarting in %s", c.cfg.Name, reason, c.backoff)
+	go c.restartLoop()
+}
+
+// lostReason describes a lost connection.
+func (c *ProxyClient) lostReason(err error) string {
+	reason := "connection lost"
+	if c.cfg.Command != "" {
+		reason = "process exited"
+	}
+	if err != nil {
+		reason = fmt.Sprintf("%s: %v", reason, err)
+	}
+	return reason
+}
+
+// restartLoop reconnects, backing off between failed attempts, until it
+// succeeds or the client is closed.
+func (c *ProxyClient) restartLoop() {
+	for {
+		c.mu.Lock()
+		delay := c.backoff
+		c.backoff = min(c.backoff*2, proxyMaxBackoff)
+		c.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-c.closed:
+			return
+		}
+
+		err := c.start()
+		if err == nil {
+			c.mu.Lock()
+			c.restarts++
+			c.mu.Unlock()
+			c.rediscover()
+			return
+		}
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+		log.Printf("[proxy:%s] Restart failed: %v", c.cfg.Name, err)
+		c.mu.Lock()
+		c.lastError = err.Error()
+		c.mu.Unlock()
+	}
+}
+
+// rediscover lists the tools of a restarted server, which may have changed,
+// and passes them to the SetOnRestart callback.
+func (c *ProxyClient) rediscover() {
+	c.mu.Lock()
+	fn := c.onRestart
+	c.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	tools, err := c.DiscoverTools()
+	if err != nil {
+		log.Printf("[proxy:%s] Failed to rediscover tools after restart: %v", c.cfg.Name, err)
+		return
+	}
+	log.Printf("[proxy:%s] Rediscovered %d tools after restart", c.cfg.Name, len(tools))
+	fn(tools)
+}
+
+// SetOnRestart registers a callback that fires with the server's tools each
+// time it has been restarted, so they can be registered again. Pass nil to
+// clear.
+func (c *ProxyClient) SetOnRestart(fn func(tools []ToolDef)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRestart = fn
+}
+
+// setState records a state change; callers hold c.mu.
+func (c *ProxyClient) setState(state string) {
+	c.state = state
+	c.since = time.Now()
+}
+
+// Health reports the server's state, for state_health.
+func (c *ProxyClient) Health() ProxyHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := ProxyHealth{
+		Name:      c.cfg.Name,
+		State:     c.state,
+		URL:       c.cfg.URL,
+		Since:     c.since,
+		Restarts:  c.restarts,
+		InFlight:  len(c.pending),
+		LastError: c.lastError,
+	}
+	if c.conn != nil {
+		h.PID = c.conn.pid()
+	}
+	return h
 }
 
 func (c *ProxyClient) newID() int64 {
 	return atomic.AddInt64(&c.nextID, 1)
 }
 
-// sendRequest sends a JSON-RPC request and reads the corresponding response.
-// The mutex must NOT be held by the caller — this method acquires it.
-func (c *ProxyClient) sendRequest(method string, params any) (json.RawMessage, error) {
+// current returns the live connection, or an error while it is restarting.
+func (c *ProxyClient) current() (proxyConn, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.conn == nil {
+		if c.state == ProxyClosed {
+			return nil, fmt.Errorf("%s is closed", c.cfg.Name)
+		}
+		return nil, fmt.Errorf("%s is restarting (last error: %s)", c.cfg.Name, c.lastError)
+	}
+	return c.conn, nil
+}
+
+// sendRequest sends a JSON-RPC request on the current connection and waits
+// for its response, the configured timeout, or ctx. A request abandoned by
+// timeout or cancellation is cancelled on the server with
+// notifications/cancelled.
+func (c *ProxyClient) sendRequest(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	conn, err := c.current()
+	if err != nil {
+		return nil, err
+	}
+	return c.roundTrip(ctx, conn, method, params)
+}
+
+func (c *ProxyClient) roundTrip(ctx context.Context, conn proxyConn, method string, params any) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 
 	id := c.newID()
 	req := map[string]any{
@@ -95,55 +428,61 @@ func (c *ProxyClient) sendRequest(method string, params any) (json.RawMessage, e
 		req["params"] = params
 	}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
+	ch := make(chan proxyResult, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
 
-	if _, err := fmt.Fprintf(c.stdin, "%s\n", data); err != nil {
-		return nil, fmt.Errorf("write to %s: %w", c.name, err)
+	if err := c.write(ctx, conn, req); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("write to %s: %w", c.cfg.Name, err)
 	}
 
-	// Read lines until we find a response with matching ID (skip notifications)
-	for {
-		line, err := c.stdout.ReadString('\n')
-		if err != nil {
-			return nil, fmt.Errorf("read from %s: %w", c.name, err)
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			return nil, res.err
 		}
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		if res.resp.Error != nil {
+			return nil, fmt.Errorf("rpc error %d: %s", res.resp.Error.Code, res.resp.Error.Message)
 		}
-
-		var resp jsonRPCResponse
-		if err := json.Unmarshal([]byte(line), &resp); err != nil {
-			// Not valid JSON-RPC — skip (could be startup logs)
-			log.Printf("[proxy:%s] Skipping non-JSON line: %.80s", c.name, line)
-			continue
-		}
-
-		// Notifications have no ID — skip them
-		if resp.ID == nil {
-			continue
-		}
-
-		if resp.Error != nil {
-			return nil, fmt.Errorf("rpc error %d: %s", resp.Error.Code, resp.Error.Message)
+		return res.resp.Result, nil
+	case <-conn.done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		select {
+		case res := <-ch:
+			if res.err == nil && res.resp.Error == nil {
+				return res.resp.Result, nil
+			}
+		default:
 		}
-
-		result, err := json.Marshal(resp.Result)
-		if err != nil {
-			return nil, fmt.Errorf("marshal result: %w", err)
+		// The reader goroutine may not have noticed yet; move to
+		// restarting now so Health agrees with the error returned.
+		c.exited(conn, conn.err())
+		return nil, fmt.Errorf("%s: %s", c.cfg.Name, c.lostReason(conn.err()))
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		reason := "timed out"
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "cancelled"
 		}
-		return result, nil
+		c.write(context.Background(), conn, map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "notifications/cancelled",
+			"params":  map[string]any{"requestId": id, "reason": reason},
+		})
+		return nil, fmt.Errorf("%s %s on %s: %w", method, reason, c.cfg.Name, ctx.Err())
 	}
 }
 
 // sendNotification sends a JSON-RPC notification (no response expected)
-func (c *ProxyClient) sendNotification(method string, params any) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+func (c *ProxyClient) sendNotification(conn proxyConn, method string, params any) error {
 	notif := map[string]any{
 		"jsonrpc": "2.0",
 		"method":  method,
@@ -151,19 +490,21 @@ func (c *ProxyClient) sendNotification(method string, params any) error {
 	if params != nil {
 		notif["params"] = params
 	}
+	return c.write(context.Background(), conn, notif)
+}
 
-	data, err := json.Marshal(notif)
+// write sends one JSON-RPC message on conn.
+func (c *ProxyClient) write(ctx context.Context, conn proxyConn, msg any) error {
+	data, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("marshal notification: %w", err)
+		return fmt.Errorf("marshal message: %w", err)
 	}
-
-	_, err = fmt.Fprintf(c.stdin, "%s\n", data)
-	return err
+	return conn.send(ctx, data)
 }
 
-func (c *ProxyClient) initialize() error {
-	_, err := c.sendRequest("initialize", map[string]any{
-		"protocolVersion": "2024-11-05",
+func (c *ProxyClient) initialize(conn proxyConn) error {
+	_, err := c.roundTrip(context.Background(), conn, "initialize", map[string]any{
+		"protocolVersion": conn.protocolVersion(),
 		"clientInfo": map[string]string{
 			"name":    "bud2",
 			"version": "0.1.0",
@@ -174,12 +515,12 @@ func (c *ProxyClient) initialize() error {
 		return fmt.Errorf("initialize handshake: %w", err)
 	}
 
-	return c.sendNotification("notifications/initialized", nil)
+	return c.sendNotification(conn, "notifications/initialized", nil)
 }
 
 // DiscoverTools lists all tools available from the external server
 func (c *ProxyClient) DiscoverTools() ([]ToolDef, error) {
-	result, err := c.sendRequest("tools/list", nil)
+	result, err := c.sendRequest(context.Background(), "tools/list", nil)
 	if err != nil {
 		return nil, fmt.Errorf("tools/list: %w", err)
 	}
@@ -211,7 +552,13 @@ func (c *ProxyClient) DiscoverTools() ([]ToolDef, error) {
 
 // CallTool calls a named tool on the external server
 func (c *ProxyClient) CallTool(name string, args map[string]any) (string, error) {
-	result, err := c.sendRequest("tools/call", map[string]any{
+	return c.CallToolContext(context.Background(), name, args)
+}
+
+// CallToolContext is CallTool with a context; cancelling it abandons the call
+// and cancels it on the server.
+func (c *ProxyClient) CallToolContext(ctx context.Context, name string, args map[string]any) (string, error) {
+	result, err := c.sendRequest(ctx, "tools/call", map[string]any{
 		"name":      name,
 		"arguments": args,
 	})
@@ -239,7 +586,7 @@ func (c *ProxyClient) CallTool(name string, args map[string]any) (string, error)
 
 // ListResources lists all resources available from the external server.
 func (c *ProxyClient) ListResources() ([]ResourceInfo, error) {
-	result, err := c.sendRequest("resources/list", nil)
+	result, err := c.sendRequest(context.Background(), "resources/list", nil)
 	if err != nil {
 		return nil, fmt.Errorf("resources/list: %w", err)
 	}
@@ -271,7 +618,7 @@ func (c *ProxyClient) ListResources() ([]ResourceInfo, error) {
 // ReadResource reads an MCP resource by URI from the external server.
 // Returns the text content of the first content item in the response.
 func (c *ProxyClient) ReadResource(uri string) (string, error) {
-	result, err := c.sendRequest("resources/read", map[string]any{
+	result, err := c.sendRequest(context.Background(), "resources/read", map[string]any{
 		"uri": uri,
 	})
 	if err != nil {
@@ -295,16 +642,25 @@ func (c *ProxyClient) ReadResource(uri string) (string, error) {
 	return readResult.Contents[0].Text, nil
 }
 
-// Close stops the external server process
+// Close stops the external server process or ends the remote session, and
+// any pending restart.
 func (c *ProxyClient) Close() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	close(c.closed)
+	conn := c.conn
+	c.conn = nil
+	c.setState(ProxyClosed)
+	c.mu.Unlock()
 
-	c.stdin.Close()
-	if c.cmd.Process != nil {
-		c.cmd.Process.Kill()
+	if conn != nil {
+		conn.close()
 	}
-	c.cmd.Wait()
 }
 
 // MCPConfig represents the MCP proxy configuration file (state/system/mcp.json)
@@ -312,13 +668,37 @@ type MCPConfig struct {
 	MCPServers map[string]MCPServerEntry `json:"mcpServers"`
 }
 
-// MCPServerEntry is a single server entry in the MCP proxy config
+// MCPServerEntry is a single server entry in the MCP proxy config: a local
+// command, or the url of a remote server.
 type MCPServerEntry struct {
-	Type    string            `json:"type,omitempty"`    // "http" for HTTP transport
-	URL     string            `json:"url,omitempty"`     // for type=http
+	Type    string            `json:"type,omitempty"`    // remote transport: "http", "sse", or empty to detect
+	URL     string            `json:"url,omitempty"`     // remote server endpoint
+	Headers map[string]string `json:"headers,omitempty"` // for url; values may use ${ENV} and ${secret:NAME}
 	Command string            `json:"command,omitempty"` // for stdio
 	Args    []string          `json:"args,omitempty"`    // for stdio
 	Env     map[string]string `json:"env,omitempty"`     // for stdio
+	Timeout int               `json:"timeout,omitempty"` // per-request timeout in seconds
+}
+
+// ServerConfig converts the entry to the config StartProxy takes, resolving
+// header references against the environment and secrets.
+func (e MCPServerEntry) ServerConfig(name string, secrets SecretStore) (ExternalServerConfig, error) {
+	cfg := ExternalServerConfig{
+		Name:    name,
+		Command: e.Command,
+		Args:    e.Args,
+		Env:     e.Env,
+		Timeout: time.Duration(e.Timeout) * time.Second,
+	}
+	if e.URL == "" {
+		return cfg, nil
+	}
+	headers, err := ExpandHeaders(e.Headers, secrets)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.URL, cfg.Transport, cfg.Headers = e.URL, e.Type, headers
+	return cfg, nil
 }
 
 // LoadMCPConfig reads and parses an MCP proxy config file
@@ -334,10 +714,12 @@ func LoadMCPConfig(path string) (*MCPConfig, error) {
 	return &cfg, nil
 }
 
-// StartProxiesFromConfig reads state/system/mcp.json and starts proxy clients for all stdio servers.
+// StartProxiesFromConfig reads state/system/mcp.json and starts proxy clients for its
+// stdio servers and connects to its remote (url) servers, resolving header
+// secrets from secrets (may be nil).
 // For each server discovered, it registers its tools with the given MCP server.
 // Returns a slice of started proxy clients (caller should defer Close on each).
-func StartProxiesFromConfig(mcpConfigPath string, server *Server) ([]*ProxyClient, error) {
+func StartProxiesFromConfig(mcpConfigPath string, server *Server, secrets SecretStore) ([]*ProxyClient, error) {
 	cfg, err := LoadMCPConfig(mcpConfigPath)
 	if err != nil {
 		return nil, fmt.Errorf("load mcp config: %w", err)
@@ -346,19 +728,22 @@ func StartProxiesFromConfig(mcpConfigPath string, server *Server) ([]*ProxyClien
 	var proxies []*ProxyClient
 
 	for name, entry := range cfg.MCPServers {
-		// Skip HTTP servers — they're not stdio proxies
-		if entry.Type == "http" || entry.Command == "" {
+		if entry.Command == "" && entry.URL == "" {
 			continue
 		}
 
-		log.Printf("[proxy] Starting %s: %s %v", name, entry.Command, entry.Args)
+		serverCfg, err := entry.ServerConfig(name, secrets)
+		if err != nil {
+			log.Printf("[proxy] Skipping %s: %v", name, err)
+			continue
+		}
+		if entry.URL != "" {
+			log.Printf("[proxy] Connecting to %s: %s", name, entry.URL)
+		} else {
+			log.Printf("[proxy] Starting %s: %s %v", name, entry.Command, entry.Args)
+		}
 
-		proxy, err := StartProxy(ExternalServerConfig{
-			Name:    name,
-			Command: entry.Command,
-			Args:    entry.Args,
-			Env:     entry.Env,
-		})
+		proxy, err := StartProxy(serverCfg)
 		if err != nil {
 			log.Printf("[proxy] Failed to start %s: %v", name, err)
 			continue
@@ -374,18 +759,37 @@ func StartProxiesFromConfig(mcpConfigPath string, server *Server) ([]*ProxyClien
 
 		log.Printf("[proxy:%s] Discovered %d tools", name, len(tools))
 
-		// Register each tool with the main MCP server
-		// The handler proxies calls through to the external process
-		for _, def := range tools {
-			toolName := def.Name
-			proxyRef := proxy // capture for closure
-			server.RegisterTool(toolName, def, func(ctx any, args map[string]any) (string, error) {
-				return proxyRef.CallTool(toolName, args)
-			})
-		}
+		// Register each tool with the main MCP server, and again whenever the
+		// server restarts, since its tools may have changed.
+		registered := RegisterProxyTools(server, proxy, tools, nil)
+		proxy.SetOnRestart(func(tools []ToolDef) {
+			registered = RegisterProxyTools(server, proxy, tools, registered)
+		})
 
 		proxies = append(proxies, proxy)
 	}
 
 	return proxies, nil
 }
+
+// RegisterProxyTools registers tools on server with handlers that call
+// through to proxy, and unregisters the names in prev that tools no longer
+// includes. It returns the names now registered.
+func RegisterProxyTools(server *Server, proxy *ProxyClient, tools []ToolDef, prev []string) []string {
+	names := make([]string, 0, len(tools))
+	current := make(map[string]bool, len(tools))
+	for _, def := range tools {
+		toolName := def.Name
+		server.RegisterTool(toolName, def, func(ctx any, args map[string]any) (string, error) {
+			return proxy.CallTool(toolName, args)
+		})
+		names = append(names, toolName)
+		current[toolName] = true
+	}
+	for _, name := range prev {
+		if !current[name] {
+			server.UnregisterTool(name)
+		}
+	}
+	return names
+}
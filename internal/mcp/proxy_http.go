@@ -0,0 +1,332 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHTTPClient is shared by remote proxy connections. Requests are bounded
+// by their context rather than a client timeout, since SSE streams stay open.
+var proxyHTTPClient = &http.Client{}
+
+// httpStatusError is an unexpected HTTP status from a remote server.
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("HTTP %d", e.code)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.code, e.body)
+}
+
+func newHTTPStatusError(resp *http.Response) *httpStatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &httpStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+}
+
+// connLoss is embedded by remote connections to signal that the session is
+// gone.
+type connLoss struct {
+	once    sync.Once
+	lost    chan struct{}
+	lostErr error
+}
+
+func newConnLoss() connLoss { return connLoss{lost: make(chan struct{})} }
+
+func (l *connLoss) fail(err error) {
+	l.once.Do(func() {
+		l.lostErr = err
+		close(l.lost)
+	})
+}
+
+func (l *connLoss) done() <-chan struct{} { return l.lost }
+
+func (l *connLoss) err() error {
+	select {
+	case <-l.lost:
+		return l.lostErr
+	default:
+		return nil
+	}
+}
+
+// streamableConn is a Streamable HTTP session with a remote server. Each
+// message is POSTed to the endpoint; replies come back as a JSON body or as
+// an SSE stream on the POST response.
+type streamableConn struct {
+	connLoss
+	c       *ProxyClient
+	mu      sync.Mutex
+	session string // Mcp-Session-Id assigned on initialize
+}
+
+func newStreamableConn(c *ProxyClient) *streamableConn {
+	return &streamableConn{connLoss: newConnLoss(), c: c}
+}
+
+func (s *streamableConn) sessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+func (s *streamableConn) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.c.cfg.URL, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	setProxyHeaders(req, s.c.cfg.Headers)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	session := s.sessionID()
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+
+	resp, err := proxyHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if sid := resp.Header.Get(sessionHeader); sid != "" && session == "" {
+		s.mu.Lock()
+		s.session = sid
+		s.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && session != "":
+		// The server dropped our session; reconnecting starts a new one.
+		resp.Body.Close()
+		err := errors.New("session expired")
+		s.fail(err)
+		return err
+	case resp.StatusCode >= 300:
+		defer resp.Body.Close()
+		return newHTTPStatusError(resp)
+	case resp.StatusCode == http.StatusAccepted:
+		resp.Body.Close()
+		return nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		go func() {
+			defer resp.Body.Close()
+			readSSE(resp.Body, func(event, data string) {
+				if event == "" || event == "message" {
+					s.c.receive(s, []byte(data))
+				}
+			})
+		}()
+		return nil
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	s.c.receiveBody(s, body)
+	return nil
+}
+
+func (s *streamableConn) protocolVersion() string { return "2025-03-26" }
+
+func (s *streamableConn) pid() int { return 0 }
+
+// close ends the session on the server, best effort.
+func (s *streamableConn) close() {
+	defer s.fail(nil)
+	session := s.sessionID()
+	if session == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.c.cfg.URL, nil)
+	if err != nil {
+		return
+	}
+	setProxyHeaders(req, s.c.cfg.Headers)
+	req.Header.Set(sessionHeader, session)
+	if resp, err := proxyHTTPClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// sseConn is a session over the HTTP+SSE transport (MCP 2024-11-05): a GET
+// stream carries everything from the server, and messages to it are POSTed
+// to the endpoint the stream announces first.
+type sseConn struct {
+	connLoss
+	c        *ProxyClient
+	cancel   context.CancelFunc
+	endpoint string
+}
+
+// dialSSEConn opens the event stream and waits for its endpoint event.
+func dialSSEConn(c *ProxyClient) (*sseConn, error) {
+	base, err := url.Parse(c.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.URL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	setProxyHeaders(req, c.cfg.Headers)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := proxyHTTPClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		cancel()
+		return nil, newHTTPStatusError(resp)
+	}
+
+	s := &sseConn{connLoss: newConnLoss(), c: c, cancel: cancel}
+	endpoint := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readSSE(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				// Messages carry the configured headers, so they may only
+				// go to the origin the user configured.
+				ref, err := base.Parse(strings.TrimSpace(data))
+				if err != nil || ref.Scheme != base.Scheme || ref.Host != base.Host {
+					s.fail(fmt.Errorf("endpoint %q is not on %s://%s", data, base.Scheme, base.Host))
+					return
+				}
+				select {
+				case endpoint <- ref.String():
+				default:
+				}
+			case "", "message":
+				s.c.receive(s, []byte(data))
+			}
+		})
+		if err == nil {
+			err = errors.New("event stream closed")
+		}
+		s.fail(err)
+	}()
+
+	select {
+	case s.endpoint = <-endpoint:
+		return s, nil
+	case <-s.lost:
+		cancel()
+		return nil, fmt.Errorf("event stream: %w", s.lostErr)
+	case <-time.After(c.timeout):
+		cancel()
+		return nil, errors.New("event stream sent no endpoint")
+	}
+}
+
+func (s *sseConn) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	setProxyHeaders(req, s.c.cfg.Headers)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := proxyHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
+
+func (s *sseConn) protocolVersion() string { return "2024-11-05" }
+
+func (s *sseConn) pid() int { return 0 }
+
+func (s *sseConn) close() {
+	s.cancel()
+	<-s.lost
+}
+
+// receiveBody handles a JSON response body, which may be a single message or
+// a batch.
+func (c *ProxyClient) receiveBody(conn proxyConn, body []byte) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return
+	}
+	if body[0] != '[' {
+		c.receive(conn, body)
+		return
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		log.Printf("[proxy:%s] Skipping malformed batch: %v", c.cfg.Name, err)
+		return
+	}
+	for _, msg := range batch {
+		c.receive(conn, msg)
+	}
+}
+
+// readSSE reads a text/event-stream, calling fn for each event, until the
+// stream ends. It returns nil at EOF.
+func readSSE(r io.Reader, fn func(event, data string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment, e.g. a keep-alive ping
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// setProxyHeaders adds a remote server's configured headers to req.
+func setProxyHeaders(req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
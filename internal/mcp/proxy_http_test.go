@@ -0,0 +1,158 @@
+package mcp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+// startBudServer serves a bud MCP server with an echo tool over Streamable
+// HTTP and returns its endpoint for a session allowed every tool.
+func startBudServer(t *testing.T) (*mcp.Server, string) {
+	t.Helper()
+	s := mcp.NewServer()
+	s.RegisterTool("echo", mcp.ToolDef{Description: "Echo"}, func(_ any, args map[string]any) (string, error) {
+		msg, _ := args["msg"].(string)
+		return "echo: " + msg, nil
+	})
+	token := mcp.NewToken()
+	s.RegisterSession(token, "test", "/", []string{"*"})
+	ln, err := s.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	return s, "http://" + ln.Addr().String() + "/mcp/" + token
+}
+
+func TestProxyStreamableHTTP(t *testing.T) {
+	_, endpoint := startBudServer(t)
+	proxy, err := mcp.StartProxy(mcp.ExternalServerConfig{Name: "remote", URL: endpoint, Transport: "http"})
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	defer proxy.Close()
+
+	defs, err := proxy.DiscoverTools()
+	if err != nil || len(defs) != 1 || defs[0].Name != "echo" {
+		t.Fatalf("DiscoverTools = %v, %v", defs, err)
+	}
+	if out, err := proxy.CallTool("echo", map[string]any{"msg": "hi"}); err != nil || out != "echo: hi" {
+		t.Errorf("CallTool = %q, %v", out, err)
+	}
+	if h := proxy.Health(); h.State != mcp.ProxyReady || h.URL != endpoint {
+		t.Errorf("Health = %+v", h)
+	}
+}
+
+// fakeSSEServer is a server speaking the HTTP+SSE transport (MCP
+// 2024-11-05): GET /sse streams an endpoint event and then every reply;
+// messages are POSTed to the endpoint.
+type fakeSSEServer struct {
+	endpoint string // announced in the endpoint event
+	replies  chan []byte
+	auth     chan string // Authorization header of each POST
+}
+
+func (f *fakeSSEServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/sse":
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", f.endpoint)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case reply := <-f.replies:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", reply)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/messages":
+		select {
+		case f.auth <- r.Header.Get("Authorization"):
+		default:
+		}
+		var req struct {
+			ID     any             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(http.StatusAccepted)
+		if req.ID == nil {
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2024-11-05", "capabilities": map[string]any{}, "serverInfo": map[string]any{"name": "fake"}}
+		case "tools/list":
+			result = map[string]any{"tools": []any{map[string]any{"name": "legacy_echo", "inputSchema": map[string]any{"type": "object"}}}}
+		case "tools/call":
+			var p struct {
+				Arguments map[string]any `json:"arguments"`
+			}
+			json.Unmarshal(req.Params, &p)
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": fmt.Sprintf("legacy: %v", p.Arguments["msg"])}}}
+		}
+		reply, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		f.replies <- reply
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestProxySSE(t *testing.T) {
+	fake := &fakeSSEServer{endpoint: "/messages?session=1", replies: make(chan []byte, 8), auth: make(chan string, 16)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	proxy, err := mcp.StartProxy(mcp.ExternalServerConfig{
+		Name:    "legacy",
+		URL:     srv.URL + "/sse",
+		Headers: map[string]string{"Authorization": "Bearer s3cret"},
+	})
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	defer proxy.Close()
+
+	defs, err := proxy.DiscoverTools()
+	if err != nil || len(defs) != 1 || defs[0].Name != "legacy_echo" {
+		t.Fatalf("DiscoverTools = %v, %v", defs, err)
+	}
+	if out, err := proxy.CallTool("legacy_echo", map[string]any{"msg": "hi"}); err != nil || out != "legacy: hi" {
+		t.Errorf("CallTool = %q, %v", out, err)
+	}
+	if got := <-fake.auth; got != "Bearer s3cret" {
+		t.Errorf("POST Authorization = %q", got)
+	}
+}
+
+func TestProxySSE_RejectsForeignEndpoint(t *testing.T) {
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request with configured headers reached a foreign host: %s %s", r.Method, r.URL)
+	}))
+	defer foreign.Close()
+	fake := &fakeSSEServer{endpoint: foreign.URL + "/messages", replies: make(chan []byte, 8), auth: make(chan string, 16)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	_, err := mcp.StartProxy(mcp.ExternalServerConfig{
+		Name:      "legacy",
+		URL:       srv.URL + "/sse",
+		Transport: "sse",
+		Headers:   map[string]string{"Authorization": "Bearer s3cret"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "endpoint") {
+		t.Fatalf("expected the foreign endpoint to be rejected, got %v", err)
+	}
+}
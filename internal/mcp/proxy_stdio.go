@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// stdioConn is one run of a local server subprocess, speaking
+// newline-delimited JSON-RPC on its stdin and stdout.
+type stdioConn struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex // serializes writes to stdin
+	exited  chan struct{}
+	exitErr error
+}
+
+// startStdioConn starts c's command and begins reading its output.
+func startStdioConn(c *ProxyClient) (*stdioConn, error) {
+	cfg := c.cfg
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+
+	cmd.Dir = cfg.Dir
+
+	// Inherit parent environment (or only its allow-listed part), then
+	// override/add specified vars
+	if cfg.EnvAllow != nil {
+		cmd.Env = []string{} // a nil Env would inherit everything
+		for _, name := range cfg.EnvAllow {
+			if v, ok := os.LookupEnv(name); ok {
+				cmd.Env = append(cmd.Env, name+"="+v)
+			}
+		}
+	} else {
+		cmd.Env = os.Environ()
+	}
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	// Pipe stderr to our stderr so we can see server logs
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+	}
+
+	conn := &stdioConn{cmd: cmd, stdin: stdin, exited: make(chan struct{})}
+	go conn.readLoop(c, bufio.NewReader(stdout))
+	return conn, nil
+}
+
+// readLoop passes each line of output to c until the process exits, then
+// reaps it.
+func (s *stdioConn) readLoop(c *ProxyClient, stdout *bufio.Reader) {
+	var readErr error
+	for {
+		line, err := stdout.ReadString('\n')
+		if err != nil {
+			readErr = err
+			break
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			c.receive(s, []byte(line))
+		}
+	}
+
+	s.exitErr = s.cmd.Wait()
+	if s.exitErr == nil && readErr != io.EOF {
+		s.exitErr = readErr
+	}
+	close(s.exited)
+}
+
+func (s *stdioConn) send(_ context.Context, msg []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := fmt.Fprintf(s.stdin, "%s\n", msg)
+	return err
+}
+
+func (s *stdioConn) done() <-chan struct{} { return s.exited }
+
+func (s *stdioConn) err() error {
+	select {
+	case <-s.exited:
+		return s.exitErr
+	default:
+		return nil
+	}
+}
+
+func (s *stdioConn) protocolVersion() string { return "2024-11-05" }
+
+func (s *stdioConn) pid() int { return s.cmd.Process.Pid }
+
+// close kills the process and waits for it to be reaped.
+func (s *stdioConn) close() {
+	s.stdin.Close()
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	<-s.exited
+}
@@ -0,0 +1,242 @@
+package mcp_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+// fakeServerEnv makes the test binary act as a stdio MCP server (see
+// runFakeServer) instead of running the tests. Its value is a directory
+// holding the server's start count.
+const fakeServerEnv = "BUD_TEST_FAKE_MCP_SERVER"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(fakeServerEnv); dir != "" {
+		runFakeServer(dir)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer serves MCP over stdin/stdout, handling each request on its
+// own goroutine. Tools:
+//   - echo returns its msg argument
+//   - sleep replies after ms milliseconds
+//   - exit terminates the process
+//   - cancelled lists the request ids received in notifications/cancelled
+//   - gen_<n> does nothing; n counts the server's starts, so a restarted
+//     server lists a different tool
+func runFakeServer(dir string) {
+	countFile := filepath.Join(dir, "starts")
+	data, _ := os.ReadFile(countFile)
+	gen, _ := strconv.Atoi(string(data))
+	gen++
+	os.WriteFile(countFile, []byte(strconv.Itoa(gen)), 0o644)
+
+	var mu sync.Mutex
+	var cancelled []string
+	reply := func(id json.RawMessage, result any) {
+		out, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "result": result})
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Printf("%s\n", out)
+	}
+	text := func(s string) any {
+		return map[string]any{"content": []any{map[string]any{"type": "text", "text": s}}}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string         `json:"name"`
+				Arguments map[string]any `json:"arguments"`
+				RequestID any            `json:"requestId"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil {
+			continue
+		}
+		switch req.Method {
+		case "initialize":
+			reply(req.ID, map[string]any{"protocolVersion": "2024-11-05", "capabilities": map[string]any{}, "serverInfo": map[string]any{"name": "fake"}})
+		case "tools/list":
+			var tools []any
+			for _, name := range []string{"echo", "sleep", "exit", "cancelled", fmt.Sprintf("gen_%d", gen)} {
+				tools = append(tools, map[string]any{"name": name, "inputSchema": map[string]any{"type": "object"}})
+			}
+			reply(req.ID, map[string]any{"tools": tools})
+		case "notifications/cancelled":
+			mu.Lock()
+			cancelled = append(cancelled, fmt.Sprint(req.Params.RequestID))
+			mu.Unlock()
+		case "tools/call":
+			go func() {
+				switch req.Params.Name {
+				case "echo":
+					reply(req.ID, text(fmt.Sprint(req.Params.Arguments["msg"])))
+				case "sleep":
+					ms, _ := req.Params.Arguments["ms"].(float64)
+					time.Sleep(time.Duration(ms) * time.Millisecond)
+					reply(req.ID, text("slept"))
+				case "exit":
+					os.Exit(1)
+				case "cancelled":
+					mu.Lock()
+					ids := strings.Join(cancelled, ",")
+					mu.Unlock()
+					reply(req.ID, text(ids))
+				default:
+					reply(req.ID, text(""))
+				}
+			}()
+		}
+	}
+}
+
+// fakeServerConfig returns the config of a fake stdio server (see
+// runFakeServer) and the directory holding its start count.
+func fakeServerConfig(t *testing.T) (mcp.ExternalServerConfig, string) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	return mcp.ExternalServerConfig{
+		Name:    "fake",
+		Command: exe,
+		Env:     map[string]string{fakeServerEnv: dir},
+	}, dir
+}
+
+func startFakeProxy(t *testing.T) *mcp.ProxyClient {
+	t.Helper()
+	cfg, _ := fakeServerConfig(t)
+	proxy, err := mcp.StartProxy(cfg)
+	if err != nil {
+		t.Fatalf("StartProxy: %v", err)
+	}
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+// waitFor polls cond until it holds or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestProxyStdioConcurrentCalls(t *testing.T) {
+	proxy := startFakeProxy(t)
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := proxy.CallTool("sleep", map[string]any{"ms": 1000})
+		slow <- err
+	}()
+	waitFor(t, time.Second, "the slow call to be in flight", func() bool { return proxy.Health().InFlight == 1 })
+
+	// A fast call is answered while the slow one is still running.
+	start := time.Now()
+	if out, err := proxy.CallTool("echo", map[string]any{"msg": "hi"}); err != nil || out != "hi" {
+		t.Fatalf("echo = %q, %v", out, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("echo took %s; calls are serialized behind the slow one", elapsed)
+	}
+	select {
+	case <-slow:
+		t.Error("slow call finished before the fast one")
+	default:
+	}
+	if err := <-slow; err != nil {
+		t.Errorf("slow call: %v", err)
+	}
+}
+
+func TestProxyStdioCancelled(t *testing.T) {
+	proxy := startFakeProxy(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := proxy.CallToolContext(ctx, "sleep", map[string]any{"ms": 2000}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected the call to time out, got %v", err)
+	}
+	if h := proxy.Health(); h.InFlight != 0 {
+		t.Errorf("abandoned call still in flight: %+v", h)
+	}
+
+	// The server was told to cancel the request. The initialize request is
+	// id 1, so the abandoned call is id 2.
+	waitFor(t, time.Second, "notifications/cancelled", func() bool {
+		out, err := proxy.CallTool("cancelled", nil)
+		return err == nil && out == "2"
+	})
+}
+
+func TestProxyStdioRestart(t *testing.T) {
+	cfg, dir := fakeServerConfig(t)
+	config := filepath.Join(dir, "mcp.json")
+	data, _ := json.Marshal(mcp.MCPConfig{MCPServers: map[string]mcp.MCPServerEntry{
+		"fake": {Command: cfg.Command, Env: cfg.Env},
+	}})
+	if err := os.WriteFile(config, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := mcp.NewServer()
+	proxies, err := mcp.StartProxiesFromConfig(config, server, nil)
+	if err != nil || len(proxies) != 1 {
+		t.Fatalf("StartProxiesFromConfig = %v, %v", proxies, err)
+	}
+	proxy := proxies[0]
+	t.Cleanup(proxy.Close)
+	if !slices.Contains(server.ToolNames(), "gen_1") {
+		t.Fatalf("tools = %v, want gen_1", server.ToolNames())
+	}
+	firstPID := proxy.Health().PID
+
+	if _, err := proxy.CallTool("exit", nil); err == nil || !strings.Contains(err.Error(), "process exited") {
+		t.Fatalf("expected the call to fail with the process, got %v", err)
+	}
+	if h := proxy.Health(); h.State != mcp.ProxyRestarting {
+		t.Errorf("state after exit = %q, want %q", h.State, mcp.ProxyRestarting)
+	}
+
+	waitFor(t, 5*time.Second, "the server to restart", func() bool {
+		h := proxy.Health()
+		return h.State == mcp.ProxyReady && h.Restarts == 1
+	})
+	if h := proxy.Health(); h.PID == firstPID || h.PID == 0 {
+		t.Errorf("PID after restart = %d, was %d", h.PID, firstPID)
+	}
+	if out, err := proxy.CallTool("echo", map[string]any{"msg": "back"}); err != nil || out != "back" {
+		t.Errorf("echo after restart = %q, %v", out, err)
+	}
+
+	// The restarted server's tools are registered again.
+	waitFor(t, 2*time.Second, "the tools to be rediscovered", func() bool {
+		names := server.ToolNames()
+		return slices.Contains(names, "gen_2") && !slices.Contains(names, "gen_1")
+	})
+}
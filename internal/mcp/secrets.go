@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretStore looks up the named secrets that remote server headers refer to
+// as ${secret:NAME}.
+type SecretStore interface {
+	Secret(name string) (string, bool)
+}
+
+// FileSecrets is a SecretStore read from a JSON object of name → value, such
+// as state/system/secrets.json.
+type FileSecrets map[string]string
+
+// LoadFileSecrets reads the secrets file at path. A missing file yields an
+// empty store. The file should be readable only by its owner; a warning is
+// logged if it is not.
+func LoadFileSecrets(path string) (FileSecrets, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return FileSecrets{}, nil
+	}
+	if err != nil {
+		return FileSecrets{}, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		log.Printf("[proxy] Warning: %s is accessible to other users (mode %v); chmod 600 it", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileSecrets{}, err
+	}
+	var secrets FileSecrets
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return FileSecrets{}, fmt.Errorf("parse secrets %s: %w", path, err)
+	}
+	return secrets, nil
+}
+
+// Secret returns the named secret.
+func (s FileSecrets) Secret(name string) (string, bool) {
+	v, ok := s[name]
+	return v, ok
+}
+
+var headerRefPattern = regexp.MustCompile(`\$\{(secret:)?([A-Za-z0-9_.-]+)\}`)
+
+// HeaderRefs returns the environment variables (${NAME}) and secrets
+// (${secret:NAME}) that header values refer to, each sorted and without
+// duplicates, so a caller can vet them before ExpandHeaders.
+func HeaderRefs(headers map[string]string) (env, secrets []string) {
+	seen := make(map[string]bool)
+	for _, v := range headers {
+		for _, m := range headerRefPattern.FindAllStringSubmatch(v, -1) {
+			if seen[m[0]] {
+				continue
+			}
+			seen[m[0]] = true
+			if m[1] != "" {
+				secrets = append(secrets, m[2])
+			} else {
+				env = append(env, m[2])
+			}
+		}
+	}
+	sort.Strings(env)
+	sort.Strings(secrets)
+	return env, secrets
+}
+
+// ExpandHeaders resolves references in header values: ${NAME} from the
+// environment and ${secret:NAME} from secrets (which may be nil), so
+// credentials such as "Authorization": "Bearer ${secret:team_mcp}" stay out
+// of config files. A reference that cannot be resolved is an error, rather
+// than sending an empty credential.
+func ExpandHeaders(headers map[string]string, secrets SecretStore) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	out := make(map[string]string, len(headers))
+	var missing []string
+	for _, k := range names {
+		out[k] = headerRefPattern.ReplaceAllStringFunc(headers[k], func(ref string) string {
+			m := headerRefPattern.FindStringSubmatch(ref)
+			var v string
+			var ok bool
+			if m[1] != "" {
+				if secrets != nil {
+					v, ok = secrets.Secret(m[2])
+				}
+			} else {
+				v, ok = os.LookupEnv(m[2])
+			}
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (header %s)", strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}"), k))
+			}
+			return v
+		})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unresolved header references: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package mcp_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+func TestExpandHeaders(t *testing.T) {
+	t.Setenv("TEAM_ORG", "acme")
+	secrets := mcp.FileSecrets{"team_mcp": "s3cret"}
+	got, err := mcp.ExpandHeaders(map[string]string{
+		"Authorization": "Bearer ${secret:team_mcp}",
+		"X-Org":         "${TEAM_ORG}",
+	}, secrets)
+	if err != nil || got["Authorization"] != "Bearer s3cret" || got["X-Org"] != "acme" {
+		t.Errorf("ExpandHeaders = %v, %v", got, err)
+	}
+	if _, err := mcp.ExpandHeaders(map[string]string{"Authorization": "Bearer ${secret:missing}"}, secrets); err == nil {
+		t.Error("expected an error for an unknown secret")
+	}
+}
+
+func TestHeaderRefs(t *testing.T) {
+	env, secrets := mcp.HeaderRefs(map[string]string{
+		"Authorization": "Bearer ${secret:team_mcp}",
+		"X-Org":         "${TEAM_ORG}/${secret:team_mcp}",
+	})
+	if !reflect.DeepEqual(env, []string{"TEAM_ORG"}) || !reflect.DeepEqual(secrets, []string{"team_mcp"}) {
+		t.Errorf("HeaderRefs = %v, %v", env, secrets)
+	}
+}
+
+func TestLoadFileSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if s, err := mcp.LoadFileSecrets(path); err != nil || len(s) != 0 {
+		t.Errorf("LoadFileSecrets(missing) = %v, %v", s, err)
+	}
+	if err := os.WriteFile(path, []byte(`{"team_mcp": "s3cret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := mcp.LoadFileSecrets(path)
+	if v, ok := s.Secret("team_mcp"); err != nil || !ok || v != "s3cret" {
+		t.Errorf("LoadFileSecrets = %v, %v", s, err)
+	}
+}
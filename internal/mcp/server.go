@@ -2,6 +2,8 @@ package mcp
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,22 +11,49 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/vthunder/bud2/internal/logging"
 )
 
-// SessionInfo holds the registration data for a per-subagent MCP session token.
+// SessionInfo holds the registration data for an MCP session token. Every
+// HTTP request must carry a registered token in its path (/mcp/{token}).
 type SessionInfo struct {
 	AgentID       string
 	DefaultDomain string
+	// Tools lists the tools the token may list and call, as path.Match
+	// patterns ("gk_*"); "*" allows every tool.
+	Tools []string
+}
+
+// Allows reports whether the session may use the named tool.
+func (i SessionInfo) Allows(tool string) bool {
+	for _, pattern := range i.Tools {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewToken returns a random 16-byte hex token for a session.
+func NewToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 // Server implements an MCP server over stdio
 type Server struct {
+	// toolsMu guards handlers, definitions and gkTools, which plugin hot
+	// reload changes while the server is running.
+	toolsMu sync.RWMutex
+
 	// Tool handlers
-	handlers map[string]ToolHandler
+	handlers map[string]ProgressToolHandler
 
 	// Tool definitions for tools/list
 	definitions []ToolDef
@@ -42,6 +71,13 @@ type Server struct {
 	// Extra HTTP handlers registered via RegisterHTTPHandler
 	extraHandlers map[string]http.HandlerFunc
 
+	// deniedToolHook is called when an HTTP session calls a tool outside its
+	// allow-list.
+	deniedToolHook func(session SessionInfo, tool string)
+
+	// toolCallHook is called before an HTTP session's allowed tool call runs.
+	toolCallHook func(session SessionInfo, tool string, args map[string]any)
+
 	// postToolHook is called after each tool call with the name, input args,
 	// result text, and whether the call was an error. Used to fire PostToolUse
 	// lifecycle hooks with the actual tool result.
@@ -53,6 +89,21 @@ type Server struct {
 	resourceLister func(domain string) ([]ResourceInfo, error)
 	resourceReader func(domain, uri string) (string, error)
 
+	// Prompt provider callbacks (optional).
+	// promptLister lists available prompts.
+	// promptGetter renders a prompt by name with the client's arguments.
+	promptLister func() ([]PromptInfo, error)
+	promptGetter func(name string, args map[string]string) (PromptContent, error)
+
+	// Streamable HTTP state: sessions issued on initialize, open GET streams
+	// for server-initiated messages, and the pending tools/list_changed
+	// notification. See http.go.
+	httpSessions   map[string]*httpSession
+	httpSessionsMu sync.Mutex
+	streams        map[*sseStream]struct{}
+	streamsMu      sync.Mutex
+	toolsChanged   *time.Timer
+
 	reader *bufio.Reader
 	writer io.Writer
 }
@@ -77,14 +128,28 @@ type PropDef struct {
 // ToolHandler handles a tool call
 type ToolHandler func(ctx any, args map[string]any) (string, error)
 
+// ProgressFunc reports how far a tool call has got. total is 0 when unknown;
+// progress should increase with each call. It is a no-op unless the client
+// asked for progress on a streaming connection.
+type ProgressFunc func(progress, total float64, message string)
+
+// ProgressToolHandler handles a tool call that can report progress while it
+// runs, for long-running tools.
+type ProgressToolHandler func(ctx any, args map[string]any, progress ProgressFunc) (string, error)
+
+// noProgress discards progress reports.
+func noProgress(float64, float64, string) {}
+
 // NewServer creates a new MCP server
 func NewServer() *Server {
 	return &Server{
-		handlers:      make(map[string]ToolHandler),
+		handlers:      make(map[string]ProgressToolHandler),
 		definitions:   []ToolDef{},
 		gkTools:       make(map[string]bool),
 		sessions:      make(map[string]SessionInfo),
 		extraHandlers: make(map[string]http.HandlerFunc),
+		httpSessions:  make(map[string]*httpSession),
+		streams:       make(map[*sseStream]struct{}),
 		reader:        bufio.NewReader(os.Stdin),
 		writer:        os.Stdout,
 	}
@@ -96,13 +161,58 @@ func (s *Server) SetPostToolHook(fn func(name string, args map[string]any, resul
 	s.postToolHook = fn
 }
 
-// RegisterSession maps a session token to an agent ID and default domain.
-// Called by Agent_spawn_async before starting a subagent so the GK domain can
-// be injected automatically for all gk_* tool calls from that subagent.
-func (s *Server) RegisterSession(token, agentID, domain string) {
+// SetDeniedToolHook registers a callback that fires when an HTTP session
+// calls a tool its token does not allow. Pass nil to clear.
+func (s *Server) SetDeniedToolHook(fn func(session SessionInfo, tool string)) {
+	s.deniedToolHook = fn
+}
+
+// SetToolCallHook registers a callback that fires when an HTTP session calls
+// a tool its token allows, before the tool runs. It is called synchronously,
+// so the caller sees it before the tool result, and must not block. Pass nil
+// to clear.
+func (s *Server) SetToolCallHook(fn func(session SessionInfo, tool string, args map[string]any)) {
+	s.toolCallHook = fn
+}
+
+// RegisterSession maps a session token to an agent ID, default domain and
+// tool allow-list. Called by Agent_spawn_async before starting a subagent so
+// the subagent only reaches the tools its profile grants, and its gk_* calls
+// get the GK domain injected.
+func (s *Server) RegisterSession(token, agentID, domain string, tools []string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.sessions[token] = SessionInfo{AgentID: agentID, DefaultDomain: domain, Tools: tools}
+}
+
+// SetSessionAgent fills in the agent ID of a registered session token. It
+// does nothing if the token was never registered or has been revoked, so a
+// subagent that finishes before its ID is known cannot revive its token.
+func (s *Server) SetSessionAgent(token, agentID string) {
 	s.sessionsMu.Lock()
 	defer s.sessionsMu.Unlock()
-	s.sessions[token] = SessionInfo{AgentID: agentID, DefaultDomain: domain}
+	if info, ok := s.sessions[token]; ok {
+		info.AgentID = agentID
+		s.sessions[token] = info
+	}
+}
+
+// UnregisterSession revokes a session token.
+func (s *Server) UnregisterSession(token string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	delete(s.sessions, token)
+}
+
+// Session returns the registration for a session token.
+func (s *Server) Session(token string) (SessionInfo, bool) {
+	if token == "" {
+		return SessionInfo{}, false
+	}
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	info, ok := s.sessions[token]
+	return info, ok
 }
 
 // DomainForToken returns the default domain for a session token.
@@ -134,28 +244,91 @@ func (s *Server) RegisterResourceProvider(lister func(domain string) ([]Resource
 	s.resourceReader = reader
 }
 
+// RegisterPromptProvider registers callbacks that handle prompts/list and prompts/get.
+// lister returns the available prompts.
+// getter receives a prompt name and the client's arguments and returns the rendered prompt.
+// Must be called before RunHTTP / Run.
+func (s *Server) RegisterPromptProvider(lister func() ([]PromptInfo, error), getter func(name string, args map[string]string) (PromptContent, error)) {
+	s.promptLister = lister
+	s.promptGetter = getter
+}
+
 // SetContext sets the context passed to tool handlers
 func (s *Server) SetContext(ctx any) {
 	s.context = ctx
 }
 
-// RegisterTool registers a tool handler with its definition
+// RegisterTool registers a tool handler with its definition.
+// Registering an existing name replaces the earlier tool.
 func (s *Server) RegisterTool(name string, def ToolDef, handler ToolHandler) {
-	s.handlers[name] = handler
+	s.RegisterProgressTool(name, def, func(ctx any, args map[string]any, _ ProgressFunc) (string, error) {
+		return handler(ctx, args)
+	})
+}
+
+// RegisterProgressTool registers a tool whose handler can report progress.
+// Registering an existing name replaces the earlier tool.
+func (s *Server) RegisterProgressTool(name string, def ToolDef, handler ProgressToolHandler) {
+	defer s.notifyToolsChanged()
+	s.toolsMu.Lock()
+	defer s.toolsMu.Unlock()
 	def.Name = name // Ensure name matches
+	if _, exists := s.handlers[name]; exists {
+		s.removeDefinition(name)
+	}
+	s.handlers[name] = handler
 	s.definitions = append(s.definitions, def)
 	if def.GKTool {
 		s.gkTools[name] = true
 	}
 }
 
+// UnregisterTool removes a tool. Unknown names are ignored.
+func (s *Server) UnregisterTool(name string) {
+	defer s.notifyToolsChanged()
+	s.toolsMu.Lock()
+	defer s.toolsMu.Unlock()
+	delete(s.handlers, name)
+	delete(s.gkTools, name)
+	s.removeDefinition(name)
+}
+
+// removeDefinition drops name from definitions; callers hold toolsMu.
+func (s *Server) removeDefinition(name string) {
+	for i, def := range s.definitions {
+		if def.Name == name {
+			s.definitions = append(s.definitions[:i:i], s.definitions[i+1:]...)
+			return
+		}
+	}
+}
+
+// handler returns the handler registered for name.
+func (s *Server) handler(name string) (ProgressToolHandler, bool) {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
+	h, ok := s.handlers[name]
+	return h, ok
+}
+
+// isGKTool reports whether name was registered as a GK tool.
+func (s *Server) isGKTool(name string) bool {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
+	return s.gkTools[name]
+}
+
 // ToolCount returns the number of registered tools
 func (s *Server) ToolCount() int {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
 	return len(s.definitions)
 }
 
 // ToolNames returns the names of all registered tools.
 func (s *Server) ToolNames() []string {
+	s.toolsMu.RLock()
+	defer s.toolsMu.RUnlock()
 	names := make([]string, len(s.definitions))
 	for i, def := range s.definitions {
 		names[i] = def.Name
@@ -165,11 +338,11 @@ func (s *Server) ToolNames() []string {
 
 // Call invokes a registered tool handler directly (for use by the reflex engine without HTTP)
 func (s *Server) Call(toolName string, args map[string]any) (string, error) {
-	handler, ok := s.handlers[toolName]
+	handler, ok := s.handler(toolName)
 	if !ok {
 		return "", fmt.Errorf("tool not found: %s", toolName)
 	}
-	return handler(s.context, args)
+	return handler(s.context, args, noProgress)
 }
 
 // JSON-RPC types
@@ -187,6 +360,13 @@ type jsonRPCResponse struct {
 	Error   *jsonRPCError `json:"error,omitempty"`
 }
 
+// jsonRPCNotification is a server-initiated message that expects no reply.
+type jsonRPCNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 type jsonRPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -217,6 +397,7 @@ type serverInfo struct {
 type capabilities struct {
 	Tools     *toolsCapability     `json:"tools,omitempty"`
 	Resources *resourcesCapability `json:"resources,omitempty"`
+	Prompts   *promptsCapability   `json:"prompts,omitempty"`
 }
 
 type toolsCapability struct {
@@ -227,6 +408,10 @@ type resourcesCapability struct {
 	ListChanged bool `json:"listChanged,omitempty"`
 }
 
+type promptsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
 // ResourceInfo describes a single MCP resource returned by resources/list.
 type ResourceInfo struct {
 	URI         string
@@ -259,6 +444,14 @@ type property struct {
 type toolsCallParams struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
+	Meta      *requestMeta   `json:"_meta,omitempty"`
+}
+
+// requestMeta is the _meta object a client may attach to a request.
+type requestMeta struct {
+	// ProgressToken, if set, asks for notifications/progress about the
+	// request, tagged with this token (a string or number).
+	ProgressToken any `json:"progressToken,omitempty"`
 }
 
 type toolsCallResult struct {
@@ -297,6 +490,59 @@ type resourceContent struct {
 	Text     string `json:"text"`
 }
 
+// PromptInfo describes a single MCP prompt returned by prompts/list.
+type PromptInfo struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+}
+
+// PromptArgument is a named argument a prompt accepts.
+type PromptArgument struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// PromptContent is a rendered prompt returned by prompts/get. Text is sent
+// as a single user message.
+type PromptContent struct {
+	Description string
+	Text        string
+}
+
+// MCP prompt types
+type promptsListResult struct {
+	Prompts []promptDefinition `json:"prompts"`
+}
+
+type promptDefinition struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []promptArgument `json:"arguments,omitempty"`
+}
+
+type promptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type promptsGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+type promptsGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []promptMessage `json:"messages"`
+}
+
+type promptMessage struct {
+	Role    string       `json:"role"`
+	Content contentBlock `json:"content"`
+}
+
 // Run starts the MCP server (blocking)
 func (s *Server) Run() error {
 	log.Println("[mcp] Server starting...")
@@ -339,14 +585,24 @@ func (s *Server) handleRequest(req jsonRPCRequest) *jsonRPCResponse {
 		logging.Debug("mcp", "Client initialized")
 		return nil
 	case "tools/list":
-		return s.handleToolsList(req)
+		return s.handleToolsList(req, nil)
 	case "tools/call":
-		return s.handleToolsCall(req)
+		return s.handleToolsCall(req, noProgress)
 	case "resources/list":
 		return s.handleResourcesList(req, "/")
 	case "resources/read":
 		return s.handleResourcesRead(req, "/")
+	case "prompts/list":
+		return s.handlePromptsList(req)
+	case "prompts/get":
+		return s.handlePromptsGet(req)
 	default:
+		if req.ID == nil {
+			// Notifications (e.g. notifications/cancelled) and replies to
+			// server requests never get a response.
+			logging.Debug("mcp", "Ignoring notification %q", req.Method)
+			return nil
+		}
 		log.Printf("[mcp] Unknown method: %s", req.Method)
 		return &jsonRPCResponse{
 			JSONRPC: "2.0",
@@ -368,17 +624,22 @@ func (s *Server) handleInitialize(req jsonRPCRequest) *jsonRPCResponse {
 	logging.Debug("mcp", "Initialize from %s %s", params.ClientInfo.Name, params.ClientInfo.Version)
 
 	caps := capabilities{
-		Tools: &toolsCapability{},
+		// tools/list_changed is sent on streamable HTTP connections when
+		// tools are registered or removed, e.g. on plugin reload.
+		Tools: &toolsCapability{ListChanged: true},
 	}
 	if s.resourceLister != nil {
 		caps.Resources = &resourcesCapability{}
 	}
+	if s.promptLister != nil {
+		caps.Prompts = &promptsCapability{}
+	}
 
 	return &jsonRPCResponse{
 		JSONRPC: "2.0",
 		ID:      req.ID,
 		Result: initializeResult{
-			ProtocolVersion: "2024-11-05",
+			ProtocolVersion: negotiateProtocolVersion(params.ProtocolVersion),
 			ServerInfo: serverInfo{
 				Name:    "bud2",
 				Version: "0.1.0",
@@ -388,10 +649,18 @@ func (s *Server) handleInitialize(req jsonRPCRequest) *jsonRPCResponse {
 	}
 }
 
-func (s *Server) handleToolsList(req jsonRPCRequest) *jsonRPCResponse {
+// handleToolsList lists the registered tools, only those allow accepts if it
+// is non-nil.
+func (s *Server) handleToolsList(req jsonRPCRequest, allow func(tool string) bool) *jsonRPCResponse {
 	// Convert registered ToolDefs to MCP toolDefinition format
-	tools := make([]toolDefinition, 0, len(s.definitions))
-	for _, def := range s.definitions {
+	s.toolsMu.RLock()
+	defs := append([]ToolDef(nil), s.definitions...)
+	s.toolsMu.RUnlock()
+	tools := make([]toolDefinition, 0, len(defs))
+	for _, def := range defs {
+		if allow != nil && !allow(def.Name) {
+			continue
+		}
 		props := make(map[string]property)
 		for name, p := range def.Properties {
 			props[name] = property{
@@ -417,7 +686,22 @@ func (s *Server) handleToolsList(req jsonRPCRequest) *jsonRPCResponse {
 	}
 }
 
-func (s *Server) handleToolsCall(req jsonRPCRequest) *jsonRPCResponse {
+// supportedProtocolVersions lists the MCP revisions the server speaks,
+// newest first.
+var supportedProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// negotiateProtocolVersion returns the client's requested revision if it is
+// supported, and the newest supported one otherwise.
+func negotiateProtocolVersion(requested string) string {
+	for _, v := range supportedProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return supportedProtocolVersions[0]
+}
+
+func (s *Server) handleToolsCall(req jsonRPCRequest, progress ProgressFunc) *jsonRPCResponse {
 	var params toolsCallParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		return &jsonRPCResponse{
@@ -432,7 +716,7 @@ func (s *Server) handleToolsCall(req jsonRPCRequest) *jsonRPCResponse {
 
 	logging.Debug("mcp", "Tool call: %s", params.Name)
 
-	handler, ok := s.handlers[params.Name]
+	handler, ok := s.handler(params.Name)
 	if !ok {
 		return &jsonRPCResponse{
 			JSONRPC: "2.0",
@@ -444,7 +728,7 @@ func (s *Server) handleToolsCall(req jsonRPCRequest) *jsonRPCResponse {
 		}
 	}
 
-	result, err := handler(s.context, params.Arguments)
+	result, err := handler(s.context, params.Arguments, progress)
 	if err != nil {
 		errText := fmt.Sprintf("Error: %v", err)
 		if s.postToolHook != nil {
@@ -554,6 +838,95 @@ func (s *Server) handleResourcesRead(req jsonRPCRequest, domain string) *jsonRPC
 	}
 }
 
+func (s *Server) handlePromptsList(req jsonRPCRequest) *jsonRPCResponse {
+	if s.promptLister == nil {
+		return &jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  promptsListResult{Prompts: []promptDefinition{}},
+		}
+	}
+
+	infos, err := s.promptLister()
+	if err != nil {
+		return &jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &jsonRPCError{
+				Code:    -32603,
+				Message: fmt.Sprintf("prompts/list: %v", err),
+			},
+		}
+	}
+
+	defs := make([]promptDefinition, 0, len(infos))
+	for _, p := range infos {
+		def := promptDefinition{Name: p.Name, Description: p.Description}
+		for _, a := range p.Arguments {
+			def.Arguments = append(def.Arguments, promptArgument{
+				Name:        a.Name,
+				Description: a.Description,
+				Required:    a.Required,
+			})
+		}
+		defs = append(defs, def)
+	}
+	return &jsonRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  promptsListResult{Prompts: defs},
+	}
+}
+
+func (s *Server) handlePromptsGet(req jsonRPCRequest) *jsonRPCResponse {
+	var params promptsGetParams
+	if req.Params != nil {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return &jsonRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error:   &jsonRPCError{Code: -32602, Message: fmt.Sprintf("Invalid params: %v", err)},
+			}
+		}
+	}
+	if params.Name == "" {
+		return &jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &jsonRPCError{Code: -32602, Message: "prompts/get: name is required"},
+		}
+	}
+	if s.promptGetter == nil {
+		return &jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &jsonRPCError{Code: -32601, Message: "prompts/get: no prompt provider configured"},
+		}
+	}
+
+	prompt, err := s.promptGetter(params.Name, params.Arguments)
+	if err != nil {
+		// Unknown prompts and missing arguments are the client's to fix.
+		return &jsonRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error:   &jsonRPCError{Code: -32602, Message: fmt.Sprintf("prompts/get %s: %v", params.Name, err)},
+		}
+	}
+
+	return &jsonRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result: promptsGetResult{
+			Description: prompt.Description,
+			Messages: []promptMessage{{
+				Role:    "user",
+				Content: contentBlock{Type: "text", Text: prompt.Text},
+			}},
+		},
+	}
+}
+
 func (s *Server) sendResponse(resp *jsonRPCResponse) {
 	data, err := json.Marshal(resp)
 	if err != nil {
@@ -608,83 +981,3 @@ func extractToken(path string) string {
 	}
 	return ""
 }
-
-// handleHTTP handles HTTP requests for the MCP protocol.
-// Supports session tokens in the path (/mcp/{token}) for per-subagent domain routing.
-func (s *Server) handleHTTP(w http.ResponseWriter, r *http.Request) {
-	// Only accept POST
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Extract session token and look up default domain
-	token := extractToken(r.URL.Path)
-	domain := s.DomainForToken(token)
-
-	// Read body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Parse JSON-RPC request
-	var req jsonRPCRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		log.Printf("[mcp-http] Failed to parse request: %v", err)
-		http.Error(w, "Invalid JSON-RPC request", http.StatusBadRequest)
-		return
-	}
-
-	// For tool calls on GK tools: inject domain from session token if not provided
-	if req.Method == "tools/call" && req.Params != nil {
-		var params toolsCallParams
-		if json.Unmarshal(req.Params, &params) == nil && s.gkTools[params.Name] {
-			if params.Arguments == nil {
-				params.Arguments = make(map[string]any)
-			}
-			if d, _ := params.Arguments["domain"].(string); d == "" {
-				params.Arguments["domain"] = domain
-				// Re-marshal params with injected domain
-				if newParams, err := json.Marshal(map[string]any{
-					"name":      params.Name,
-					"arguments": params.Arguments,
-				}); err == nil {
-					req.Params = newParams
-				}
-			}
-		}
-	}
-
-	// For resource methods, route directly with session domain (domain already extracted above)
-	var resp *jsonRPCResponse
-	switch req.Method {
-	case "resources/list":
-		resp = s.handleResourcesList(req, domain)
-	case "resources/read":
-		resp = s.handleResourcesRead(req, domain)
-	default:
-		resp = s.handleRequest(req)
-	}
-
-	// Set headers
-	w.Header().Set("Content-Type", "application/json")
-
-	// For notifications (no response needed), return 204
-	if resp == nil {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	// Marshal and send response
-	data, err := json.Marshal(resp)
-	if err != nil {
-		log.Printf("[mcp-http] Failed to marshal response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Write(data)
-}
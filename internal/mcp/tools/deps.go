@@ -3,8 +3,11 @@ package tools
 
 import (
 	"github.com/vthunder/bud2/internal/activity"
+	"github.com/vthunder/bud2/internal/budget"
 	"github.com/vthunder/bud2/internal/engram"
 	"github.com/vthunder/bud2/internal/eval"
+	"github.com/vthunder/bud2/internal/focus"
+	"github.com/vthunder/bud2/internal/mcp"
 	"github.com/vthunder/bud2/internal/plugins"
 	"github.com/vthunder/bud2/internal/integrations/calendar"
 	"github.com/vthunder/bud2/internal/integrations/github"
@@ -57,9 +60,25 @@ type Dependencies struct {
 	// uri is a resource URI like "gk://guides/extraction".
 	ReadResource func(domain, uri string) (string, error)
 
-	// RegisterSession registers a session token with an agent ID and default domain
-	// so the MCP HTTP handler can inject the domain into gk_* tool calls.
-	RegisterSession func(token, agentID, domain string)
+	// RegisterSession registers a session token with an agent ID, default domain
+	// and tool allow-list, so the MCP HTTP handler can inject the domain into
+	// gk_* tool calls and refuse tools the session was not granted.
+	RegisterSession func(token, agentID, domain string, tools []string)
+
+	// SetSessionAgent fills in the agent ID of a token registered with
+	// RegisterSession, once the subagent has been spawned.
+	SetSessionAgent func(token, agentID string)
+
+	// UnregisterSession revokes a session token.
+	UnregisterSession func(token string)
+
+	// SubagentMCPTools returns the bud2 MCP tools (path.Match patterns, without
+	// the mcp__bud2__ prefix) a subagent spawned with profile may use.
+	SubagentMCPTools func(profile string) []string
+
+	// ProxyHealth reports the external MCP servers bud proxies (mcp.json,
+	// plugin mcp_servers, GK processes), for state_health.
+	ProxyHealth func() []mcp.ProxyHealth
 
 	// MCPBaseURL is the base URL of the bud2 MCP HTTP server (e.g. "http://127.0.0.1:8066").
 	// Used to construct per-subagent tokenized URLs.
@@ -90,6 +109,18 @@ type Dependencies struct {
 	// ListSubagentMemories returns the content of all staged memories for a session without draining.
 	ListSubagentMemories func(sessionID string) []string
 
+	// FocusQueuePicks returns the focus queue's recent pick explanations
+	// (state_queues action=picks). Optional — injected by main.
+	FocusQueuePicks func() []focus.PickReason
+
+	// CostReport returns priced spend for today and this month, with a
+	// per-day breakdown of the last days days (state_costs). Optional.
+	CostReport func(days int) budget.CostReport
+
+	// QueryUsage aggregates archived and current token usage over a range
+	// of days (state_usage). Optional.
+	QueryUsage func(q budget.UsageQuery) ([]budget.UsageBucket, error)
+
 	// VMControlURL is the base URL for the vm-control-server REST API.
 	// Defaults to http://127.0.0.1:3099 if empty.
 	VMControlURL string
@@ -98,4 +129,12 @@ type Dependencies struct {
 	// discovery via the invoke_workflow and Skill MCP tools. Optional — when nil,
 	// those tools are not registered.
 	PluginRegistry *plugins.Registry
+
+	// PluginLifecycle runs plugin lifecycle hooks when plugins are approved,
+	// enabled, disabled or reconfigured through the plugin tools. Optional.
+	PluginLifecycle *plugins.Lifecycle
+
+	// PluginDispatcher fires plugin behaviors; plugin_next_runs lists its
+	// upcoming schedule firings. Optional.
+	PluginDispatcher *plugins.Dispatcher
 }
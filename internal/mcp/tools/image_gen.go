@@ -20,7 +20,7 @@ var imageInCapable = map[string]bool{
 }
 
 func registerImageGenTools(server *mcp.Server, deps *Dependencies) {
-	server.RegisterTool("generate_image", mcp.ToolDef{
+	server.RegisterProgressTool("generate_image", mcp.ToolDef{
 		Description: "Generate an image using Replicate's Gemini image generation models (nano-banana). Returns the path to the saved image file.",
 		Properties: map[string]mcp.PropDef{
 			"prompt":       {Type: "string", Description: "Text prompt describing the image to generate"},
@@ -29,7 +29,8 @@ func registerImageGenTools(server *mcp.Server, deps *Dependencies) {
 			"image_url":    {Type: "string", Description: "URL of an input image for editing or style transfer. Only supported by flash and pro models."},
 		},
 		Required: []string{"prompt"},
-	}, func(ctx any, args map[string]any) (string, error) {
+	}, func(ctx any, args map[string]any, progress mcp.ProgressFunc) (string, error) {
+		start := time.Now()
 		token := os.Getenv("REPLICATE_API_TOKEN")
 		if token == "" {
 			return "", fmt.Errorf("REPLICATE_API_TOKEN not set")
@@ -81,7 +82,9 @@ func registerImageGenTools(server *mcp.Server, deps *Dependencies) {
 		req.Header.Set("Prefer", "wait")
 
 		client := &http.Client{Timeout: 70 * time.Second}
+		stop := heartbeat(progress, start, "waiting for "+modelSlug)
 		resp, err := client.Do(req)
+		stop()
 		if err != nil {
 			return "", fmt.Errorf("replicate API call: %w", err)
 		}
@@ -155,6 +158,7 @@ func registerImageGenTools(server *mcp.Server, deps *Dependencies) {
 				if prediction.Status == "succeeded" || prediction.Status == "failed" {
 					break
 				}
+				progress(time.Since(start).Seconds(), 0, "prediction "+prediction.Status)
 			}
 		}
 
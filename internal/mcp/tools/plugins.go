@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// registerPluginTools registers the plugin_permissions MCP tool, which lists
+// plugins' declared permissions and whether the user has approved them,
+// plugin_manage, which enables, disables and reconfigures plugins,
+// plugin_next_runs, which lists upcoming scheduled behaviors, and
+// state_dead_letters, which inspects and replays failed behavior runs.
+// Approval itself is recorded only from the user's /plugin-approve and
+// /plugin-deny Discord commands, so no session can approve a plugin.
+func registerPluginTools(server *mcp.Server, deps *Dependencies) {
+	server.RegisterTool("plugin_permissions", mcp.ToolDef{
+		Description: "List plugins, their declared permissions and approval status. Only the user can approve or deny a plugin, with the /plugin-approve and /plugin-deny Discord commands.",
+	}, func(_ any, args map[string]any) (string, error) {
+		return listPluginPermissions(deps.PluginRegistry)
+	})
+	if deps.PluginLifecycle != nil {
+		registerPluginManage(server, deps)
+	}
+	if deps.PluginDispatcher != nil {
+		registerPluginNextRuns(server, deps)
+		if deps.PluginDispatcher.DeadLetters() != nil {
+			registerDeadLetters(server, deps)
+		}
+	}
+}
+
+// registerPluginManage registers plugin_manage. Every change goes through
+// plugins.Lifecycle so the plugin's enable, disable and settings_changed
+// hooks run; a failing enable hook vetoes the change.
+func registerPluginManage(server *mcp.Server, deps *Dependencies) {
+	server.RegisterTool("plugin_manage", mcp.ToolDef{
+		Description: "Enable or disable a plugin, or change one of its settings. The plugin's lifecycle hooks run and may refuse an enable.",
+		Properties: map[string]mcp.PropDef{
+			"action": {
+				Type:        "string",
+				Description: `"enable", "disable" or "set_setting"`,
+			},
+			"plugin": {Type: "string", Description: "Plugin name"},
+			"key":    {Type: "string", Description: "Setting key (set_setting only)"},
+			"value":  {Type: "string", Description: "New setting value (set_setting only); JSON values such as numbers and booleans are decoded"},
+		},
+		Required: []string{"action", "plugin"},
+	}, func(_ any, args map[string]any) (string, error) {
+		lc := deps.PluginLifecycle
+		action, _ := args["action"].(string)
+		name, _ := args["plugin"].(string)
+		ctx := context.Background()
+		switch action {
+		case "enable":
+			if err := lc.Enable(ctx, name); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Enabled plugin %s.", name), nil
+		case "disable":
+			if err := lc.Disable(ctx, name); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Disabled plugin %s.", name), nil
+		case "set_setting":
+			key, _ := args["key"].(string)
+			if key == "" {
+				return "", fmt.Errorf("key is required for action=set_setting")
+			}
+			value := args["value"]
+			if str, ok := value.(string); ok {
+				var decoded any
+				if json.Unmarshal([]byte(str), &decoded) == nil {
+					value = decoded
+				}
+			}
+			if err := lc.SetSetting(ctx, name, key, value); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Set %s.%s.", name, key), nil
+		default:
+			return "", fmt.Errorf("unknown action %q: must be \"enable\", \"disable\" or \"set_setting\"", action)
+		}
+	})
+}
+
+// registerPluginNextRuns registers plugin_next_runs.
+func registerPluginNextRuns(server *mcp.Server, deps *Dependencies) {
+	server.RegisterTool("plugin_next_runs", mcp.ToolDef{
+		Description: "List the upcoming firings of plugin schedule behaviors, soonest first.",
+		Properties: map[string]mcp.PropDef{
+			"plugin": {Type: "string", Description: "Only this plugin's behaviors (optional)"},
+			"count":  {Type: "number", Description: "How many firings to list (default 10, max 100)"},
+		},
+	}, func(_ any, args map[string]any) (string, error) {
+		name, _ := args["plugin"].(string)
+		count := 10
+		if n, ok := args["count"].(float64); ok && n > 0 {
+			count = min(int(n), 100)
+		}
+		runs := deps.PluginDispatcher.NextRuns(name, count)
+		if len(runs) == 0 {
+			return "No scheduled plugin behaviors.", nil
+		}
+		data, err := json.MarshalIndent(map[string]any{"runs": runs}, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("marshalling next runs: %w", err)
+		}
+		return string(data), nil
+	})
+}
+
+// registerDeadLetters registers state_dead_letters.
+func registerDeadLetters(server *mcp.Server, deps *Dependencies) {
+	server.RegisterTool("state_dead_letters", mcp.ToolDef{
+		Description: "Inspect and replay plugin behavior runs whose workflow failed. Actions: list, show, replay, replay_all, delete, clear. Replay once the cause of the failure is fixed.",
+		Properties: map[string]mcp.PropDef{
+			"action": {Type: "string", Description: "Action: list (default), show, replay, replay_all, delete, clear"},
+			"id":     {Type: "string", Description: "Dead letter ID (show, replay, delete)"},
+			"plugin": {Type: "string", Description: "Only this plugin's entries (list, replay_all, clear)"},
+		},
+	}, func(_ any, args map[string]any) (string, error) {
+		d := deps.PluginDispatcher
+		q := d.DeadLetters()
+		action, _ := args["action"].(string)
+		id, _ := args["id"].(string)
+		plugin, _ := args["plugin"].(string)
+		if id == "" && (action == "show" || action == "replay" || action == "delete") {
+			return "", fmt.Errorf("id is required for action=%s", action)
+		}
+		ctx := context.Background()
+
+		switch action {
+		case "", "list":
+			entries := q.List(plugin)
+			if len(entries) == 0 {
+				return "No failed behavior runs.", nil
+			}
+			// Vars can be large; show them only on request.
+			for i := range entries {
+				entries[i].Vars = nil
+			}
+			data, _ := json.MarshalIndent(entries, "", "  ")
+			return string(data), nil
+
+		case "show":
+			dl, ok := q.Get(id)
+			if !ok {
+				return "", fmt.Errorf("no dead letter %q", id)
+			}
+			data, _ := json.MarshalIndent(dl, "", "  ")
+			return string(data), nil
+
+		case "replay":
+			if err := d.Replay(ctx, id); err != nil {
+				return "", fmt.Errorf("replay failed, entry kept: %w", err)
+			}
+			return fmt.Sprintf("Replayed %s successfully and removed it.", id), nil
+
+		case "replay_all":
+			replayed, failed := d.ReplayAll(ctx, plugin)
+			msg := fmt.Sprintf("Replayed %d run(s) successfully.", replayed)
+			ids := make([]string, 0, len(failed))
+			for failedID := range failed {
+				ids = append(ids, failedID)
+			}
+			sort.Strings(ids)
+			for _, failedID := range ids {
+				msg += fmt.Sprintf("\n%s failed again: %v", failedID, failed[failedID])
+			}
+			return msg, nil
+
+		case "delete":
+			if err := q.Remove(id); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Deleted %s.", id), nil
+
+		case "clear":
+			n, err := q.Clear(plugin)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Deleted %d dead letter(s).", n), nil
+
+		default:
+			return "", fmt.Errorf("unknown action: %s", action)
+		}
+	})
+}
+
+// listPluginPermissions returns a JSON summary of every plugin's permissions.
+func listPluginPermissions(reg *plugins.Registry) (string, error) {
+	pending := make(map[string]bool)
+	for _, ext := range reg.PendingApproval() {
+		pending[ext.Manifest.Name] = true
+	}
+
+	type pluginEntry struct {
+		Name        string   `json:"name"`
+		Status      string   `json:"status"`
+		Permissions []string `json:"permissions"`
+	}
+	var entries []pluginEntry
+	for _, ext := range reg.All() {
+		status := "denied"
+		switch {
+		case ext.Trusted:
+			status = "trusted"
+		case reg.Approved(ext):
+			status = "approved"
+		case pending[ext.Manifest.Name]:
+			status = "pending"
+		}
+		entries = append(entries, pluginEntry{
+			Name:        ext.Manifest.Name,
+			Status:      status,
+			Permissions: ext.Manifest.Permissions.Summary(),
+		})
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"plugins": entries}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshalling plugin permissions: %w", err)
+	}
+	return string(data), nil
+}
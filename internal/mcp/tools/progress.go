@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"time"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+// heartbeatInterval is how often heartbeat reports progress.
+const heartbeatInterval = 5 * time.Second
+
+// heartbeat reports message as progress every heartbeatInterval until stop is
+// called, so a client can tell a slow blocking call from a hung one. Progress
+// is counted in seconds since start, which callers also use for their own
+// reports to keep it increasing.
+func heartbeat(progress mcp.ProgressFunc, start time.Time, message string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progress(time.Since(start).Seconds(), 0, message)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -2,8 +2,6 @@ package tools
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -14,24 +12,16 @@ import (
 	"time"
 
 	"github.com/vthunder/bud2/internal/activity"
+	"github.com/vthunder/bud2/internal/budget"
 	"github.com/vthunder/bud2/internal/integrations/calendar"
 	"github.com/vthunder/bud2/internal/integrations/github"
 	"github.com/vthunder/bud2/internal/mcp"
 	"github.com/vthunder/bud2/internal/reflex"
+	"github.com/vthunder/bud2/internal/state"
 	"github.com/vthunder/bud2/internal/types"
 	"gopkg.in/yaml.v3"
 )
 
-// generateToken creates a random 16-byte hex token for session identification.
-func generateToken() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		// Fallback: shouldn't happen, but avoid panic
-		return fmt.Sprintf("tok-%d", time.Now().UnixNano())
-	}
-	return hex.EncodeToString(b)
-}
-
 // RegisterAll registers all MCP tools with the given server and dependencies.
 func RegisterAll(server *mcp.Server, deps *Dependencies) {
 	registerCommunicationTools(server, deps)
@@ -63,6 +53,7 @@ func RegisterAll(server *mcp.Server, deps *Dependencies) {
 	registerProjectTools(server, deps)
 	if deps.PluginRegistry != nil {
 		registerWorkflowTools(server, deps)
+		registerPluginTools(server, deps)
 	}
 }
 
@@ -653,14 +644,27 @@ func registerStateTools(server *mcp.Server, deps *Dependencies) {
 
 	// state_health
 	server.RegisterTool("state_health", mcp.ToolDef{
-		Description: "Run health checks on state and get recommendations for cleanup.",
+		Description: "Run health checks on state and get recommendations for cleanup. Also reports the state of each proxied external MCP server.",
 		Properties:  map[string]mcp.PropDef{},
 	}, func(ctx any, args map[string]any) (string, error) {
 		health, err := deps.StateInspector.Health()
 		if err != nil {
 			return "", err
 		}
-		data, _ := json.MarshalIndent(health, "", "  ")
+		report := struct {
+			*state.HealthReport
+			MCPServers []mcp.ProxyHealth `json:"mcp_servers,omitempty"`
+		}{HealthReport: health}
+		if deps.ProxyHealth != nil {
+			report.MCPServers = deps.ProxyHealth()
+		}
+		for _, p := range report.MCPServers {
+			if p.State != mcp.ProxyReady {
+				health.Warnings = append(health.Warnings, fmt.Sprintf("MCP server %s is %s: %s", p.Name, p.State, p.LastError))
+				health.Status = "warnings"
+			}
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
 		return string(data), nil
 	})
 
@@ -973,9 +977,9 @@ func registerStateTools(server *mcp.Server, deps *Dependencies) {
 
 	// state_queues - manage queues
 	server.RegisterTool("state_queues", mcp.ToolDef{
-		Description: "Manage message queues (inbox, outbox, signals). Actions: list, clear.",
+		Description: "Manage message queues (inbox, outbox, signals). Actions: list, clear, picks (why the focus queue chose its recent items: effective priority, aging, deadline promotion, source fairness).",
 		Properties: map[string]mcp.PropDef{
-			"action": {Type: "string", Description: "Action: list (default), clear"},
+			"action": {Type: "string", Description: "Action: list (default), clear, picks"},
 		},
 	}, func(ctx any, args map[string]any) (string, error) {
 		action, _ := args["action"].(string)
@@ -998,10 +1002,87 @@ func registerStateTools(server *mcp.Server, deps *Dependencies) {
 			}
 			return "Cleared all queues", nil
 
+		case "picks":
+			if deps.FocusQueuePicks == nil {
+				return "", fmt.Errorf("focus queue not available")
+			}
+			data, _ := json.MarshalIndent(deps.FocusQueuePicks(), "", "  ")
+			return string(data), nil
+
 		default:
 			return "", fmt.Errorf("unknown action: %s", action)
 		}
 	})
+
+	// state_costs - priced token spend
+	server.RegisterTool("state_costs", mcp.ToolDef{
+		Description: "Show what model usage cost in USD: today and this month, broken down by role (executive, agent, reflex) and provider, against the configured daily/monthly dollar caps.",
+		Properties: map[string]mcp.PropDef{
+			"days": {Type: "number", Description: "Also include a per-day breakdown of the last N days (default 7, 0 to omit)"},
+		},
+	}, func(ctx any, args map[string]any) (string, error) {
+		if deps.CostReport == nil {
+			return "", fmt.Errorf("cost accounting not available")
+		}
+		days := 7
+		if d, ok := args["days"].(float64); ok && d >= 0 {
+			days = int(d)
+		}
+		data, _ := json.MarshalIndent(deps.CostReport(days), "", "  ")
+		return string(data), nil
+	})
+
+	// state_usage - multi-day usage history
+	server.RegisterTool("state_usage", mcp.ToolDef{
+		Description: "Chart token usage trends over past days: output tokens, cost and cache hit rate, grouped by day, role (executive, agent, reflex) or focus thread. Use to tune wake intervals or spot cache regressions.",
+		Properties: map[string]mcp.PropDef{
+			"from":     {Type: "string", Description: "First day, YYYY-MM-DD (default: 'days' before to)"},
+			"to":       {Type: "string", Description: "Last day, YYYY-MM-DD (default today)"},
+			"days":     {Type: "number", Description: "Number of days to cover when from is omitted (default 14)"},
+			"group_by": {Type: "string", Description: "Grouping: day (default), role, thread"},
+			"role":     {Type: "string", Description: "Only count this role: executive, agent, reflex"},
+			"format":   {Type: "string", Description: "Output: chart (default) or json"},
+		},
+	}, func(ctx any, args map[string]any) (string, error) {
+		if deps.QueryUsage == nil {
+			return "", fmt.Errorf("usage history not available")
+		}
+		q := budget.UsageQuery{}
+		q.GroupBy, _ = args["group_by"].(string)
+		q.Role, _ = args["role"].(string)
+		if to, _ := args["to"].(string); to != "" {
+			d, err := time.ParseInLocation("2006-01-02", to, time.Local)
+			if err != nil {
+				return "", fmt.Errorf("invalid to date %q: %w", to, err)
+			}
+			q.To = d
+		} else {
+			q.To = time.Now()
+		}
+		if from, _ := args["from"].(string); from != "" {
+			d, err := time.ParseInLocation("2006-01-02", from, time.Local)
+			if err != nil {
+				return "", fmt.Errorf("invalid from date %q: %w", from, err)
+			}
+			q.From = d
+		} else {
+			days := 14
+			if n, ok := args["days"].(float64); ok && n > 0 {
+				days = int(n)
+			}
+			q.From = q.To.AddDate(0, 0, -(days - 1))
+		}
+
+		buckets, err := deps.QueryUsage(q)
+		if err != nil {
+			return "", err
+		}
+		if format, _ := args["format"].(string); format == "json" {
+			data, _ := json.MarshalIndent(buckets, "", "  ")
+			return string(data), nil
+		}
+		return budget.RenderUsageChart(buckets), nil
+	})
 }
 
 
@@ -1543,21 +1624,30 @@ func registerSubagentTools(server *mcp.Server, deps *Dependencies) {
 		}
 
 		// Generate a session token and build a tokenized MCP URL so the subagent's
-		// gk_* calls are automatically routed to the right domain.
+		// gk_* calls are automatically routed to the right domain, and it can
+		// only reach the bud2 tools its profile grants.
 		var mcpURL, sessionToken string
+		var mcpTools []string
 		if deps.RegisterSession != nil && deps.MCPBaseURL != "" {
-			sessionToken = generateToken()
-			deps.RegisterSession(sessionToken, "", domain) // agentID filled in after spawn
+			if deps.SubagentMCPTools != nil {
+				mcpTools = deps.SubagentMCPTools(profile)
+			}
+			sessionToken = mcp.NewToken()
+			deps.RegisterSession(sessionToken, "", domain, mcpTools) // agentID set after spawn
 			mcpURL = deps.MCPBaseURL + "/mcp/" + sessionToken
 		}
 
 		sessionID, logPath, err := deps.SpawnSubagent(task, constraints, profile, workflowInstanceID, workflowStep, mcpURL)
 		if err != nil {
+			if deps.UnregisterSession != nil && sessionToken != "" {
+				deps.UnregisterSession(sessionToken)
+			}
 			return "", fmt.Errorf("failed to spawn subagent: %w", err)
 		}
-		// Update session registration with the real agent ID now that we have it.
-		if deps.RegisterSession != nil && sessionToken != "" {
-			deps.RegisterSession(sessionToken, sessionID, domain)
+		// Fill in the real agent ID now that we have it. The executive revokes
+		// the token when the subagent completes, fails or is stopped.
+		if deps.SetSessionAgent != nil && sessionToken != "" {
+			deps.SetSessionAgent(sessionToken, sessionID)
 		}
 
 		logNote := ""
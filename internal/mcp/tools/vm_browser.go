@@ -64,12 +64,12 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 
 	// ── vm_start ─────────────────────────────────────────────────────────────
 
-	server.RegisterTool("vm_start", mcp.ToolDef{
+	server.RegisterProgressTool("vm_start", mcp.ToolDef{
 		Description: "Start the VM control server (headful Chrome with Mac OS 8 emulator). Singleton — safe to call if already running. The emulator loads in the background after the server starts; use vm_screenshot to check when it's ready.",
 		Properties: map[string]mcp.PropDef{
 			"url": {Type: "string", Description: "Emulator URL (default: http://localhost:8000/newhome)"},
 		},
-	}, func(ctx any, args map[string]any) (string, error) {
+	}, func(ctx any, args map[string]any, progress mcp.ProgressFunc) (string, error) {
 		// Already running?
 		data, status, err := vmHTTP("GET", base+"/status", nil)
 		if err == nil && status == 200 {
@@ -122,9 +122,11 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 		os.WriteFile(vmControlPIDFile, []byte(strconv.Itoa(pid)), 0644)
 
 		// Wait up to 15s for HTTP to respond
-		deadline := time.Now().Add(15 * time.Second)
+		started := time.Now()
+		deadline := started.Add(15 * time.Second)
 		for time.Now().Before(deadline) {
 			time.Sleep(500 * time.Millisecond)
+			progress(time.Since(started).Seconds(), 15, "waiting for vm-control-server")
 			_, st, err := vmHTTP("GET", base+"/status", nil)
 			if err == nil && st == 200 {
 				return fmt.Sprintf("VM control server started (PID %d). Emulator loading in background — use vm_screenshot to check. Logs: %s", pid, vmControlLog), nil
@@ -263,13 +265,13 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 
 	// ── vm_actions ────────────────────────────────────────────────────────────
 
-	server.RegisterTool("vm_actions", mcp.ToolDef{
+	server.RegisterProgressTool("vm_actions", mcp.ToolDef{
 		Description: `Execute a sequence of actions in the emulator or browser. Supported actions: wait, screenshot, mouse_click, mouse_move, key_press, type, navigate, evaluate, browser_click. Returns results for each step including screenshot data URLs.`,
 		Properties: map[string]mcp.PropDef{
 			"steps": {Type: "array", Description: `Array of action objects. Examples: {"action":"wait","ms":2000}, {"action":"screenshot"}, {"action":"mouse_click","x":320,"y":240}, {"action":"type","text":"hello\n"}, {"action":"key_press","code":"Enter"}, {"action":"navigate","url":"http://..."}, {"action":"evaluate","js":"document.title"}`},
 		},
 		Required: []string{"steps"},
-	}, func(ctx any, args map[string]any) (string, error) {
+	}, func(ctx any, args map[string]any, progress mcp.ProgressFunc) (string, error) {
 		steps, ok := args["steps"].([]any)
 		if !ok {
 			return "", fmt.Errorf("steps must be an array")
@@ -289,7 +291,9 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 				timeout += 10 * time.Second
 			}
 		}
+		stop := heartbeat(progress, time.Now(), fmt.Sprintf("running %d actions", len(steps)))
 		data, status, err := vmHTTPWithTimeout("POST", base+"/actions", steps, timeout)
+		stop()
 		if err != nil {
 			return "", fmt.Errorf("vm-control-server unreachable: %w", err)
 		}
@@ -737,7 +741,7 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 
 	// ── vm_wait_for_text ──────────────────────────────────────────────────────
 
-	server.RegisterTool("vm_wait_for_text", mcp.ToolDef{
+	server.RegisterProgressTool("vm_wait_for_text", mcp.ToolDef{
 		Description: "Wait until specified text appears in the emulator (polls OCR). Use to wait for app launch, dialog boxes, network status changes, etc.",
 		Properties: map[string]mcp.PropDef{
 			"text":            {Type: "string", Description: "Text to wait for"},
@@ -746,7 +750,7 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 			"region":          {Type: "object", Description: "{x,y,width,height} to restrict OCR region"},
 		},
 		Required: []string{"text"},
-	}, func(ctx any, args map[string]any) (string, error) {
+	}, func(ctx any, args map[string]any, progress mcp.ProgressFunc) (string, error) {
 		text, ok := args["text"].(string)
 		if !ok || text == "" {
 			return "", fmt.Errorf("text is required")
@@ -764,7 +768,9 @@ func registerVMBrowserTools(server *mcp.Server, deps *Dependencies) {
 			body["region"] = region
 		}
 		httpTimeout := time.Duration(timeoutMs)*time.Millisecond + 5*time.Second
+		stop := heartbeat(progress, time.Now(), fmt.Sprintf("waiting for text %q", text))
 		data, status, err := vmHTTPWithTimeout("POST", base+"/wait-for-text", body, httpTimeout)
+		stop()
 		if err != nil {
 			return "", fmt.Errorf("vm-control-server unreachable: %w", err)
 		}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/vthunder/bud2/internal/plugins"
 	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/reflex"
 )
 
 // registerWorkflowTools registers the invoke_workflow and Skill MCP tools.
@@ -25,7 +26,7 @@ func registerWorkflowTools(server *mcp.Server, deps *Dependencies) {
 // registerInvokeWorkflow registers the invoke_workflow MCP tool.
 // It lists and invokes workflow-type capabilities from the plugin registry.
 func registerInvokeWorkflow(server *mcp.Server, deps *Dependencies) {
-	server.RegisterTool("invoke_workflow", mcp.ToolDef{
+	server.RegisterProgressTool("invoke_workflow", mcp.ToolDef{
 		Description: "List or invoke a named workflow from the plugin registry. Workflows are reusable automation sequences defined in plugins. Use action=list to discover available workflows; use action=invoke with a workflow name to run one.",
 		Properties: map[string]mcp.PropDef{
 			"action": {
@@ -42,7 +43,7 @@ func registerInvokeWorkflow(server *mcp.Server, deps *Dependencies) {
 			},
 		},
 		Required: []string{"action"},
-	}, func(_ any, args map[string]any) (string, error) {
+	}, func(_ any, args map[string]any, progress mcp.ProgressFunc) (string, error) {
 		if deps.PluginRegistry == nil {
 			return "", fmt.Errorf("plugin registry not configured")
 		}
@@ -60,7 +61,7 @@ func registerInvokeWorkflow(server *mcp.Server, deps *Dependencies) {
 			if p, ok := args["params"].(map[string]any); ok {
 				params = p
 			}
-			return invokeWorkflow(deps, name, params)
+			return invokeWorkflow(deps, name, params, progress)
 		default:
 			return "", fmt.Errorf("unknown action %q: must be \"list\" or \"invoke\"", action)
 		}
@@ -105,8 +106,9 @@ func listWorkflows(reg *plugins.Registry) (string, error) {
 }
 
 // invokeWorkflow loads a workflow capability YAML from the plugin directory and
-// executes it through the reflex engine. Returns the workflow result as a string.
-func invokeWorkflow(deps *Dependencies, name string, params map[string]any) (string, error) {
+// executes it through the reflex engine, reporting each pipeline step as
+// progress. Returns the workflow result as a string.
+func invokeWorkflow(deps *Dependencies, name string, params map[string]any, progress mcp.ProgressFunc) (string, error) {
 	cap, ext, ok := deps.PluginRegistry.GetCapabilityByFullName(name)
 	if !ok {
 		return "", fmt.Errorf("workflow %q not found in plugin registry", name)
@@ -115,6 +117,10 @@ func invokeWorkflow(deps *Dependencies, name string, params map[string]any) (str
 		return "", fmt.Errorf("capability %q has type %q, not \"workflow\"", name, cap.Type)
 	}
 
+	if err := deps.PluginRegistry.Authorize(ext.Manifest.Name, plugins.PermissionExecute, name); err != nil {
+		return "", err
+	}
+
 	// Derive the capability name from the full name (extName:capName).
 	idx := strings.LastIndex(name, ":")
 	capName := name[idx+1:]
@@ -142,6 +148,7 @@ func invokeWorkflow(deps *Dependencies, name string, params map[string]any) (str
 				name, loadErr, string(paramsJSON),
 			), nil
 		}
+		loaded.Plugin = ext.Manifest.Name
 		r = loaded
 	}
 
@@ -151,7 +158,10 @@ func invokeWorkflow(deps *Dependencies, name string, params map[string]any) (str
 		extracted[k] = fmt.Sprintf("%v", v)
 	}
 
-	result, err := deps.ReflexEngine.Execute(context.Background(), r, extracted, params)
+	ctx := reflex.WithProgress(context.Background(), func(step, total int, label string) {
+		progress(float64(step), float64(total), fmt.Sprintf("step %d/%d: %s", step+1, total, label))
+	})
+	result, err := deps.ReflexEngine.Execute(ctx, r, extracted, params)
 	if err != nil {
 		return "", fmt.Errorf("workflow %q execution failed: %w", name, err)
 	}
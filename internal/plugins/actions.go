@@ -11,24 +11,28 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/sandbox"
 )
 
 // ActionProxy exposes plugin-declared shell actions to the MCP server and to
 // workflow type:direct steps. It implements mcp.ToolCaller so it can be set as
 // the reflex engine's action caller.
 type ActionProxy struct {
-	mu      sync.RWMutex
-	actions map[string]*actionEntry // "<ext>:<cap>" → entry
+	mu       sync.RWMutex
+	actions  map[string]*actionEntry // "<ext>:<cap>" → entry
+	sandbox  *sandbox.Runner
+	registry *Registry
+	server   *mcp.Server // set by RegisterMCPTools; Refresh re-registers on it
 }
 
 // actionEntry holds a shell action's plugin context and capability definition.
@@ -42,19 +46,38 @@ type actionEntry struct {
 // are indexed. Call RegisterMCPTools to wire callable_from:both|model actions
 // onto an MCP server.
 func NewActionProxy(registry *Registry) *ActionProxy {
-	p := &ActionProxy{
-		actions: make(map[string]*actionEntry),
+	return &ActionProxy{
+		actions:  indexActions(registry),
+		sandbox:  sandbox.NewRunner(sandbox.Policy{}, ""),
+		registry: registry,
 	}
+}
+
+// indexActions returns the registry's shell actions keyed by "<ext>:<cap>".
+func indexActions(registry *Registry) map[string]*actionEntry {
+	actions := make(map[string]*actionEntry)
 	for _, ext := range registry.All() {
 		for capName, cap := range ext.Capabilities {
 			if cap.Type != "action" || cap.Run == "" {
 				continue
 			}
 			toolName := ext.Manifest.Name + ":" + capName
-			p.actions[toolName] = &actionEntry{ext: ext, cap: cap}
+			actions[toolName] = &actionEntry{ext: ext, cap: cap}
 		}
 	}
-	return p
+	return actions
+}
+
+// modelCallable reports whether an action is exposed as an MCP tool.
+func modelCallable(cap *Capability) bool {
+	return cap.CallableFrom == "both" || cap.CallableFrom == "model"
+}
+
+// SetSandbox replaces the runner that executes action scripts.
+func (p *ActionProxy) SetSandbox(r *sandbox.Runner) {
+	p.mu.Lock()
+	p.sandbox = r
+	p.mu.Unlock()
 }
 
 // RegisterMCPTools registers shell actions with callable_from=both|model as MCP
@@ -64,24 +87,51 @@ func NewActionProxy(registry *Registry) *ActionProxy {
 // Actions with callable_from=direct are silently skipped — they are reachable only
 // from workflow type:direct steps via the Call method.
 func (p *ActionProxy) RegisterMCPTools(server *mcp.Server) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	for toolName, entry := range p.actions {
-		switch entry.cap.CallableFrom {
-		case "both", "model":
-			def := buildActionToolDef(entry.cap)
-			// Capture loop variables for the closure.
-			tName := toolName
-			ent := entry
-			server.RegisterTool(tName, def, func(_ any, args map[string]any) (string, error) {
-				return p.runShell(ent, args)
-			})
-			log.Printf("[actions] registered MCP tool %q → %s", tName, ent.cap.Run)
-		default:
-			// callable_from: direct — skip MCP registration.
+	p.mu.Lock()
+	p.server = server
+	actions := p.actions
+	p.mu.Unlock()
+
+	for toolName, entry := range actions {
+		if !modelCallable(entry.cap) {
+			continue // callable_from: direct — skip MCP registration.
+		}
+		def := buildActionToolDef(entry.cap)
+		// Capture loop variables for the closure.
+		tName := toolName
+		ent := entry
+		server.RegisterTool(tName, def, func(_ any, args map[string]any) (string, error) {
+			return p.runShell(ent, args)
+		})
+		log.Printf("[actions] registered MCP tool %q → %s", tName, ent.cap.Run)
+	}
+}
+
+// Refresh re-indexes actions after the registry is reloaded. If
+// RegisterMCPTools was called, tools for actions that are gone or no longer
+// model-callable are unregistered and the rest re-registered, so they pick up
+// new definitions.
+func (p *ActionProxy) Refresh() {
+	actions := indexActions(p.registry)
+	p.mu.Lock()
+	old := p.actions
+	p.actions = actions
+	server := p.server
+	p.mu.Unlock()
+	if server == nil {
+		return
+	}
+
+	for toolName, entry := range old {
+		if !modelCallable(entry.cap) {
+			continue
+		}
+		if next, ok := actions[toolName]; !ok || !modelCallable(next.cap) {
+			server.UnregisterTool(toolName)
+			log.Printf("[actions] unregistered MCP tool %q", toolName)
 		}
 	}
+	p.RegisterMCPTools(server)
 }
 
 // Call invokes a shell action by its fully-qualified "<ext>:<cap>" name.
@@ -105,10 +155,17 @@ func (p *ActionProxy) HasAction(toolName string) bool {
 	return ok
 }
 
-// runShell executes the action's shell script.
-// params are serialized as JSON and written to the script's stdin.
-// The script's stdout (trimmed) is returned as the result.
+// runShell executes the action's shell script in the sandbox, confined to
+// the plugin directory. params are serialized as JSON and written to the
+// script's stdin. The script's stdout (trimmed) is returned as the result.
+// The plugin's permissions must have been approved, and scripts of plugins
+// that declare no network hosts run without network access when the sandbox
+// isolates.
 func (p *ActionProxy) runShell(entry *actionEntry, params map[string]any) (string, error) {
+	extName := entry.ext.Manifest.Name
+	if err := p.registry.AuthorizePlugin(entry.ext, PermissionExecute, extName+":"+entry.cap.Name); err != nil {
+		return "", err
+	}
 	if params == nil {
 		params = map[string]any{}
 	}
@@ -124,6 +181,9 @@ func (p *ActionProxy) runShell(entry *actionEntry, params map[string]any) (strin
 
 	// Resolve the script path relative to the plugin directory.
 	scriptPath := filepath.Join(entry.ext.Dir, entry.cap.Run)
+	if !sandbox.Within(entry.ext.Dir, scriptPath) {
+		return "", fmt.Errorf("action %s: script %s is outside the plugin directory", entry.cap.Name, entry.cap.Run)
+	}
 	if _, err := os.Stat(scriptPath); err != nil {
 		return "", fmt.Errorf("action %s: script not found at %s: %w", entry.cap.Name, scriptPath, err)
 	}
@@ -134,20 +194,35 @@ func (p *ActionProxy) runShell(entry *actionEntry, params map[string]any) (strin
 		return "", fmt.Errorf("action %s: marshaling params: %w", entry.cap.Name, err)
 	}
 
-	cmd := exec.Command(scriptPath) // #nosec G204 — path is from trusted plugin manifest
-	cmd.Stdin = bytes.NewReader(input)
-	out, err := cmd.Output()
+	dataDir, err := entry.ext.DataDir()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr := strings.TrimSpace(string(exitErr.Stderr))
-			if stderr != "" {
-				return "", fmt.Errorf("action %s exited %d: %s", entry.cap.Name, exitErr.ExitCode(), stderr)
-			}
-			return "", fmt.Errorf("action %s exited with code %d", entry.cap.Name, exitErr.ExitCode())
-		}
 		return "", fmt.Errorf("action %s: %w", entry.cap.Name, err)
 	}
-	return strings.TrimSpace(string(out)), nil
+
+	p.mu.RLock()
+	runner := p.sandbox
+	p.mu.RUnlock()
+	res, err := runner.Run(context.Background(), sandbox.Cmd{
+		Path:      scriptPath,
+		Stdin:     bytes.NewReader(input),
+		Dir:       dataDir,
+		Env:       []string{"BUD_PLUGIN_DIR=" + entry.ext.Dir},
+		NoNetwork: !entry.ext.Trusted && len(entry.ext.Manifest.Permissions.Network) == 0,
+	})
+	if err != nil {
+		if res == nil || res.TimedOut {
+			return "", fmt.Errorf("action %s: %w", entry.cap.Name, err)
+		}
+		stderr := strings.TrimSpace(string(res.Stderr))
+		if stderr != "" {
+			return "", fmt.Errorf("action %s exited %d: %s", entry.cap.Name, res.ExitCode, stderr)
+		}
+		return "", fmt.Errorf("action %s exited with code %d", entry.cap.Name, res.ExitCode)
+	}
+	if res.Truncated {
+		log.Printf("[actions] %s: output truncated at %d bytes", entry.cap.Name, runner.Policy().MaxOutputBytes)
+	}
+	return strings.TrimSpace(string(res.Stdout)), nil
 }
 
 // buildActionToolDef converts a Capability's param schema to an mcp.ToolDef.
@@ -7,9 +7,11 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vthunder/bud2/internal/plugins"
 	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/sandbox"
 )
 
 // --- test helpers specific to action tests ---
@@ -346,6 +348,70 @@ func TestActionProxy_Call_UnknownAction(t *testing.T) {
 	}
 }
 
+// --- TestActionProxy_Call_Sandboxed ---
+
+// TestActionProxy_Call_Sandboxed verifies that scripts run with a scrubbed
+// environment, inside the plugin directory, and under the sandbox timeout.
+func TestActionProxy_Call_Sandboxed(t *testing.T) {
+	t.Setenv("BUD_TEST_SECRET", "hunter2")
+	dir := makeTestPluginDir(t, "test-ext")
+	envScript := writeScript(t, dir, "env.sh", "#!/bin/sh\necho \"secret=$BUD_TEST_SECRET\"\n")
+	slowScript := writeScript(t, dir, "slow.sh", "#!/bin/sh\nsleep 10\n")
+	for name, run := range map[string]string{"env": envScript, "slow": slowScript, "escape": "../outside.sh"} {
+		capYAML := fmt.Sprintf("name: %s\ndescription: test\ntype: action\ncallable_from: direct\nrun: %s\n", name, run)
+		writeCapabilityYAMLRaw(t, dir, name, []byte(capYAML))
+	}
+
+	proxy := plugins.NewActionProxy(makeSystemRegistry(t, dir))
+	proxy.SetSandbox(sandbox.NewRunner(sandbox.Policy{Timeout: 200 * time.Millisecond}, ""))
+
+	result, err := proxy.Call("test-ext:env", nil)
+	if err != nil {
+		t.Fatalf("Call env: %v", err)
+	}
+	if result != "secret=" {
+		t.Errorf("expected scrubbed environment, got %q", result)
+	}
+
+	if _, err := proxy.Call("test-ext:slow", nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	if _, err := proxy.Call("test-ext:escape", nil); err == nil || !strings.Contains(err.Error(), "outside the plugin directory") {
+		t.Errorf("expected confinement error, got %v", err)
+	}
+}
+
+func TestActionProxy_Call_RunsInDataDir(t *testing.T) {
+	dir := makeTestPluginDir(t, "test-ext")
+	run := writeScript(t, dir, "write.sh", "#!/bin/sh\necho cached > cache.txt\nprintf '%s|%s|%s' \"$(pwd)\" \"$HOME\" \"$BUD_PLUGIN_DIR\"\n")
+	writeCapabilityYAMLRaw(t, dir, "write", []byte("name: write\ndescription: test\ntype: action\ncallable_from: direct\nrun: "+run+"\n"))
+
+	reg := makeSystemRegistry(t, dir)
+	ext := reg.Get("test-ext")
+	before, err := plugins.ContentHash(ext.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := plugins.NewActionProxy(reg)
+	proxy.SetSandbox(sandbox.NewRunner(sandbox.Policy{}, ""))
+
+	result, err := proxy.Call("test-ext:write", nil)
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	dataDir := filepath.Join(ext.Dir, ".bud-plugin", "data")
+	if want := dataDir + "|" + dataDir + "|" + ext.Dir; result != want {
+		t.Errorf("cwd|HOME|BUD_PLUGIN_DIR = %q, want %q", result, want)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "cache.txt")); err != nil {
+		t.Errorf("expected the script's file in the data dir: %v", err)
+	}
+	if after, _ := plugins.ContentHash(ext.Dir); after != before {
+		t.Error("files written by the script changed the plugin's content hash")
+	}
+}
+
 // --- TestActionProxy_DirectCallableFromDirect ---
 
 // TestActionProxy_DirectCallableFromDirect verifies that a callable_from:direct action
@@ -0,0 +1,271 @@
+package plugins
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxDeadLetters caps the store; the oldest entries are dropped first.
+const maxDeadLetters = 500
+
+// DeadLetter is a behavior run whose workflow failed, kept so it can be
+// inspected and replayed once the cause is fixed.
+type DeadLetter struct {
+	ID       string         `json:"id"`
+	Plugin   string         `json:"plugin"`
+	Behavior string         `json:"behavior"`
+	Trigger  string         `json:"trigger"` // trigger type, e.g. "schedule"
+	Workflow string         `json:"workflow"`
+	Params   map[string]any `json:"params,omitempty"`
+	Step     string         `json:"step,omitempty"` // failing step, if the workflow reported it
+	Error    string         `json:"error"`
+	Vars     map[string]any `json:"vars,omitempty"` // workflow variables when it failed
+	FailedAt time.Time      `json:"failed_at"`
+	Attempts int            `json:"attempts"`
+}
+
+// stepFailure is implemented by workflow errors that know where the
+// workflow stopped (reflex.StepError).
+type stepFailure interface {
+	FailedStep() string
+	FailedVars() map[string]any
+}
+
+// DeadLetters persists failed behavior runs, oldest first.
+type DeadLetters struct {
+	path    string
+	mu      sync.Mutex
+	entries []*DeadLetter
+}
+
+// LoadDeadLetters reads the dead-letter file at path. A missing file yields
+// an empty store; so does an unreadable one, returned along with the error.
+func LoadDeadLetters(path string) (*DeadLetters, error) {
+	q := &DeadLetters{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return q, err
+	}
+	if err := json.Unmarshal(data, &q.entries); err != nil {
+		q.entries = nil
+		return q, fmt.Errorf("plugins: parsing %s: %w", path, err)
+	}
+	return q, nil
+}
+
+// Add records a failed run, assigning its ID.
+func (q *DeadLetters) Add(dl *DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dl.ID = newDeadLetterID()
+	q.entries = append(q.entries, dl)
+	if len(q.entries) > maxDeadLetters {
+		q.entries = q.entries[len(q.entries)-maxDeadLetters:]
+	}
+	return q.save()
+}
+
+// List returns copies of the entries for plugin (all if ""), oldest first.
+func (q *DeadLetters) List(plugin string) []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []DeadLetter
+	for _, dl := range q.entries {
+		if plugin == "" || dl.Plugin == plugin {
+			out = append(out, *dl)
+		}
+	}
+	return out
+}
+
+// Get returns a copy of the entry with the given ID.
+func (q *DeadLetters) Get(id string) (DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.indexLocked(id); i >= 0 {
+		return *q.entries[i], true
+	}
+	return DeadLetter{}, false
+}
+
+// Remove deletes the entry with the given ID.
+func (q *DeadLetters) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.indexLocked(id)
+	if i < 0 {
+		return fmt.Errorf("no dead letter %q", id)
+	}
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	return q.save()
+}
+
+// Clear deletes every entry for plugin (all if "") and returns how many.
+func (q *DeadLetters) Clear(plugin string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.entries[:0]
+	for _, dl := range q.entries {
+		if plugin != "" && dl.Plugin != plugin {
+			kept = append(kept, dl)
+		}
+	}
+	n := len(q.entries) - len(kept)
+	q.entries = kept
+	return n, q.save()
+}
+
+// retried records another failed attempt at replaying an entry.
+func (q *DeadLetters) retried(id string, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.indexLocked(id)
+	if i < 0 {
+		return nil
+	}
+	dl := q.entries[i]
+	dl.Attempts++
+	dl.FailedAt = time.Now()
+	dl.setError(err)
+	return q.save()
+}
+
+func (q *DeadLetters) indexLocked(id string) int {
+	for i, dl := range q.entries {
+		if dl.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the store; callers hold q.mu.
+func (q *DeadLetters) save() error {
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return err
+	}
+	return writeJSONFile(q.path, q.entries)
+}
+
+// setError fills in Error, and Step and Vars if err reports them.
+func (dl *DeadLetter) setError(err error) {
+	dl.Error = err.Error()
+	dl.Step, dl.Vars = "", nil
+	var sf stepFailure
+	if errors.As(err, &sf) {
+		dl.Step = sf.FailedStep()
+		dl.Vars = jsonSafe(sf.FailedVars())
+	}
+}
+
+// jsonSafe replaces values that cannot be encoded as JSON with their %v text,
+// so one odd variable does not prevent the failure from being recorded.
+func jsonSafe(vars map[string]any) map[string]any {
+	out := make(map[string]any, len(vars))
+	for k, v := range vars {
+		if _, err := json.Marshal(v); err != nil {
+			out[k] = fmt.Sprintf("%v", v)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func newDeadLetterID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// SetDeadLetters wires the store failed behavior runs are recorded in.
+func (d *Dispatcher) SetDeadLetters(q *DeadLetters) { d.deadLetters = q }
+
+// DeadLetters returns the dead-letter store, or nil if none is wired.
+func (d *Dispatcher) DeadLetters() *DeadLetters { return d.deadLetters }
+
+// recordFailure adds a failed behavior run to the dead-letter store and
+// notes it in the SaveThought log, if one is wired.
+func (d *Dispatcher) recordFailure(ext *Plugin, behName, workflow string, params map[string]any, err error) {
+	if d.deadLetters == nil {
+		return
+	}
+	dl := &DeadLetter{
+		Plugin:   ext.Manifest.Name,
+		Behavior: behName,
+		Workflow: workflow,
+		Params:   jsonSafe(params),
+		FailedAt: time.Now(),
+		Attempts: 1,
+	}
+	for _, beh := range ext.Manifest.Behaviors {
+		if beh.Name == behName {
+			dl.Trigger, _ = parseTriggerType(beh.Trigger)
+			break
+		}
+	}
+	dl.setError(err)
+	if err := d.deadLetters.Add(dl); err != nil {
+		log.Printf("[triggers] %s/%s: failed to record dead letter: %v", dl.Plugin, behName, err)
+		return
+	}
+	if d.logger != nil {
+		d.logger.Log(fmt.Sprintf("%s/%s: workflow %s failed (dead letter %s): %s", dl.Plugin, behName, workflow, dl.ID, dl.Error))
+	}
+}
+
+// Replay reruns a dead-lettered behavior run with its original params. On
+// success the entry is removed; on failure it stays with the new error.
+func (d *Dispatcher) Replay(ctx context.Context, id string) error {
+	if d.deadLetters == nil {
+		return fmt.Errorf("no dead-letter store configured")
+	}
+	dl, ok := d.deadLetters.Get(id)
+	if !ok {
+		return fmt.Errorf("no dead letter %q", id)
+	}
+	ext := d.registry.Get(dl.Plugin)
+	if ext == nil {
+		return fmt.Errorf("plugin %s is not loaded", dl.Plugin)
+	}
+	if err := d.registry.AuthorizePlugin(ext, PermissionExecute, dl.Workflow); err != nil {
+		return err
+	}
+	if _, err := d.runner.RunWorkflow(WithPlugin(ctx, dl.Plugin), dl.Workflow, dl.Params); err != nil {
+		if rerr := d.deadLetters.retried(id, err); rerr != nil {
+			log.Printf("[triggers] failed to update dead letter %s: %v", id, rerr)
+		}
+		return err
+	}
+	return d.deadLetters.Remove(id)
+}
+
+// ReplayAll replays every entry for plugin (all if ""), oldest first, and
+// returns how many succeeded and the errors of those that failed again,
+// keyed by ID.
+func (d *Dispatcher) ReplayAll(ctx context.Context, plugin string) (int, map[string]error) {
+	if d.deadLetters == nil {
+		return 0, nil
+	}
+	replayed, failed := 0, make(map[string]error)
+	for _, dl := range d.deadLetters.List(plugin) {
+		if err := d.Replay(ctx, dl.ID); err != nil {
+			failed[dl.ID] = err
+			continue
+		}
+		replayed++
+	}
+	return replayed, failed
+}
@@ -0,0 +1,105 @@
+package plugins_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// stepErr mimics reflex.StepError.
+type stepErr struct{ vars map[string]any }
+
+func (e *stepErr) Error() string              { return "step 1 (direct:fetch) failed: connection refused" }
+func (e *stepErr) FailedStep() string         { return "1 (direct:fetch)" }
+func (e *stepErr) FailedVars() map[string]any { return e.vars }
+
+// brokenRunner fails every workflow until fixed.
+type brokenRunner struct {
+	mu    sync.Mutex
+	fixed bool
+	runs  int
+}
+
+func (r *brokenRunner) RunWorkflow(_ context.Context, _ string, params map[string]any) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs++
+	if !r.fixed {
+		return nil, &stepErr{vars: map[string]any{"day": params["day"], "fn": func() {}}}
+	}
+	return "ok", nil
+}
+
+func TestDispatcher_DeadLetterAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.json")
+	q, err := plugins.LoadDeadLetters(path)
+	if err != nil {
+		t.Fatalf("LoadDeadLetters: %v", err)
+	}
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "test-ext", map[string]any{
+		"behaviors": []any{map[string]any{
+			"name":     "nightly",
+			"trigger":  map[string]any{"slash_command": "nightly"},
+			"workflow": "nightly-report",
+		}},
+	})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	runner := &brokenRunner{}
+	d := plugins.NewDispatcher(reg, plugins.NewEventBus(), runner)
+	d.SetDeadLetters(q)
+	var logged []string
+	d.SetSaveThought(&fakeLogger{fn: func(msg string) error { logged = append(logged, msg); return nil }})
+
+	d.RegisterAll(context.Background())
+	d.FireSlashCommand("nightly", map[string]any{"day": "mon"})
+	d.FireSlashCommand("nightly", map[string]any{"day": "tue"})
+
+	entries := q.List("")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 dead letters, got %d", len(entries))
+	}
+	dl := entries[0]
+	if dl.Plugin != "test-ext" || dl.Behavior != "nightly" || dl.Trigger != "slash_command" || dl.Workflow != "nightly-report" {
+		t.Errorf("unexpected dead letter %+v", dl)
+	}
+	if dl.Step != "1 (direct:fetch)" || dl.Vars["day"] != "mon" || dl.Params["day"] != "mon" {
+		t.Errorf("expected step, vars and params recorded, got %+v", dl)
+	}
+	if len(logged) != 2 {
+		t.Errorf("expected failures logged, got %v", logged)
+	}
+
+	// Entries survive a restart.
+	reloaded, err := plugins.LoadDeadLetters(path)
+	if err != nil || len(reloaded.List("test-ext")) != 2 {
+		t.Fatalf("reload: %v, %d entries", err, len(reloaded.List("")))
+	}
+
+	// Replaying before the fix keeps the entry and counts the attempt.
+	var sf interface{ FailedStep() string }
+	if err := d.Replay(context.Background(), dl.ID); !errors.As(err, &sf) {
+		t.Fatalf("expected replay to fail with the step error, got %v", err)
+	}
+	if got, _ := q.Get(dl.ID); got.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", got.Attempts)
+	}
+
+	runner.mu.Lock()
+	runner.fixed = true
+	runner.mu.Unlock()
+	replayed, failed := d.ReplayAll(context.Background(), "test-ext")
+	if replayed != 2 || len(failed) != 0 {
+		t.Errorf("ReplayAll = %d, %v", replayed, failed)
+	}
+	if n := len(q.List("")); n != 0 {
+		t.Errorf("expected store empty after replay, got %d", n)
+	}
+}
@@ -0,0 +1,389 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// Lifecycle events a plugin can hook in the lifecycle: block of plugin.yaml.
+const (
+	LifecycleInstall         = "install"          // first time the plugin is seen
+	LifecycleUpgrade         = "upgrade"          // version changed since last run; failure vetoes
+	LifecycleEnable          = "enable"           // plugin being enabled; failure vetoes
+	LifecycleDisable         = "disable"          // plugin being disabled
+	LifecycleSettingsChanged = "settings_changed" // a setting was changed at runtime
+	LifecycleDaemonStart     = "daemon_start"     // bud started, after install/upgrade
+	LifecycleDaemonStop      = "daemon_stop"      // bud shutting down
+)
+
+var lifecycleEvents = map[string]bool{
+	LifecycleInstall: true, LifecycleUpgrade: true, LifecycleEnable: true, LifecycleDisable: true,
+	LifecycleSettingsChanged: true, LifecycleDaemonStart: true, LifecycleDaemonStop: true,
+}
+
+// DefaultHookTimeout bounds a lifecycle hook that sets no timeout of its own.
+const DefaultHookTimeout = 30 * time.Second
+
+// LifecycleHook is what runs for one lifecycle event: a script in the plugin
+// directory or one of the plugin's workflows. In plugin.yaml it is either a
+// map or a bare string — a script path if it contains "/" or ".", otherwise a
+// workflow name:
+//
+//	lifecycle:
+//	  install: scripts/setup.sh
+//	  enable: check-credentials
+//	  upgrade: {run: scripts/migrate.sh, timeout: 2m}
+type LifecycleHook struct {
+	Run      string `yaml:"run,omitempty"`
+	Workflow string `yaml:"workflow,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+}
+
+// UnmarshalYAML accepts the bare-string shorthand as well as the map form.
+func (h *LifecycleHook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		if strings.ContainsAny(node.Value, "/.") {
+			h.Run = node.Value
+		} else {
+			h.Workflow = node.Value
+		}
+		return nil
+	}
+	type plain LifecycleHook
+	return node.Decode((*plain)(h))
+}
+
+// ParsedTimeout returns the hook timeout, DefaultHookTimeout if unset or invalid.
+func (h LifecycleHook) ParsedTimeout() time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHookTimeout
+}
+
+// validateLifecycle warns about unknown events and empty hooks.
+func validateLifecycle(m Manifest) {
+	for event, hook := range m.Lifecycle {
+		if !lifecycleEvents[event] {
+			log.Printf("plugins: %s: unknown lifecycle event %q (ignored)", m.Name, event)
+		}
+		if hook.Run == "" && hook.Workflow == "" {
+			log.Printf("plugins: %s: lifecycle %s: needs run: or workflow:", m.Name, event)
+		}
+		if hook.Timeout != "" {
+			if _, err := time.ParseDuration(hook.Timeout); err != nil {
+				log.Printf("plugins: %s: lifecycle %s: invalid timeout %q, using %v", m.Name, event, hook.Timeout, DefaultHookTimeout)
+			}
+		}
+	}
+}
+
+// installRecord is what Lifecycle remembers about an installed plugin.
+type installRecord struct {
+	Version     string    `json:"version"`
+	InstalledAt time.Time `json:"installed_at"`
+	UpgradedAt  time.Time `json:"upgraded_at,omitempty"`
+}
+
+// Lifecycle fires plugin lifecycle hooks. It detects installs and upgrades by
+// comparing loaded plugins against a record of what was installed before, and
+// owns enabling, disabling and runtime settings changes so the matching hooks
+// always run.
+type Lifecycle struct {
+	registry   *Registry
+	runner     WorkflowRunner
+	sandbox    *sandbox.Runner
+	dispatcher *Dispatcher
+
+	mu       sync.Mutex
+	path     string
+	installs map[string]installRecord
+}
+
+// NewLifecycle creates a Lifecycle. Install records are kept in the JSON file
+// at installsPath; runner executes workflow hooks.
+func NewLifecycle(registry *Registry, runner WorkflowRunner, installsPath string) *Lifecycle {
+	l := &Lifecycle{
+		registry: registry,
+		runner:   runner,
+		sandbox:  sandbox.NewRunner(sandbox.Policy{}, ""),
+		path:     installsPath,
+		installs: make(map[string]installRecord),
+	}
+	if data, err := os.ReadFile(installsPath); err == nil {
+		if err := json.Unmarshal(data, &l.installs); err != nil {
+			log.Printf("[lifecycle] parsing %s: %v; treating all plugins as new", installsPath, err)
+			l.installs = make(map[string]installRecord)
+		}
+	}
+	return l
+}
+
+// SetSandbox sets the runner for script hooks.
+func (l *Lifecycle) SetSandbox(r *sandbox.Runner) { l.sandbox = r }
+
+// SetDispatcher lets Enable and Disable register and unregister behaviors.
+func (l *Lifecycle) SetDispatcher(d *Dispatcher) { l.dispatcher = d }
+
+// Start runs install and upgrade hooks for new and changed plugins, then
+// daemon_start for every enabled plugin. A failed upgrade disables the plugin
+// until it is re-enabled, so call Start before registering behaviors.
+func (l *Lifecycle) Start(ctx context.Context) {
+	for _, ext := range l.registry.All() {
+		l.Sync(ctx, ext)
+	}
+	for _, ext := range l.registry.All() {
+		if ext.Enabled() && l.installed(ext) {
+			l.fireLogged(ctx, ext, LifecycleDaemonStart, nil)
+		}
+	}
+}
+
+// Stop runs daemon_stop for every enabled plugin.
+func (l *Lifecycle) Stop(ctx context.Context) {
+	for _, ext := range l.registry.All() {
+		if ext.Enabled() && l.installed(ext) {
+			l.fireLogged(ctx, ext, LifecycleDaemonStop, nil)
+		}
+	}
+}
+
+// Sync runs the install or upgrade hook if ext is new or its version changed.
+// Plugins awaiting permission approval are skipped and synced once approved.
+// A failed install is retried on the next Sync; a failed upgrade disables
+// the plugin.
+func (l *Lifecycle) Sync(ctx context.Context, ext *Plugin) {
+	if !l.registry.Approved(ext) {
+		return
+	}
+	name := ext.Manifest.Name
+	l.mu.Lock()
+	rec, ok := l.installs[name]
+	l.mu.Unlock()
+
+	switch {
+	case !ok:
+		if err := l.Fire(ctx, ext, LifecycleInstall, nil); err != nil {
+			log.Printf("[lifecycle] %s: install hook failed, will retry: %v", name, err)
+			return
+		}
+		l.record(name, installRecord{Version: ext.Manifest.Version, InstalledAt: time.Now()})
+		log.Printf("[lifecycle] %s: installed (version %q)", name, ext.Manifest.Version)
+	case rec.Version != ext.Manifest.Version:
+		if err := l.upgrade(ctx, ext, rec); err != nil {
+			log.Printf("[lifecycle] %s: %v; disabling plugin", name, err)
+			if err := ext.StateSet("_enabled", false); err != nil {
+				log.Printf("[lifecycle] %s: disabling: %v", name, err)
+			}
+		}
+	}
+}
+
+// upgrade runs the upgrade hook and, on success, records the new version.
+func (l *Lifecycle) upgrade(ctx context.Context, ext *Plugin, rec installRecord) error {
+	payload := map[string]any{"previous_version": rec.Version}
+	if err := l.Fire(ctx, ext, LifecycleUpgrade, payload); err != nil {
+		return fmt.Errorf("upgrade %q → %q vetoed: %w", rec.Version, ext.Manifest.Version, err)
+	}
+	rec.Version = ext.Manifest.Version
+	rec.UpgradedAt = time.Now()
+	l.record(ext.Manifest.Name, rec)
+	log.Printf("[lifecycle] %s: upgraded to %q", ext.Manifest.Name, ext.Manifest.Version)
+	return nil
+}
+
+// Enable runs any pending install or upgrade and the enable hook, and only if
+// they succeed marks the plugin enabled and registers its behaviors.
+func (l *Lifecycle) Enable(ctx context.Context, name string) error {
+	ext := l.registry.Get(name)
+	if ext == nil {
+		return fmt.Errorf("plugins: unknown plugin %q", name)
+	}
+	l.mu.Lock()
+	rec, ok := l.installs[name]
+	l.mu.Unlock()
+	switch {
+	case !ok:
+		if l.Sync(ctx, ext); !l.installed(ext) {
+			return fmt.Errorf("plugin %s is not installed (unapproved or install hook failed)", name)
+		}
+	case rec.Version != ext.Manifest.Version:
+		if err := l.upgrade(ctx, ext, rec); err != nil {
+			return err
+		}
+	}
+	if err := l.Fire(ctx, ext, LifecycleEnable, nil); err != nil {
+		return fmt.Errorf("enable vetoed: %w", err)
+	}
+	if err := ext.StateSet("_enabled", true); err != nil {
+		return err
+	}
+	if l.dispatcher != nil {
+		return l.dispatcher.RegisterPlugin(ctx, ext)
+	}
+	return nil
+}
+
+// Disable unregisters the plugin's behaviors, marks it disabled and runs the
+// disable hook. A failing disable hook is logged but does not keep the plugin
+// enabled.
+func (l *Lifecycle) Disable(ctx context.Context, name string) error {
+	ext := l.registry.Get(name)
+	if ext == nil {
+		return fmt.Errorf("plugins: unknown plugin %q", name)
+	}
+	if l.dispatcher != nil {
+		l.dispatcher.UnregisterPlugin(name)
+	}
+	if err := ext.StateSet("_enabled", false); err != nil {
+		return err
+	}
+	l.fireLogged(ctx, ext, LifecycleDisable, nil)
+	return nil
+}
+
+// SetSetting changes one of the plugin's settings and runs settings_changed
+// with the key and the old and new values.
+func (l *Lifecycle) SetSetting(ctx context.Context, name, key string, value any) error {
+	ext := l.registry.Get(name)
+	if ext == nil {
+		return fmt.Errorf("plugins: unknown plugin %q", name)
+	}
+	old := ext.SettingsGet(key)
+	if err := ext.SettingsSet(key, value); err != nil {
+		return err
+	}
+	l.fireLogged(ctx, ext, LifecycleSettingsChanged, map[string]any{"key": key, "old": old, "new": value})
+	return nil
+}
+
+// Fire runs ext's hook for event, if it declares one. The hook receives a JSON
+// payload with the event, plugin name, version, directory and current
+// settings, plus extra. A script hook gets it on stdin and fails by exiting
+// non-zero; a workflow hook gets it as params and fails by returning an error.
+func (l *Lifecycle) Fire(ctx context.Context, ext *Plugin, event string, extra map[string]any) error {
+	hook, ok := ext.Manifest.Lifecycle[event]
+	if !ok {
+		return nil
+	}
+	name := ext.Manifest.Name
+	if err := l.registry.AuthorizePlugin(ext, PermissionExecute, "lifecycle:"+event); err != nil {
+		return err
+	}
+
+	ext.mu.Lock()
+	settings := make(map[string]any, len(ext.Settings))
+	for k, v := range ext.Settings {
+		settings[k] = v
+	}
+	ext.mu.Unlock()
+	payload := map[string]any{
+		"event":      event,
+		"plugin":     name,
+		"version":    ext.Manifest.Version,
+		"plugin_dir": ext.Dir,
+		"settings":   settings,
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+
+	timeout := hook.ParsedTimeout()
+	start := time.Now()
+	var err error
+	switch {
+	case hook.Run != "":
+		err = l.runScript(ctx, ext, hook.Run, timeout, payload)
+	case hook.Workflow != "":
+		wf := hook.Workflow
+		if !strings.Contains(wf, ":") {
+			wf = name + ":" + wf
+		}
+		wctx, cancel := context.WithTimeout(WithPlugin(ctx, name), timeout)
+		_, err = l.runner.RunWorkflow(wctx, wf, payload)
+		if err == nil && wctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("workflow %s timed out after %v", wf, timeout)
+		}
+		cancel()
+	default:
+		return fmt.Errorf("lifecycle %s: hook needs run: or workflow:", event)
+	}
+	if err != nil {
+		return fmt.Errorf("lifecycle %s: %w", event, err)
+	}
+	log.Printf("[lifecycle] %s: %s hook ok (%v)", name, event, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// fireLogged runs a hook whose failure cannot veto anything.
+func (l *Lifecycle) fireLogged(ctx context.Context, ext *Plugin, event string, extra map[string]any) {
+	if err := l.Fire(ctx, ext, event, extra); err != nil {
+		log.Printf("[lifecycle] %s: %v", ext.Manifest.Name, err)
+	}
+}
+
+// runScript runs a hook script in the sandbox like action scripts: the
+// script must be inside the plugin directory, and runs in its data dir.
+func (l *Lifecycle) runScript(ctx context.Context, ext *Plugin, run string, timeout time.Duration, payload map[string]any) error {
+	scriptPath := filepath.Join(ext.Dir, run)
+	if !sandbox.Within(ext.Dir, scriptPath) {
+		return fmt.Errorf("script %s is outside the plugin directory", run)
+	}
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling payload: %w", err)
+	}
+	dataDir, err := ext.DataDir()
+	if err != nil {
+		return err
+	}
+	res, err := l.sandbox.Run(ctx, sandbox.Cmd{
+		Path:      scriptPath,
+		Stdin:     bytes.NewReader(input),
+		Dir:       dataDir,
+		Env:       []string{"BUD_PLUGIN_DIR=" + ext.Dir},
+		NoNetwork: !ext.Trusted && len(ext.Manifest.Permissions.Network) == 0,
+		Timeout:   timeout,
+	})
+	if err != nil {
+		if res != nil && !res.TimedOut {
+			if stderr := strings.TrimSpace(string(res.Stderr)); stderr != "" {
+				return fmt.Errorf("%s exited %d: %s", run, res.ExitCode, stderr)
+			}
+		}
+		return fmt.Errorf("%s: %w", run, err)
+	}
+	return nil
+}
+
+func (l *Lifecycle) installed(ext *Plugin) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.installs[ext.Manifest.Name]
+	return ok
+}
+
+// record stores rec for name and persists the install records.
+func (l *Lifecycle) record(name string, rec installRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.installs[name] = rec
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		log.Printf("[lifecycle] saving install records: %v", err)
+		return
+	}
+	if err := writeJSONFile(l.path, l.installs); err != nil {
+		log.Printf("[lifecycle] saving install records: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package plugins_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/plugins"
+	"github.com/vthunder/bud2/internal/sandbox"
+)
+
+// recordingRunner is a WorkflowRunner that records calls and returns err.
+type recordingRunner struct {
+	calls  []string
+	params []map[string]any
+	err    error
+}
+
+func (r *recordingRunner) RunWorkflow(_ context.Context, name string, params map[string]any) (any, error) {
+	r.calls = append(r.calls, name)
+	r.params = append(r.params, params)
+	return nil, r.err
+}
+
+// makeLifecyclePlugin writes a plugin with the given version and lifecycle
+// block, plus a hook script that appends its stdin to hooks.log and exits
+// with the code in the file "exit" (0 if absent), both in the data dir it
+// runs in. Returns the registry.
+func makeLifecyclePlugin(t *testing.T, version string, lifecycle map[string]any) *plugins.Registry {
+	t.Helper()
+	dir := t.TempDir()
+	writePluginYAML(t, dir, map[string]any{
+		"name":        "test-ext",
+		"description": "test",
+		"version":     version,
+		"lifecycle":   lifecycle,
+	})
+	writeScript(t, dir, "hook.sh", "#!/bin/sh\ncat >> hooks.log\necho >> hooks.log\n[ -f exit ] && exit $(cat exit)\nexit 0\n")
+	return makeSystemRegistry(t, dir)
+}
+
+func hookLog(t *testing.T, reg *plugins.Registry) string {
+	t.Helper()
+	data, _ := os.ReadFile(filepath.Join(reg.Get("test-ext").Dir, ".bud-plugin", "data", "hooks.log"))
+	return string(data)
+}
+
+func TestLifecycle_InstallThenDaemonStart(t *testing.T) {
+	reg := makeLifecyclePlugin(t, "1.0.0", map[string]any{
+		"install":      "scripts/hook.sh",
+		"daemon_start": "scripts/hook.sh",
+	})
+	installs := filepath.Join(t.TempDir(), "installs.json")
+	lc := plugins.NewLifecycle(reg, &recordingRunner{}, installs)
+	lc.Start(context.Background())
+
+	log := hookLog(t, reg)
+	if !strings.Contains(log, `"event":"install"`) || !strings.Contains(log, `"event":"daemon_start"`) {
+		t.Fatalf("expected install and daemon_start payloads, got %q", log)
+	}
+	if !strings.Contains(log, `"version":"1.0.0"`) {
+		t.Errorf("expected version in payload, got %q", log)
+	}
+
+	// A second start does not reinstall.
+	plugins.NewLifecycle(reg, &recordingRunner{}, installs).Start(context.Background())
+	if n := strings.Count(hookLog(t, reg), `"event":"install"`); n != 1 {
+		t.Errorf("expected install to run once, ran %d times", n)
+	}
+}
+
+func TestLifecycle_FailedUpgradeDisables(t *testing.T) {
+	installs := filepath.Join(t.TempDir(), "installs.json")
+	if err := os.WriteFile(installs, []byte(`{"test-ext":{"version":"1.0.0"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg := makeLifecyclePlugin(t, "2.0.0", map[string]any{"upgrade": "scripts/hook.sh"})
+	ext := reg.Get("test-ext")
+	dataDir, err := ext.DataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dataDir, "exit"), []byte("1"), 0o644)
+
+	lc := plugins.NewLifecycle(reg, &recordingRunner{}, installs)
+	lc.Start(context.Background())
+	if ext.Enabled() {
+		t.Fatal("expected failed upgrade to disable the plugin")
+	}
+	if !strings.Contains(hookLog(t, reg), `"previous_version":"1.0.0"`) {
+		t.Errorf("expected previous_version in upgrade payload, got %q", hookLog(t, reg))
+	}
+
+	// Re-enabling retries the upgrade; once it passes, the plugin is enabled.
+	os.Remove(filepath.Join(dataDir, "exit"))
+	if err := lc.Enable(context.Background(), "test-ext"); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if !ext.Enabled() {
+		t.Error("expected plugin enabled after successful upgrade")
+	}
+}
+
+func TestLifecycle_EnableVeto(t *testing.T) {
+	reg := makeLifecyclePlugin(t, "1.0.0", map[string]any{"enable": "check-credentials"})
+	runner := &recordingRunner{err: os.ErrNotExist}
+	lc := plugins.NewLifecycle(reg, runner, filepath.Join(t.TempDir(), "installs.json"))
+	lc.Start(context.Background())
+	ext := reg.Get("test-ext")
+	ext.StateSet("_enabled", false)
+
+	if err := lc.Enable(context.Background(), "test-ext"); err == nil || !strings.Contains(err.Error(), "vetoed") {
+		t.Fatalf("expected enable veto, got %v", err)
+	}
+	if ext.Enabled() {
+		t.Error("expected plugin to stay disabled")
+	}
+	if len(runner.calls) != 1 || runner.calls[0] != "test-ext:check-credentials" {
+		t.Errorf("expected workflow hook test-ext:check-credentials, got %v", runner.calls)
+	}
+}
+
+func TestLifecycle_SettingsChangedPayload(t *testing.T) {
+	reg := makeLifecyclePlugin(t, "1.0.0", map[string]any{"settings_changed": map[string]any{"workflow": "reconfigure"}})
+	runner := &recordingRunner{}
+	lc := plugins.NewLifecycle(reg, runner, filepath.Join(t.TempDir(), "installs.json"))
+
+	if err := lc.SetSetting(context.Background(), "test-ext", "interval", 5); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	if len(runner.params) != 1 {
+		t.Fatalf("expected one settings_changed call, got %d", len(runner.params))
+	}
+	p := runner.params[0]
+	if p["event"] != "settings_changed" || p["key"] != "interval" || p["new"] != 5 {
+		t.Errorf("unexpected payload %v", p)
+	}
+}
+
+func TestLifecycle_HookTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writePluginYAML(t, dir, map[string]any{
+		"name":        "test-ext",
+		"description": "test",
+		"lifecycle":   map[string]any{"install": map[string]any{"run": "scripts/slow.sh", "timeout": "200ms"}},
+	})
+	writeScript(t, dir, "slow.sh", "#!/bin/sh\nsleep 10\n")
+	reg := makeSystemRegistry(t, dir)
+	lc := plugins.NewLifecycle(reg, &recordingRunner{}, filepath.Join(t.TempDir(), "installs.json"))
+	lc.SetSandbox(sandbox.NewRunner(sandbox.Policy{}, ""))
+
+	start := time.Now()
+	err := lc.Fire(context.Background(), reg.Get("test-ext"), plugins.LifecycleInstall, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("hook timeout not honoured: took %v", elapsed)
+	}
+}
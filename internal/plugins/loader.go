@@ -17,6 +17,8 @@ import (
 // Soft failures (unknown schema keywords, missing-but-defaultable settings, etc.)
 // emit log warnings rather than returning errors.
 func LoadPlugin(dir string) (*Plugin, error) {
+	// Snapshot before reading so an edit made mid-load is seen by the next Reload.
+	snapshot := dirSnapshot(dir)
 	manifestPath := filepath.Join(dir, ".bud-plugin", "plugin.yaml")
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
@@ -36,9 +38,13 @@ func LoadPlugin(dir string) (*Plugin, error) {
 		log.Printf("plugins: %s: .mcp.json found — bud does not support direct MCP connections from plugins; use mcp_servers: in .bud-plugin/plugin.yaml instead", m.Name)
 	}
 
+	validateLifecycle(m)
+	validateVersions(m)
+
 	ext := &Plugin{
 		Manifest: m,
 		Dir:      dir,
+		snapshot: snapshot,
 	}
 
 	// Load capabilities from capabilities/ subdirectory.
@@ -233,9 +239,32 @@ func parseCapabilityMD(fallbackName string, data []byte) (*Capability, error) {
 		Body:         string(body),
 		Tools:        stringSliceField(fm, "tools"),
 	}
+	params, err := paramsField(fm)
+	if err != nil {
+		return nil, err
+	}
+	cap.Params = params
 	return cap, nil
 }
 
+// paramsField decodes the frontmatter "params" map into ParamDefs, as
+// declared in YAML capability files. Returns nil if the key is absent.
+func paramsField(m map[string]any) (map[string]ParamDef, error) {
+	v, ok := m["params"]
+	if !ok {
+		return nil, nil
+	}
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encoding params: %w", err)
+	}
+	var params map[string]ParamDef
+	if err := yaml.Unmarshal(data, &params); err != nil {
+		return nil, fmt.Errorf("parsing params: %w", err)
+	}
+	return params, nil
+}
+
 // stringSliceField extracts a []string value from a generic map.
 // Returns nil if the key is absent or not a []interface{}.
 func stringSliceField(m map[string]any, key string) []string {
@@ -360,6 +389,18 @@ func initSettings(ext *Plugin) error {
 	return nil
 }
 
+// DataDir returns the plugin's writable data directory,
+// <ext-dir>/.bud-plugin/data, creating it if needed. Action and hook scripts
+// and local MCP servers run there, with it as HOME and TMPDIR, so the files
+// they write stay out of the plugin's content hash.
+func (e *Plugin) DataDir() (string, error) {
+	dir := filepath.Join(e.Dir, ".bud-plugin", "data")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating data dir for %s: %w", e.Manifest.Name, err)
+	}
+	return dir, nil
+}
+
 // initState loads <ext-dir>/state.json into ext.State.
 // A missing file is treated as empty state (not an error).
 func initState(ext *Plugin) error {
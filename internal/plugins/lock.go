@@ -0,0 +1,171 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lock is the content of plugins.lock: the resolved commit of every git
+// source in plugins.yaml, the resolved version of every floating ClaWHub
+// skill, and the version and content hash of every loaded plugin. Sharing it
+// through the state repo makes every machine load the same plugin code.
+type Lock struct {
+	path string
+
+	Sources map[string]*LockedSource `yaml:"sources,omitempty"` // "owner/repo" → resolved commit
+	Skills  map[string]*LockedSkill  `yaml:"skills,omitempty"`  // ClaWHub slug → resolved version
+	Plugins map[string]*LockedPlugin `yaml:"plugins,omitempty"` // plugin name → version and hash
+}
+
+// LockedSource is the commit a git source is held at.
+type LockedSource struct {
+	Commit string `yaml:"commit"`
+	// Previous is the commit before the last upgrade, restored by a rollback.
+	Previous string `yaml:"previous,omitempty"`
+	// Pinned sources are skipped by an upgrade of all plugins.
+	Pinned bool `yaml:"pinned,omitempty"`
+}
+
+// LockedSkill is the version a floating ClaWHub skill is held at.
+type LockedSkill struct {
+	Version string `yaml:"version"`
+}
+
+// LockedPlugin records a plugin as it was last resolved.
+type LockedPlugin struct {
+	Version string `yaml:"version,omitempty"`
+	Hash    string `yaml:"hash"`
+	Source  string `yaml:"source,omitempty"` // "owner/repo", empty for local plugins
+}
+
+// Lock statuses reported by Lock.Check.
+const (
+	LockOK       = "locked"   // content matches the lock
+	LockModified = "modified" // content differs from the locked hash
+	LockMissing  = "unlocked" // plugin not in the lock
+)
+
+const lockHeader = "# plugins.lock — generated by bud; edit with `bud plugins`, not by hand.\n"
+
+// LoadLock reads the lockfile at path. A missing file yields an empty lock
+// that Save will create.
+func LoadLock(path string) (*Lock, error) {
+	l := &Lock{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, l); err != nil {
+			return nil, fmt.Errorf("plugins: parsing %s: %w", path, err)
+		}
+	}
+	if l.Sources == nil {
+		l.Sources = make(map[string]*LockedSource)
+	}
+	if l.Skills == nil {
+		l.Skills = make(map[string]*LockedSkill)
+	}
+	if l.Plugins == nil {
+		l.Plugins = make(map[string]*LockedPlugin)
+	}
+	return l, nil
+}
+
+// Save writes the lock back to the file it was loaded from.
+func (l *Lock) Save() error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, append([]byte(lockHeader), data...), 0o644)
+}
+
+// Record stores ext's current version and content hash, attributed to
+// source. It reports whether the entry changed.
+func (l *Lock) Record(ext *Plugin, source string) (bool, error) {
+	hash, err := ContentHash(ext.Dir)
+	if err != nil {
+		return false, err
+	}
+	next := &LockedPlugin{Version: ext.Manifest.Version, Hash: hash, Source: source}
+	if prev, ok := l.Plugins[ext.Manifest.Name]; ok && *prev == *next {
+		return false, nil
+	}
+	l.Plugins[ext.Manifest.Name] = next
+	return true, nil
+}
+
+// Check compares ext's content with the lock and returns LockOK,
+// LockModified or LockMissing.
+func (l *Lock) Check(ext *Plugin) (string, error) {
+	locked, ok := l.Plugins[ext.Manifest.Name]
+	if !ok {
+		return LockMissing, nil
+	}
+	hash, err := ContentHash(ext.Dir)
+	if err != nil {
+		return "", err
+	}
+	if hash != locked.Hash {
+		return LockModified, nil
+	}
+	return LockOK, nil
+}
+
+// ContentHash returns a "sha256:<hex>" hash of every file in a plugin dir,
+// covering relative paths and contents. VCS metadata, the plugin's runtime
+// state.json and settings.json, and its data dir (see Plugin.DataDir) are
+// excluded.
+func ContentHash(dir string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if d.IsDir() {
+			if d.Name() == ".git" || rel == filepath.Join(".bud-plugin", "data") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch rel {
+		case filepath.Join(".bud-plugin", "state.json"), filepath.Join(".bud-plugin", "settings.json"):
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, rel := range files {
+		f, err := os.Open(filepath.Join(dir, rel))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
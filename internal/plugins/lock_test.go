@@ -0,0 +1,74 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestLock_RecordCheckRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "calendar", map[string]any{"version": "1.4.0"})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	ext := reg.Get("calendar")
+
+	path := filepath.Join(t.TempDir(), "plugins.lock")
+	lock, err := plugins.LoadLock(path)
+	if err != nil {
+		t.Fatalf("LoadLock: %v", err)
+	}
+	if status, _ := lock.Check(ext); status != plugins.LockMissing {
+		t.Errorf("expected %s before recording, got %s", plugins.LockMissing, status)
+	}
+	if changed, err := lock.Record(ext, "vthunder/useful-plugins"); err != nil || !changed {
+		t.Fatalf("Record = %v, %v", changed, err)
+	}
+	if changed, _ := lock.Record(ext, "vthunder/useful-plugins"); changed {
+		t.Error("expected re-recording unchanged plugin to be a no-op")
+	}
+	lock.Sources["vthunder/useful-plugins"] = &plugins.LockedSource{Commit: "abc123", Pinned: true}
+	if err := lock.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := plugins.LoadLock(path)
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	if p := reloaded.Plugins["calendar"]; p == nil || p.Version != "1.4.0" || !strings.HasPrefix(p.Hash, "sha256:") {
+		t.Errorf("unexpected locked plugin %+v", p)
+	}
+	if s := reloaded.Sources["vthunder/useful-plugins"]; s == nil || s.Commit != "abc123" || !s.Pinned {
+		t.Errorf("unexpected locked source %+v", s)
+	}
+
+	// Runtime state does not count as a change; plugin files do.
+	if err := ext.StateSet("counter", 1); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := reloaded.Check(ext); status != plugins.LockOK {
+		t.Errorf("expected state.json to be ignored, got %s", status)
+	}
+	dataDir, err := ext.DataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "cache.db"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := reloaded.Check(ext); status != plugins.LockOK {
+		t.Errorf("expected the data dir to be ignored, got %s", status)
+	}
+	if err := os.WriteFile(filepath.Join(ext.Dir, "extra.sh"), []byte("echo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := reloaded.Check(ext); status != plugins.LockModified {
+		t.Errorf("expected %s after editing, got %s", plugins.LockModified, status)
+	}
+}
@@ -11,6 +11,9 @@ type Plugin struct {
 	Capabilities map[string]*Capability
 	Settings     map[string]any // current settings (loaded + schema defaults applied)
 	State        map[string]any // current state
+	// Trusted plugins ship with bud and bypass permission checks.
+	Trusted      bool
+	snapshot     string        // dirSnapshot at load time; Registry.Reload compares it
 	mu           sync.Mutex    // serializes all file I/O for this plugin
 }
 
@@ -22,9 +25,12 @@ type Manifest struct {
 	Author      string                   `yaml:"author,omitempty"`
 	Capabilities map[string]CapabilityMeta `yaml:"capabilities,omitempty"`
 	Behaviors   []Behavior               `yaml:"behaviors,omitempty"`
-	Lifecycle   map[string]string        `yaml:"lifecycle,omitempty"`
+	Lifecycle   map[string]LifecycleHook `yaml:"lifecycle,omitempty"` // event → hook; see lifecycle.go
 	Requires    Requirements             `yaml:"requires,omitempty"`
 	MCPServers  map[string]MCPServerDef  `yaml:"mcp_servers,omitempty"`
+	// Permissions declares what the plugin may touch at runtime. Enforced by
+	// Registry.Authorize; see permissions.go.
+	Permissions Permissions `yaml:"permissions,omitempty"`
 	// Settings is a flat map from setting key to its JSON Schema subset node.
 	// Treat this as the "properties" of an implicit root object schema.
 	Settings         map[string]SchemaNode `yaml:"settings,omitempty"`
@@ -76,18 +82,28 @@ type Behavior struct {
 
 // Requirements declares what a plugin depends on from the runtime.
 type Requirements struct {
-	// Plugins lists required plugin names. LoadAll topologically sorts
-	// based on this field and rejects cycles.
+	// Plugins lists required plugin names, each optionally with a semver
+	// constraint ("calendar@^1.2"; see ParseRequirement). LoadAll
+	// topologically sorts based on this field and rejects cycles and
+	// unmet constraints.
 	Plugins    []string `yaml:"plugins,omitempty"`
 	Tools      []string `yaml:"tools,omitempty"`
 	MCPServers []string `yaml:"mcp_servers,omitempty"`
 }
 
-// MCPServerDef describes an MCP subprocess that the extension can start on demand.
+// MCPServerDef describes an MCP server the extension uses: a subprocess it
+// ships (Command), or a remote server it connects to (URL).
 type MCPServerDef struct {
-	Command string            `yaml:"command"`
+	Command string            `yaml:"command,omitempty"`
 	Args    []string          `yaml:"args,omitempty"`
 	Env     map[string]string `yaml:"env,omitempty"`
+	// URL is a remote server's endpoint; Type is its transport ("http",
+	// "sse", or empty to detect). Header values may use ${ENV} and
+	// ${secret:NAME}. Connecting needs a network permission for the host.
+	URL     string            `yaml:"url,omitempty"`
+	Type    string            `yaml:"type,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Timeout int               `yaml:"timeout,omitempty"` // per-request timeout in seconds
 }
 
 // SchemaNode is a subset of JSON Schema used to describe and validate settings values.
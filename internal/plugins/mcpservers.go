@@ -0,0 +1,243 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/sandbox"
+)
+
+// MCPServers runs the mcp_servers declared by plugins and registers their
+// tools on bud's MCP server. Servers are tracked per plugin so a reload can
+// stop and restart them.
+type MCPServers struct {
+	server     *mcp.Server
+	secrets    mcp.SecretStore
+	authorizer *Registry
+
+	mu      sync.Mutex
+	running map[string][]*pluginMCPServer // plugin name → its running servers
+}
+
+// pluginMCPServer is one running plugin MCP server and the tools it
+// registered. tools and stopped are guarded by MCPServers.mu.
+type pluginMCPServer struct {
+	name    string
+	proxy   *mcp.ProxyClient
+	tools   []string
+	stopped bool
+}
+
+// NewMCPServers creates a manager that registers tools on server.
+func NewMCPServers(server *mcp.Server) *MCPServers {
+	return &MCPServers{
+		server:  server,
+		running: make(map[string][]*pluginMCPServer),
+	}
+}
+
+// SetSecrets wires the store that remote servers' ${secret:NAME} header
+// references are resolved from.
+func (m *MCPServers) SetSecrets(s mcp.SecretStore) { m.secrets = s }
+
+// SetAuthorizer wires the registry that checks a plugin is approved and may
+// reach a remote server's host. Without one, servers are not checked.
+func (m *MCPServers) SetAuthorizer(r *Registry) { m.authorizer = r }
+
+// StartAll starts the servers of every plugin in the registry.
+func (m *MCPServers) StartAll(registry *Registry) {
+	for _, ext := range registry.All() {
+		m.Start(ext)
+	}
+}
+
+// Start starts ext's mcp_servers, or connects to them if remote, and
+// registers their tools. Nothing is started until the user has approved the
+// plugin, or if its servers are already running. Local servers get the
+// sandbox's scrubbed environment and run in the plugin's data dir; a relative
+// command path and relative args are resolved against the plugin directory.
+// A server that fails to start or to list its tools is logged and skipped.
+func (m *MCPServers) Start(ext *Plugin) {
+	if len(ext.Manifest.MCPServers) == 0 {
+		return
+	}
+	m.mu.Lock()
+	_, running := m.running[ext.Manifest.Name]
+	m.mu.Unlock()
+	if running {
+		return
+	}
+	if m.authorizer != nil {
+		if err := m.authorizer.AuthorizePlugin(ext, PermissionExecute, ext.Manifest.Name+":mcp_servers"); err != nil {
+			log.Printf("[plugins] Not starting MCP servers of %s: %v", ext.Manifest.Name, err)
+			return
+		}
+	}
+	for srvName, srv := range ext.Manifest.MCPServers {
+		cfg, err := m.serverConfig(ext, srvName, srv)
+		if err != nil {
+			log.Printf("[plugins] Warning: skipping plugin MCP server %s: %v", srvName, err)
+			continue
+		}
+		if cfg.Command == "" && cfg.URL == "" {
+			continue
+		}
+		log.Printf("[plugins] Starting plugin MCP server %s from %s", srvName, ext.Manifest.Name)
+		proxy, err := mcp.StartProxy(cfg)
+		if err != nil {
+			log.Printf("[plugins] Warning: failed to start plugin MCP server %s: %v", srvName, err)
+			continue
+		}
+		defs, err := proxy.DiscoverTools()
+		if err != nil {
+			log.Printf("[plugins] Warning: plugin MCP server %s tool discovery failed: %v", srvName, err)
+			proxy.Close()
+			continue
+		}
+		log.Printf("[plugins] Plugin MCP server %s: %d tools", srvName, len(defs))
+		running := &pluginMCPServer{name: srvName, proxy: proxy}
+		m.mu.Lock()
+		running.tools = mcp.RegisterProxyTools(m.server, proxy, defs, nil)
+		m.running[ext.Manifest.Name] = append(m.running[ext.Manifest.Name], running)
+		m.mu.Unlock()
+		proxy.SetOnRestart(func(defs []mcp.ToolDef) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if !running.stopped {
+				running.tools = mcp.RegisterProxyTools(m.server, proxy, defs, running.tools)
+			}
+		})
+	}
+}
+
+// serverConfig builds the proxy config for one of ext's servers. A remote
+// server's host must be covered by the plugin's network permission. Its
+// headers may only reference secrets the plugin declares, never the daemon's
+// environment, and the references must resolve.
+func (m *MCPServers) serverConfig(ext *Plugin, name string, srv MCPServerDef) (mcp.ExternalServerConfig, error) {
+	if srv.URL == "" {
+		args := make([]string, len(srv.Args))
+		for i, a := range srv.Args {
+			if !filepath.IsAbs(a) {
+				a = filepath.Join(ext.Dir, a)
+			}
+			args[i] = a
+		}
+		command := srv.Command
+		if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) {
+			command = filepath.Join(ext.Dir, command)
+		}
+		dataDir, err := ext.DataDir()
+		if err != nil {
+			return mcp.ExternalServerConfig{}, err
+		}
+		env := map[string]string{"HOME": dataDir, "TMPDIR": dataDir, "BUD_PLUGIN_DIR": ext.Dir}
+		for k, v := range srv.Env {
+			env[k] = v
+		}
+		return mcp.ExternalServerConfig{
+			Name:     name,
+			Command:  command,
+			Args:     args,
+			Env:      env,
+			EnvAllow: sandbox.DefaultEnvAllow,
+			Dir:      dataDir,
+			Timeout:  time.Duration(srv.Timeout) * time.Second,
+		}, nil
+	}
+
+	if srv.Command != "" {
+		return mcp.ExternalServerConfig{}, fmt.Errorf("command and url are mutually exclusive")
+	}
+	u, err := url.Parse(srv.URL)
+	if err != nil || u.Host == "" {
+		return mcp.ExternalServerConfig{}, fmt.Errorf("invalid url %q", srv.URL)
+	}
+	if m.authorizer != nil {
+		if err := m.authorizer.AuthorizePlugin(ext, PermissionNetwork, u.Host); err != nil {
+			return mcp.ExternalServerConfig{}, err
+		}
+	}
+	env, secrets := mcp.HeaderRefs(srv.Headers)
+	if len(env) > 0 {
+		return mcp.ExternalServerConfig{}, fmt.Errorf("plugin headers may not reference environment variables (%s); declare a secret instead", strings.Join(env, ", "))
+	}
+	if m.authorizer != nil {
+		for _, name := range secrets {
+			if err := m.authorizer.AuthorizePlugin(ext, PermissionSecret, name); err != nil {
+				return mcp.ExternalServerConfig{}, err
+			}
+		}
+	}
+	headers, err := mcp.ExpandHeaders(srv.Headers, m.secrets)
+	if err != nil {
+		return mcp.ExternalServerConfig{}, err
+	}
+	return mcp.ExternalServerConfig{
+		Name:      name,
+		URL:       srv.URL,
+		Transport: srv.Type,
+		Headers:   headers,
+		Timeout:   time.Duration(srv.Timeout) * time.Second,
+	}, nil
+}
+
+// Stop closes the named plugin's servers and unregisters their tools.
+func (m *MCPServers) Stop(name string) {
+	m.mu.Lock()
+	servers := m.running[name]
+	delete(m.running, name)
+	for _, s := range servers {
+		s.stopped = true
+	}
+	m.mu.Unlock()
+
+	for _, s := range servers {
+		for _, tool := range s.tools {
+			m.server.UnregisterTool(tool)
+		}
+		s.proxy.Close()
+		log.Printf("[plugins] Stopped plugin MCP server %s from %s", s.name, name)
+	}
+}
+
+// Health reports the state of every running plugin server, by plugin name.
+func (m *MCPServers) Health() []mcp.ProxyHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []mcp.ProxyHealth
+	for _, name := range names {
+		for _, s := range m.running[name] {
+			h := s.proxy.Health()
+			h.Name = name + "/" + s.name
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Close stops every running plugin server.
+func (m *MCPServers) Close() {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+
+	for _, name := range names {
+		m.Stop(name)
+	}
+}
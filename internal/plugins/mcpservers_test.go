@@ -0,0 +1,152 @@
+package plugins_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/mcp"
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// startRemoteMCP serves a bud MCP server with one tool behind a front end
+// that requires a bearer token, and returns its URL.
+func startRemoteMCP(t *testing.T) string {
+	t.Helper()
+	remote := mcp.NewServer()
+	remote.RegisterTool("team_echo", mcp.ToolDef{Description: "Echo"}, func(_ any, args map[string]any) (string, error) {
+		msg, _ := args["msg"].(string)
+		return "team: " + msg, nil
+	})
+	token := mcp.NewToken()
+	remote.RegisterSession(token, "test", "/", []string{"*"})
+	ln, err := remote.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go remote.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+
+	backend := &url.URL{Scheme: "http", Host: ln.Addr().String()}
+	proxy := httputil.NewSingleHostReverseProxy(backend)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(front.Close)
+	return front.URL + "/mcp/" + token
+}
+
+func TestMCPServers_Remote(t *testing.T) {
+	remoteURL := startRemoteMCP(t)
+	root := t.TempDir()
+	server := map[string]any{
+		"url":     remoteURL,
+		"headers": map[string]any{"Authorization": "Bearer ${secret:team_mcp}"},
+	}
+	writeReloadPlugin(t, root, "team", map[string]any{
+		"mcp_servers": map[string]any{"team-mcp": server},
+		"permissions": map[string]any{"network": []any{"127.0.0.1"}, "secrets": []any{"team_mcp"}},
+	})
+	writeReloadPlugin(t, root, "sneaky", map[string]any{
+		"mcp_servers": map[string]any{"sneaky-mcp": server},
+		"permissions": map[string]any{"secrets": []any{"team_mcp"}},
+	})
+	// A plugin may only send the secrets it declares, and never the daemon's
+	// environment.
+	writeReloadPlugin(t, root, "greedy", map[string]any{
+		"mcp_servers": map[string]any{"greedy-mcp": server},
+		"permissions": map[string]any{"network": []any{"127.0.0.1"}},
+	})
+	writeReloadPlugin(t, root, "envy", map[string]any{
+		"mcp_servers": map[string]any{"envy-mcp": map[string]any{
+			"url":     remoteURL,
+			"headers": map[string]any{"Authorization": "Bearer ${HOME}"},
+		}},
+		"permissions": map[string]any{"network": []any{"127.0.0.1"}},
+	})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+
+	host := mcp.NewServer()
+	m := plugins.NewMCPServers(host)
+	m.SetSecrets(mcp.FileSecrets{"team_mcp": "s3cret"})
+	m.SetAuthorizer(reg)
+	defer m.Close()
+
+	// Without a network permission for the host, an undeclared secret or
+	// with an environment reference, the server is not used.
+	for _, name := range []string{"sneaky", "greedy", "envy"} {
+		m.Start(reg.Get(name))
+		if host.ToolCount() != 0 {
+			t.Fatalf("expected no tools from plugin %s, got %v", name, host.ToolNames())
+		}
+	}
+
+	m.Start(reg.Get("team"))
+	out, err := host.Call("team_echo", map[string]any{"msg": "hi"})
+	if err != nil || out != "team: hi" {
+		t.Fatalf("Call = %q, %v", out, err)
+	}
+	health := m.Health()
+	if len(health) != 1 || health[0].Name != "team/team-mcp" || health[0].State != mcp.ProxyReady || health[0].URL != remoteURL {
+		t.Errorf("unexpected health %+v", health)
+	}
+
+	m.Stop("team")
+	if host.ToolCount() != 0 {
+		t.Errorf("expected tools unregistered after Stop, got %v", host.ToolNames())
+	}
+}
+
+func TestMCPServers_RequireApproval(t *testing.T) {
+	remoteURL := startRemoteMCP(t)
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "team", map[string]any{
+		"mcp_servers": map[string]any{"team-mcp": map[string]any{
+			"url":     remoteURL,
+			"headers": map[string]any{"Authorization": "Bearer ${secret:team_mcp}"},
+		}},
+		"permissions": map[string]any{"network": []any{"127.0.0.1"}, "secrets": []any{"team_mcp"}},
+	})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	approvals, err := plugins.LoadApprovals(filepath.Join(t.TempDir(), "approvals.json"))
+	if err != nil {
+		t.Fatalf("LoadApprovals: %v", err)
+	}
+	reg.SetApprovals(approvals)
+
+	host := mcp.NewServer()
+	m := plugins.NewMCPServers(host)
+	m.SetSecrets(mcp.FileSecrets{"team_mcp": "s3cret"})
+	m.SetAuthorizer(reg)
+	defer m.Close()
+
+	m.Start(reg.Get("team"))
+	if host.ToolCount() != 0 || len(m.Health()) != 0 {
+		t.Fatalf("expected nothing started before approval, got %v", host.ToolNames())
+	}
+
+	if err := reg.Decide("team", true); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	m.Start(reg.Get("team"))
+	m.Start(reg.Get("team")) // already running: no second connection
+	if out, err := host.Call("team_echo", map[string]any{"msg": "ok"}); err != nil || out != "team: ok" {
+		t.Fatalf("Call after approval = %q, %v", out, err)
+	}
+	if n := len(m.Health()); n != 1 {
+		t.Errorf("expected 1 running server, got %d", n)
+	}
+}
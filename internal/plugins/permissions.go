@@ -0,0 +1,269 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/sandbox"
+)
+
+// Permission names checked by Registry.Authorize. All but PermissionExecute
+// correspond to a field of the plugin.yaml permissions block.
+const (
+	// PermissionExecute gates running anything a plugin ships — behavior
+	// workflows and action scripts. It is granted by approving the plugin.
+	PermissionExecute       = "execute"
+	PermissionNetwork       = "network"
+	PermissionFilesystem    = "filesystem"
+	PermissionTool          = "tool"
+	PermissionMessageUser   = "message_user"
+	PermissionSpawnSubagent = "spawn_subagent"
+	PermissionShell         = "shell"
+	PermissionSecret        = "secret"
+)
+
+// Permissions is the permissions block of plugin.yaml. It declares everything
+// a plugin's actions, workflows and hooks may touch at runtime; anything not
+// declared is denied once the plugin is subject to checks.
+type Permissions struct {
+	// Network lists hosts the plugin may reach. "*.example.com" matches any
+	// subdomain of example.com; "*" matches every host.
+	Network []string `yaml:"network,omitempty" json:"network,omitempty"`
+	// Filesystem lists paths the plugin may read or write, including
+	// everything beneath them. Relative paths are resolved against the plugin
+	// directory, which is always accessible.
+	Filesystem []string `yaml:"filesystem,omitempty" json:"filesystem,omitempty"`
+	// Tools lists MCP tools the plugin may call, as glob patterns
+	// (e.g. "calendar_*"). A plugin may always call its own actions.
+	Tools []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	// MessageUser allows talking to the user (reply, react, on_result notify).
+	MessageUser bool `yaml:"message_user,omitempty" json:"message_user,omitempty"`
+	// SpawnSubagents allows type:subagent workflow steps.
+	SpawnSubagents bool `yaml:"spawn_subagents,omitempty" json:"spawn_subagents,omitempty"`
+	// Shell allows workflow shell steps. Commands run in the sandbox; when it
+	// isolates (sandbox.isolate), they get no network unless Network lists
+	// hosts. Without isolation they keep the daemon's network access.
+	Shell bool `yaml:"shell,omitempty" json:"shell,omitempty"`
+	// Secrets lists the secrets from state/system/secrets.json that the
+	// plugin's remote mcp_servers headers may reference as ${secret:NAME}.
+	Secrets []string `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+}
+
+// Allows reports whether the declared permissions cover target for the given
+// permission. dir is the plugin directory, used to resolve relative paths.
+func (p Permissions) Allows(permission, target, dir string) bool {
+	switch permission {
+	case PermissionNetwork:
+		host := strings.ToLower(target)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		for _, allowed := range p.Network {
+			allowed = strings.ToLower(allowed)
+			switch {
+			case allowed == "*" || allowed == host:
+				return true
+			case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]):
+				return true
+			}
+		}
+	case PermissionFilesystem:
+		// Within resolves target itself: cleaning it first would drop a
+		// "link/.." that really leaves the allowed dir.
+		if dir != "" && sandbox.Within(dir, target) {
+			return true
+		}
+		for _, allowed := range p.Filesystem {
+			if sandbox.Within(resolvePermissionPath(allowed, dir), target) {
+				return true
+			}
+		}
+	case PermissionTool:
+		for _, pattern := range p.Tools {
+			if ok, _ := path.Match(pattern, target); ok {
+				return true
+			}
+		}
+	case PermissionMessageUser:
+		return p.MessageUser
+	case PermissionSpawnSubagent:
+		return p.SpawnSubagents
+	case PermissionShell:
+		return p.Shell
+	case PermissionSecret:
+		for _, name := range p.Secrets {
+			if name == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Summary describes the permissions in one line per grant, for the approval
+// prompt shown to the user.
+func (p Permissions) Summary() []string {
+	var lines []string
+	if len(p.Network) > 0 {
+		lines = append(lines, "network: "+strings.Join(p.Network, ", "))
+	}
+	if len(p.Filesystem) > 0 {
+		lines = append(lines, "filesystem: "+strings.Join(p.Filesystem, ", "))
+	}
+	if len(p.Tools) > 0 {
+		lines = append(lines, "tools: "+strings.Join(p.Tools, ", "))
+	}
+	if p.MessageUser {
+		lines = append(lines, "message you")
+	}
+	if p.SpawnSubagents {
+		lines = append(lines, "spawn subagents")
+	}
+	if p.Shell {
+		lines = append(lines, "run shell commands")
+	}
+	if len(p.Secrets) > 0 {
+		lines = append(lines, "send secrets to its MCP servers: "+strings.Join(p.Secrets, ", "))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no special permissions (sandboxed scripts and workflows only)")
+	}
+	return lines
+}
+
+// Fingerprint identifies this exact set of permissions. An approval applies
+// only to the fingerprint it was given for, so a plugin update that widens its
+// permissions has to be approved again.
+func (p Permissions) Fingerprint() string {
+	b, _ := json.Marshal(p)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// resolvePermissionPath expands ~ and resolves relative paths against dir.
+func resolvePermissionPath(p, dir string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return filepath.Clean(p)
+}
+
+// PermissionError is returned when a plugin attempts something it is not
+// permitted to do.
+type PermissionError struct {
+	Plugin     string
+	Permission string
+	Target     string
+	Reason     string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("plugin %s: %s permission denied for %q: %s", e.Plugin, e.Permission, e.Target, e.Reason)
+}
+
+// Approval records the user's decision about one plugin's permissions.
+type Approval struct {
+	Fingerprint string    `json:"fingerprint"`
+	Approved    bool      `json:"approved"`
+	PromptedAt  time.Time `json:"prompted_at,omitempty"`
+	DecidedAt   time.Time `json:"decided_at,omitempty"`
+}
+
+// Approvals persists permission approvals across restarts, keyed by plugin name.
+type Approvals struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]Approval
+}
+
+// LoadApprovals reads the approvals file at path. A missing file yields an
+// empty store; so does an unreadable one, returned along with the error, so
+// the caller can carry on with every plugin unapproved.
+func LoadApprovals(path string) (*Approvals, error) {
+	a := &Approvals{path: path, entries: make(map[string]Approval)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(data, &a.entries); err != nil {
+		a.entries = make(map[string]Approval)
+		return a, fmt.Errorf("plugins: parsing %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// Get returns the approval recorded for name, if any.
+func (a *Approvals) Get(name string) (Approval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.entries[name]
+	return e, ok
+}
+
+// MarkPrompted records that the user was asked about fingerprint, so the
+// prompt is not repeated on the next start.
+func (a *Approvals) MarkPrompted(name, fingerprint string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e := a.entries[name]
+	if e.Fingerprint != fingerprint {
+		e = Approval{Fingerprint: fingerprint}
+	}
+	e.PromptedAt = time.Now()
+	a.entries[name] = e
+	return a.save()
+}
+
+// Decide records the user's approval or denial of fingerprint.
+func (a *Approvals) Decide(name, fingerprint string, approved bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e := a.entries[name]
+	if e.Fingerprint != fingerprint {
+		e = Approval{Fingerprint: fingerprint}
+	}
+	e.Approved = approved
+	e.DecidedAt = time.Now()
+	a.entries[name] = e
+	return a.save()
+}
+
+// save writes the store; callers hold a.mu.
+func (a *Approvals) save() error {
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		return err
+	}
+	return writeJSONFile(a.path, a.entries)
+}
+
+type pluginCtxKey struct{}
+
+// WithPlugin returns a context attributing work to the named plugin, so
+// handlers further down (e.g. on_result notify) can check its permissions.
+func WithPlugin(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, pluginCtxKey{}, name)
+}
+
+// PluginFromContext returns the plugin set by WithPlugin.
+func PluginFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(pluginCtxKey{}).(string)
+	return name, ok && name != ""
+}
@@ -0,0 +1,140 @@
+package plugins_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestPermissions_Allows(t *testing.T) {
+	dir := t.TempDir()
+	perms := plugins.Permissions{
+		Network:     []string{"api.example.com", "*.github.com"},
+		Filesystem:  []string{"data", "/var/lib/bud"},
+		Tools:       []string{"calendar_*", "talk_to_user"},
+		MessageUser: true,
+	}
+
+	cases := []struct {
+		permission, target string
+		want               bool
+	}{
+		{plugins.PermissionNetwork, "api.example.com", true},
+		{plugins.PermissionNetwork, "API.example.com:443", true},
+		{plugins.PermissionNetwork, "evil.example.com", false},
+		{plugins.PermissionNetwork, "raw.github.com", true},
+		{plugins.PermissionNetwork, "github.com.evil.net", false},
+		{plugins.PermissionFilesystem, filepath.Join(dir, "notes.txt"), true},
+		{plugins.PermissionFilesystem, filepath.Join(dir, "data", "x.json"), true},
+		{plugins.PermissionFilesystem, "/var/lib/bud/state.json", true},
+		{plugins.PermissionFilesystem, "/etc/passwd", false},
+		{plugins.PermissionTool, "calendar_today", true},
+		{plugins.PermissionTool, "memory_reset", false},
+		{plugins.PermissionMessageUser, "reply", true},
+		{plugins.PermissionSpawnSubagent, "researcher", false},
+	}
+	for _, c := range cases {
+		if got := perms.Allows(c.permission, c.target, dir); got != c.want {
+			t.Errorf("Allows(%s, %s) = %v, want %v", c.permission, c.target, got, c.want)
+		}
+	}
+}
+
+func TestRegistry_AuthorizeRequiresApproval(t *testing.T) {
+	dir := makeTestPluginDir(t, "test-ext")
+	writePluginYAML(t, dir, map[string]any{
+		"name":        "test-ext",
+		"description": "test",
+		"permissions": map[string]any{"tools": []any{"calendar_*"}},
+	})
+	reg := makeSystemRegistry(t, dir)
+	approvalsPath := filepath.Join(t.TempDir(), "approvals.json")
+	approvals, err := plugins.LoadApprovals(approvalsPath)
+	if err != nil {
+		t.Fatalf("LoadApprovals: %v", err)
+	}
+	reg.SetApprovals(approvals)
+	var denied []*plugins.PermissionError
+	reg.SetOnDenied(func(e *plugins.PermissionError) { denied = append(denied, e) })
+
+	ext := reg.Get("test-ext")
+	if !reg.NeedsPrompt(ext) || len(reg.PendingApproval()) != 1 {
+		t.Fatal("expected unapproved plugin to be pending and need a prompt")
+	}
+	var permErr *plugins.PermissionError
+	if err := reg.Authorize("test-ext", plugins.PermissionExecute, "wf"); !errors.As(err, &permErr) {
+		t.Fatalf("expected PermissionError before approval, got %v", err)
+	}
+	if err := reg.MarkPrompted(ext); err != nil {
+		t.Fatalf("MarkPrompted: %v", err)
+	}
+	if reg.NeedsPrompt(ext) {
+		t.Error("expected no second prompt")
+	}
+
+	if err := reg.Decide("test-ext", true); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if err := reg.Authorize("test-ext", plugins.PermissionExecute, "wf"); err != nil {
+		t.Errorf("execute after approval: %v", err)
+	}
+	if err := reg.Authorize("test-ext", plugins.PermissionTool, "calendar_today"); err != nil {
+		t.Errorf("declared tool: %v", err)
+	}
+	if err := reg.Authorize("test-ext", plugins.PermissionTool, "test-ext:own-action"); err != nil {
+		t.Errorf("own action: %v", err)
+	}
+	if err := reg.Authorize("test-ext", plugins.PermissionMessageUser, "reply"); err == nil {
+		t.Error("expected undeclared message_user to be denied")
+	}
+	if len(denied) != 2 {
+		t.Errorf("expected 2 denials reported, got %d", len(denied))
+	}
+
+	// Approvals persist and survive a reload.
+	reloaded, err := plugins.LoadApprovals(approvalsPath)
+	if err != nil {
+		t.Fatalf("reloading approvals: %v", err)
+	}
+	if a, ok := reloaded.Get("test-ext"); !ok || !a.Approved {
+		t.Errorf("expected persisted approval, got %+v", a)
+	}
+}
+
+func TestRegistry_TrustDirBypassesChecks(t *testing.T) {
+	dir := makeTestPluginDir(t, "test-ext")
+	reg := makeSystemRegistry(t, dir)
+	approvals, _ := plugins.LoadApprovals(filepath.Join(t.TempDir(), "approvals.json"))
+	reg.SetApprovals(approvals)
+
+	reg.TrustDir(filepath.Dir(reg.Get("test-ext").Dir))
+	if err := reg.Authorize("test-ext", plugins.PermissionNetwork, "example.com"); err != nil {
+		t.Errorf("expected trusted plugin to be allowed, got %v", err)
+	}
+	if len(reg.PendingApproval()) != 0 {
+		t.Error("expected trusted plugin not to be pending")
+	}
+}
+
+func TestActionProxy_Call_RequiresApproval(t *testing.T) {
+	dir := makeTestPluginDir(t, "test-ext")
+	writeCapabilityYAMLRaw(t, dir, "echo", []byte(fmt.Sprintf(
+		"name: echo\ndescription: test\ntype: action\ncallable_from: direct\nrun: %s\n", makeEchoScript(t, dir))))
+	reg := makeSystemRegistry(t, dir)
+	approvals, _ := plugins.LoadApprovals(filepath.Join(t.TempDir(), "approvals.json"))
+	reg.SetApprovals(approvals)
+	proxy := plugins.NewActionProxy(reg)
+
+	if _, err := proxy.Call("test-ext:echo", nil); err == nil {
+		t.Fatal("expected unapproved action to be denied")
+	}
+	if err := reg.Decide("test-ext", true); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if _, err := proxy.Call("test-ext:echo", nil); err != nil {
+		t.Errorf("Call after approval: %v", err)
+	}
+}
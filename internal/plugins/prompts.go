@@ -0,0 +1,194 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vthunder/bud2/internal/mcp"
+)
+
+// guidePrefix names guide prompts, e.g. "guides/jobs". Capability prompts use
+// their "plugin:capability" full name, so the two never collide.
+const guidePrefix = "guides/"
+
+// Prompts serves skill and workflow capabilities, plus Markdown guides, as
+// MCP prompts so external clients can use them without the Skill tool.
+type Prompts struct {
+	reg    *Registry
+	guides func() []string
+}
+
+// NewPrompts creates a prompt provider over reg (which may be nil). guides
+// returns the paths of the guide files to expose; it may be nil.
+func NewPrompts(reg *Registry, guides func() []string) *Prompts {
+	return &Prompts{reg: reg, guides: guides}
+}
+
+// List returns every model-callable skill and workflow, with its declared
+// params as arguments, followed by the guides.
+func (p *Prompts) List() ([]mcp.PromptInfo, error) {
+	var prompts []mcp.PromptInfo
+	if p.reg != nil {
+		for _, capType := range []string{"skill", "workflow"} {
+			for _, item := range p.reg.CapabilitiesOfType(capType) {
+				prompts = append(prompts, mcp.PromptInfo{
+					Name:        item.FullName,
+					Description: item.Cap.Description,
+					Arguments:   promptArguments(item.Cap.Params),
+				})
+			}
+		}
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+
+	var guides []mcp.PromptInfo
+	for name, path := range p.guidePaths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		guides = append(guides, mcp.PromptInfo{Name: guidePrefix + name, Description: guideTitle(name, data)})
+	}
+	sort.Slice(guides, func(i, j int) bool { return guides[i].Name < guides[j].Name })
+	prompts = append(prompts, guides...)
+	return prompts, nil
+}
+
+// Get renders the named prompt. A skill's body has {{param}} references
+// replaced by the arguments (or param defaults); a workflow becomes an
+// instruction to run it with invoke_workflow; a guide is returned as is.
+func (p *Prompts) Get(name string, args map[string]string) (mcp.PromptContent, error) {
+	if guide, ok := strings.CutPrefix(name, guidePrefix); ok {
+		path, ok := p.guidePaths()[guide]
+		if !ok {
+			return mcp.PromptContent{}, fmt.Errorf("guide %q not found", guide)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return mcp.PromptContent{}, err
+		}
+		return mcp.PromptContent{Description: guideTitle(guide, data), Text: string(data)}, nil
+	}
+
+	if p.reg == nil {
+		return mcp.PromptContent{}, fmt.Errorf("plugin registry not configured")
+	}
+	cap, _, ok := p.reg.GetCapabilityByFullName(name)
+	if !ok || (cap.CallableFrom != "model" && cap.CallableFrom != "both") {
+		return mcp.PromptContent{}, fmt.Errorf("prompt %q not found", name)
+	}
+	values, err := promptValues(cap.Params, args)
+	if err != nil {
+		return mcp.PromptContent{}, err
+	}
+
+	switch cap.Type {
+	case "skill":
+		return mcp.PromptContent{Description: cap.Description, Text: renderSkill(cap.Body, values)}, nil
+	case "workflow":
+		params, err := json.Marshal(values)
+		if err != nil {
+			return mcp.PromptContent{}, fmt.Errorf("encoding params: %w", err)
+		}
+		text := fmt.Sprintf("Run the bud workflow %q using the invoke_workflow tool with action=invoke, name=%q and params=%s.", name, name, params)
+		if cap.Description != "" {
+			text += "\n\nWorkflow: " + cap.Description
+		}
+		return mcp.PromptContent{Description: cap.Description, Text: text}, nil
+	default:
+		return mcp.PromptContent{}, fmt.Errorf("prompt %q not found", name)
+	}
+}
+
+// guidePaths maps guide names (file names without .md) to their paths.
+func (p *Prompts) guidePaths() map[string]string {
+	guides := make(map[string]string)
+	if p.guides == nil {
+		return guides
+	}
+	for _, path := range p.guides() {
+		guides[strings.TrimSuffix(filepath.Base(path), ".md")] = path
+	}
+	return guides
+}
+
+// guideTitle returns a guide's first Markdown heading, or its name if it has none.
+func guideTitle(name string, data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+			return title
+		}
+	}
+	return name
+}
+
+// promptArguments converts declared params to prompt arguments, sorted by name.
+func promptArguments(params map[string]ParamDef) []mcp.PromptArgument {
+	args := make([]mcp.PromptArgument, 0, len(params))
+	for name, def := range params {
+		desc := def.Description
+		if len(def.Enum) > 0 {
+			opts := make([]string, len(def.Enum))
+			for i, v := range def.Enum {
+				opts[i] = fmt.Sprint(v)
+			}
+			desc = strings.TrimSpace(fmt.Sprintf("%s (one of: %s)", desc, strings.Join(opts, ", ")))
+		}
+		args = append(args, mcp.PromptArgument{Name: name, Description: desc, Required: def.Required})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+	return args
+}
+
+// promptValues merges args over the params' defaults and checks that every
+// required param has a value. Arguments that match no param are kept.
+func promptValues(params map[string]ParamDef, args map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(params)+len(args))
+	for name, def := range params {
+		if def.Default != nil {
+			values[name] = fmt.Sprint(def.Default)
+		}
+	}
+	for k, v := range args {
+		values[k] = v
+	}
+	var missing []string
+	for name, def := range params {
+		if _, ok := values[name]; def.Required && !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required arguments: %s", strings.Join(missing, ", "))
+	}
+	return values, nil
+}
+
+// renderSkill substitutes {{name}} references in body. Values the body does
+// not reference are appended so they still reach the model.
+func renderSkill(body string, values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var extra []string
+	for _, name := range names {
+		ref := "{{" + name + "}}"
+		if strings.Contains(body, ref) {
+			body = strings.ReplaceAll(body, ref, values[name])
+		} else {
+			extra = append(extra, fmt.Sprintf("- %s: %s", name, values[name]))
+		}
+	}
+	if len(extra) > 0 {
+		body = strings.TrimRight(body, "\n") + "\n\nArguments:\n" + strings.Join(extra, "\n") + "\n"
+	}
+	return body
+}
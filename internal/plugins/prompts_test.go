@@ -0,0 +1,106 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestPrompts(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "team", nil)
+	skills := filepath.Join(root, "team", "skills")
+	if err := os.MkdirAll(skills, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(skills, "review.md"), `---
+description: Review a change
+callable_from: both
+params:
+  path:
+    type: string
+    description: File to review
+    required: true
+  depth:
+    type: string
+    default: quick
+    enum: [quick, deep]
+---
+Review {{path}} ({{depth}}).
+`)
+	writeFile(t, filepath.Join(skills, "deploy.yaml"), `description: Deploy a service
+type: workflow
+callable_from: model
+params:
+  service:
+    type: string
+    required: true
+pipeline: []
+`)
+	writeFile(t, filepath.Join(skills, "internal.md"), "---\ncallable_from: system\n---\nHidden.\n")
+	guide := filepath.Join(t.TempDir(), "jobs.md")
+	writeFile(t, guide, "# Jobs Guide\n\nJobs are templates.\n")
+
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	p := plugins.NewPrompts(reg, func() []string { return []string{guide} })
+
+	list, err := p.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, info := range list {
+		names = append(names, info.Name)
+	}
+	if strings.Join(names, ",") != "team:deploy,team:review,guides/jobs" {
+		t.Fatalf("List names = %v", names)
+	}
+	review := list[1]
+	if len(review.Arguments) != 2 || review.Arguments[1].Name != "path" || !review.Arguments[1].Required ||
+		review.Arguments[0].Description != "(one of: quick, deep)" {
+		t.Errorf("review arguments = %+v", review.Arguments)
+	}
+	if list[2].Description != "Jobs Guide" {
+		t.Errorf("guide description = %q", list[2].Description)
+	}
+
+	got, err := p.Get("team:review", map[string]string{"path": "main.go", "ticket": "BUD-7"})
+	if err != nil {
+		t.Fatalf("Get skill: %v", err)
+	}
+	if got.Text != "Review main.go (quick).\n\nArguments:\n- ticket: BUD-7\n" {
+		t.Errorf("skill text = %q", got.Text)
+	}
+	if _, err := p.Get("team:review", nil); err == nil || !strings.Contains(err.Error(), "path") {
+		t.Errorf("expected a missing argument error, got %v", err)
+	}
+
+	got, err = p.Get("team:deploy", map[string]string{"service": "api"})
+	if err != nil || !strings.Contains(got.Text, `name="team:deploy" and params={"service":"api"}`) {
+		t.Errorf("workflow prompt = %q, %v", got.Text, err)
+	}
+
+	got, err = p.Get("guides/jobs", nil)
+	if err != nil || got.Text != "# Jobs Guide\n\nJobs are templates.\n" {
+		t.Errorf("guide prompt = %q, %v", got.Text, err)
+	}
+
+	for _, name := range []string{"team:internal", "team:missing", "guides/missing"} {
+		if _, err := p.Get(name, nil); err == nil {
+			t.Errorf("Get(%q): expected an error", name)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
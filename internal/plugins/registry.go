@@ -6,12 +6,23 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/vthunder/bud2/internal/sandbox"
 )
 
 // Registry holds all successfully loaded plugins, ordered by dependency.
 type Registry struct {
-	byName map[string]*Plugin
-	order  []string // topological load order (dependencies before dependents)
+	mu       sync.RWMutex // guards byName, order, trusted and excluded; Reload swaps them
+	reloadMu sync.Mutex   // serializes Reload
+	byName   map[string]*Plugin
+	order    []string         // topological load order (dependencies before dependents)
+	dirs     []string         // dirs passed to LoadAll, rescanned by Reload
+	trusted  []string         // dirs passed to TrustDir, reapplied by Reload
+	excluded map[string]error // plugins left out by the last load, with the reason
+
+	approvals *Approvals             // nil: every plugin counts as approved
+	onDenied  func(*PermissionError) // called for every denial, e.g. to log it
 }
 
 // LoadAll loads plugins from each of the given dirs in order.
@@ -27,23 +38,50 @@ func LoadAll(dirs ...string) (*Registry, error) {
 		if dir == "" {
 			continue
 		}
-		if err := loadDir(dir, exts); err != nil {
+		if err := loadDir(dir, exts, LoadPlugin); err != nil {
 			return nil, fmt.Errorf("plugins: loading dir %s: %w", dir, err)
 		}
 	}
 
+	order, excluded, err := sortPlugins(exts)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{byName: exts, order: order, dirs: dirs, excluded: excluded}, nil
+}
+
+// sortPlugins returns the topological load order of exts, removing from exts
+// any plugin that participates in a dependency cycle or whose requires.plugins
+// version constraints are not met. Removed plugins are returned with the reason.
+func sortPlugins(exts map[string]*Plugin) ([]string, map[string]error, error) {
 	// Topological sort to determine load order and detect cycles.
 	order, cycled, err := topoSort(exts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	excluded := make(map[string]error)
 
 	// Remove cycled plugins from the registry.
 	for _, name := range cycled {
 		log.Printf("plugins: %s: excluded from registry due to dependency cycle", name)
+		excluded[name] = fmt.Errorf("plugins: %s: dependency cycle", name)
 		delete(exts, name)
 	}
 
+	// Remove plugins whose dependencies are at an unsupported version. Repeat
+	// until stable, since a removal can unsatisfy a dependent in turn.
+	for {
+		errs := CheckRequirements(exts)
+		if len(errs) == 0 {
+			break
+		}
+		for _, e := range errs {
+			log.Printf("plugins: %s: excluded from registry: %v", e.Plugin, e)
+			excluded[e.Plugin] = e
+			delete(exts, e.Plugin)
+		}
+	}
+
 	// Filter order to exclude cycled plugins.
 	filtered := order[:0]
 	for _, name := range order {
@@ -51,14 +89,14 @@ func LoadAll(dirs ...string) (*Registry, error) {
 			filtered = append(filtered, name)
 		}
 	}
-
-	return &Registry{byName: exts, order: filtered}, nil
+	return filtered, excluded, nil
 }
 
-// loadDir scans dir for subdirectories and attempts to load each as a plugin.
-// Subdirs without plugin.yaml are silently skipped (they may be non-plugin dirs).
-// Successfully loaded plugins are added to exts (overwriting existing entries with the same name).
-func loadDir(dir string, exts map[string]*Plugin) error {
+// loadDir scans dir for subdirectories and attempts to load each as a plugin
+// with load. Subdirs without plugin.yaml are silently skipped (they may be
+// non-plugin dirs). Successfully loaded plugins are added to exts
+// (overwriting existing entries with the same name).
+func loadDir(dir string, exts map[string]*Plugin, load func(extDir string) (*Plugin, error)) error {
 	entries, err := os.ReadDir(dir)
 	if os.IsNotExist(err) {
 		return nil
@@ -75,7 +113,7 @@ func loadDir(dir string, exts map[string]*Plugin) error {
 		if _, statErr := os.Stat(filepath.Join(extDir, ".bud-plugin", "plugin.yaml")); statErr != nil {
 			continue
 		}
-		ext, err := LoadPlugin(extDir)
+		ext, err := load(extDir)
 		if err != nil {
 			log.Printf("plugins: skipping %s: %v", extDir, err)
 			continue
@@ -150,7 +188,7 @@ func topoSort(exts map[string]*Plugin) (order []string, cycled []string, err err
 
 		ok := true
 		for _, dep := range exts[name].Manifest.Requires.Plugins {
-			if !dfs(dep) {
+			if !dfs(requirementName(dep)) {
 				ok = false
 			}
 		}
@@ -179,11 +217,15 @@ func topoSort(exts map[string]*Plugin) (order []string, cycled []string, err err
 
 // Get returns the Plugin with the given name, or nil if not found.
 func (r *Registry) Get(name string) *Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.byName[name]
 }
 
 // All returns all plugins in topological dependency order.
 func (r *Registry) All() []*Plugin {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	out := make([]*Plugin, 0, len(r.order))
 	for _, name := range r.order {
 		if ext, ok := r.byName[name]; ok {
@@ -193,6 +235,151 @@ func (r *Registry) All() []*Plugin {
 	return out
 }
 
+// Excluded returns the plugins that loaded but were left out of the registry
+// because of a dependency cycle or an unmet version constraint, keyed by name.
+func (r *Registry) Excluded() map[string]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]error, len(r.excluded))
+	for name, err := range r.excluded {
+		out[name] = err
+	}
+	return out
+}
+
+// TrustDir marks every plugin loaded from dir as trusted. Used for the plugins
+// bundled with bud, which bypass approval and permission checks.
+func (r *Registry) TrustDir(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trusted = append(r.trusted, dir)
+	for _, ext := range r.byName {
+		if sandbox.Within(dir, ext.Dir) {
+			ext.Trusted = true
+		}
+	}
+}
+
+// SetApprovals sets the store consulted for user approval of plugin
+// permissions. Without one, every plugin is treated as approved and only its
+// declared permissions are enforced.
+func (r *Registry) SetApprovals(a *Approvals) {
+	r.approvals = a
+}
+
+// SetOnDenied sets a callback invoked for every permission denial.
+func (r *Registry) SetOnDenied(fn func(*PermissionError)) {
+	r.onDenied = fn
+}
+
+// Approved reports whether the user has approved ext's current permissions.
+func (r *Registry) Approved(ext *Plugin) bool {
+	if ext.Trusted || r.approvals == nil {
+		return true
+	}
+	a, ok := r.approvals.Get(ext.Manifest.Name)
+	return ok && a.Approved && a.Fingerprint == ext.Manifest.Permissions.Fingerprint()
+}
+
+// PendingApproval returns the plugins whose current permissions the user has
+// neither approved nor denied, in load order.
+func (r *Registry) PendingApproval() []*Plugin {
+	if r.approvals == nil {
+		return nil
+	}
+	var out []*Plugin
+	for _, ext := range r.All() {
+		if ext.Trusted {
+			continue
+		}
+		a, ok := r.approvals.Get(ext.Manifest.Name)
+		if !ok || a.Fingerprint != ext.Manifest.Permissions.Fingerprint() || a.DecidedAt.IsZero() {
+			out = append(out, ext)
+		}
+	}
+	return out
+}
+
+// NeedsPrompt reports whether the user should be asked to approve ext: its
+// current permissions are pending and the user has not been asked about them.
+func (r *Registry) NeedsPrompt(ext *Plugin) bool {
+	if ext.Trusted || r.approvals == nil {
+		return false
+	}
+	a, ok := r.approvals.Get(ext.Manifest.Name)
+	return !ok || a.Fingerprint != ext.Manifest.Permissions.Fingerprint() || (a.PromptedAt.IsZero() && a.DecidedAt.IsZero())
+}
+
+// MarkPrompted records that the user was asked to approve ext.
+func (r *Registry) MarkPrompted(ext *Plugin) error {
+	if r.approvals == nil {
+		return nil
+	}
+	return r.approvals.MarkPrompted(ext.Manifest.Name, ext.Manifest.Permissions.Fingerprint())
+}
+
+// Decide records the user's approval or denial of the named plugin's
+// current permissions.
+func (r *Registry) Decide(name string, approved bool) error {
+	ext := r.Get(name)
+	if ext == nil {
+		return fmt.Errorf("plugins: unknown plugin %q", name)
+	}
+	if r.approvals == nil {
+		return fmt.Errorf("plugins: no approval store configured")
+	}
+	return r.approvals.Decide(name, ext.Manifest.Permissions.Fingerprint(), approved)
+}
+
+// Authorize checks whether the named plugin may use permission on target
+// (a host, path, tool name, or for PermissionExecute the workflow or action
+// being run). Trusted plugins are always allowed; others must be approved and
+// must have declared the permission. Denials are returned as *PermissionError
+// and reported to the SetOnDenied callback.
+func (r *Registry) Authorize(name, permission, target string) error {
+	ext := r.Get(name)
+	if ext == nil {
+		return r.deny(name, permission, target, "unknown plugin")
+	}
+	return r.AuthorizePlugin(ext, permission, target)
+}
+
+// AuthorizePlugin is Authorize for a plugin the caller already holds.
+func (r *Registry) AuthorizePlugin(ext *Plugin, permission, target string) error {
+	name := ext.Manifest.Name
+	if ext.Trusted {
+		return nil
+	}
+	if !r.Approved(ext) {
+		return r.deny(name, permission, target, "permissions not approved by the user")
+	}
+	switch {
+	case permission == PermissionExecute:
+		return nil
+	case permission == PermissionTool && strings.HasPrefix(target, name+":"):
+		return nil // a plugin may always call its own actions
+	case ext.Manifest.Permissions.Allows(permission, target, ext.Dir):
+		return nil
+	}
+	return r.deny(name, permission, target, "not declared in plugin.yaml permissions")
+}
+
+// DeclaresNetwork reports whether the named plugin may reach the network at
+// all: it is trusted or declares network hosts.
+func (r *Registry) DeclaresNetwork(name string) bool {
+	ext := r.Get(name)
+	return ext != nil && (ext.Trusted || len(ext.Manifest.Permissions.Network) > 0)
+}
+
+func (r *Registry) deny(name, permission, target, reason string) error {
+	err := &PermissionError{Plugin: name, Permission: permission, Target: target, Reason: reason}
+	log.Printf("[plugins] %v", err)
+	if r.onDenied != nil {
+		r.onDenied(err)
+	}
+	return err
+}
+
 // Capabilities returns the names of all capabilities across all loaded plugins.
 // Names are in the form "<plugin-name>:<capability-name>".
 func (r *Registry) Capabilities() []string {
@@ -207,6 +394,8 @@ func (r *Registry) Capabilities() []string {
 
 // Len returns the number of plugins in the registry.
 func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return len(r.byName)
 }
 
@@ -219,8 +408,8 @@ func (r *Registry) GetCapabilityByFullName(fullName string) (*Capability, *Plugi
 	}
 	extName := fullName[:idx]
 	capName := fullName[idx+1:]
-	ext, ok := r.byName[extName]
-	if !ok {
+	ext := r.Get(extName)
+	if ext == nil {
 		return nil, nil, false
 	}
 	cap, ok := ext.Capabilities[capName]
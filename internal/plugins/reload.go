@@ -0,0 +1,295 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vthunder/bud2/internal/sandbox"
+)
+
+// DefaultReloadInterval is how often a Reloader rescans the plugin dirs.
+const DefaultReloadInterval = 5 * time.Second
+
+// snapshotPaths are the parts of a plugin dir that LoadPlugin reads. Runtime
+// files (state.json, settings.json, anything a script writes) are excluded so
+// they never trigger a reload.
+var snapshotPaths = []string{
+	filepath.Join(".bud-plugin", "plugin.yaml"),
+	"skills",
+	"agents",
+}
+
+// dirSnapshot fingerprints the files under dir that define a plugin, by path,
+// size and modification time. Two equal snapshots mean nothing changed.
+func dirSnapshot(dir string) string {
+	var lines []string
+	for _, rel := range snapshotPaths {
+		filepath.WalkDir(filepath.Join(dir, rel), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			lines = append(lines, fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano()))
+			return nil
+		})
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, l := range lines {
+		h.Write([]byte(l + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReloadResult describes what a Registry.Reload changed. Added and Changed
+// are in dependency order.
+type ReloadResult struct {
+	Added   []*Plugin
+	Changed []*Plugin
+	Removed []*Plugin
+	// Previous holds the replaced versions of changed and removed plugins.
+	Previous map[string]*Plugin
+	// Failed maps a plugin dir to the reason its new version was rejected.
+	// A plugin that was already loaded keeps its previous version.
+	Failed map[string]error
+}
+
+// Empty reports whether the reload left the registry unchanged.
+func (r *ReloadResult) Empty() bool {
+	return len(r.Added) == 0 && len(r.Changed) == 0 && len(r.Removed) == 0
+}
+
+// Reload rescans the dirs the registry was loaded from. Plugins whose files
+// are unchanged keep their current instance; changed ones are loaded afresh.
+// A plugin whose new version fails to load, or introduces a dependency
+// cycle, is rolled back to its previous version. The new plugin set replaces
+// the old one atomically, so readers see either the old or the new registry.
+func (r *Registry) Reload() (*ReloadResult, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.RLock()
+	current := make(map[string]*Plugin, len(r.byName))
+	byDir := make(map[string]*Plugin, len(r.byName))
+	for name, ext := range r.byName {
+		current[name] = ext
+		byDir[ext.Dir] = ext
+	}
+	currentOrder := append([]string(nil), r.order...)
+	dirs, trusted := r.dirs, append([]string(nil), r.trusted...)
+	r.mu.RUnlock()
+
+	res := &ReloadResult{Previous: make(map[string]*Plugin), Failed: make(map[string]error)}
+	load := func(extDir string) (*Plugin, error) {
+		old := byDir[extDir]
+		if old != nil && old.snapshot == dirSnapshot(extDir) {
+			return old, nil
+		}
+		ext, err := LoadPlugin(extDir)
+		if err != nil {
+			res.Failed[extDir] = err
+			if old != nil {
+				log.Printf("[plugins] %s: reload failed, keeping previous version: %v", old.Manifest.Name, err)
+				return old, nil
+			}
+			return nil, err
+		}
+		for _, dir := range trusted {
+			if sandbox.Within(dir, ext.Dir) {
+				ext.Trusted = true
+			}
+		}
+		return ext, nil
+	}
+
+	next := make(map[string]*Plugin)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := loadDir(dir, next, load); err != nil {
+			return nil, fmt.Errorf("plugins: reloading dir %s: %w", dir, err)
+		}
+	}
+
+	// Roll back changed plugins whose new requires.plugins close a cycle.
+	if _, cycled, _ := topoSort(next); len(cycled) > 0 {
+		for _, name := range cycled {
+			if old, ok := current[name]; ok && next[name] != old {
+				res.Failed[next[name].Dir] = fmt.Errorf("plugins: %s: new version introduces a dependency cycle", name)
+				log.Printf("[plugins] %s: new version introduces a dependency cycle, keeping previous version", name)
+				next[name] = old
+			}
+		}
+	}
+	// Roll back changed plugins whose new version breaks a dependent's
+	// requires.plugins version constraint.
+	for _, e := range CheckRequirements(next) {
+		dep := next[e.Dependency]
+		if old, ok := current[e.Dependency]; ok && dep != old {
+			res.Failed[dep.Dir] = e
+			log.Printf("[plugins] %s: keeping previous version: %v", e.Dependency, e)
+			next[e.Dependency] = old
+		}
+	}
+	order, excluded, err := sortPlugins(next)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range order {
+		old, ok := current[name]
+		switch {
+		case !ok:
+			res.Added = append(res.Added, next[name])
+		case old != next[name]:
+			res.Changed = append(res.Changed, next[name])
+			res.Previous[name] = old
+		}
+	}
+	for _, name := range currentOrder {
+		if _, ok := next[name]; !ok {
+			res.Removed = append(res.Removed, current[name])
+			res.Previous[name] = current[name]
+		}
+	}
+
+	r.mu.Lock()
+	r.byName = next
+	r.order = order
+	r.excluded = excluded
+	r.mu.Unlock()
+	return res, nil
+}
+
+// Reloader polls the plugin dirs and applies changes without restarting the
+// daemon: behaviors are re-registered with the dispatcher, action tools are
+// refreshed, changed mcp_servers are restarted and install or upgrade hooks
+// run. Each collaborator is optional.
+type Reloader struct {
+	registry   *Registry
+	dispatcher *Dispatcher
+	actions    *ActionProxy
+	servers    *MCPServers
+	lifecycle  *Lifecycle
+	onReload   func(*ReloadResult)
+	interval   time.Duration
+
+	mu   sync.Mutex // serializes Check
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewReloader creates a Reloader for registry.
+func NewReloader(registry *Registry) *Reloader {
+	return &Reloader{
+		registry: registry,
+		interval: DefaultReloadInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// SetDispatcher sets the dispatcher whose behavior registrations are updated.
+func (w *Reloader) SetDispatcher(d *Dispatcher) { w.dispatcher = d }
+
+// SetActionProxy sets the action proxy refreshed after each reload.
+func (w *Reloader) SetActionProxy(p *ActionProxy) { w.actions = p }
+
+// SetMCPServers sets the manager that restarts plugin mcp_servers.
+func (w *Reloader) SetMCPServers(s *MCPServers) { w.servers = s }
+
+// SetLifecycle sets the Lifecycle that runs install and upgrade hooks.
+func (w *Reloader) SetLifecycle(l *Lifecycle) { w.lifecycle = l }
+
+// SetOnReload sets a callback invoked after each reload that changed something.
+func (w *Reloader) SetOnReload(fn func(*ReloadResult)) { w.onReload = fn }
+
+// Start begins polling in a goroutine.
+func (w *Reloader) Start() {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.Check(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop ends polling.
+func (w *Reloader) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// Check reloads the registry and applies any changes.
+func (w *Reloader) Check(ctx context.Context) (*ReloadResult, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res, err := w.registry.Reload()
+	if err != nil {
+		log.Printf("[plugins] Reload failed: %v", err)
+		return nil, err
+	}
+	if res.Empty() {
+		return res, nil
+	}
+
+	for _, ext := range res.Removed {
+		name := ext.Manifest.Name
+		if w.dispatcher != nil {
+			w.dispatcher.UnregisterPlugin(name)
+		}
+		if w.servers != nil {
+			w.servers.Stop(name)
+		}
+		log.Printf("[plugins] Unloaded %s", name)
+	}
+	if w.actions != nil {
+		w.actions.Refresh()
+	}
+
+	loaded := append(append([]*Plugin(nil), res.Added...), res.Changed...)
+	for _, ext := range loaded {
+		name := ext.Manifest.Name
+		if w.servers != nil {
+			old := res.Previous[name]
+			// Changed permissions need a new approval before servers run.
+			if old == nil || !reflect.DeepEqual(old.Manifest.MCPServers, ext.Manifest.MCPServers) ||
+				!reflect.DeepEqual(old.Manifest.Permissions, ext.Manifest.Permissions) {
+				w.servers.Stop(name)
+				w.servers.Start(ext)
+			}
+		}
+		if w.lifecycle != nil {
+			w.lifecycle.Sync(ctx, ext)
+		}
+		if w.dispatcher != nil {
+			if err := w.dispatcher.RegisterPlugin(ctx, ext); err != nil {
+				log.Printf("[plugins] %s: registration failed: %v", name, err)
+			}
+		}
+		log.Printf("[plugins] Loaded %s (version %q)", name, ext.Manifest.Version)
+	}
+
+	if w.onReload != nil {
+		w.onReload(res)
+	}
+	return res, nil
+}
@@ -0,0 +1,178 @@
+package plugins_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+// writeReloadPlugin writes <root>/<name>/.bud-plugin/plugin.yaml and pushes
+// its mtime forward so a rewrite within the same clock tick is still seen.
+func writeReloadPlugin(t *testing.T, root, name string, fields map[string]any) {
+	t.Helper()
+	data := map[string]any{"name": name, "description": "test"}
+	for k, v := range fields {
+		data[k] = v
+	}
+	dir := filepath.Join(root, name)
+	writePluginYAML(t, dir, data)
+	bump(t, filepath.Join(dir, ".bud-plugin", "plugin.yaml"))
+}
+
+var bumpCount int
+
+func bump(t *testing.T, path string) {
+	t.Helper()
+	bumpCount++
+	future := time.Now().Add(time.Duration(bumpCount) * time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+}
+
+func TestRegistry_ReloadAddChangeRemove(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "alpha", map[string]any{"version": "1.0.0"})
+	writeReloadPlugin(t, root, "beta", nil)
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	beta := reg.Get("beta")
+
+	res, err := reg.Reload()
+	if err != nil || !res.Empty() {
+		t.Fatalf("expected no-op reload, got %+v, %v", res, err)
+	}
+
+	writeReloadPlugin(t, root, "alpha", map[string]any{"version": "2.0.0"})
+	writeReloadPlugin(t, root, "gamma", nil)
+	if err := os.RemoveAll(filepath.Join(root, "beta")); err != nil {
+		t.Fatal(err)
+	}
+	res, err = reg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(res.Added) != 1 || res.Added[0].Manifest.Name != "gamma" {
+		t.Errorf("expected gamma added, got %v", res.Added)
+	}
+	if len(res.Changed) != 1 || res.Changed[0].Manifest.Version != "2.0.0" {
+		t.Errorf("expected alpha changed to 2.0.0, got %v", res.Changed)
+	}
+	if len(res.Removed) != 1 || res.Removed[0] != beta {
+		t.Errorf("expected beta removed, got %v", res.Removed)
+	}
+	if res.Previous["alpha"].Manifest.Version != "1.0.0" {
+		t.Errorf("expected previous alpha 1.0.0, got %+v", res.Previous["alpha"])
+	}
+	if reg.Get("beta") != nil || reg.Get("alpha").Manifest.Version != "2.0.0" || reg.Len() != 2 {
+		t.Errorf("registry not updated: len=%d", reg.Len())
+	}
+}
+
+func TestRegistry_ReloadRollsBackBrokenPlugin(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "alpha", map[string]any{"version": "1.0.0"})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	old := reg.Get("alpha")
+
+	manifest := filepath.Join(root, "alpha", ".bud-plugin", "plugin.yaml")
+	if err := os.WriteFile(manifest, []byte("name: [unterminated\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bump(t, manifest)
+	res, err := reg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !res.Empty() {
+		t.Errorf("expected broken plugin to be rolled back, got %+v", res)
+	}
+	if res.Failed[filepath.Join(root, "alpha")] == nil {
+		t.Errorf("expected load failure to be reported, got %v", res.Failed)
+	}
+	if reg.Get("alpha") != old {
+		t.Error("expected previous version to stay loaded")
+	}
+
+	// Fixing the plugin loads the new version.
+	writeReloadPlugin(t, root, "alpha", map[string]any{"version": "1.0.1"})
+	if res, _ := reg.Reload(); len(res.Changed) != 1 {
+		t.Errorf("expected fixed plugin to load, got %+v", res)
+	}
+}
+
+func TestRegistry_ReloadRollsBackDependencyCycle(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "alpha", map[string]any{"requires": map[string]any{"plugins": []any{"beta"}}})
+	writeReloadPlugin(t, root, "beta", nil)
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	old := reg.Get("beta")
+
+	writeReloadPlugin(t, root, "beta", map[string]any{"requires": map[string]any{"plugins": []any{"alpha"}}})
+	res, err := reg.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if reg.Get("beta") != old || reg.Get("alpha") == nil {
+		t.Error("expected cycle-introducing beta to be rolled back and alpha kept")
+	}
+	if res.Failed[filepath.Join(root, "beta")] == nil {
+		t.Errorf("expected cycle to be reported, got %v", res.Failed)
+	}
+}
+
+func TestReloader_RefreshesActionsAndBehaviors(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "test-ext", map[string]any{"version": "1.0.0"})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	proxy := plugins.NewActionProxy(reg)
+	runner := &recordingRunner{}
+	lc := plugins.NewLifecycle(reg, runner, filepath.Join(t.TempDir(), "installs.json"))
+	lc.Start(context.Background())
+
+	dir := filepath.Join(root, "test-ext")
+	writeCapabilityYAMLRaw(t, dir, "echo", []byte(fmt.Sprintf(
+		"name: echo\ndescription: test\ntype: action\ncallable_from: direct\nrun: %s\n", makeEchoScript(t, dir))))
+	writeReloadPlugin(t, root, "test-ext", map[string]any{
+		"version":   "1.1.0",
+		"lifecycle": map[string]any{"upgrade": "migrate"},
+	})
+
+	w := plugins.NewReloader(reg)
+	w.SetActionProxy(proxy)
+	w.SetLifecycle(lc)
+	var reloaded *plugins.ReloadResult
+	w.SetOnReload(func(res *plugins.ReloadResult) { reloaded = res })
+	if _, err := w.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	if reloaded == nil || len(reloaded.Changed) != 1 {
+		t.Fatalf("expected one changed plugin, got %+v", reloaded)
+	}
+	if !proxy.HasAction("test-ext:echo") {
+		t.Error("expected new action to be picked up")
+	}
+	if len(runner.calls) != 1 || runner.calls[0] != "test-ext:migrate" {
+		t.Errorf("expected upgrade hook to run, got %v", runner.calls)
+	}
+	if out, err := proxy.Call("test-ext:echo", map[string]any{"x": 1}); err != nil || out == "" {
+		t.Errorf("Call after reload: %q, %v", out, err)
+	}
+}
@@ -0,0 +1,277 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+// Catch-up policies for schedule triggers: what to do about slots missed
+// while the daemon was down or the machine asleep.
+const (
+	CatchUpNone = "none" // skip missed slots (default)
+	CatchUpOnce = "once" // fire once, however many slots were missed
+	CatchUpAll  = "all"  // fire every missed slot, oldest first
+)
+
+// maxCatchUp bounds how many missed slots catch_up: all replays; older ones
+// are dropped.
+const maxCatchUp = 100
+
+// scheduleStatePrefix prefixes the plugin state key holding a schedule
+// behavior's last run. Underscore keys are reserved for the runtime.
+const scheduleStatePrefix = "_schedule:"
+
+// scheduleTrigger is a parsed schedule trigger: either a bare cron string or
+// a map with cron, catch_up, jitter and timezone.
+type scheduleTrigger struct {
+	cron    string
+	sched   *ParsedCron
+	loc     *time.Location
+	catchUp string
+	jitter  time.Duration
+}
+
+func parseScheduleTrigger(val any) (*scheduleTrigger, error) {
+	trig := &scheduleTrigger{loc: time.Local, catchUp: CatchUpNone}
+	m, _ := val.(map[string]any)
+	if s, ok := val.(string); ok {
+		trig.cron = s
+	} else if m != nil {
+		trig.cron, _ = m["cron"].(string)
+	}
+	if trig.cron == "" {
+		return nil, fmt.Errorf("schedule trigger missing cron expression")
+	}
+	sched, err := parseCron(trig.cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron %q: %w", trig.cron, err)
+	}
+	trig.sched = sched
+
+	if tz, _ := m["timezone"].(string); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", tz, err)
+		}
+		trig.loc = loc
+	}
+	if c, _ := m["catch_up"].(string); c != "" {
+		if c != CatchUpNone && c != CatchUpOnce && c != CatchUpAll {
+			return nil, fmt.Errorf("invalid catch_up %q: must be %q, %q or %q", c, CatchUpOnce, CatchUpAll, CatchUpNone)
+		}
+		trig.catchUp = c
+	}
+	if j, _ := m["jitter"].(string); j != "" {
+		jitter, err := time.ParseDuration(j)
+		if err != nil || jitter < 0 {
+			return nil, fmt.Errorf("invalid jitter %q", j)
+		}
+		trig.jitter = jitter
+	}
+	return trig, nil
+}
+
+// scheduledFire is one firing decided by scheduleTrigger.due.
+type scheduledFire struct {
+	slot    time.Time
+	catchUp bool
+	missed  int // slots this fire stands in for (catch_up: once)
+}
+
+func (f scheduledFire) params() map[string]any {
+	p := map[string]any{
+		"scheduled_at": f.slot.Format(time.RFC3339),
+		"catch_up":     f.catchUp,
+	}
+	if f.missed > 0 {
+		p["missed"] = f.missed
+	}
+	return p
+}
+
+// due returns the fires owed for the slots after last up to now, applying
+// the catch-up policy, and the newest slot, which becomes the new last run
+// (zero if there are no slots). A slot in the current minute is on time;
+// older ones were missed.
+func (s *scheduleTrigger) due(last, now time.Time) ([]scheduledFire, time.Time) {
+	slots, total := s.sched.slotsBetween(last.In(s.loc), now.In(s.loc), maxCatchUp+1)
+	if total == 0 {
+		return nil, time.Time{}
+	}
+	latest := slots[len(slots)-1]
+	onTime := now.Sub(latest) < time.Minute
+	missed, missedSlots := total, slots
+	if onTime {
+		missed, missedSlots = total-1, slots[:len(slots)-1]
+	}
+
+	var fires []scheduledFire
+	switch s.catchUp {
+	case CatchUpOnce:
+		if missed > 0 && !onTime {
+			fires = append(fires, scheduledFire{slot: latest, catchUp: true, missed: missed})
+		}
+	case CatchUpAll:
+		if len(missedSlots) > maxCatchUp {
+			missedSlots = missedSlots[len(missedSlots)-maxCatchUp:]
+		}
+		if dropped := missed - len(missedSlots); dropped > 0 {
+			log.Printf("[triggers] schedule %q: dropping %d missed run(s) beyond the catch-up limit of %d", s.cron, dropped, maxCatchUp)
+		}
+		for _, slot := range missedSlots {
+			fires = append(fires, scheduledFire{slot: slot, catchUp: true})
+		}
+	}
+	if onTime {
+		fires = append(fires, scheduledFire{slot: latest})
+	}
+	return fires, latest
+}
+
+// runSchedule fires action for each slot of the schedule. It ticks on start
+// and then every 30 seconds aligned to the minute. Each tick handles every
+// slot since last, so runs missed while the machine slept are subject to the
+// catch-up policy like those missed while the daemon was down. save persists
+// the newest handled slot before its fires run.
+func runSchedule(ctx context.Context, trig *scheduleTrigger, last time.Time, save func(time.Time), stop <-chan struct{}, action func(params map[string]any)) {
+	if last.IsZero() {
+		// Never run: start with the current minute, which fires if it matches.
+		last = time.Now().Truncate(time.Minute).Add(-time.Minute)
+	}
+
+	// wait sleeps for d, reporting false if stopped meanwhile.
+	wait := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	tick := func() bool {
+		fires, latest := trig.due(last, time.Now())
+		if latest.IsZero() {
+			return true
+		}
+		last = latest
+		save(latest)
+		for _, f := range fires {
+			if trig.jitter > 0 && !wait(rand.N(trig.jitter)) {
+				return false
+			}
+			action(f.params())
+		}
+		return true
+	}
+
+	// Catch up straight away, then align to the next 30-second boundary.
+	if !tick() {
+		return
+	}
+	now := time.Now()
+	if !wait(time.Duration(30-now.Second()%30) * time.Second) {
+		return
+	}
+	if !tick() {
+		return
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !tick() {
+				return
+			}
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lastScheduleRun returns the persisted last run of a schedule behavior, or
+// the zero time if it has never run.
+func lastScheduleRun(ext *Plugin, behavior string) time.Time {
+	s, _ := ext.StateGet(scheduleStatePrefix + behavior).(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// saveScheduleRun persists the last run of a schedule behavior in the
+// plugin's state.
+func saveScheduleRun(ext *Plugin, behavior string, t time.Time) {
+	if err := ext.StateSet(scheduleStatePrefix+behavior, t.Format(time.RFC3339)); err != nil {
+		log.Printf("[triggers] %s/%s: failed to save last run: %v", ext.Manifest.Name, behavior, err)
+	}
+}
+
+// ScheduledRun is an upcoming firing of a schedule behavior.
+type ScheduledRun struct {
+	Plugin   string    `json:"plugin"`
+	Behavior string    `json:"behavior"`
+	Workflow string    `json:"workflow"`
+	Cron     string    `json:"cron"`
+	Timezone string    `json:"timezone"`
+	Jitter   string    `json:"jitter,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// NextRuns returns the next n firings across the schedule behaviors of all
+// enabled plugins (or only the named one), soonest first. Jitter is not
+// applied to At.
+func (d *Dispatcher) NextRuns(plugin string, n int) []ScheduledRun {
+	now := time.Now()
+	var runs []ScheduledRun
+	for _, ext := range d.registry.All() {
+		if (plugin != "" && ext.Manifest.Name != plugin) || !ext.Enabled() {
+			continue
+		}
+		for _, beh := range ext.Manifest.Behaviors {
+			typ, val := parseTriggerType(beh.Trigger)
+			if typ != "schedule" {
+				continue
+			}
+			trig, err := parseScheduleTrigger(val)
+			if err != nil {
+				continue
+			}
+			run := ScheduledRun{
+				Plugin:   ext.Manifest.Name,
+				Behavior: beh.Name,
+				Workflow: beh.Workflow,
+				Cron:     trig.cron,
+				Timezone: trig.loc.String(),
+			}
+			if trig.jitter > 0 {
+				run.Jitter = trig.jitter.String()
+			}
+			t := now.In(trig.loc)
+			for i := 0; i < n; i++ {
+				if t = trig.sched.Next(t); t.IsZero() {
+					break
+				}
+				run.At = t
+				runs = append(runs, run)
+			}
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].At.Before(runs[j].At) })
+	if len(runs) > n {
+		runs = runs[:n]
+	}
+	return runs
+}
@@ -0,0 +1,157 @@
+package plugins_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestParsedCron_Next(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	cases := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 4, 21, 10, 31, 20, 0, time.UTC), time.Date(2026, 4, 21, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 4, 21, 9, 0, 0, 0, time.UTC), time.Date(2026, 4, 22, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1", time.Date(2026, 4, 21, 9, 0, 0, 0, time.UTC), time.Date(2026, 4, 27, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2026, 4, 21, 9, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2026, 4, 21, 1, 0, 0, 0, tokyo), time.Date(2026, 4, 21, 9, 30, 0, 0, tokyo)},
+	}
+	for _, c := range cases {
+		sched, err := plugins.ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.expr, err)
+		}
+		if got := sched.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%q after %v = %v, want %v", c.expr, c.from, got, c.want)
+		}
+	}
+
+	never, _ := plugins.ParseCron("0 0 31 2 *")
+	if got := never.Next(time.Now()); !got.IsZero() {
+		t.Errorf("expected no match for Feb 31, got %v", got)
+	}
+}
+
+func TestDispatcher_ScheduleCatchUp(t *testing.T) {
+	now := time.Now().UTC()
+	if now.Month() == time.January && now.Day() == 1 && now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("the yearly slot is on time right now")
+	}
+
+	runner := &fakeRunner{}
+	d := plugins.NewDispatcher(newMinimalRegistry(t), plugins.NewEventBus(), runner)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	yearly := func(catchUp string) map[string]any {
+		return map[string]any{"schedule": map[string]any{
+			"cron":     "0 0 1 1 *",
+			"timezone": "UTC",
+			"catch_up": catchUp,
+		}}
+	}
+	ext := makeTestPlugin(t, []plugins.Behavior{
+		{Name: "all", Trigger: yearly("all"), Workflow: "run-all"},
+		{Name: "once", Trigger: yearly("once"), Workflow: "run-once"},
+		{Name: "none", Trigger: yearly("none"), Workflow: "run-none"},
+	})
+	// Last ran just after New Year three years ago: three slots were missed.
+	last := time.Date(now.Year()-3, 1, 2, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	for _, name := range []string{"all", "once", "none"} {
+		if err := ext.StateSet("_schedule:"+name, last); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.RegisterPlugin(ctx, ext); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+	for i := 0; i < 20 && runner.count() < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	d.Stop()
+
+	counts := make(map[string]int)
+	runner.mu.Lock()
+	for _, c := range runner.calls {
+		counts[c.name]++
+		if c.params["catch_up"] != true {
+			t.Errorf("%s: expected catch_up param, got %v", c.name, c.params)
+		}
+		if c.name == "run-once" && c.params["missed"] != 3 {
+			t.Errorf("run-once: expected missed=3, got %v", c.params["missed"])
+		}
+	}
+	runner.mu.Unlock()
+	if counts["run-all"] != 3 || counts["run-once"] != 1 || counts["run-none"] != 0 {
+		t.Errorf("unexpected catch-up runs: %v", counts)
+	}
+
+	// Every policy advances the last run to the newest slot.
+	want := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	for _, name := range []string{"all", "once", "none"} {
+		if got := ext.StateGet("_schedule:" + name); got != want {
+			t.Errorf("%s: last run = %v, want %s", name, got, want)
+		}
+	}
+}
+
+func TestDispatcher_NextRuns(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "briefing", map[string]any{
+		"behaviors": []any{
+			map[string]any{
+				"name":     "morning",
+				"trigger":  map[string]any{"schedule": map[string]any{"cron": "30 9 * * *", "timezone": "Asia/Tokyo", "jitter": "5m"}},
+				"workflow": "briefing:morning",
+			},
+			map[string]any{
+				"name":     "hourly",
+				"trigger":  map[string]any{"schedule": "0 * * * *"},
+				"workflow": "briefing:hourly",
+			},
+		},
+	})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	d := plugins.NewDispatcher(reg, plugins.NewEventBus(), &fakeRunner{})
+
+	runs := d.NextRuns("briefing", 30)
+	if len(runs) != 30 {
+		t.Fatalf("expected 30 runs, got %d", len(runs))
+	}
+	mornings := 0
+	for i, run := range runs {
+		if i > 0 && run.At.Before(runs[i-1].At) {
+			t.Errorf("runs not sorted: %v before %v", runs[i-1].At, run.At)
+		}
+		if run.Behavior != "morning" {
+			continue
+		}
+		mornings++
+		if at := run.At.In(tokyo); at.Hour() != 9 || at.Minute() != 30 || run.Jitter != "5m0s" {
+			t.Errorf("unexpected morning run %+v", run)
+		}
+	}
+	if mornings < 1 || mornings > 2 {
+		t.Errorf("expected one or two morning runs in the next 30, got %d", mornings)
+	}
+	if runs := d.NextRuns("other", 5); len(runs) != 0 {
+		t.Errorf("expected no runs for unknown plugin, got %d", len(runs))
+	}
+}
@@ -0,0 +1,259 @@
+package plugins
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version (major.minor.patch[-prerelease]).
+// Build metadata (+...) is accepted and ignored.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// ParseVersion parses a semantic version. A leading "v" is allowed, and
+// missing minor or patch components default to 0 ("1.2" is 1.2.0).
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(raw, '+'); i >= 0 {
+		raw = raw[:i]
+	}
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		v.Pre = raw[i+1:]
+		raw = raw[:i]
+	}
+	parts := strings.Split(raw, ".")
+	if raw == "" || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// A prerelease sorts before its release (1.0.0-rc1 < 1.0.0).
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	}
+	return 1
+}
+
+// Constraint is a version range such as "^1.2", "~1.4.0", ">=1.0 <2.0",
+// "1.x" or "^1 || ^2". Space-separated comparators must all hold; "||"
+// separates alternatives.
+type Constraint struct {
+	raw  string
+	alts [][]comparator
+}
+
+type comparator struct {
+	op string // one of = > >= < <=
+	v  Version
+}
+
+// ParseConstraint parses a version constraint. "" and "*" allow any version.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	for _, alt := range strings.Split(c.raw, "||") {
+		var cmps []comparator
+		for _, term := range strings.Fields(alt) {
+			expanded, err := parseTerm(term)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			cmps = append(cmps, expanded...)
+		}
+		c.alts = append(c.alts, cmps)
+	}
+	return c, nil
+}
+
+// parseTerm expands one constraint term into plain comparators.
+func parseTerm(term string) ([]comparator, error) {
+	if term == "*" || term == "x" {
+		return nil, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(term, op) {
+			v, err := ParseVersion(term[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			return []comparator{{op, v}}, nil
+		}
+	}
+
+	prefix := term[0]
+	if prefix == '^' || prefix == '~' {
+		term = term[1:]
+	}
+	// Count the components actually given: "1.x", "1.2" and "1" are partial.
+	parts := strings.Split(strings.TrimPrefix(term, "v"), ".")
+	given := 0
+	for _, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		given++
+	}
+	v, err := ParseVersion(strings.Join(parts[:given], "."))
+	if given == 0 || err != nil {
+		return nil, fmt.Errorf("invalid version %q", term)
+	}
+	var upper Version
+	switch {
+	case given == 1 || (prefix == '^' && v.Major > 0):
+		upper = Version{Major: v.Major + 1}
+	case given == 2 || prefix == '~' || (prefix == '^' && v.Minor > 0):
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	case prefix == '^':
+		upper = Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	default:
+		return []comparator{{"=", v}}, nil // bare full version
+	}
+	return []comparator{{">=", v}, {"<", upper}}, nil
+}
+
+// Allows reports whether v satisfies the constraint.
+func (c Constraint) Allows(v Version) bool {
+	for _, alt := range c.alts {
+		ok := true
+		for _, cmp := range alt {
+			if !cmp.allows(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return len(c.alts) == 0
+}
+
+func (c comparator) allows(v Version) bool {
+	d := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return d == 0
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	}
+	return d <= 0
+}
+
+func (c Constraint) String() string { return c.raw }
+
+// ParseRequirement splits a requires.plugins entry into the plugin name and
+// its version constraint. Entries are "name", "name@constraint" or
+// "name constraint" (e.g. "calendar@^1.2", "calendar >=1.0 <2").
+func ParseRequirement(s string) (string, Constraint, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, "@ ")
+	if i < 0 {
+		return s, Constraint{}, nil
+	}
+	c, err := ParseConstraint(s[i+1:])
+	return s[:i], c, err
+}
+
+// validateVersions warns about a manifest version that is not semver and
+// requires.plugins constraints that do not parse. Neither fails the load: an
+// unparseable constraint is ignored, and a non-semver version satisfies no
+// constraint.
+func validateVersions(m Manifest) {
+	if m.Version != "" {
+		if _, err := ParseVersion(m.Version); err != nil {
+			log.Printf("plugins: %s: version %q is not semver; constraints on it will not match", m.Name, m.Version)
+		}
+	}
+	for _, req := range m.Requires.Plugins {
+		if _, _, err := ParseRequirement(req); err != nil {
+			log.Printf("plugins: %s: requires.plugins %q: %v (constraint ignored)", m.Name, req, err)
+		}
+	}
+}
+
+// requirementName returns the plugin name of a requires.plugins entry.
+func requirementName(s string) string {
+	name, _, _ := ParseRequirement(s)
+	return name
+}
+
+// RequirementError reports a plugin whose requires.plugins constraint is not
+// met by the loaded version of the dependency.
+type RequirementError struct {
+	Plugin     string
+	Dependency string
+	Constraint string
+	Found      string // the dependency's version, "" if unversioned
+}
+
+func (e *RequirementError) Error() string {
+	found := e.Found
+	if found == "" {
+		found = "no version"
+	}
+	return fmt.Sprintf("plugins: %s requires %s %s, found %s", e.Plugin, e.Dependency, e.Constraint, found)
+}
+
+// CheckRequirements returns an error for every requires.plugins constraint
+// in exts that the loaded dependency does not satisfy. Dependencies that are
+// not loaded are not reported; topoSort already warns about them.
+func CheckRequirements(exts map[string]*Plugin) []*RequirementError {
+	var errs []*RequirementError
+	for name, ext := range exts {
+		for _, req := range ext.Manifest.Requires.Plugins {
+			depName, c, err := ParseRequirement(req)
+			dep, ok := exts[depName]
+			if err != nil || !ok || c.raw == "" {
+				continue
+			}
+			v, verr := ParseVersion(dep.Manifest.Version)
+			if verr == nil && c.Allows(v) {
+				continue
+			}
+			errs = append(errs, &RequirementError{Plugin: name, Dependency: depName, Constraint: c.raw, Found: dep.Manifest.Version})
+		}
+	}
+	return errs
+}
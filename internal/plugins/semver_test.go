@@ -0,0 +1,111 @@
+package plugins_test
+
+import (
+	"testing"
+
+	"github.com/vthunder/bud2/internal/plugins"
+)
+
+func TestConstraint_Allows(t *testing.T) {
+	cases := []struct {
+		constraint, version string
+		want                bool
+	}{
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.9.3", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^0.2.1", "0.2.5", true},
+		{"^0.2.1", "0.3.0", false},
+		{"~1.4.0", "1.4.7", true},
+		{"~1.4.0", "1.5.0", false},
+		{">=1.0 <2.0", "1.5.0", true},
+		{">=1.0 <2.0", "2.0.0", false},
+		{"1.x", "1.7.2", true},
+		{"1.x", "2.0.0", false},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"^1 || ^3", "3.1.0", true},
+		{"^1 || ^3", "2.1.0", false},
+		{"*", "9.9.9", true},
+		{"^1.0.0", "1.0.0-rc1", false},
+		{">=1.0.0-rc1", "1.0.0-rc2", true},
+		{"^1.0", "v1.4.0", true},
+	}
+	for _, c := range cases {
+		con, err := plugins.ParseConstraint(c.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q): %v", c.constraint, err)
+		}
+		v, err := plugins.ParseVersion(c.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q): %v", c.version, err)
+		}
+		if got := con.Allows(v); got != c.want {
+			t.Errorf("%q allows %q = %v, want %v", c.constraint, c.version, got, c.want)
+		}
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	for _, s := range []string{"calendar@^1.2", "calendar ^1.2"} {
+		name, c, err := plugins.ParseRequirement(s)
+		if err != nil || name != "calendar" || c.String() != "^1.2" {
+			t.Errorf("ParseRequirement(%q) = %q, %q, %v", s, name, c, err)
+		}
+	}
+	if name, c, err := plugins.ParseRequirement("calendar"); err != nil || name != "calendar" || c.String() != "" {
+		t.Errorf("bare requirement: %q, %q, %v", name, c, err)
+	}
+	if _, _, err := plugins.ParseRequirement("calendar@^x"); err == nil {
+		t.Error("expected invalid constraint to fail")
+	}
+}
+
+func TestLoadAll_ExcludesUnmetVersionConstraint(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "calendar", map[string]any{"version": "1.4.0"})
+	writeReloadPlugin(t, root, "agenda", map[string]any{"requires": map[string]any{"plugins": []any{"calendar@^1.2"}}})
+	writeReloadPlugin(t, root, "legacy", map[string]any{"requires": map[string]any{"plugins": []any{"calendar@^2"}}})
+	writeReloadPlugin(t, root, "legacy-ui", map[string]any{"requires": map[string]any{"plugins": []any{"legacy"}}})
+
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if reg.Get("agenda") == nil {
+		t.Error("expected agenda (constraint met) to load")
+	}
+	if reg.Get("legacy") != nil {
+		t.Error("expected legacy (constraint unmet) to be excluded")
+	}
+	excluded := reg.Excluded()
+	if _, ok := excluded["legacy"].(*plugins.RequirementError); !ok {
+		t.Errorf("expected RequirementError for legacy, got %v", excluded["legacy"])
+	}
+	// legacy-ui has no version constraint, so losing its dependency only warns.
+	if reg.Get("legacy-ui") == nil {
+		t.Error("expected legacy-ui to load")
+	}
+}
+
+func TestRegistry_ReloadRollsBackConstraintBreak(t *testing.T) {
+	root := t.TempDir()
+	writeReloadPlugin(t, root, "calendar", map[string]any{"version": "1.4.0"})
+	writeReloadPlugin(t, root, "agenda", map[string]any{"requires": map[string]any{"plugins": []any{"calendar@^1.2"}}})
+	reg, err := plugins.LoadAll(root)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+
+	writeReloadPlugin(t, root, "calendar", map[string]any{"version": "2.0.0"})
+	if _, err := reg.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if v := reg.Get("calendar").Manifest.Version; v != "1.4.0" {
+		t.Errorf("expected calendar held at 1.4.0, got %s", v)
+	}
+	if reg.Get("agenda") == nil {
+		t.Error("expected agenda to stay loaded")
+	}
+}
@@ -38,6 +38,13 @@ type SaveThought interface {
 	Log(message string) error
 }
 
+// SystemEnv supplies daemon-wide variables (idle time, queue depths, token
+// usage, ...) to condition expressions. Vars is called on every condition
+// tick, so implementations should cache anything expensive.
+type SystemEnv interface {
+	Vars() map[string]any
+}
+
 // Dispatcher registers and fires behaviors from all loaded plugins.
 // It owns the EventBus and is the authority on trigger lifecycle.
 type Dispatcher struct {
@@ -46,6 +53,9 @@ type Dispatcher struct {
 	runner   WorkflowRunner
 	talker   TalkToUser
 	logger   SaveThought
+	env      SystemEnv
+
+	deadLetters *DeadLetters
 
 	mu          sync.Mutex
 	cancelFuncs map[string][]func() // extName → cancel/unsubscribe funcs
@@ -71,6 +81,9 @@ func (d *Dispatcher) SetTalkToUser(t TalkToUser) { d.talker = t }
 // SetSaveThought wires the on_result:action=log callback.
 func (d *Dispatcher) SetSaveThought(s SaveThought) { d.logger = s }
 
+// SetSystemEnv wires the daemon variables exposed to condition triggers.
+func (d *Dispatcher) SetSystemEnv(e SystemEnv) { d.env = e }
+
 // RegisterAll registers behaviors for every plugin currently in the registry.
 // Call this once after initial load.
 func (d *Dispatcher) RegisterAll(ctx context.Context) {
@@ -103,31 +116,25 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 		switch triggerType {
 
 		case "schedule":
-			cronExpr, _ := triggerVal.(string)
-			if cronExpr == "" {
-				if m, ok := triggerVal.(map[string]any); ok {
-					cronExpr, _ = m["cron"].(string)
-				}
-			}
-			if cronExpr == "" {
-				log.Printf("[triggers] %s/%s: schedule trigger missing cron expression", extName, beh.Name)
-				continue
-			}
-			sched, err := parseCron(cronExpr)
+			trig, err := parseScheduleTrigger(triggerVal)
 			if err != nil {
-				log.Printf("[triggers] %s/%s: invalid cron %q: %v", extName, beh.Name, cronExpr, err)
+				log.Printf("[triggers] %s/%s: %v", extName, beh.Name, err)
 				continue
 			}
 
+			last := lastScheduleRun(ext, beh.Name)
 			stopCh := make(chan struct{})
 			d.wg.Add(1)
 			go func() {
 				defer d.wg.Done()
-				runSchedule(ctx, sched, stopCh, func() {
-					log.Printf("[triggers] %s/%s: schedule fired, running %q", extName, beh.Name, workflow)
-					if _, err := d.runner.RunWorkflow(ctx, workflow, nil); err != nil {
-						log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, beh.Name, workflow, err)
+				save := func(t time.Time) { saveScheduleRun(ext, beh.Name, t) }
+				runSchedule(ctx, trig, last, save, stopCh, func(params map[string]any) {
+					if params["catch_up"] == true {
+						log.Printf("[triggers] %s/%s: catching up run scheduled at %v, running %q", extName, beh.Name, params["scheduled_at"], workflow)
+					} else {
+						log.Printf("[triggers] %s/%s: schedule fired, running %q", extName, beh.Name, workflow)
 					}
+					d.runBehavior(ctx, ext, beh.Name, workflow, params)
 				})
 			}()
 			cancels = append(cancels, func() { close(stopCh) })
@@ -147,9 +154,7 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 			unsub := d.bus.Subscribe("slash_command:"+cmdName+":invoke", func(e Event) {
 				log.Printf("[triggers] %s/%s: slash_command /%s fired, running %q", extName, beh.Name, cmdName, workflow)
 				params, _ := e.Payload["data"].(map[string]any)
-				if _, err := d.runner.RunWorkflow(ctx, workflow, params); err != nil {
-					log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, beh.Name, workflow, err)
-				}
+				d.runBehavior(ctx, ext, beh.Name, workflow, params)
 			})
 			cancels = append(cancels, unsub)
 			log.Printf("[triggers] %s/%s: registered slash command /%s → %q", extName, beh.Name, cmdName, workflow)
@@ -168,9 +173,7 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 
 			unsub := d.bus.Subscribe(topic, func(e Event) {
 				log.Printf("[triggers] %s/%s: event %q fired, running %q", extName, beh.Name, e.Topic, workflow)
-				if _, err := d.runner.RunWorkflow(ctx, workflow, e.Payload); err != nil {
-					log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, beh.Name, workflow, err)
-				}
+				d.runBehavior(ctx, ext, beh.Name, workflow, e.Payload)
 			})
 			cancels = append(cancels, unsub)
 			log.Printf("[triggers] %s/%s: subscribed to event %q → %q", extName, beh.Name, topic, workflow)
@@ -195,15 +198,27 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 				}
 			}
 
+			gate := &conditionGate{}
+			gate.edge, _ = condMap["edge"].(bool)
+			if cooldownStr, _ := condMap["cooldown"].(string); cooldownStr != "" {
+				cooldown, err := time.ParseDuration(cooldownStr)
+				if err != nil {
+					log.Printf("[triggers] %s/%s: invalid cooldown %q: %v", extName, beh.Name, cooldownStr, err)
+					continue
+				}
+				gate.cooldown = cooldown
+			}
+			loadConditionState(ext, beh.Name, gate)
+			gate.save = func(last bool, lastFire time.Time) { saveConditionState(ext, beh.Name, last, lastFire) }
+
 			stopCh := make(chan struct{})
 			d.wg.Add(1)
 			go func() {
 				defer d.wg.Done()
-				runCondition(ctx, exprStr, interval, stopCh, func(condVars map[string]any) {
+				env := func(t time.Time) map[string]any { return d.conditionEnv(ext, t) }
+				runCondition(ctx, exprStr, interval, gate, env, stopCh, func(condVars map[string]any) {
 					log.Printf("[triggers] %s/%s: condition %q matched, running %q", extName, beh.Name, exprStr, workflow)
-					if _, err := d.runner.RunWorkflow(ctx, workflow, condVars); err != nil {
-						log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, beh.Name, workflow, err)
-					}
+					d.runBehavior(ctx, ext, beh.Name, workflow, condVars)
 				})
 			}()
 			cancels = append(cancels, func() { close(stopCh) })
@@ -263,9 +278,7 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 				for k, v := range e.Payload {
 					data[k] = v
 				}
-				if _, err := d.runner.RunWorkflow(ctx, workflow, data); err != nil {
-					log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, beh.Name, workflow, err)
-				}
+				d.runBehavior(ctx, ext, beh.Name, workflow, data)
 			})
 			cancels = append(cancels, unsub)
 			log.Printf("[triggers] %s/%s: registered pattern_match %q → %q", extName, beh.Name, pattern, workflow)
@@ -286,6 +299,21 @@ func (d *Dispatcher) RegisterPlugin(ctx context.Context, ext *Plugin) error {
 	return nil
 }
 
+// runBehavior runs a behavior's workflow on behalf of ext, provided the
+// plugin is allowed to run at all (see Registry.Authorize). A failed run is
+// recorded in the dead-letter store.
+func (d *Dispatcher) runBehavior(ctx context.Context, ext *Plugin, behName, workflow string, params map[string]any) {
+	extName := ext.Manifest.Name
+	if err := d.registry.AuthorizePlugin(ext, PermissionExecute, workflow); err != nil {
+		log.Printf("[triggers] %s/%s: not running %q: %v", extName, behName, workflow, err)
+		return
+	}
+	if _, err := d.runner.RunWorkflow(WithPlugin(ctx, extName), workflow, params); err != nil {
+		log.Printf("[triggers] %s/%s: workflow %q error: %v", extName, behName, workflow, err)
+		d.recordFailure(ext, behName, workflow, params, err)
+	}
+}
+
 // UnregisterPlugin removes all trigger registrations for the named plugin.
 func (d *Dispatcher) UnregisterPlugin(name string) {
 	d.mu.Lock()
@@ -415,6 +443,7 @@ type OnResultConfig struct {
 // HandleOnResult applies a list of on_result handlers to the output of a workflow run.
 // result is the raw output (may be string, map, or nil).
 // vars provides template resolution context.
+// If ctx carries a plugin (WithPlugin), notify requires its message_user permission.
 func (d *Dispatcher) HandleOnResult(ctx context.Context, configs []OnResultConfig, result any, vars map[string]any) {
 	for _, cfg := range configs {
 		if err := d.applyOnResult(ctx, cfg, result, vars); err != nil {
@@ -470,6 +499,11 @@ func (d *Dispatcher) applyOnResult(ctx context.Context, cfg OnResultConfig, resu
 		if d.talker == nil {
 			return fmt.Errorf("on_result: notify action requires TalkToUser to be configured")
 		}
+		if extName, ok := PluginFromContext(ctx); ok {
+			if err := d.registry.Authorize(extName, PermissionMessageUser, "notify"); err != nil {
+				return err
+			}
+		}
 		msg, err := renderOnResultTemplate(cfg.Message, env)
 		if err != nil {
 			return fmt.Errorf("on_result: rendering message: %w", err)
@@ -702,68 +736,69 @@ func (f *cronField) matches(v int) bool {
 	return f.values[v]
 }
 
-// matchesFields returns true if all cron fields match the given time.
-func (c *ParsedCron) matchesFields(t time.Time) bool {
-	matchAny := func(fields []*cronField, v int) bool {
-		for _, f := range fields {
-			if f.matches(v) {
-				return true
-			}
+// matchAnyField returns true if any of fields matches v.
+func matchAnyField(fields []*cronField, v int) bool {
+	for _, f := range fields {
+		if f.matches(v) {
+			return true
 		}
-		return false
 	}
-	return matchAny(c.minute, t.Minute()) &&
-		matchAny(c.hour, t.Hour()) &&
-		matchAny(c.dom, t.Day()) &&
-		matchAny(c.month, int(t.Month())) &&
-		matchAny(c.dow, int(t.Weekday()))
+	return false
 }
 
-// runSchedule fires action on each minute boundary that matches the cron expression.
-// It ticks every 30 seconds aligned to the minute start to avoid double-fires.
-func runSchedule(ctx context.Context, sched *ParsedCron, stop <-chan struct{}, action func()) {
-	lastFired := time.Time{}
+// matchesFields returns true if all cron fields match the given time.
+func (c *ParsedCron) matchesFields(t time.Time) bool {
+	return matchAnyField(c.minute, t.Minute()) &&
+		matchAnyField(c.hour, t.Hour()) &&
+		matchAnyField(c.dom, t.Day()) &&
+		matchAnyField(c.month, int(t.Month())) &&
+		matchAnyField(c.dow, int(t.Weekday()))
+}
 
-	tick := func() {
-		now := time.Now().Truncate(time.Minute)
-		if sched.matchesFields(now) && now != lastFired {
-			lastFired = now
-			action()
+// Next returns the first minute after t that matches the expression,
+// evaluated in t's location, or the zero time if none falls within five
+// years. Non-matching months, days and hours are skipped whole.
+func (c *ParsedCron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !matchAnyField(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !matchAnyField(c.dom, t.Day()) || !matchAnyField(c.dow, int(t.Weekday())):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !matchAnyField(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !matchAnyField(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
 		}
 	}
+	return time.Time{}
+}
 
-	// Align to next 30-second boundary.
-	now := time.Now()
-	untilNext := time.Duration(30-now.Second()%30) * time.Second
-	select {
-	case <-time.After(untilNext):
-	case <-stop:
-		return
-	case <-ctx.Done():
-		return
-	}
-
-	tick()
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			tick()
-		case <-stop:
-			return
-		case <-ctx.Done():
-			return
+// slotsBetween returns the matching minutes after from up to and including
+// until, keeping only the newest max of them, and how many there were.
+func (c *ParsedCron) slotsBetween(from, until time.Time, max int) ([]time.Time, int) {
+	var slots []time.Time
+	total := 0
+	for t := c.Next(from); !t.IsZero() && !t.After(until); t = c.Next(t) {
+		total++
+		slots = append(slots, t)
+		if len(slots) > max {
+			slots = slots[1:]
 		}
 	}
+	return slots, total
 }
 
-// runCondition evaluates an expr expression on each interval and fires action when true.
-func runCondition(ctx context.Context, exprStr string, interval time.Duration, stop <-chan struct{}, action func(vars map[string]any)) {
+// runCondition evaluates an expr expression on each interval and lets gate
+// decide whether a true result fires action.
+func runCondition(ctx context.Context, exprStr string, interval time.Duration, gate *conditionGate, env func(time.Time) map[string]any, stop <-chan struct{}, action func(vars map[string]any)) {
 	// Pre-compile the expression with a generic environment.
 	// We use an empty env at compile time; runtime env is provided per-tick.
-	// Use AsBool to validate the expression returns a bool.
 	program, err := expr.Compile(exprStr)
 	if err != nil {
 		log.Printf("[triggers] condition: compile error for %q: %v", exprStr, err)
@@ -776,14 +811,17 @@ func runCondition(ctx context.Context, exprStr string, interval time.Duration, s
 	for {
 		select {
 		case t := <-ticker.C:
-			env := buildSystemEnv(t)
-			out, err := expr.Run(program, env)
+			vars := env(t)
+			out, err := expr.Run(program, vars)
 			if err != nil {
+				// A runtime error (e.g. a state key not yet set) leaves the
+				// edge state untouched rather than counting as false.
 				log.Printf("[triggers] condition: runtime error for %q: %v", exprStr, err)
 				continue
 			}
-			if b, ok := out.(bool); ok && b {
-				action(env)
+			b, _ := out.(bool)
+			if gate.fire(b, t) {
+				action(vars)
 			}
 		case <-stop:
 			return
@@ -793,6 +831,93 @@ func runCondition(ctx context.Context, exprStr string, interval time.Duration, s
 	}
 }
 
+// conditionStatePrefix prefixes the plugin state key holding a condition
+// behavior's last result and fire time, so restarts keep the edge state.
+const conditionStatePrefix = "_condition:"
+
+// conditionGate turns a stream of condition results into fires.
+//
+// Level-triggered (the default) fires on every true result; edge-triggered
+// fires only when the result goes from false to true, so a condition that
+// stays true fires once. The first evaluation of a behavior with no saved
+// state counts as a transition from false. A cooldown suppresses fires
+// within that long of the previous one; in edge mode a suppressed transition
+// is dropped, not deferred.
+type conditionGate struct {
+	edge     bool
+	cooldown time.Duration
+
+	last     bool
+	lastFire time.Time
+	// save, if set, persists last and lastFire when they change.
+	save func(last bool, lastFire time.Time)
+}
+
+func (g *conditionGate) fire(result bool, now time.Time) bool {
+	rising := result && !g.last
+	changed := result != g.last
+	g.last = result
+	fired := result && (!g.edge || rising) &&
+		(g.cooldown <= 0 || g.lastFire.IsZero() || now.Sub(g.lastFire) >= g.cooldown)
+	if fired {
+		g.lastFire = now
+	}
+	// lastFire only matters across restarts when a cooldown applies.
+	if g.save != nil && (changed || (fired && g.cooldown > 0)) {
+		g.save(g.last, g.lastFire)
+	}
+	return fired
+}
+
+// loadConditionState restores a condition behavior's last result and fire
+// time from the plugin's state.
+func loadConditionState(ext *Plugin, behavior string, gate *conditionGate) {
+	m, _ := ext.StateGet(conditionStatePrefix + behavior).(map[string]any)
+	gate.last, _ = m["last"].(bool)
+	if s, _ := m["last_fire"].(string); s != "" {
+		gate.lastFire, _ = time.Parse(time.RFC3339, s)
+	}
+}
+
+// saveConditionState persists a condition behavior's last result and fire
+// time in the plugin's state.
+func saveConditionState(ext *Plugin, behavior string, last bool, lastFire time.Time) {
+	state := map[string]any{"last": last}
+	if !lastFire.IsZero() {
+		state["last_fire"] = lastFire.Format(time.RFC3339)
+	}
+	if err := ext.StateSet(conditionStatePrefix+behavior, state); err != nil {
+		log.Printf("[triggers] %s/%s: failed to save condition state: %v", ext.Manifest.Name, behavior, err)
+	}
+}
+
+// conditionEnv builds the environment for one evaluation of a plugin's
+// condition trigger: the clock variables, the daemon variables from the
+// SystemEnv, and the plugin's own state and settings.
+func (d *Dispatcher) conditionEnv(ext *Plugin, t time.Time) map[string]any {
+	env := buildSystemEnv(t)
+	if d.env != nil {
+		for k, v := range d.env.Vars() {
+			env[k] = v
+		}
+	}
+	ext.mu.Lock()
+	env["state"] = copyVars(ext.State)
+	env["settings"] = copyVars(ext.Settings)
+	ext.mu.Unlock()
+	return env
+}
+
+// copyVars returns a shallow copy of m, never nil, so expressions can index
+// it before the plugin has stored anything.
+func copyVars(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
 // buildSystemEnv returns the system variables available to condition expressions.
 func buildSystemEnv(t time.Time) map[string]any {
 	return map[string]any{
@@ -662,8 +662,133 @@ func TestDispatcher_Condition(t *testing.T) {
 	d2.Stop()
 }
 
+// TestDispatcher_ConditionEdge verifies an edge-triggered condition sees the
+// plugin's state and the SystemEnv variables, and fires once per false→true
+// transition rather than on every tick.
+func TestDispatcher_ConditionEdge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping condition integration test in short mode")
+	}
+
+	runner := &fakeRunner{}
+	d := plugins.NewDispatcher(newMinimalRegistry(t), plugins.NewEventBus(), runner)
+	d.SetSystemEnv(fakeEnv{"idle_minutes": 42.0})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ext := makeTestPlugin(t, []plugins.Behavior{
+		{
+			Name: "armed-and-idle",
+			Trigger: map[string]any{
+				"condition": map[string]any{
+					"expr":     "state.armed == true && idle_minutes > 30",
+					"interval": "1s",
+					"edge":     true,
+				},
+			},
+			Workflow: "cond-task",
+		},
+	})
+	if err := ext.StateSet("armed", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RegisterPlugin(ctx, ext); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+	defer d.Stop()
+
+	waitFor := func(n int) {
+		t.Helper()
+		for i := 0; i < 20 && runner.count() < n; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if got := runner.count(); got != n {
+			t.Fatalf("expected %d fires, got %d", n, got)
+		}
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	waitFor(1)
+	// Still true on the next tick: no second fire.
+	time.Sleep(1200 * time.Millisecond)
+	waitFor(1)
+	if idle, _ := runner.calls[0].params["idle_minutes"].(float64); idle != 42 {
+		t.Errorf("expected idle_minutes in params, got %v", runner.calls[0].params["idle_minutes"])
+	}
+
+	// Falling and rising again fires once more.
+	if err := ext.StateSet("armed", false); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1200 * time.Millisecond)
+	if err := ext.StateSet("armed", true); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1000 * time.Millisecond)
+	waitFor(2)
+}
+
+// TestDispatcher_ConditionEdgeSurvivesRestart verifies an edge-triggered
+// condition that is already true does not fire again after a restart, since
+// its last result is kept in the plugin's state.
+func TestDispatcher_ConditionEdgeSurvivesRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping condition integration test in short mode")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ext := makeTestPlugin(t, []plugins.Behavior{
+		{
+			Name: "armed",
+			Trigger: map[string]any{
+				"condition": map[string]any{
+					"expr":     "state.armed == true",
+					"interval": "1s",
+					"edge":     true,
+				},
+			},
+			Workflow: "cond-task",
+		},
+	})
+	if err := ext.StateSet("armed", true); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{}
+	d := plugins.NewDispatcher(newMinimalRegistry(t), plugins.NewEventBus(), runner)
+	if err := d.RegisterPlugin(ctx, ext); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+	for i := 0; i < 20 && runner.count() < 1; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	d.Stop()
+	if got := runner.count(); got != 1 {
+		t.Fatalf("expected 1 fire before the restart, got %d", got)
+	}
+	if st, _ := ext.StateGet("_condition:armed").(map[string]any); st["last"] != true {
+		t.Errorf("expected the last result in state, got %v", ext.StateGet("_condition:armed"))
+	}
+
+	restarted := &fakeRunner{}
+	d2 := plugins.NewDispatcher(newMinimalRegistry(t), plugins.NewEventBus(), restarted)
+	if err := d2.RegisterPlugin(ctx, ext); err != nil {
+		t.Fatalf("RegisterPlugin: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	d2.Stop()
+	if got := restarted.count(); got != 0 {
+		t.Errorf("expected no fire after the restart while still true, got %d", got)
+	}
+}
+
 // --- fakes ---
 
+type fakeEnv map[string]any
+
+func (e fakeEnv) Vars() map[string]any { return e }
+
 type fakeTalker struct{ fn func(string) error }
 
 func (f *fakeTalker) Notify(msg string) error { return f.fn(msg) }
@@ -1,17 +1,17 @@
 package reflex
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/vthunder/bud2/internal/sandbox"
 )
 
 // ErrStopPipeline signals the pipeline should stop (not an error, just early exit)
@@ -47,11 +47,11 @@ func NewActionRegistry() *ActionRegistry {
 	r.Register("fetch_url", ActionFunc(actionFetchURL))
 	r.Register("read_file", ActionFunc(actionReadFile))
 	r.Register("write_file", ActionFunc(actionWriteFile))
-	r.Register("ollama_prompt", ActionFunc(actionOllamaPrompt))
+	r.Register("ollama_prompt", ollamaPromptAction(generateUnbudgeted))
 	r.Register("extract_json", ActionFunc(actionExtractJSON))
 	r.Register("template", ActionFunc(actionTemplate))
 	r.Register("log", ActionFunc(actionLog))
-	r.Register("shell", ActionFunc(actionShell))
+	r.Register("shell", shellAction(sandbox.NewRunner(sandbox.Policy{}, "")))
 	r.Register("gate", ActionFunc(actionGate))
 	r.Register("escalate", ActionFunc(actionEscalate))
 
@@ -77,7 +77,20 @@ func actionFetchURL(ctx context.Context, params map[string]any, vars map[string]
 		return nil, fmt.Errorf("url is required")
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	if err := checkPermission(ctx, "network", urlHost(url)); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// Each redirect hop must be to a host the plugin may reach too.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return checkPermission(ctx, "network", req.URL.Host)
+		},
+	}
 	resp, err := client.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("fetch failed: %w", err)
@@ -98,6 +111,10 @@ func actionReadFile(ctx context.Context, params map[string]any, vars map[string]
 		return nil, fmt.Errorf("path is required")
 	}
 
+	if err := checkPermission(ctx, "filesystem", path); err != nil {
+		return nil, err
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read failed: %w", err)
@@ -113,6 +130,9 @@ func actionWriteFile(ctx context.Context, params map[string]any, vars map[string
 	}
 
 	content := resolveVar(params, vars, "content", "input")
+	if err := checkPermission(ctx, "filesystem", path); err != nil {
+		return nil, err
+	}
 
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return nil, fmt.Errorf("write failed: %w", err)
@@ -121,47 +141,28 @@ func actionWriteFile(ctx context.Context, params map[string]any, vars map[string
 	return fmt.Sprintf("Wrote %d bytes to %s", len(content), path), nil
 }
 
-func actionOllamaPrompt(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
-	model := "qwen2.5:14b" // default model
-	if m, ok := params["model"].(string); ok {
-		model = m
-	}
-
-	promptTemplate := ""
-	if p, ok := params["prompt"].(string); ok {
-		promptTemplate = p
-	}
-
-	// Resolve template with variables
-	prompt, err := renderNewTemplate(promptTemplate, vars)
-	if err != nil {
-		return nil, fmt.Errorf("template failed: %w", err)
-	}
+// ollamaPromptAction builds the ollama_prompt action around a generate func,
+// so the engine can route calls through its LLM budget.
+func ollamaPromptAction(generate func(ctx context.Context, model, prompt string) (string, error)) ActionFunc {
+	return func(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
+		model := "qwen2.5:14b" // default model
+		if m, ok := params["model"].(string); ok {
+			model = m
+		}
 
-	// Call Ollama API
-	reqBody := map[string]any{
-		"model":      model,
-		"prompt":     prompt,
-		"stream":     false,
-		"keep_alive": "30m",
-	}
+		promptTemplate := ""
+		if p, ok := params["prompt"].(string); ok {
+			promptTemplate = p
+		}
 
-	jsonBody, _ := json.Marshal(reqBody)
-	ollamaClient := &http.Client{Timeout: 90 * time.Second}
-	resp, err := ollamaClient.Post("http://localhost:11434/api/generate", "application/json", bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("ollama request failed: %w", err)
-	}
-	defer resp.Body.Close()
+		// Resolve template with variables
+		prompt, err := renderNewTemplate(promptTemplate, vars)
+		if err != nil {
+			return nil, fmt.Errorf("template failed: %w", err)
+		}
 
-	var result struct {
-		Response string `json:"response"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("ollama decode failed: %w", err)
+		return generate(ctx, model, prompt)
 	}
-
-	return result.Response, nil
 }
 
 func actionExtractJSON(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
@@ -221,19 +222,34 @@ func actionLog(ctx context.Context, params map[string]any, vars map[string]any)
 	return message, nil
 }
 
-func actionShell(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
-	command := resolveVar(params, vars, "command", "input")
-	if command == "" {
-		return nil, fmt.Errorf("command is required")
-	}
+// shellAction runs a command with sh -c in the sandbox, in a scratch
+// working directory. Output is stdout followed by stderr. A plugin workflow
+// needs the shell permission, and when the sandbox isolates its commands run
+// without network access unless the plugin declares network hosts.
+func shellAction(runner *sandbox.Runner) ActionFunc {
+	return func(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
+		command := resolveVar(params, vars, "command", "input")
+		if command == "" {
+			return nil, fmt.Errorf("command is required")
+		}
+		noNetwork, err := shellPermission(ctx, command)
+		if err != nil {
+			return nil, err
+		}
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return string(output), fmt.Errorf("shell failed: %w", err)
+		res, err := runner.Run(ctx, sandbox.Cmd{Path: "sh", Args: []string{"-c", command}, NoNetwork: noNetwork})
+		if res == nil {
+			return nil, fmt.Errorf("shell failed: %w", err)
+		}
+		output := string(res.Stdout) + string(res.Stderr)
+		if res.Truncated {
+			output += "\n[output truncated]"
+		}
+		if err != nil {
+			return output, fmt.Errorf("shell failed: %w", err)
+		}
+		return output, nil
 	}
-
-	return string(output), nil
 }
 
 func actionGate(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
@@ -242,29 +258,47 @@ func actionGate(ctx context.Context, params map[string]any, vars map[string]any)
 		condition = c
 	}
 
-	// Render the condition template
-	rendered, err := renderNewTemplate(condition, vars)
+	met, err := gateConditionMet(condition, vars)
 	if err != nil {
-		return nil, fmt.Errorf("gate condition template failed: %w", err)
+		return nil, err
 	}
-
-	// Evaluate condition (simple string equality check)
-	// Format: "{{intent}} == not_gtd" renders to "not_gtd == not_gtd"
-	parts := strings.Split(rendered, "==")
-	if len(parts) == 2 {
-		left := strings.TrimSpace(parts[0])
-		right := strings.TrimSpace(parts[1])
-		if left == right {
-			// Condition is true, check if we should stop
-			if stop, ok := params["stop"].(bool); ok && stop {
-				return nil, ErrStopPipeline
-			}
+	if met {
+		// Condition is true, check if we should stop
+		if stop, ok := params["stop"].(bool); ok && stop {
+			return nil, ErrStopPipeline
 		}
 	}
 
 	return "gate passed", nil
 }
 
+// gateConditionMet evaluates a gate condition as an expr expression, e.g.
+// `intent != "gtd" && len(items) > 0`. Conditions in the legacy template form
+// ("{{intent}} == not_gtd") are rendered and compared as strings instead.
+func gateConditionMet(condition string, vars map[string]any) (bool, error) {
+	if condition == "" {
+		return false, nil
+	}
+	if !isLegacyGateCondition(condition) {
+		met, err := evalCondition(condition, vars)
+		if err != nil {
+			return false, fmt.Errorf("gate condition %q: %w", condition, err)
+		}
+		return met, nil
+	}
+
+	rendered, err := renderNewTemplate(condition, vars)
+	if err != nil {
+		return false, fmt.Errorf("gate condition template failed: %w", err)
+	}
+	// Format: "{{intent}} == not_gtd" renders to "not_gtd == not_gtd"
+	parts := strings.Split(rendered, "==")
+	if len(parts) != 2 {
+		return false, nil
+	}
+	return strings.TrimSpace(parts[0]) == strings.TrimSpace(parts[1]), nil
+}
+
 func actionEscalate(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
 	if msg := resolveVar(params, vars, "message", ""); msg != "" {
 		vars["_escalate_message"] = msg
@@ -377,8 +411,22 @@ func resolveVarPath(path string, vars map[string]any) (any, bool) {
 		}
 		return nil, false
 	default:
-		val, ok := vars[path]
-		return val, ok
+		if val, ok := vars[path]; ok {
+			return val, true
+		}
+		// Dotted paths walk into map values, e.g. {{item.url}} in a foreach.
+		parts = strings.Split(path, ".")
+		var val any = vars
+		for _, part := range parts {
+			m, ok := val.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if val, ok = m[part]; !ok {
+				return nil, false
+			}
+		}
+		return val, true
 	}
 }
 
@@ -0,0 +1,296 @@
+package reflex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+// checkExpr reports syntax errors in an expr expression. Pipeline variables
+// are only known when the reflex fires, so type errors surface then.
+func checkExpr(src string) error {
+	_, err := parser.Parse(src)
+	return err
+}
+
+// evalExpr evaluates src against vars, plus a "system" map with the runtime
+// variables also available to templates as {{system.*}}. It is compiled
+// against the actual vars so that variables shadow builtins of the same
+// name (count, len, ...); unset variables evaluate to nil. asBool requires a
+// boolean result.
+func evalExpr(src string, asBool bool, vars map[string]any) (any, error) {
+	env := make(map[string]any, len(vars)+1)
+	for k, v := range vars {
+		env[k] = v
+	}
+	if _, ok := env["system"]; !ok {
+		system := make(map[string]any)
+		for _, name := range []string{"time", "hour", "weekday"} {
+			system[name], _ = resolveSystemVar(name)
+		}
+		env["system"] = system
+	}
+	opts := []expr.Option{expr.Env(env), expr.AllowUndefinedVariables()}
+	if asBool {
+		opts = append(opts, expr.AsBool())
+	}
+	program, err := expr.Compile(src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Run(program, env)
+}
+
+// evalCondition evaluates a boolean expression against vars.
+func evalCondition(src string, vars map[string]any) (bool, error) {
+	out, err := evalExpr(src, true, vars)
+	if err != nil {
+		return false, err
+	}
+	b, _ := out.(bool)
+	return b, nil
+}
+
+// isLegacyGateCondition reports whether a gate condition uses the old
+// template form, e.g. "{{intent}} == not_gtd", which is rendered and then
+// compared as strings instead of being evaluated as an expression.
+func isLegacyGateCondition(condition string) bool {
+	return strings.Contains(condition, "{{")
+}
+
+// executeIfStep runs Then when Condition is true and Else otherwise, on the
+// pipeline's own vars. Its output is the chosen branch's last output.
+func (e *Engine) executeIfStep(ctx context.Context, step PipelineStep, vars map[string]any) (any, error) {
+	if step.Condition == "" {
+		return nil, fmt.Errorf("type:if: condition is required")
+	}
+	ok, err := evalCondition(step.Condition, vars)
+	if err != nil {
+		return nil, fmt.Errorf("type:if: condition %q: %w", step.Condition, err)
+	}
+	branch := step.Else
+	if ok {
+		branch = step.Then
+	}
+	return e.runSteps(ctx, branch, vars)
+}
+
+// executeSwitchStep evaluates Value and runs the case whose key equals its
+// text form, or Default if none does, on the pipeline's own vars.
+func (e *Engine) executeSwitchStep(ctx context.Context, step PipelineStep, vars map[string]any) (any, error) {
+	if step.Value == "" {
+		return nil, fmt.Errorf("type:switch: value is required")
+	}
+	val, err := evalExpr(step.Value, false, vars)
+	if err != nil {
+		return nil, fmt.Errorf("type:switch: value %q: %w", step.Value, err)
+	}
+	branch, ok := step.Cases[fmt.Sprint(val)]
+	if !ok {
+		branch = step.Default
+	}
+	return e.runSteps(ctx, branch, vars)
+}
+
+// validateConditions checks every expression in the pipeline, including
+// nested ones, so a malformed condition fails when the reflex loads rather
+// than when it fires.
+func validateConditions(r *Reflex) error {
+	return validatePipelineConditions(r.Pipeline, "")
+}
+
+func validatePipelineConditions(steps Pipeline, prefix string) error {
+	var errs []error
+	for i, step := range steps {
+		at := fmt.Sprintf("%sstep %d (%s)", prefix, i, stepLabel(step))
+		check := func(field, src string) {
+			if err := checkExpr(src); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s %q: %w", at, field, src, err))
+			}
+		}
+		nested := func(name string, p Pipeline) {
+			if err := validatePipelineConditions(p, at+" "+name+": "); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		switch step.Type {
+		case "":
+			if step.Action == "gate" {
+				cond := step.Condition
+				if cond == "" {
+					cond, _ = step.Params["condition"].(string)
+				}
+				if cond != "" && !isLegacyGateCondition(cond) {
+					check("condition", cond)
+				}
+			}
+		case "if":
+			if step.Condition == "" {
+				errs = append(errs, fmt.Errorf("%s: condition is required", at))
+			} else {
+				check("condition", step.Condition)
+			}
+		case "switch":
+			if step.Value == "" {
+				errs = append(errs, fmt.Errorf("%s: value is required", at))
+			} else {
+				check("value", step.Value)
+			}
+		}
+
+		nested("then", step.Then)
+		nested("else", step.Else)
+		for name, p := range step.Cases {
+			nested("case "+name, p)
+		}
+		nested("default", step.Default)
+		for name, p := range step.Branches {
+			nested("branch "+name, p)
+		}
+		nested("steps", step.Steps)
+	}
+	return errors.Join(errs...)
+}
+
+// identVisitor collects the variables an expression reads and the names it
+// declares with let.
+type identVisitor struct {
+	read     map[string]bool
+	declared map[string]bool
+}
+
+func (v *identVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.read[n.Value] = true
+	case *ast.VariableDeclaratorNode:
+		v.declared[n.Name] = true
+	}
+}
+
+// exprVars returns the variables src reads, sorted. Names it declares itself
+// are left out.
+func exprVars(src string) []string {
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return nil
+	}
+	v := &identVisitor{read: make(map[string]bool), declared: make(map[string]bool)}
+	ast.Walk(&tree.Node, v)
+	var names []string
+	for name := range v.read {
+		if !v.declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assignedVars returns the variables a reflex sets before or while its
+// pipeline runs: params, trigger captures, the classified intent, step
+// outputs and foreach loop variables, plus the engine's own _, _error and
+// system.
+func assignedVars(r *Reflex) map[string]bool {
+	vars := map[string]bool{"_": true, "_error": true, "system": true}
+	for name := range r.Params {
+		vars[name] = true
+	}
+	for _, name := range r.Trigger.Extract {
+		vars[name] = true
+	}
+	if r.Trigger.Classifier == "ollama" {
+		vars["intent"] = true
+	}
+	var walk func(Pipeline)
+	walk = func(steps Pipeline) {
+		for _, step := range steps {
+			if step.Output != "" {
+				vars[step.Output] = true
+			}
+			if step.Type == "foreach" {
+				as := step.As
+				if as == "" {
+					as = "item"
+				}
+				vars[as] = true
+				vars["index"] = true
+			}
+			walk(step.Then)
+			walk(step.Else)
+			walk(step.Default)
+			walk(step.Steps)
+			for _, p := range step.Cases {
+				walk(p)
+			}
+			for _, p := range step.Branches {
+				walk(p)
+			}
+		}
+	}
+	walk(r.Pipeline)
+	return vars
+}
+
+// unassignedVars describes each variable read by a condition or switch value
+// that nothing in the reflex assigns. Such a variable is nil unless the
+// percept data supplies it, which usually means a typo.
+func unassignedVars(r *Reflex) []string {
+	assigned := assignedVars(r)
+	var out []string
+	var walk func(Pipeline, string)
+	walk = func(steps Pipeline, prefix string) {
+		for i, step := range steps {
+			at := fmt.Sprintf("%sstep %d (%s)", prefix, i, stepLabel(step))
+			var src string
+			switch {
+			case step.Type == "" && step.Action == "gate":
+				src = step.Condition
+				if src == "" {
+					src, _ = step.Params["condition"].(string)
+				}
+				if isLegacyGateCondition(src) {
+					src = ""
+				}
+			case step.Type == "if":
+				src = step.Condition
+			case step.Type == "switch":
+				src = step.Value
+			}
+			for _, name := range exprVars(src) {
+				if !assigned[name] {
+					out = append(out, fmt.Sprintf("%s: %q is not set by any param, trigger extract or step output", at, name))
+				}
+			}
+
+			walk(step.Then, at+" then: ")
+			walk(step.Else, at+" else: ")
+			walk(step.Default, at+" default: ")
+			walk(step.Steps, at+" steps: ")
+			for _, name := range sortedKeys(step.Cases) {
+				walk(step.Cases[name], at+" case "+name+": ")
+			}
+			for _, name := range sortedKeys(step.Branches) {
+				walk(step.Branches[name], at+" branch "+name+": ")
+			}
+		}
+	}
+	walk(r.Pipeline, "")
+	return out
+}
+
+func sortedKeys(m map[string]Pipeline) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,13 +1,11 @@
 package reflex
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
@@ -18,6 +16,7 @@ import (
 	"github.com/itchyny/gojq"
 	"github.com/vthunder/bud2/internal/integrations/calendar"
 	"github.com/vthunder/bud2/internal/paths"
+	"github.com/vthunder/bud2/internal/sandbox"
 	"gopkg.in/yaml.v3"
 )
 
@@ -64,6 +63,12 @@ type Engine struct {
 	// Fallback resolver for type:invoke steps when the workflow is not in e.reflexes.
 	// Used to look up capabilities from the extension registry at runtime.
 	workflowFallback func(name string) (*Reflex, error)
+
+	// Budget for Ollama calls (classifiers and ollama_prompt actions)
+	llmBudget LLMBudget
+
+	// Permission checks for plugin-owned workflows (Reflex.Plugin set)
+	authorizer Authorizer
 }
 
 // NewEngine creates a new reflex engine
@@ -105,6 +110,11 @@ func (e *Engine) SetCalendarClient(client *calendar.Client) {
 	}
 }
 
+// SetSandbox sets the runner used by shell actions.
+func (e *Engine) SetSandbox(r *sandbox.Runner) {
+	e.actions.Register("shell", shellAction(r))
+}
+
 // SetToolCaller sets the tool caller for call_tool pipeline actions
 func (e *Engine) SetToolCaller(tc ToolCaller) {
 	e.toolCaller = tc
@@ -127,6 +137,12 @@ func (e *Engine) SetActionCaller(ac ToolCaller) {
 	e.actionCaller = ac
 }
 
+// SetAuthorizer sets the permission check applied to steps of plugin-owned
+// workflows. Reflexes without a plugin are not checked.
+func (e *Engine) SetAuthorizer(a Authorizer) {
+	e.authorizer = a
+}
+
 // SetWorkflowFallback sets a resolver called by type:invoke steps when a workflow
 // is not found in the loaded reflexes. Use this to bridge into the extension registry.
 func (e *Engine) SetWorkflowFallback(f func(name string) (*Reflex, error)) {
@@ -264,6 +280,12 @@ func (e *Engine) loadReflexFile(path string) (*Reflex, error) {
 	if err := validateOutputNames(&reflex); err != nil {
 		return nil, fmt.Errorf("invalid pipeline in %s: %w", filepath.Base(path), err)
 	}
+	if err := validateConditions(&reflex); err != nil {
+		return nil, fmt.Errorf("invalid condition in %s: %w", filepath.Base(path), err)
+	}
+	for _, warning := range unassignedVars(&reflex) {
+		log.Printf("[reflex] Warning: %s: %s; it is nil unless the percept data has it", filepath.Base(path), warning)
+	}
 
 	return &reflex, nil
 }
@@ -385,8 +407,15 @@ func (e *Engine) Execute(ctx context.Context, reflex *Reflex, extracted map[stri
 		}, nil
 	}
 
-	// Per-extension execution serialization
-	if reflex.Extension != "" {
+	// Plugin-owned workflows run under the plugin's permissions, including
+	// any workflows they invoke.
+	if reflex.Plugin != "" && e.authorizer != nil {
+		ctx = withPermissions(ctx, reflex.Plugin, e.authorizer)
+	}
+
+	// Per-extension execution serialization. Workflows invoked from this one,
+	// including from parallel branches, run under the same hold.
+	if reflex.Extension != "" && !holdsExtSem(ctx, reflex.Extension) {
 		ok, release := e.acquireExtSem(reflex.Extension, 30*time.Second)
 		if !ok {
 			return &ReflexResult{
@@ -397,111 +426,53 @@ func (e *Engine) Execute(ctx context.Context, reflex *Reflex, extracted map[stri
 			}, nil
 		}
 		defer release()
+		ctx = withExtSem(ctx, reflex.Extension)
+	}
+
+	// Report top-level steps only; workflows this one invokes stay quiet.
+	progress, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	if progress != nil {
+		ctx = context.WithValue(ctx, progressKey{}, nil)
 	}
 
 	// Execute pipeline steps
 	for i, step := range reflex.Pipeline {
-		var stepResult any
-		var stepErr error
-
-		if step.Type != "" {
-			stepResult, stepErr = e.executeTypedStep(ctx, step, vars)
-		} else {
-			stepResult, stepErr = e.executeActionStep(ctx, i, step, vars)
-		}
-
-		if stepErr != nil {
-			// Control-flow signals bypass on_error handling
-			if errors.Is(stepErr, ErrStopPipeline) {
-				reflex.LastFired = time.Now()
-				reflex.FireCount++
-				e.saveReflexStats(reflex)
-				return &ReflexResult{
-					ReflexName: reflex.Name,
-					Success:    true,
-					Stopped:    true,
-					Output:     vars,
-					Duration:   time.Since(start),
-				}, nil
-			}
-			if errors.Is(stepErr, ErrEscalate) {
-				msg, _ := vars["_escalate_message"].(string)
-				varsCopy := make(map[string]any, len(vars))
-				for k, v := range vars {
-					varsCopy[k] = v
-				}
-				return &ReflexResult{
-					ReflexName:      reflex.Name,
-					Success:         false,
-					Escalate:        true,
-					EscalateMessage: msg,
-					EscalateStep:    i,
-					EscalateVars:    varsCopy,
-					Output:          vars,
-					Duration:        time.Since(start),
-				}, nil
-			}
-
-			// Apply per-step on_error policy
-			switch step.OnError {
-			case "skip":
-				// Record error, set output to nil, continue pipeline
-				vars["_error"] = stepErr.Error()
-				setStepOutput(vars, step, nil)
-				continue
-
-			case "retry":
-				maxR := step.MaxRetries
-				if maxR <= 0 {
-					maxR = 3
-				}
-				delay := time.Duration(step.RetryDelaySecs) * time.Second
-				if delay <= 0 {
-					delay = time.Second
-				}
-				var lastErr error = stepErr
-				for attempt := 1; attempt <= maxR; attempt++ {
-					select {
-					case <-time.After(delay):
-					case <-ctx.Done():
-						return &ReflexResult{
-							ReflexName: reflex.Name,
-							Success:    false,
-							Error:      ctx.Err(),
-							Duration:   time.Since(start),
-						}, nil
-					}
-					if step.Type != "" {
-						stepResult, lastErr = e.executeTypedStep(ctx, step, vars)
-					} else {
-						stepResult, lastErr = e.executeActionStep(ctx, i, step, vars)
-					}
-					if lastErr == nil {
-						break
-					}
-				}
-				if lastErr != nil {
-					return &ReflexResult{
-						ReflexName: reflex.Name,
-						Success:    false,
-						Error:      fmt.Errorf("step %d (%s) failed after %d retries: %w", i, stepLabel(step), maxR, lastErr),
-						Duration:   time.Since(start),
-					}, nil
-				}
-				stepErr = nil // retry succeeded
-
-			default: // "stop" or ""
-				return &ReflexResult{
-					ReflexName: reflex.Name,
-					Success:    false,
-					Error:      fmt.Errorf("step %d (%s) failed: %w", i, stepLabel(step), stepErr),
-					Duration:   time.Since(start),
-				}, nil
-			}
+		if progress != nil {
+			progress(i, len(reflex.Pipeline), stepLabel(step))
 		}
-
-		if stepErr == nil {
-			setStepOutput(vars, step, stepResult)
+		err := e.runStep(ctx, i, step, vars)
+		switch {
+		case err == nil:
+			continue
+		// Control-flow signals bypass on_error handling
+		case errors.Is(err, ErrStopPipeline):
+			e.recordFire(reflex)
+			return &ReflexResult{
+				ReflexName: reflex.Name,
+				Success:    true,
+				Stopped:    true,
+				Output:     vars,
+				Duration:   time.Since(start),
+			}, nil
+		case errors.Is(err, ErrEscalate):
+			msg, _ := vars["_escalate_message"].(string)
+			return &ReflexResult{
+				ReflexName:      reflex.Name,
+				Success:         false,
+				Escalate:        true,
+				EscalateMessage: msg,
+				EscalateStep:    i,
+				EscalateVars:    snapshotVars(vars),
+				Output:          vars,
+				Duration:        time.Since(start),
+			}, nil
+		default:
+			return &ReflexResult{
+				ReflexName: reflex.Name,
+				Success:    false,
+				Error:      err,
+				Duration:   time.Since(start),
+			}, nil
 		}
 	}
 
@@ -514,10 +485,7 @@ func (e *Engine) Execute(ctx context.Context, reflex *Reflex, extracted map[stri
 		}
 	}
 
-	// Update stats
-	reflex.LastFired = time.Now()
-	reflex.FireCount++
-	e.saveReflexStats(reflex)
+	e.recordFire(reflex)
 
 	return &ReflexResult{
 		ReflexName: reflex.Name,
@@ -527,14 +495,77 @@ func (e *Engine) Execute(ctx context.Context, reflex *Reflex, extracted map[stri
 	}, nil
 }
 
+// runStep executes one pipeline step under its on_error policy and stores
+// its output in vars. Control-flow signals (ErrStopPipeline, ErrEscalate) are
+// returned as is, as is the context error if cancelled while waiting to
+// retry; a failure that stops the pipeline is returned as a *StepError.
+func (e *Engine) runStep(ctx context.Context, i int, step PipelineStep, vars map[string]any) error {
+	result, err := e.executeStep(ctx, i, step, vars)
+	if err == nil {
+		setStepOutput(vars, step, result)
+		return nil
+	}
+	if errors.Is(err, ErrStopPipeline) || errors.Is(err, ErrEscalate) {
+		return err
+	}
+
+	// Apply per-step on_error policy
+	switch step.OnError {
+	case "skip":
+		// Record error, set output to nil, continue pipeline
+		vars["_error"] = err.Error()
+		setStepOutput(vars, step, nil)
+		return nil
+
+	case "retry":
+		maxR := step.MaxRetries
+		if maxR <= 0 {
+			maxR = 3
+		}
+		delay := time.Duration(step.RetryDelaySecs) * time.Second
+		if delay <= 0 {
+			delay = time.Second
+		}
+		for attempt := 1; attempt <= maxR; attempt++ {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if result, err = e.executeStep(ctx, i, step, vars); err == nil {
+				setStepOutput(vars, step, result)
+				return nil
+			}
+		}
+		return &StepError{Step: i, Label: stepLabel(step), Retries: maxR, Vars: snapshotVars(vars), Err: err}
+
+	default: // "stop" or ""
+		return &StepError{Step: i, Label: stepLabel(step), Vars: snapshotVars(vars), Err: err}
+	}
+}
+
+// executeStep dispatches a step by kind, without error handling.
+func (e *Engine) executeStep(ctx context.Context, i int, step PipelineStep, vars map[string]any) (any, error) {
+	if step.Type != "" {
+		return e.executeTypedStep(ctx, step, vars)
+	}
+	return e.executeActionStep(ctx, i, step, vars)
+}
+
 // executeActionStep dispatches a legacy action-based pipeline step.
 func (e *Engine) executeActionStep(ctx context.Context, stepIdx int, step PipelineStep, vars map[string]any) (any, error) {
 	action, ok := e.actions.Get(step.Action)
 	if !ok {
 		switch step.Action {
 		case "reply":
+			if err := checkPermission(ctx, "message_user", "reply"); err != nil {
+				return nil, err
+			}
 			return nil, e.executeReply(step, vars)
 		case "react":
+			if err := checkPermission(ctx, "message_user", "react"); err != nil {
+				return nil, err
+			}
 			return nil, e.executeReact(step, vars)
 		case "add_task", "add_idea":
 			log.Printf("[reflex] Action %s not yet integrated", step.Action)
@@ -554,6 +585,10 @@ func (e *Engine) executeActionStep(ctx context.Context, stepIdx int, step Pipeli
 	if step.Tool != "" {
 		params["tool"] = step.Tool
 	}
+	// Likewise condition, which type:if steps share with the gate action.
+	if step.Condition != "" {
+		params["condition"] = step.Condition
+	}
 	if step.Input != "" {
 		params["input"] = step.Input
 	}
@@ -569,6 +604,14 @@ func (e *Engine) executeTypedStep(ctx context.Context, step PipelineStep, vars m
 		return e.executeInvokeStep(ctx, step, vars)
 	case "direct":
 		return e.executeDirectStep(ctx, step, vars)
+	case "parallel":
+		return e.executeParallelStep(ctx, step, vars)
+	case "foreach":
+		return e.executeForeachStep(ctx, step, vars)
+	case "if":
+		return e.executeIfStep(ctx, step, vars)
+	case "switch":
+		return e.executeSwitchStep(ctx, step, vars)
 	default:
 		return nil, fmt.Errorf("unknown step type: %s", step.Type)
 	}
@@ -579,7 +622,7 @@ func (e *Engine) executeTypedStep(ctx context.Context, step PipelineStep, vars m
 // template expressions. Step params (params: block) are resolved and passed to
 // the action as its input. The action must be registered in the ActionProxy;
 // callable_from:direct actions are reachable here even without MCP registration.
-func (e *Engine) executeDirectStep(_ context.Context, step PipelineStep, vars map[string]any) (any, error) {
+func (e *Engine) executeDirectStep(ctx context.Context, step PipelineStep, vars map[string]any) (any, error) {
 	if e.actionCaller == nil {
 		return nil, fmt.Errorf("type:direct: action caller not configured (call SetActionCaller)")
 	}
@@ -607,6 +650,9 @@ func (e *Engine) executeDirectStep(_ context.Context, step PipelineStep, vars ma
 		}
 	}
 
+	if err := checkPermission(ctx, "tool", toolName); err != nil {
+		return nil, err
+	}
 	return e.actionCaller.Call(toolName, args)
 }
 
@@ -627,6 +673,10 @@ func (e *Engine) executeSubagentStep(ctx context.Context, step PipelineStep, var
 		return nil, fmt.Errorf("type:subagent: agent field is required")
 	}
 
+	if err := checkPermission(ctx, "spawn_subagent", agentName); err != nil {
+		return nil, err
+	}
+
 	// Look up capability prompt from registry
 	systemPrompt := ""
 	if e.capabilityResolver != nil {
@@ -758,6 +808,31 @@ func (e *Engine) applyWorkflowParams(reflex *Reflex, vars map[string]any) error
 	return nil
 }
 
+// ProgressFunc receives the index, count and label of each pipeline step
+// before it runs.
+type ProgressFunc func(step, total int, label string)
+
+type progressKey struct{}
+
+// WithProgress returns a context under which Execute reports the reflex's
+// pipeline steps to fn as it reaches them.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+type extSemKey string
+
+// withExtSem marks ctx as holding the named extension's semaphore.
+func withExtSem(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, extSemKey(name), true)
+}
+
+// holdsExtSem reports whether ctx already holds the extension's semaphore.
+func holdsExtSem(ctx context.Context, name string) bool {
+	held, _ := ctx.Value(extSemKey(name)).(bool)
+	return held
+}
+
 // acquireExtSem acquires the semaphore for the named extension.
 // Returns (true, release) on success, (false, nil) on timeout.
 func (e *Engine) acquireExtSem(name string, timeout time.Duration) (bool, func()) {
@@ -782,6 +857,16 @@ func setStepOutput(vars map[string]any, step PipelineStep, val any) {
 	}
 }
 
+// snapshotVars returns a shallow copy of vars, safe to keep after the
+// pipeline moves on.
+func snapshotVars(vars map[string]any) map[string]any {
+	out := make(map[string]any, len(vars))
+	for k, v := range vars {
+		out[k] = v
+	}
+	return out
+}
+
 // stepLabel returns a human-readable label for error messages.
 func stepLabel(step PipelineStep) string {
 	if step.Type != "" {
@@ -837,6 +922,16 @@ func (e *Engine) loadStats() {
 
 // saveReflexStats persists FireCount and LastFired to a separate stats file
 // This avoids overwriting manual config edits
+// recordFire updates a reflex's fire stats and persists them. Fires may be
+// concurrent when a workflow is invoked from parallel branches.
+func (e *Engine) recordFire(reflex *Reflex) {
+	e.mu.Lock()
+	reflex.LastFired = time.Now()
+	reflex.FireCount++
+	e.mu.Unlock()
+	e.saveReflexStats(reflex)
+}
+
 func (e *Engine) saveReflexStats(reflex *Reflex) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -964,38 +1059,13 @@ Respond with ONLY the intent name, nothing else.
 Message: %s`, intentList, content)
 	}
 
-	// Call Ollama API
-	reqBody := map[string]any{
-		"model":      model,
-		"prompt":     prompt,
-		"stream":     false,
-		"keep_alive": "30m",
-	}
-
-	jsonBody, _ := json.Marshal(reqBody)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "http://localhost:11434/api/generate", bytes.NewReader(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 90 * time.Second}
-	resp, err := client.Do(req)
+	response, err := e.generate(ctx, model, prompt)
 	if err != nil {
-		return "", fmt.Errorf("ollama request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Response string `json:"response"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("ollama decode failed: %w", err)
+		return "", err
 	}
 
 	// Clean up the response
-	intent := strings.TrimSpace(result.Response)
+	intent := strings.TrimSpace(response)
 	intent = strings.ToLower(intent)
 
 	// Validate intent is in the list (or not_matched)
@@ -1343,6 +1413,10 @@ func (e *Engine) createCallToolAction() {
 			args[k] = v
 		}
 
+		if err := checkPermission(ctx, "tool", toolName); err != nil {
+			return nil, err
+		}
+
 		result, err := e.toolCaller.Call(toolName, args)
 		if err != nil {
 			return nil, fmt.Errorf("tool %s failed: %w", toolName, err)
@@ -0,0 +1,91 @@
+package reflex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const ollamaGenerateURL = "http://localhost:11434/api/generate"
+
+// LLMBudget gates and accounts for reflex LLM calls. Allow returns an error
+// when the reflex role is over budget; Record reports the tokens a call used.
+type LLMBudget interface {
+	Allow() error
+	Record(model string, inputTokens, outputTokens int)
+}
+
+// ollamaResult is the subset of Ollama's /api/generate response used here.
+type ollamaResult struct {
+	Response        string `json:"response"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// ollamaGenerate runs a non-streaming completion against the local Ollama.
+func ollamaGenerate(ctx context.Context, model, prompt string) (*ollamaResult, error) {
+	reqBody := map[string]any{
+		"model":      model,
+		"prompt":     prompt,
+		"stream":     false,
+		"keep_alive": "30m",
+	}
+
+	jsonBody, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ollamaGenerateURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 90 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result ollamaResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ollama decode failed: %w", err)
+	}
+	return &result, nil
+}
+
+// SetLLMBudget sets the budget consulted before, and charged after, every
+// Ollama call made by classifiers and ollama_prompt actions.
+func (e *Engine) SetLLMBudget(b LLMBudget) {
+	e.llmBudget = b
+	e.actions.Register("ollama_prompt", ollamaPromptAction(e.generate))
+}
+
+// generate calls Ollama through the engine's LLM budget, if any.
+func (e *Engine) generate(ctx context.Context, model, prompt string) (string, error) {
+	if e.llmBudget != nil {
+		if err := e.llmBudget.Allow(); err != nil {
+			return "", err
+		}
+	}
+	result, err := ollamaGenerate(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	if e.llmBudget != nil {
+		e.llmBudget.Record(model, result.PromptEvalCount, result.EvalCount)
+	}
+	return result.Response, nil
+}
+
+// generateUnbudgeted calls Ollama without budget checks. It backs the
+// ollama_prompt action of a bare ActionRegistry.
+func generateUnbudgeted(ctx context.Context, model, prompt string) (string, error) {
+	result, err := ollamaGenerate(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
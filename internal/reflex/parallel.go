@@ -0,0 +1,185 @@
+package reflex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// executeParallelStep runs each named branch concurrently on its own copy of
+// vars and returns a map of branch name to the branch's result. A branch's
+// result is its last step's output. Each step keeps its own on_error policy;
+// a branch that still fails cancels the others and fails the step, which the
+// parallel step's own on_error then handles.
+func (e *Engine) executeParallelStep(ctx context.Context, step PipelineStep, vars map[string]any) (any, error) {
+	if len(step.Branches) == 0 {
+		return nil, fmt.Errorf("type:parallel: branches is required")
+	}
+	names := make([]string, 0, len(step.Branches))
+	for name := range step.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]any, len(names))
+	err := fanOut(ctx, len(names), step.Concurrency, func(ctx context.Context, i int) error {
+		out, err := e.runSteps(ctx, step.Branches[names[i]], snapshotVars(vars))
+		if err != nil && !errors.Is(err, ErrStopPipeline) {
+			return fmt.Errorf("branch %q: %w", names[i], err)
+		}
+		results[i] = out
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("type:parallel: %w", err)
+	}
+
+	joined := make(map[string]any, len(names))
+	for i, name := range names {
+		joined[name] = results[i]
+	}
+	return joined, nil
+}
+
+// executeForeachStep runs Steps once per element of the Items list, each on
+// its own copy of vars with the element bound to As (default "item") and its
+// position to "index". It returns the results in element order. Elements run
+// one at a time unless Concurrency is set.
+func (e *Engine) executeForeachStep(ctx context.Context, step PipelineStep, vars map[string]any) (any, error) {
+	if step.Items == "" {
+		return nil, fmt.Errorf("type:foreach: items is required")
+	}
+	if len(step.Steps) == 0 {
+		return nil, fmt.Errorf("type:foreach: steps is required")
+	}
+	items, err := resolveItems(step.Items, vars)
+	if err != nil {
+		return nil, fmt.Errorf("type:foreach: %w", err)
+	}
+	as := step.As
+	if as == "" {
+		as = "item"
+	}
+	limit := step.Concurrency
+	if limit <= 0 {
+		limit = 1
+	}
+
+	results := make([]any, len(items))
+	err = fanOut(ctx, len(items), limit, func(ctx context.Context, i int) error {
+		itemVars := snapshotVars(vars)
+		itemVars[as] = items[i]
+		itemVars["index"] = i
+		out, err := e.runSteps(ctx, step.Steps, itemVars)
+		if err != nil && !errors.Is(err, ErrStopPipeline) {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		results[i] = out
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("type:foreach: %w", err)
+	}
+	return results, nil
+}
+
+// runSteps runs a nested pipeline (a branch, a foreach body or an if/switch
+// arm) on vars and returns its last step's output. Control-flow signals are
+// returned as is, with the output so far: a stop ends just a parallel branch
+// or foreach element, but the whole pipeline from inside an if or switch.
+func (e *Engine) runSteps(ctx context.Context, steps Pipeline, vars map[string]any) (any, error) {
+	var out any
+	for i, step := range steps {
+		if err := e.runStep(ctx, i, step, vars); err != nil {
+			return out, err
+		}
+		if step.Output != "" {
+			out = vars[step.Output]
+		} else {
+			out = vars["_"]
+		}
+	}
+	return out, nil
+}
+
+// fanOut calls job for 0..n-1 with at most limit running at once (all at
+// once if limit <= 0). The first error cancels the context passed to the
+// remaining jobs, and jobs not yet started are skipped.
+func fanOut(ctx context.Context, n, limit int, job func(ctx context.Context, i int) error) error {
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := job(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// resolveItems looks up a foreach list given as name, $name or {{name}}.
+// The value may be any slice or a JSON array string.
+func resolveItems(ref string, vars map[string]any) ([]any, error) {
+	name := strings.TrimPrefix(ref, "$")
+	if strings.HasPrefix(name, "{{") && strings.HasSuffix(name, "}}") {
+		name = strings.TrimSpace(name[2 : len(name)-2])
+	}
+	val, ok := resolveVarPath(name, vars)
+	if !ok {
+		return nil, fmt.Errorf("items variable %q is not set", name)
+	}
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return v, nil
+	case string:
+		var items []any
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, fmt.Errorf("items variable %q is not a list", name)
+		}
+		return items, nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("items variable %q is not a list (got %T)", name, val)
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
@@ -0,0 +1,62 @@
+package reflex
+
+import (
+	"context"
+	"net/url"
+)
+
+type permissionsKey struct{}
+
+// pluginPermissions identifies the plugin a workflow runs for and the
+// authorizer that checks its steps.
+type pluginPermissions struct {
+	plugin     string
+	authorizer Authorizer
+}
+
+func withPermissions(ctx context.Context, plugin string, a Authorizer) context.Context {
+	return context.WithValue(ctx, permissionsKey{}, pluginPermissions{plugin: plugin, authorizer: a})
+}
+
+// checkPermission asks the authorizer whether the plugin running the current
+// workflow may use permission on target. Workflows that do not belong to a
+// plugin are unrestricted.
+func checkPermission(ctx context.Context, permission, target string) error {
+	p, ok := ctx.Value(permissionsKey{}).(pluginPermissions)
+	if !ok {
+		return nil
+	}
+	return p.authorizer.Authorize(p.plugin, permission, target)
+}
+
+// NetworkDeclarer is implemented by authorizers that can say whether a
+// plugin may reach the network at all. Shell steps of plugins that may not,
+// or whose authorizer cannot say, run without network access.
+type NetworkDeclarer interface {
+	DeclaresNetwork(plugin string) bool
+}
+
+// shellPermission checks that the plugin running the current workflow may
+// run command in a shell step, and reports whether the command must run
+// without network access. Workflows that do not belong to a plugin are
+// unrestricted.
+func shellPermission(ctx context.Context, command string) (noNetwork bool, err error) {
+	p, ok := ctx.Value(permissionsKey{}).(pluginPermissions)
+	if !ok {
+		return false, nil
+	}
+	if err := p.authorizer.Authorize(p.plugin, "shell", command); err != nil {
+		return false, err
+	}
+	nd, ok := p.authorizer.(NetworkDeclarer)
+	return !ok || !nd.DeclaresNetwork(p.plugin), nil
+}
+
+// urlHost returns the host[:port] of rawURL, or rawURL itself if it does not parse.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
@@ -3,10 +3,14 @@ package reflex
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // mockSpawner is a test double for SubagentSpawner.
@@ -772,3 +776,355 @@ func TestExtensionSemaphore(t *testing.T) {
 		t.Fatalf("Expected success: %v", results[0].Error)
 	}
 }
+
+// mockAuthorizer allows only the listed permission:target pairs.
+type mockAuthorizer struct {
+	allowed map[string]bool
+	checked []string
+}
+
+func (m *mockAuthorizer) Authorize(plugin, permission, target string) error {
+	key := permission + ":" + target
+	m.checked = append(m.checked, plugin+"/"+key)
+	if m.allowed[key] {
+		return nil
+	}
+	return fmt.Errorf("plugin %s: %s denied", plugin, key)
+}
+
+func TestPluginWorkflowPermissions(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	spawner := &mockSpawner{response: "done"}
+	engine.SetSubagentSpawner(spawner)
+	auth := &mockAuthorizer{allowed: map[string]bool{"spawn_subagent:researcher": true}}
+	engine.SetAuthorizer(auth)
+
+	allowed := &Reflex{
+		Name:     "plugin-allowed",
+		Plugin:   "test-ext",
+		Pipeline: Pipeline{{Type: "subagent", Agent: "researcher"}},
+	}
+	result, _ := engine.Execute(context.Background(), allowed, nil, nil)
+	if !result.Success {
+		t.Fatalf("expected declared permission to pass, got %v", result.Error)
+	}
+
+	denied := &Reflex{
+		Name:     "plugin-denied",
+		Plugin:   "test-ext",
+		Pipeline: Pipeline{{Action: "read_file", Params: map[string]any{"path": "/etc/hostname"}}},
+	}
+	result, _ = engine.Execute(context.Background(), denied, nil, nil)
+	if result.Success || !strings.Contains(result.Error.Error(), "filesystem:/etc/hostname denied") {
+		t.Fatalf("expected filesystem denial, got %v", result.Error)
+	}
+
+	// Reflexes that don't belong to a plugin are not checked.
+	auth.checked = nil
+	unowned := &Reflex{Name: "unowned", Pipeline: Pipeline{{Type: "subagent", Agent: "writer"}}}
+	if result, _ := engine.Execute(context.Background(), unowned, nil, nil); !result.Success || len(auth.checked) != 0 {
+		t.Errorf("expected unowned reflex to run unchecked, got err=%v checks=%v", result.Error, auth.checked)
+	}
+}
+
+func TestPluginShellAndRedirectPermissions(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	auth := &mockAuthorizer{allowed: map[string]bool{}}
+	engine.SetAuthorizer(auth)
+
+	shell := &Reflex{
+		Name:     "plugin-shell",
+		Plugin:   "test-ext",
+		Pipeline: Pipeline{{Action: "shell", Params: map[string]any{"command": "echo hi"}}},
+	}
+	result, _ := engine.Execute(context.Background(), shell, nil, nil)
+	if result.Success || !strings.Contains(result.Error.Error(), "shell:echo hi denied") {
+		t.Fatalf("expected shell denial, got %v", result.Error)
+	}
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer other.Close()
+	allowed := httptest.NewServer(http.RedirectHandler(other.URL, http.StatusFound))
+	defer allowed.Close()
+	auth.allowed["network:"+strings.TrimPrefix(allowed.URL, "http://")] = true
+
+	fetch := &Reflex{
+		Name:     "plugin-fetch",
+		Plugin:   "test-ext",
+		Pipeline: Pipeline{{Action: "fetch_url", Params: map[string]any{"url": allowed.URL}}},
+	}
+	result, _ = engine.Execute(context.Background(), fetch, nil, nil)
+	otherHost := strings.TrimPrefix(other.URL, "http://")
+	if result.Success || !strings.Contains(result.Error.Error(), "network:"+otherHost+" denied") {
+		t.Fatalf("expected redirect to an undeclared host to be denied, got %v", result.Error)
+	}
+}
+
+func TestExecute_StepErrorRecordsFailurePoint(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	rx := &Reflex{
+		Name:     "broken",
+		Pipeline: Pipeline{{Action: "read_file", Params: map[string]any{"path": filepath.Join(t.TempDir(), "missing")}}},
+	}
+	result, _ := engine.Execute(context.Background(), rx, nil, map[string]any{"day": "mon"})
+	if result.Success {
+		t.Fatal("expected failure")
+	}
+	stepErr, ok := result.Error.(*StepError)
+	if !ok {
+		t.Fatalf("expected *StepError, got %T: %v", result.Error, result.Error)
+	}
+	if stepErr.Step != 0 || stepErr.Label != "read_file" || stepErr.Vars["day"] != "mon" {
+		t.Errorf("unexpected step error %+v", stepErr)
+	}
+	if !strings.HasPrefix(stepErr.Error(), "step 0 (read_file) failed: ") {
+		t.Errorf("unexpected message %q", stepErr.Error())
+	}
+}
+
+func TestParallelStep(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	engine.SaveReflex(&Reflex{
+		Name:      "sub",
+		Extension: "test-ext",
+		Pipeline: Pipeline{
+			{Action: "template", Params: map[string]any{"template": "sub {{who}}"}},
+		},
+	})
+	engine.Load()
+
+	rx := &Reflex{
+		Name:      "fan",
+		Extension: "test-ext",
+		Pipeline: Pipeline{
+			{Type: "parallel", Output: "joined", Branches: map[string]Pipeline{
+				"a": {
+					{Action: "template", Output: "x", Params: map[string]any{"template": "a-{{who}}"}},
+					{Action: "template", Params: map[string]any{"template": "{{x}}!"}},
+				},
+				// Invoking a workflow of the same extension must not deadlock on
+				// the semaphore the parent already holds.
+				"b": {{Type: "invoke", Workflow: "sub", StepParams: map[string]any{"who": "{{who}}"}}},
+			}},
+			{Action: "template", Params: map[string]any{"template": "{{joined.a}} / {{joined.b}}"}},
+		},
+	}
+	done := make(chan *ReflexResult, 1)
+	go func() {
+		result, _ := engine.Execute(context.Background(), rx, nil, map[string]any{"who": "bud"})
+		done <- result
+	}()
+	var result *ReflexResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parallel step deadlocked")
+	}
+	if !result.Success {
+		t.Fatalf("expected success: %v", result.Error)
+	}
+	if got := result.Output["_"]; got != "a-bud! / sub bud" {
+		t.Errorf("unexpected joined output %q", got)
+	}
+	if _, leaked := result.Output["x"]; leaked {
+		t.Error("branch variables should not leak into the parent")
+	}
+}
+
+func TestForeachStep(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	engine.actions.Register("slow_upper", ActionFunc(func(ctx context.Context, params map[string]any, vars map[string]any) (any, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		page := vars["page"].(map[string]any)
+		return fmt.Sprintf("%d:%s", vars["index"], strings.ToUpper(page["name"].(string))), nil
+	}))
+
+	rx := &Reflex{
+		Name: "map",
+		Pipeline: Pipeline{
+			{Type: "foreach", Items: "$pages", As: "page", Concurrency: 2, Output: "names", Steps: Pipeline{
+				{Action: "slow_upper"},
+			}},
+		},
+	}
+	pages := []any{
+		map[string]any{"name": "a"}, map[string]any{"name": "b"},
+		map[string]any{"name": "c"}, map[string]any{"name": "d"},
+	}
+	result, _ := engine.Execute(context.Background(), rx, nil, map[string]any{"pages": pages})
+	if !result.Success {
+		t.Fatalf("expected success: %v", result.Error)
+	}
+	got := fmt.Sprint(result.Output["names"])
+	if got != "[0:A 1:B 2:C 3:D]" {
+		t.Errorf("unexpected results %s", got)
+	}
+	if maxInFlight != 2 {
+		t.Errorf("expected 2 items in flight at most, got %d", maxInFlight)
+	}
+}
+
+func TestParallelStepBranchFailure(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	step := PipelineStep{Type: "parallel", Output: "joined", Branches: map[string]Pipeline{
+		"ok":  {{Action: "template", Params: map[string]any{"template": "fine"}}},
+		"bad": {{Action: "totally_nonexistent_action_xyz"}},
+	}}
+
+	rx := &Reflex{Name: "fail", Pipeline: Pipeline{step}}
+	result, _ := engine.Execute(context.Background(), rx, nil, nil)
+	if result.Success {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(result.Error.Error(), `branch "bad"`) {
+		t.Errorf("expected failing branch named, got %v", result.Error)
+	}
+
+	// The parallel step's own on_error applies to the joined failure.
+	step.OnError = "skip"
+	rx = &Reflex{Name: "skip", Pipeline: Pipeline{step}}
+	result, _ = engine.Execute(context.Background(), rx, nil, nil)
+	if !result.Success {
+		t.Fatalf("expected skip to continue: %v", result.Error)
+	}
+	if result.Output["joined"] != nil || result.Output["_error"] == nil {
+		t.Errorf("expected nil output and _error set, got %v", result.Output)
+	}
+}
+
+func TestGateExpression(t *testing.T) {
+	cases := []struct {
+		condition string
+		vars      map[string]any
+		stopped   bool
+	}{
+		{`intent != "gtd"`, map[string]any{"intent": "chat"}, true},
+		{`count > 3 and priority in ["high", "urgent"]`, map[string]any{"count": 5, "priority": "high"}, true},
+		{`count > 3 or missing != nil`, map[string]any{"count": 1}, false},
+		{"{{intent}} == not_gtd", map[string]any{"intent": "not_gtd"}, true},
+		{"{{intent}} == not_gtd", map[string]any{"intent": "gtd_add"}, false},
+	}
+	engine := NewEngine(t.TempDir())
+	for _, c := range cases {
+		rx := &Reflex{Name: "gate", Pipeline: Pipeline{
+			{Action: "gate", Condition: c.condition, Params: map[string]any{"stop": true}},
+			{Action: "template", Params: map[string]any{"template": "ran"}},
+		}}
+		result, _ := engine.Execute(context.Background(), rx, nil, c.vars)
+		if !result.Success {
+			t.Fatalf("%q: unexpected failure: %v", c.condition, result.Error)
+		}
+		if result.Stopped != c.stopped {
+			t.Errorf("%q with %v: stopped = %v, want %v", c.condition, c.vars, result.Stopped, c.stopped)
+		}
+	}
+}
+
+func TestIfAndSwitchSteps(t *testing.T) {
+	engine := NewEngine(t.TempDir())
+	rx := &Reflex{Name: "route", Pipeline: Pipeline{
+		{Type: "if", Condition: "len(items) > 2", Output: "size",
+			Then: Pipeline{{Action: "template", Params: map[string]any{"template": "many"}}},
+			Else: Pipeline{{Action: "template", Params: map[string]any{"template": "few"}}},
+		},
+		{Type: "switch", Value: "intent", Output: "routed",
+			Cases: map[string]Pipeline{
+				"gtd_add":  {{Action: "template", Params: map[string]any{"template": "adding"}}},
+				"gtd_list": {{Action: "template", Params: map[string]any{"template": "listing"}}},
+			},
+			Default: Pipeline{{Action: "template", Params: map[string]any{"template": "unknown {{intent}}"}}},
+		},
+	}}
+
+	run := func(vars map[string]any) map[string]any {
+		t.Helper()
+		result, _ := engine.Execute(context.Background(), rx, nil, vars)
+		if !result.Success {
+			t.Fatalf("unexpected failure: %v", result.Error)
+		}
+		return result.Output
+	}
+	out := run(map[string]any{"items": []any{1, 2, 3}, "intent": "gtd_list"})
+	if out["size"] != "many" || out["routed"] != "listing" {
+		t.Errorf("unexpected outputs %v / %v", out["size"], out["routed"])
+	}
+	out = run(map[string]any{"items": []any{}, "intent": "chat"})
+	if out["size"] != "few" || out["routed"] != "unknown chat" {
+		t.Errorf("unexpected outputs %v / %v", out["size"], out["routed"])
+	}
+
+	// A gate stop inside a branch stops the whole pipeline.
+	rx = &Reflex{Name: "stop", Pipeline: Pipeline{
+		{Type: "if", Condition: "true", Then: Pipeline{
+			{Action: "gate", Condition: "true", Params: map[string]any{"stop": true}},
+		}},
+		{Action: "template", Output: "after", Params: map[string]any{"template": "ran"}},
+	}}
+	result, _ := engine.Execute(context.Background(), rx, nil, nil)
+	if !result.Stopped || result.Output["after"] != nil {
+		t.Errorf("expected pipeline stopped inside if, got %+v", result)
+	}
+}
+
+func TestUnassignedConditionVars(t *testing.T) {
+	r := &Reflex{
+		Name:    "check",
+		Trigger: Trigger{Extract: []string{"repo"}},
+		Params:  map[string]WorkflowParam{"limit": {Type: "integer"}},
+		Pipeline: Pipeline{
+			{Action: "fetch_url", Output: "page"},
+			{Type: "if", Condition: `page != nil && len(repo) < limit && statuss == "ok"`},
+			{Type: "foreach", Items: "page", Steps: Pipeline{
+				{Type: "switch", Value: `let n = index; item.kind + string(n) + system.hour`, Cases: map[string]Pipeline{
+					"a": {{Action: "gate", Condition: "_ != nil || typo"}},
+				}},
+			}},
+			{Action: "gate", Condition: "{{legacy}} == ok"},
+		},
+	}
+	got := unassignedVars(r)
+	want := []string{
+		`step 1 (if): "statuss" is not set by any param, trigger extract or step output`,
+		`step 2 (foreach) steps: step 0 (switch) case a: step 0 (gate): "typo" is not set by any param, trigger extract or step output`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unassignedVars =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadRejectsBadCondition(t *testing.T) {
+	tmpDir := t.TempDir()
+	engine := NewEngine(tmpDir)
+	path := filepath.Join(tmpDir, "bad.yaml")
+	yaml := `name: bad
+pipeline:
+  - type: if
+    condition: "count >"
+    then:
+      - action: template
+        template: ok
+  - action: gate
+    condition: "{{legacy}} == ok"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := engine.loadReflexFile(path)
+	if err == nil || !strings.Contains(err.Error(), "step 0 (if)") {
+		t.Fatalf("expected compile error for step 0, got %v", err)
+	}
+	if strings.Contains(err.Error(), "step 1") {
+		t.Errorf("legacy gate conditions should not be compiled: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package reflex
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 	"time"
 )
@@ -29,6 +30,13 @@ type CapabilityResolver interface {
 	ResolveCapability(name string) (body string, ok bool)
 }
 
+// Authorizer checks what a plugin-owned workflow may touch. permission is one
+// of "network", "filesystem", "tool", "message_user", "spawn_subagent" or
+// "shell" and target the host, path, tool, agent or shell command involved. plugins.Registry implements it.
+type Authorizer interface {
+	Authorize(plugin, permission, target string) error
+}
+
 // Reflex is a pattern-action rule defined in YAML
 type Reflex struct {
 	Name        string   `yaml:"name"`
@@ -50,6 +58,7 @@ type Reflex struct {
 
 	// Runtime only (not persisted)
 	compiledPattern *regexp.Regexp `yaml:"-"`
+	Plugin          string         `yaml:"-"` // owning plugin for workflows loaded from a plugin; scopes permission checks
 }
 
 // Trigger defines when a reflex fires
@@ -80,7 +89,8 @@ type Pipeline []PipelineStep
 // PipelineStep is a single step in a pipeline.
 // Steps are either action-based (backward-compatible) or type-based (new WS4 step kinds).
 type PipelineStep struct {
-	// Type selects the step kind: "subagent" or "invoke".
+	// Type selects the step kind: "subagent", "invoke", "direct", "parallel",
+	// "foreach", "if" or "switch".
 	// If empty, the step is an action step using the Action field (backward-compatible).
 	Type   string `yaml:"type,omitempty"`
 	Action string `yaml:"action,omitempty"` // action name (fetch_url, ollama_prompt, reply, etc)
@@ -99,6 +109,32 @@ type PipelineStep struct {
 	// type:direct fields — calls an extension action through the ActionProxy
 	Tool string `yaml:"tool,omitempty"` // action name in <ext>:<cap> format; {{var}} template expressions allowed
 
+	// type:parallel fields — runs named branches concurrently and joins their
+	// results into a map keyed by branch name
+	Branches map[string]Pipeline `yaml:"branches,omitempty"`
+
+	// type:foreach fields — runs Steps once per element of a list variable and
+	// joins the results into a list in element order
+	Items string   `yaml:"items,omitempty"` // list variable: name, $name or {{name}}
+	As    string   `yaml:"as,omitempty"`    // variable holding the current element; default "item"
+	Steps Pipeline `yaml:"steps,omitempty"`
+
+	// type:if fields — runs Then or Else on the pipeline's vars depending on
+	// an expr condition. Condition is also the gate action's condition.
+	Condition string   `yaml:"condition,omitempty"`
+	Then      Pipeline `yaml:"then,omitempty"`
+	Else      Pipeline `yaml:"else,omitempty"`
+
+	// type:switch fields — runs the case keyed by the value of an expr
+	// expression, or Default if no case matches
+	Value   string              `yaml:"value,omitempty"`
+	Cases   map[string]Pipeline `yaml:"cases,omitempty"`
+	Default Pipeline            `yaml:"default,omitempty"`
+
+	// Concurrency caps how many branches or elements run at once. For parallel
+	// the default (0) runs every branch at once; for foreach it is 1.
+	Concurrency int `yaml:"concurrency,omitempty"`
+
 	// Per-step error handling
 	OnError        string `yaml:"on_error,omitempty"`         // "stop" (default), "skip", or "retry"
 	MaxRetries     int    `yaml:"max_retries,omitempty"`       // for on_error:retry; default 3
@@ -187,3 +223,28 @@ type ReflexResult struct {
 	Error           error
 	Duration        time.Duration
 }
+
+// StepError is the ReflexResult.Error of a pipeline stopped by a failing
+// step. It records which step failed and the variables at that point.
+type StepError struct {
+	Step    int
+	Label   string
+	Retries int // retries made under on_error: retry before giving up
+	Vars    map[string]any
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	if e.Retries > 0 {
+		return fmt.Sprintf("step %d (%s) failed after %d retries: %v", e.Step, e.Label, e.Retries, e.Err)
+	}
+	return fmt.Sprintf("step %d (%s) failed: %v", e.Step, e.Label, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// FailedStep and FailedVars expose the failure point to packages that cannot
+// import reflex, such as the plugin dead-letter store.
+func (e *StepError) FailedStep() string { return fmt.Sprintf("%d (%s)", e.Step, e.Label) }
+
+func (e *StepError) FailedVars() map[string]any { return e.Vars }
@@ -0,0 +1,265 @@
+// Package sandbox runs untrusted commands — reflex shell actions and plugin
+// action scripts — with a timeout, capped output, a scrubbed environment and
+// a confined working directory. On Linux the command can additionally run in
+// fresh namespaces, and any platform can wrap it in an external jail such as
+// bwrap or nsjail for seccomp filtering.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults applied to zero Policy fields.
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxOutputBytes = 1 << 20
+)
+
+// DefaultEnvAllow lists the daemon environment variables passed to sandboxed
+// commands when Policy.EnvAllow is nil.
+var DefaultEnvAllow = []string{"PATH", "LANG", "LC_ALL", "TZ", "TERM"}
+
+// ErrTimeout is returned when a command exceeds its timeout.
+var ErrTimeout = errors.New("sandbox: command timed out")
+
+// Policy bounds what a sandboxed command may do.
+type Policy struct {
+	// Timeout kills the command (and its process group) after this long.
+	Timeout time.Duration
+	// MaxOutputBytes caps each of stdout and stderr; further output is
+	// discarded and Result.Truncated is set.
+	MaxOutputBytes int
+	// EnvAllow names the daemon environment variables the command inherits.
+	// Everything else is dropped. HOME and TMPDIR always point at the
+	// command's working directory.
+	EnvAllow []string
+	// Isolate runs the command in new user, mount, PID, IPC and UTS
+	// namespaces (Linux only) and, unless Network is set, a new network
+	// namespace with no interfaces. Nothing is remounted: the command still
+	// sees the host filesystem and can write wherever the daemon's user can.
+	// Use Wrapper (e.g. bwrap with read-only binds) to confine the filesystem.
+	Isolate bool
+	Network bool
+	// Wrapper is a command prefix the sandboxed command is appended to, e.g.
+	// ["nsjail", "--config", "/etc/bud/nsjail.cfg", "--"] with a seccomp policy.
+	Wrapper []string
+}
+
+// Cmd is one command to run.
+type Cmd struct {
+	Path  string
+	Args  []string
+	Stdin io.Reader
+	// Dir is the working directory. Empty runs the command in a fresh
+	// scratch directory that is removed afterwards.
+	Dir string
+	// Env adds KEY=VALUE pairs on top of the allow-listed environment.
+	Env []string
+	// NoNetwork withholds network access even if the policy grants it. It
+	// only takes effect when the policy isolates commands.
+	NoNetwork bool
+	// Timeout overrides the policy timeout for this command when positive.
+	Timeout time.Duration
+}
+
+// Result is the outcome of a command that ran.
+type Result struct {
+	Stdout    []byte
+	Stderr    []byte
+	ExitCode  int
+	Truncated bool
+	TimedOut  bool
+	Duration  time.Duration
+}
+
+// Runner runs commands under a fixed policy.
+type Runner struct {
+	policy      Policy
+	scratchRoot string
+}
+
+// NewRunner creates a runner. Scratch directories are created under
+// scratchRoot, or the system temp directory if it is empty.
+func NewRunner(policy Policy, scratchRoot string) *Runner {
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultTimeout
+	}
+	if policy.MaxOutputBytes <= 0 {
+		policy.MaxOutputBytes = DefaultMaxOutputBytes
+	}
+	if policy.EnvAllow == nil {
+		policy.EnvAllow = DefaultEnvAllow
+	}
+	return &Runner{policy: policy, scratchRoot: scratchRoot}
+}
+
+// Policy returns the runner's effective policy.
+func (r *Runner) Policy() Policy {
+	return r.policy
+}
+
+// Run executes c. A non-nil Result is returned whenever the command started;
+// the error reports a timeout, a non-zero exit or a failure to start.
+func (r *Runner) Run(ctx context.Context, c Cmd) (*Result, error) {
+	dir := c.Dir
+	if dir == "" {
+		if r.scratchRoot != "" {
+			if err := os.MkdirAll(r.scratchRoot, 0o700); err != nil {
+				return nil, fmt.Errorf("sandbox: creating scratch root: %w", err)
+			}
+		}
+		scratch, err := os.MkdirTemp(r.scratchRoot, "run-")
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: creating scratch dir: %w", err)
+		}
+		defer os.RemoveAll(scratch)
+		dir = scratch
+	} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("sandbox: working directory %s is not a directory", dir)
+	}
+
+	timeout := r.policy.Timeout
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	argv := append(append([]string{}, r.policy.Wrapper...), c.Path)
+	argv = append(argv, c.Args...)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = r.env(dir, c.Env)
+	cmd.Stdin = c.Stdin
+	stdout := &cappedBuffer{max: r.policy.MaxOutputBytes}
+	stderr := &cappedBuffer{max: r.policy.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = time.Second
+	policy := r.policy
+	if c.NoNetwork {
+		policy.Network = false
+	}
+	if err := configureProcess(cmd, policy); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	res := &Result{
+		Stdout:    stdout.Bytes(),
+		Stderr:    stderr.Bytes(),
+		Truncated: stdout.truncated || stderr.truncated,
+		Duration:  time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		res.TimedOut = true
+		return res, fmt.Errorf("%w after %v", ErrTimeout, timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return res, fmt.Errorf("exit status %d", res.ExitCode)
+		}
+		if cmd.ProcessState == nil {
+			return nil, fmt.Errorf("sandbox: starting %s: %w", c.Path, err)
+		}
+		return res, err
+	}
+	return res, nil
+}
+
+// env builds the command environment from the allow-list, the confinement
+// variables and the caller's extras.
+func (r *Runner) env(dir string, extra []string) []string {
+	env := make([]string, 0, len(r.policy.EnvAllow)+len(extra)+2)
+	for _, name := range r.policy.EnvAllow {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	env = append(env, "HOME="+dir, "TMPDIR="+dir)
+	return append(env, extra...)
+}
+
+// Within reports whether path resolves to a location inside dir. Symlinks in
+// both are followed, so a link inside dir that points elsewhere is not within
+// it. It is used to keep plugin scripts and file access from escaping their
+// plugin directory.
+func Within(dir, path string) bool {
+	dir, err := resolve(dir)
+	if err != nil {
+		return false
+	}
+	if path, err = resolve(path); err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// resolve makes path absolute and evaluates its symlinks one component at a
+// time, so ".." steps out of where a link points rather than out of the link.
+// Components that do not exist yet, such as a file about to be written, are
+// kept as written.
+func resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = wd + string(filepath.Separator) + path
+	}
+	resolved := filepath.VolumeName(path) + string(filepath.Separator)
+	for _, part := range strings.Split(path[len(filepath.VolumeName(path)):], string(filepath.Separator)) {
+		if part == "" || part == "." {
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		if real, err := filepath.EvalSymlinks(next); err == nil {
+			next = real
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the
+// rest, so a chatty command cannot exhaust daemon memory. The buffer is not
+// embedded so io.Copy cannot bypass Write through ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.max - b.buf.Len()
+	if len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
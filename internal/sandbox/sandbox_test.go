@@ -0,0 +1,108 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunScrubsEnvironment(t *testing.T) {
+	t.Setenv("BUD_SANDBOX_SECRET", "hunter2")
+	r := NewRunner(Policy{}, t.TempDir())
+
+	res, err := r.Run(context.Background(), Cmd{
+		Path: "sh",
+		Args: []string{"-c", `echo "secret=$BUD_SANDBOX_SECRET extra=$EXTRA"; pwd; echo "home=$HOME"`},
+		Env:  []string{"EXTRA=ok"},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	out := string(res.Stdout)
+	if !strings.Contains(out, "secret= extra=ok") {
+		t.Errorf("expected secret scrubbed and extra passed, got %q", out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || "home="+lines[1] != lines[2] {
+		t.Errorf("expected HOME to be the scratch working dir, got %q", out)
+	}
+	if _, err := os.Stat(lines[1]); !os.IsNotExist(err) {
+		t.Errorf("expected scratch dir %s removed after run", lines[1])
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := NewRunner(Policy{Timeout: 200 * time.Millisecond}, "")
+	start := time.Now()
+	res, err := r.Run(context.Background(), Cmd{Path: "sh", Args: []string{"-c", "sleep 10 & sleep 10"}})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if !res.TimedOut {
+		t.Error("expected TimedOut")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timeout took %v; process group not killed?", elapsed)
+	}
+}
+
+func TestRunCapsOutput(t *testing.T) {
+	r := NewRunner(Policy{MaxOutputBytes: 10}, "")
+	res, err := r.Run(context.Background(), Cmd{Path: "sh", Args: []string{"-c", "printf '0123456789abcdef'"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if string(res.Stdout) != "0123456789" || !res.Truncated {
+		t.Errorf("expected 10 bytes and Truncated, got %q truncated=%v", res.Stdout, res.Truncated)
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	r := NewRunner(Policy{}, "")
+	res, err := r.Run(context.Background(), Cmd{Path: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}, Dir: t.TempDir()})
+	if err == nil || res == nil || res.ExitCode != 3 || strings.TrimSpace(string(res.Stderr)) != "oops" {
+		t.Fatalf("expected exit 3 with stderr, got res=%+v err=%v", res, err)
+	}
+}
+
+func TestWithin(t *testing.T) {
+	dir := t.TempDir()
+	if !Within(dir, filepath.Join(dir, "scripts", "run.sh")) {
+		t.Error("expected path inside dir")
+	}
+	if Within(dir, filepath.Join(dir, "..", "escape.sh")) {
+		t.Error("expected path outside dir to be rejected")
+	}
+
+	// Symlinks are followed: a link out of dir is outside it, and ".." after
+	// a link steps out of its target.
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(outside, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "sub"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if Within(dir, filepath.Join(dir, "link", "run.sh")) {
+		t.Error("expected path through a link out of dir to be rejected")
+	}
+	if Within(dir, dir+"/link/../escape.sh") {
+		t.Error("expected .. after a link to step out of its target")
+	}
+	if Within(dir, filepath.Join(dir, "link", "new", "file")) {
+		t.Error("expected a missing path under a link out of dir to be rejected")
+	}
+
+	// A dir reached through a link still contains its own files.
+	alias := filepath.Join(t.TempDir(), "alias")
+	if err := os.Symlink(outside, alias); err != nil {
+		t.Fatal(err)
+	}
+	if !Within(alias, filepath.Join(outside, "sub", "run.sh")) {
+		t.Error("expected path inside a linked dir to be accepted")
+	}
+}
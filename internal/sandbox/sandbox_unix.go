@@ -0,0 +1,23 @@
+//go:build unix && !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess puts the command in its own process group so a timeout
+// kills everything it spawned. Namespaces are Linux-only; use Wrapper for
+// isolation elsewhere.
+func configureProcess(cmd *exec.Cmd, p Policy) error {
+	if p.Isolate {
+		return errors.New("sandbox: namespace isolation requires Linux")
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return nil
+}
@@ -100,6 +100,22 @@ func NewCalendarSense(cfg CalendarConfig, onMessage func(*memory.InboxMessage))
 	}
 }
 
+// SetTimezone changes the timezone used for daily agenda timing.
+func (c *CalendarSense) SetTimezone(tz *time.Location) {
+	if tz == nil {
+		tz = time.UTC
+	}
+	c.mu.Lock()
+	c.timezone = tz
+	c.mu.Unlock()
+}
+
+func (c *CalendarSense) location() *time.Location {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.timezone
+}
+
 // Start begins polling the calendar
 func (c *CalendarSense) Start() error {
 	c.mu.Lock()
@@ -176,9 +192,10 @@ func (c *CalendarSense) poll() {
 
 func (c *CalendarSense) checkDailyAgenda(ctx context.Context) {
 	// Use user's timezone for daily agenda timing
+	tz := c.location()
 	nowUTC := time.Now()
-	nowLocal := nowUTC.In(c.timezone)
-	today := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), 0, 0, 0, 0, c.timezone)
+	nowLocal := nowUTC.In(tz)
+	today := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), 0, 0, 0, 0, tz)
 
 	c.mu.RLock()
 	lastAgenda := c.lastDailyAgenda
@@ -196,7 +213,7 @@ func (c *CalendarSense) checkDailyAgenda(ctx context.Context) {
 	}
 
 	// Get today's events in user's timezone
-	events, err := c.client.GetTodayEvents(ctx, c.timezone)
+	events, err := c.client.GetTodayEvents(ctx, tz)
 	if err != nil {
 		log.Printf("[calendar-sense] Failed to get today's events: %v", err)
 		c.handleError(err)
@@ -252,9 +269,10 @@ func (c *CalendarSense) checkDailyAgenda(ctx context.Context) {
 }
 
 func (c *CalendarSense) checkPredictionReview() {
+	tz := c.location()
 	nowUTC := time.Now()
-	nowLocal := nowUTC.In(c.timezone)
-	today := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), 0, 0, 0, 0, c.timezone)
+	nowLocal := nowUTC.In(tz)
+	today := time.Date(nowLocal.Year(), nowLocal.Month(), nowLocal.Day(), 0, 0, 0, 0, tz)
 
 	c.mu.RLock()
 	lastReview := c.lastPredictionReview
@@ -442,6 +460,7 @@ func (c *CalendarSense) checkSprintBrief(ctx context.Context) {
 // found on a day and the first starts within sprintBriefBefore.
 func (c *CalendarSense) checkSprintCluster(events []calendar.Event, now time.Time, keyword, briefKeyPrefix, impulseSubtype, contentFmt string) {
 	// Group matching events by local date
+	tz := c.location()
 	byDate := make(map[string][]calendar.Event)
 	for _, event := range events {
 		if event.Status == "cancelled" || event.AllDay {
@@ -450,7 +469,7 @@ func (c *CalendarSense) checkSprintCluster(events []calendar.Event, now time.Tim
 		if !strings.Contains(strings.ToLower(event.Summary), keyword) {
 			continue
 		}
-		localDate := event.Start.In(c.timezone).Format("2006-01-02")
+		localDate := event.Start.In(tz).Format("2006-01-02")
 		byDate[localDate] = append(byDate[localDate], event)
 	}
 
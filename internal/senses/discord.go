@@ -31,6 +31,7 @@ type DiscordSense struct {
 	onMessage        func(*memory.InboxMessage) // direct callback for message processing
 	onStop           func()                     // called immediately when /stop slash command is received
 	onDebugExecutive func(channelID string) string // called for /debug-executive; returns response text
+	onPluginDecision func(plugin string, approve bool) string // called for /plugin-approve and /plugin-deny; returns response text
 
 	// Connection health tracking
 	mu               sync.RWMutex
@@ -432,7 +433,8 @@ func (d *DiscordSense) handleInteraction(s *discordgo.Session, i *discordgo.Inte
 	cmdName := cmdData.Name
 
 	// Extract the argument string from the first string option, regardless of its name.
-	// Built-in commands (/stop, /debug-executive) have no options; extension commands use "args".
+	// Built-in commands (/stop, /debug-executive) have no options; /plugin-approve and
+	// /plugin-deny use "plugin"; extension commands use "args".
 	var args string
 	for _, opt := range cmdData.Options {
 		if opt.Type == discordgo.ApplicationCommandOptionString {
@@ -493,6 +495,28 @@ func (d *DiscordSense) handleInteraction(s *discordgo.Session, i *discordgo.Inte
 		return
 	}
 
+	// /plugin-approve and /plugin-deny record the user's decision on a
+	// plugin's permissions. They are handled here, never by the model, so only
+	// the user can grant a plugin its permissions. Without a configured owner
+	// anyone in the channel could decide, so they are refused.
+	if cmdName == "plugin-approve" || cmdName == "plugin-deny" {
+		responseMsg := "Plugin approvals are not configured."
+		switch {
+		case d.ownerID == "":
+			responseMsg = "Plugin approvals need discord.owner_id set in bud.yaml."
+		case d.onPluginDecision != nil && args != "":
+			responseMsg = d.onPluginDecision(args, cmdName == "plugin-approve")
+		}
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: responseMsg,
+			},
+		})
+		log.Printf("[discord-sense] /%s %s from %s", cmdName, args, authorName)
+		return
+	}
+
 	// /stop is handled immediately without queuing — it kills the active session
 	if cmdName == "stop" {
 		if d.onStop != nil {
@@ -559,6 +583,13 @@ func (d *DiscordSense) SetOnDebugExecutive(fn func(channelID string) string) {
 	d.onDebugExecutive = fn
 }
 
+// SetOnPluginDecision sets the callback invoked for /plugin-approve and
+// /plugin-deny. fn receives the plugin name and whether it was approved, and
+// returns the response message to show the user.
+func (d *DiscordSense) SetOnPluginDecision(fn func(plugin string, approve bool) string) {
+	d.onPluginDecision = fn
+}
+
 // SetOnStop sets the callback invoked when the /stop slash command is received.
 // The callback is called synchronously before the interaction is acknowledged.
 func (d *DiscordSense) SetOnStop(fn func()) {
@@ -588,7 +619,8 @@ func sanitizeSlashCommandDesc(desc, fallback string) string {
 }
 
 // RegisterSlashCommands registers application commands with Discord.
-// extensionCmds is appended after the built-in /stop and /debug-executive entries;
+// extensionCmds is appended after the built-in /stop, /debug-executive,
+// /plugin-approve and /plugin-deny entries;
 // pass nil (or an empty slice) to register only the built-ins.
 // If guildID is empty, commands are registered globally (takes up to 1 hour to propagate).
 func (d *DiscordSense) RegisterSlashCommands(guildID string, extensionCmds []SlashCommandInfo) error {
@@ -599,6 +631,12 @@ func (d *DiscordSense) RegisterSlashCommands(guildID string, extensionCmds []Sla
 		Description: "arguments",
 		Required:    false,
 	}
+	pluginOption := &discordgo.ApplicationCommandOption{
+		Type:        optString,
+		Name:        "plugin",
+		Description: "plugin name",
+		Required:    true,
+	}
 
 	commands := []*discordgo.ApplicationCommand{
 		{
@@ -609,6 +647,16 @@ func (d *DiscordSense) RegisterSlashCommands(guildID string, extensionCmds []Sla
 			Name:        "debug-executive",
 			Description: "Toggle a live debug stream of the executive session into a Discord thread",
 		},
+		{
+			Name:        "plugin-approve",
+			Description: "Approve a plugin's declared permissions so it can run",
+			Options:     []*discordgo.ApplicationCommandOption{pluginOption},
+		},
+		{
+			Name:        "plugin-deny",
+			Description: "Deny a plugin's declared permissions; it will not run",
+			Options:     []*discordgo.ApplicationCommandOption{pluginOption},
+		},
 	}
 
 	for _, ec := range extensionCmds {
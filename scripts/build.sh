@@ -12,6 +12,7 @@ esac
 
 go build -o bin/bud ./cmd/bud
 go build -o bin/efficient-notion-mcp ./cmd/efficient-notion-mcp
+go build -o bin/bud-scenarios ./cmd/bud-scenarios
 
 # Codesign on macOS if the signing identity exists
 if [ "$(uname -s)" = "Darwin" ] && security find-identity -v -p codesigning 2>/dev/null | grep -q bud-dev; then
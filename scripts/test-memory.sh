@@ -2,17 +2,12 @@
 # Test memory scenarios
 
 set -e
-cd /Users/thunder/src/bud2
+cd "$(dirname "$0")/.."
 
 # Build if needed
-if [ ! -f bin/bud ] || [ cmd/bud/main.go -nt bin/bud ]; then
-    echo "Building bud..."
-    go build -o bin/bud ./cmd/bud
-fi
-
-if [ ! -f bin/test-synthetic ] || [ cmd/test-synthetic/main.go -nt bin/test-synthetic ]; then
-    echo "Building test-synthetic..."
-    go build -o bin/test-synthetic ./cmd/test-synthetic
+if [ ! -f bin/bud-scenarios ] || [ -n "$(find cmd/bud-scenarios -newer bin/bud-scenarios -name '*.go')" ]; then
+    echo "Building bud-scenarios..."
+    go build -o bin/bud-scenarios ./cmd/bud-scenarios
 fi
 
 echo ""
@@ -20,10 +15,10 @@ echo ""
 # Parse args
 case "${1:-}" in
     --list|-l)
-        ./bin/test-synthetic -list
+        ls tests/scenarios/*.yaml | xargs -n1 basename | sed 's/\.yaml$//'
         ;;
     --all|-a)
-        ./bin/test-synthetic -all "${@:2}"
+        ./bin/bud-scenarios "${@:2}" tests/scenarios
         ;;
     --help|-h)
         echo "Usage:"
@@ -31,18 +26,19 @@ case "${1:-}" in
         echo "  ./scripts/test-memory.sh -l|--list          # List available scenarios"
         echo "  ./scripts/test-memory.sh -a|--all           # Run all scenarios"
         echo "  ./scripts/test-memory.sh <scenario-name>    # Run specific scenario"
-        echo "  ./scripts/test-memory.sh -v                 # Verbose output"
         echo ""
+        echo "Extra flags are passed to bud-scenarios, e.g. --config bud.yaml to use"
+        echo "the real executive model, -v for daemon logs, --format junit."
         echo "Scenarios are YAML files in tests/scenarios/"
         ;;
     "")
-        ./bin/test-synthetic
+        ./bin/bud-scenarios tests/scenarios/short-recall.yaml
         ;;
     -*)
-        ./bin/test-synthetic "$@"
+        ./bin/bud-scenarios "$@"
         ;;
     *)
         # Assume it's a scenario name
-        ./bin/test-synthetic -scenario "tests/scenarios/$1.yaml" "${@:2}"
+        ./bin/bud-scenarios "${@:2}" "tests/scenarios/$1.yaml"
         ;;
 esac